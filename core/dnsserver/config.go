@@ -40,6 +40,14 @@ type Config struct {
 	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
 	TLSConfig *tls.Config
 
+	// TsigSecret holds the TSIG secrets, keyed by key name, that are used to verify signed
+	// requests and sign the responses to them. The secrets of all zones on a server are merged.
+	TsigSecret map[string]string
+
+	// Updates is set by plugins that handle dynamic updates (RFC 2136) for this zone. Updates for
+	// zones without it are answered with NOTIMP and don't reach the plugin chain.
+	Updates bool
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
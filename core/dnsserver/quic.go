@@ -0,0 +1,73 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DoQWriter is a dns.ResponseWriter that writes the reply to the QUIC stream the query came in on.
+type DoQWriter struct {
+	stream quic.Stream
+
+	// raddr is the remote's address. This can be optionally set.
+	raddr net.Addr
+	// laddr is our address. This can be optionally set.
+	laddr net.Addr
+
+	tsigSecret     map[string]string
+	tsigStatus     error
+	tsigRequestMAC string
+	tsigTimersOnly bool
+}
+
+// WriteMsg implements the dns.ResponseWriter interface. The message is signed when it carries a TSIG record.
+func (w *DoQWriter) WriteMsg(m *dns.Msg) error {
+	var (
+		buf []byte
+		err error
+	)
+	if t := m.IsTsig(); t != nil && w.tsigSecret != nil {
+		buf, w.tsigRequestMAC, err = dns.TsigGenerate(m, w.tsigSecret[t.Hdr.Name], w.tsigRequestMAC, w.tsigTimersOnly)
+	} else {
+		buf, err = m.Pack()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write implements the dns.ResponseWriter interface, b is prefixed with its length as described in
+// section 4.2 of RFC 9250.
+func (w *DoQWriter) Write(b []byte) (int, error) {
+	buf := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(buf, uint16(len(b)))
+	copy(buf[2:], b)
+	n, err := w.stream.Write(buf)
+	if n >= 2 {
+		n -= 2
+	}
+	return n, err
+}
+
+// Close implements the dns.ResponseWriter interface, it closes our side of the stream.
+func (w *DoQWriter) Close() error { return w.stream.Close() }
+
+// TsigStatus implements the dns.ResponseWriter interface.
+func (w *DoQWriter) TsigStatus() error { return w.tsigStatus }
+
+// TsigTimersOnly implements the dns.ResponseWriter interface.
+func (w *DoQWriter) TsigTimersOnly(b bool) { w.tsigTimersOnly = b }
+
+// Hijack implements the dns.ResponseWriter interface.
+func (w *DoQWriter) Hijack() {}
+
+// RemoteAddr returns the remote address.
+func (w *DoQWriter) RemoteAddr() net.Addr { return w.raddr }
+
+// LocalAddr returns the local address.
+func (w *DoQWriter) LocalAddr() net.Addr { return w.laddr }
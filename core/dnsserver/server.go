@@ -39,6 +39,7 @@ type Server struct {
 	trace        trace.Trace        // the trace plugin for the server
 	debug        bool               // disable recover()
 	classChaos   bool               // allow non-INET class queries
+	tsigSecret   map[string]string  // TSIG secrets of all zones, nil if there are none
 }
 
 // NewServer returns a new CoreDNS server and compiles all plugins in to it. By default CH class
@@ -67,6 +68,13 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 		// set the config per zone
 		s.zones[site.Zone] = site
 
+		for name, secret := range site.TsigSecret {
+			if s.tsigSecret == nil {
+				s.tsigSecret = make(map[string]string)
+			}
+			s.tsigSecret[name] = secret
+		}
+
 		// compile custom plugin for everything
 		var stack plugin.Handler
 		for i := len(site.Plugin) - 1; i >= 0; i-- {
@@ -97,7 +105,7 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 // This implements caddy.TCPServer interface.
 func (s *Server) Serve(l net.Listener) error {
 	s.m.Lock()
-	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptUpdate, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -110,7 +118,7 @@ func (s *Server) Serve(l net.Listener) error {
 // This implements caddy.UDPServer interface.
 func (s *Server) ServePacket(p net.PacketConn) error {
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", TsigSecret: s.tsigSecret, MsgAcceptFunc: acceptUpdate, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -237,6 +245,10 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		}
 
 		if h, ok := s.zones[string(b[:l])]; ok {
+			if r.Opcode == dns.OpcodeUpdate && !h.Updates {
+				errorFunc(s.Addr, w, r, dns.RcodeNotImplemented)
+				return
+			}
 			if r.Question[0].Qtype != dns.TypeDS {
 				if h.FilterFunc == nil {
 					rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
@@ -279,6 +291,10 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	// Wildcard match, if we have found nothing try the root zone as a last resort.
 	if h, ok := s.zones["."]; ok && h.pluginChain != nil {
+		if r.Opcode == dns.OpcodeUpdate && !h.Updates {
+			errorFunc(s.Addr, w, r, dns.RcodeNotImplemented)
+			return
+		}
 		rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
 		if !plugin.ClientWrite(rcode) {
 			errorFunc(s.Addr, w, r, rcode)
@@ -353,3 +369,20 @@ var EnableChaos = map[string]struct{}{
 
 // Quiet mode will not show any informative output on initialization.
 var Quiet bool
+
+// acceptUpdate accepts the same messages as dns.DefaultMsgAcceptFunc and dynamic updates (RFC 2136),
+// which may have any number of records in their sections. Updates are only handed to the plugin chain
+// of zones that have Config.Updates set.
+func acceptUpdate(dh dns.Header) dns.MsgAcceptAction {
+	const (
+		qr = 1 << 15
+		z  = 1 << 6
+	)
+	if opcode := int(dh.Bits>>11) & 0xF; opcode != dns.OpcodeUpdate || dh.Bits&qr != 0 {
+		return dns.DefaultMsgAcceptFunc(dh)
+	}
+	if dh.Bits&z != 0 || dh.Qdcount != 1 {
+		return dns.MsgReject
+	}
+	return dns.MsgAccept
+}
@@ -0,0 +1,177 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// ServerQUIC represents an instance of a DNS-over-QUIC server.
+type ServerQUIC struct {
+	*Server
+	quicListener *quic.Listener
+	packetConn   net.PacketConn
+	listenAddr   net.Addr
+	tlsConfig    *tls.Config
+}
+
+// NewServerQUIC returns a new CoreDNS QUIC server and compiles all plugins in to it.
+func NewServerQUIC(addr string, group []*Config) (*ServerQUIC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *tls* plugin must make sure that multiple conflicting
+	// TLS configuration return an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	for _, conf := range s.zones {
+		tlsConfig = conf.TLSConfig
+	}
+	// QUIC always uses TLS 1.3.
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("the tls plugin must be used with %s", addr)
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{"doq"}
+
+	return &ServerQUIC{Server: s, tlsConfig: tlsConfig}, nil
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerQUIC) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ServePacket(p net.PacketConn) error {
+	l, err := quic.Listen(p, s.tlsConfig, &quic.Config{MaxIdleTimeout: doqIdleTimeout})
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	s.listenAddr = p.LocalAddr()
+	s.packetConn = p
+	s.quicListener = l
+	s.m.Unlock()
+
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			if errors.Is(err, quic.ErrServerClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn handles the streams of a QUIC connection, each stream carries a single query.
+func (s *ServerQUIC) serveConn(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go s.serveStream(conn, stream)
+	}
+}
+
+func (s *ServerQUIC) serveStream(conn quic.Connection, stream quic.Stream) {
+	stream.SetReadDeadline(time.Now().Add(doqReadTimeout))
+
+	var l [2]byte
+	if _, err := io.ReadFull(stream, l[:]); err != nil {
+		stream.CancelRead(doqProtocolError)
+		stream.Close()
+		return
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		stream.CancelRead(doqProtocolError)
+		stream.Close()
+		return
+	}
+
+	msg := new(dns.Msg)
+	// The message ID must be 0, anything else is a protocol error, see section 4.2.1 of RFC 9250.
+	if err := msg.Unpack(buf); err != nil || msg.Id != 0 {
+		conn.CloseWithError(doqProtocolError, "")
+		return
+	}
+
+	// Remote addresses are passed as TCP addresses; like TCP, QUIC streams are not limited to the UDP message size.
+	raddr := conn.RemoteAddr()
+	if u, ok := raddr.(*net.UDPAddr); ok {
+		raddr = &net.TCPAddr{IP: u.IP, Port: u.Port, Zone: u.Zone}
+	}
+	w := &DoQWriter{stream: stream, laddr: s.listenAddr, raddr: raddr, tsigSecret: s.tsigSecret}
+	if t := msg.IsTsig(); t != nil {
+		if secret, ok := s.tsigSecret[t.Hdr.Name]; ok {
+			w.tsigStatus = dns.TsigVerify(buf, secret, "", false)
+		} else {
+			w.tsigStatus = dns.ErrSecret
+		}
+		w.tsigRequestMAC = t.MAC
+	}
+
+	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	s.ServeDNS(ctx, w, msg)
+	w.Close()
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerQUIC) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ListenPacket() (net.PacketConn, error) {
+	p, err := net.ListenPacket("udp", s.Addr[len(transport.QUIC+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerQUIC) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.QUIC+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+	return
+}
+
+// Stop stops the server. It blocks until the server is totally stopped.
+func (s *ServerQUIC) Stop() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.quicListener != nil {
+		s.quicListener.Close()
+		s.packetConn.Close()
+	}
+	return nil
+}
+
+// Shutdown stops the server (non gracefully).
+func (s *ServerQUIC) Shutdown() error { return s.Stop() }
+
+const (
+	doqIdleTimeout = 30 * time.Second
+	doqReadTimeout = 2 * time.Second
+
+	doqProtocolError = 0x2 // DOQ_PROTOCOL_ERROR
+)
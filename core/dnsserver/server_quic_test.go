@@ -0,0 +1,107 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+type answerPlugin struct{}
+
+func (ap answerPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, test.A("aaa.example.com. IN A 127.0.0.1"))
+	w.WriteMsg(m)
+	return 0, nil
+}
+
+func (ap answerPlugin) Name() string { return "answerplugin" }
+
+func TestNewServerQUIC(t *testing.T) {
+	if _, err := NewServerQUIC("quic://127.0.0.1:853", []*Config{testConfig("quic", testPlugin{})}); err == nil {
+		t.Errorf("Expected error for NewServerQUIC without TLS config")
+	}
+}
+
+func TestServerQUIC(t *testing.T) {
+	tmpdir, rmFunc, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatalf("Could not write PEM files: %s", err)
+	}
+	defer rmFunc()
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(tmpdir, "cert.pem"), filepath.Join(tmpdir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Could not load certificate: %s", err)
+	}
+
+	c := testConfig("quic", answerPlugin{})
+	c.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	s, err := NewServerQUIC("quic://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerQUIC, got %s", err)
+	}
+	p, err := s.ListenPacket()
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	go s.ServePacket(p)
+	defer s.Stop()
+
+	conn, err := quic.DialAddr(context.TODO(), p.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"doq"}}, nil)
+	if err != nil {
+		t.Fatalf("Could not dial: %s", err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("aaa.example.com.", dns.TypeA)
+		m.Id = 0
+		r, err := exchangeDoQ(conn, m)
+		if err != nil {
+			t.Fatalf("Expected reply, got %s", err)
+		}
+		if len(r.Answer) != 1 {
+			t.Fatalf("Expected 1 answer, got %d", len(r.Answer))
+		}
+		if x := r.Answer[0].Header().Name; x != "aaa.example.com." {
+			t.Errorf("Expected %s, got %s", "aaa.example.com.", x)
+		}
+	}
+}
+
+func exchangeDoQ(conn quic.Connection, m *dns.Msg) (*dns.Msg, error) {
+	stream, err := conn.OpenStreamSync(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	buf, _ := m.Pack()
+	out := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(out, uint16(len(buf)))
+	copy(out[2:], buf)
+	if _, err := stream.Write(out); err != nil {
+		return nil, err
+	}
+	stream.Close()
+
+	var l [2]byte
+	if _, err := io.ReadFull(stream, l[:]); err != nil {
+		return nil, err
+	}
+	buf = make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+	r := new(dns.Msg)
+	return r, r.Unpack(buf)
+}
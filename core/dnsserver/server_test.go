@@ -2,12 +2,19 @@ package dnsserver
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/coredns/coredns/pb"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/doh"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
+	"google.golang.org/grpc/peer"
 )
 
 type testPlugin struct{}
@@ -65,3 +72,92 @@ func BenchmarkCoreServeDNS(b *testing.B) {
 		s.ServeDNS(ctx, w, m)
 	}
 }
+
+func TestAcceptUpdate(t *testing.T) {
+	tests := []struct {
+		opcode int
+		qd, ns uint16
+		want   dns.MsgAcceptAction
+	}{
+		{dns.OpcodeUpdate, 1, 10, dns.MsgAccept},
+		{dns.OpcodeUpdate, 2, 10, dns.MsgReject},
+		{dns.OpcodeQuery, 1, 0, dns.MsgAccept},
+		{dns.OpcodeQuery, 1, 10, dns.MsgReject},
+	}
+	for i, tc := range tests {
+		dh := dns.Header{Bits: uint16(tc.opcode) << 11, Qdcount: tc.qd, Nscount: tc.ns}
+		if got := acceptUpdate(dh); got != tc.want {
+			t.Errorf("Test %d: expected %d, got %d", i, tc.want, got)
+		}
+	}
+}
+
+// tsigPlugin refuses signed requests that didn't pass verification, like the tsig plugin does.
+type tsigPlugin struct{}
+
+func (tp tsigPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.IsTsig() != nil && w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeNotAuth
+	}
+	w.WriteMsg(m)
+	return 0, nil
+}
+
+func (tp tsigPlugin) Name() string { return "tsigplugin" }
+
+func signedMsg() *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeAXFR)
+	m.SetTsig("key.", dns.HmacSHA256, 300, time.Now().Unix())
+	return m
+}
+
+func TestTsiggRPC(t *testing.T) {
+	s, err := NewServergRPC("127.0.0.1:53", []*Config{testConfig("grpc", tsigPlugin{})})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServergRPC, got %s", err)
+	}
+
+	// We can't sign this message, the MAC is empty, but gRPC doesn't verify it either way.
+	buf, err := signedMsg().Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}})
+	p, err := s.Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		t.Fatalf("Expected no error for Query, got %s", err)
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(p.Msg); err != nil {
+		t.Fatal(err)
+	}
+	if m.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH for signed request over gRPC, got %s", dns.RcodeToString[m.Rcode])
+	}
+}
+
+func TestTsigHTTPS(t *testing.T) {
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{testConfig("https", tsigPlugin{})})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerHTTPS, got %s", err)
+	}
+
+	req, err := doh.NewRequest(http.MethodPost, "127.0.0.1:443", signedMsg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "10.0.0.1:4000"
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	m := new(dns.Msg)
+	if err := m.Unpack(rec.Body.Bytes()); err != nil {
+		t.Fatalf("Expected a DNS response, got %q: %s", rec.Body.String(), err)
+	}
+	if m.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH for signed request over DoH, got %s", dns.RcodeToString[m.Rcode])
+	}
+}
@@ -26,14 +26,17 @@ var Directives = []string{
 	"errors",
 	"log",
 	"dnstap",
+	"acl",
 	"any",
 	"chaos",
 	"loadbalance",
+	"tsig",
 	"cache",
 	"rewrite",
 	"dnssec",
 	"autopath",
 	"template",
+	"transfer",
 	"hosts",
 	"route53",
 	"federation",
@@ -46,6 +49,7 @@ var Directives = []string{
 	"loop",
 	"forward",
 	"grpc",
+	"recursive",
 	"erratic",
 	"whoami",
 	"on",
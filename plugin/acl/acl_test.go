@@ -0,0 +1,166 @@
+package acl
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+type testResponseWriter struct {
+	test.ResponseWriter
+	remote net.IP
+}
+
+func (t *testResponseWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: t.remote, Port: 40212}
+}
+
+func TestACLServeDNS(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		zones     []string
+		source    string
+		qname     string
+		qtype     uint16
+		wantRcode int
+		wantEmpty bool
+		wantNext  bool
+	}{
+		{
+			name: "Blocked by source",
+			config: `acl example.org {
+				block net 192.168.0.0/16
+			}`,
+			source:    "192.168.1.2",
+			qname:     "www.example.org.",
+			qtype:     dns.TypeA,
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Not blocked, other source",
+			config: `acl example.org {
+				block net 192.168.0.0/16
+			}`,
+			source:   "10.1.1.1",
+			qname:    "www.example.org.",
+			qtype:    dns.TypeA,
+			wantNext: true,
+		},
+		{
+			name: "Not blocked, other zone",
+			config: `acl example.org {
+				block net 192.168.0.0/16
+			}`,
+			source:   "192.168.1.2",
+			qname:    "www.example.com.",
+			qtype:    dns.TypeA,
+			wantNext: true,
+		},
+		{
+			name: "Blocked by type",
+			config: `acl example.org {
+				block type AAAA ANY
+			}`,
+			source:    "10.1.1.1",
+			qname:     "www.example.org.",
+			qtype:     dns.TypeANY,
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Filtered",
+			config: `acl example.org {
+				filter type A net 10.0.0.0/8
+			}`,
+			source:    "10.1.1.1",
+			qname:     "www.example.org.",
+			qtype:     dns.TypeA,
+			wantRcode: dns.RcodeSuccess,
+			wantEmpty: true,
+		},
+		{
+			name: "Allow takes precedence in order",
+			config: `acl example.org {
+				allow net 192.168.1.0/24
+				block net *
+			}`,
+			source:   "192.168.1.2",
+			qname:    "www.example.org.",
+			qtype:    dns.TypeA,
+			wantNext: true,
+		},
+		{
+			name: "Fall through to block",
+			config: `acl example.org {
+				allow net 192.168.1.0/24
+				block
+			}`,
+			source:    "192.168.2.2",
+			qname:     "www.example.org.",
+			qtype:     dns.TypeA,
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Zone from server block",
+			config: `acl {
+				block net 2001:db8::/32
+			}`,
+			zones:     []string{"example.org."},
+			source:    "2001:db8::1",
+			qname:     "www.example.org.",
+			qtype:     dns.TypeAAAA,
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Single IP",
+			config: `acl example.org {
+				block net 10.1.1.1
+			}`,
+			source:    "10.1.1.1",
+			qname:     "example.org.",
+			qtype:     dns.TypeMX,
+			wantRcode: dns.RcodeRefused,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := caddy.NewTestController("dns", tc.config)
+			c.ServerBlockKeys = tc.zones
+			a, err := parse(c)
+			if err != nil {
+				t.Fatalf("Failed to parse config: %s", err)
+			}
+			a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+			w := dnstest.NewRecorder(&testResponseWriter{remote: net.ParseIP(tc.source)})
+			m := new(dns.Msg)
+			m.SetQuestion(tc.qname, tc.qtype)
+
+			if _, err := a.ServeDNS(context.TODO(), w, m); err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+			if tc.wantNext {
+				if w.Msg != nil {
+					t.Errorf("Expected the query to be passed to the next plugin, got a reply")
+				}
+				return
+			}
+			if w.Msg == nil {
+				t.Fatalf("Expected a reply, got none")
+			}
+			if w.Msg.Rcode != tc.wantRcode {
+				t.Errorf("Expected rcode %d, got %d", tc.wantRcode, w.Msg.Rcode)
+			}
+			if tc.wantEmpty && len(w.Msg.Answer) != 0 {
+				t.Errorf("Expected an empty answer, got %d RRs", len(w.Msg.Answer))
+			}
+		})
+	}
+}
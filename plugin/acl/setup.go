@@ -0,0 +1,142 @@
+package acl
+
+import (
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("acl", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	a, err := parse(c)
+	if err != nil {
+		return plugin.Error("acl", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		a.Next = next
+		return a
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, RequestBlockCount, RequestFilterCount, RequestAllowCount)
+		return nil
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (ACL, error) {
+	a := ACL{}
+	for c.Next() {
+		r := rule{}
+		r.zones = c.RemainingArgs()
+		if len(r.zones) == 0 {
+			// if empty, the zones from the configuration block are used.
+			r.zones = make([]string, len(c.ServerBlockKeys))
+			copy(r.zones, c.ServerBlockKeys)
+		}
+		for i := range r.zones {
+			r.zones[i] = plugin.Host(r.zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			p := policy{}
+
+			action := strings.ToLower(c.Val())
+			switch action {
+			case "allow":
+				p.action = actionAllow
+			case "block":
+				p.action = actionBlock
+			case "filter":
+				p.action = actionFilter
+			default:
+				return a, c.Errf("unexpected token %q; expect 'allow', 'block', or 'filter'", c.Val())
+			}
+
+			p.qtypes = make(map[uint16]struct{})
+			var rawNets []string
+			section := ""
+			for _, token := range c.RemainingArgs() {
+				switch t := strings.ToLower(token); t {
+				case "type", "net":
+					section = t
+					continue
+				}
+
+				switch section {
+				case "type":
+					if token == "*" {
+						p.qtypes[dns.TypeNone] = struct{}{}
+						continue
+					}
+					qtype, ok := dns.StringToType[strings.ToUpper(token)]
+					if !ok {
+						return a, c.Errf("unexpected token %q; expect legal QTYPE", token)
+					}
+					p.qtypes[qtype] = struct{}{}
+				case "net":
+					rawNets = append(rawNets, token)
+				default:
+					return a, c.Errf("unexpected token %q; expect 'type' or 'net'", token)
+				}
+			}
+
+			// optional fields.
+			if len(p.qtypes) == 0 {
+				p.qtypes[dns.TypeNone] = struct{}{}
+			}
+			if len(rawNets) == 0 {
+				rawNets = append(rawNets, "*")
+			}
+			for _, rawNet := range rawNets {
+				n, err := parseNet(rawNet)
+				if err != nil {
+					return a, c.Errf("illegal CIDR notation %q", rawNet)
+				}
+				p.nets = append(p.nets, n...)
+			}
+
+			r.policies = append(r.policies, p)
+		}
+		a.Rules = append(a.Rules, r)
+	}
+	return a, nil
+}
+
+// parseNet parses s as a CIDR or a single IP address. The wildcard "*" matches
+// every IPv4 and IPv6 address.
+func parseNet(s string) ([]*net.IPNet, error) {
+	if s == "*" {
+		_, v4, _ := net.ParseCIDR("0.0.0.0/0")
+		_, v6, _ := net.ParseCIDR("::/0")
+		return []*net.IPNet{v4, v6}, nil
+	}
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return []*net.IPNet{n}, nil
+}
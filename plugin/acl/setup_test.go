@@ -0,0 +1,52 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+	}{
+		{`acl`, false},
+		{`acl example.org {
+			block type A net 192.168.0.0/16
+		}`, false},
+		{`acl example.org {
+			filter type A AAAA net 192.168.0.0/16 2001:db8::/32
+		}`, false},
+		{`acl example.org {
+			allow net 192.168.1.1
+			block
+		}`, false},
+		{`acl example.org {
+			block type *
+		}`, false},
+		{`acl example.org {
+			drop type A
+		}`, true},
+		{`acl example.org {
+			block type ABC
+		}`, true},
+		{`acl example.org {
+			block net 192.168.0.0/33
+		}`, true},
+		{`acl example.org {
+			block 192.168.0.0/16
+		}`, true},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		err := setup(c)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/coredns/coredns/plugin/file"
 	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/transfer"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -21,7 +22,8 @@ type (
 		Next plugin.Handler
 		*Zones
 
-		metrics *metrics.Metrics
+		metrics  *metrics.Metrics
+		transfer *transfer.Transfer
 		loader
 	}
 
@@ -31,7 +33,6 @@ type (
 		re        *regexp.Regexp
 
 		// In the future this should be something like ZoneMeta that contains all this stuff.
-		transferTo     []string
 		ReloadInterval time.Duration
 		upstream       *upstream.Upstream // Upstream for looking up names during the resolution process.
 	}
@@ -59,11 +60,6 @@ func (a Auto) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 		return dns.RcodeServerFailure, nil
 	}
 
-	if state.QType() == dns.TypeAXFR || state.QType() == dns.TypeIXFR {
-		xfr := file.Xfr{Zone: z}
-		return xfr.ServeDNS(ctx, w, r)
-	}
-
 	answer, ns, extra, result := z.Lookup(ctx, state, qname)
 
 	m := new(dns.Msg)
@@ -86,5 +82,17 @@ func (a Auto) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 	return dns.RcodeSuccess, nil
 }
 
+// Transfer implements the plugin.Transferer interface.
+func (a Auto) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
+	a.Zones.RLock()
+	z, ok := a.Zones.Z[zone]
+	a.Zones.RUnlock()
+
+	if !ok || z == nil {
+		return nil, plugin.ErrNotAuthoritative
+	}
+	return z.Transfer(serial)
+}
+
 // Name implements the Handler interface.
 func (a Auto) Name() string { return "auto" }
@@ -10,8 +10,8 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
-	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/transfer"
 
 	"github.com/mholt/caddy"
 )
@@ -32,6 +32,8 @@ func setup(c *caddy.Controller) error {
 	}
 
 	c.OnStartup(func() error {
+		(&a).transfer, _ = dnsserver.GetConfig(c).Handler("transfer").(*transfer.Transfer)
+
 		m := dnsserver.GetConfig(c).Handler("prometheus")
 		if m == nil {
 			return nil
@@ -151,15 +153,6 @@ func autoParse(c *caddy.Controller) (Auto, error) {
 				c.RemainingArgs() // eat remaining args
 				a.loader.upstream = upstream.New()
 
-			case "transfer":
-				t, _, e := parse.Transfer(c, false)
-				if e != nil {
-					return a, e
-				}
-				if t != nil {
-					a.loader.transferTo = append(a.loader.transferTo, t...)
-				}
-
 			default:
 				return Auto{}, c.Errf("unknown property '%s'", c.Val())
 			}
@@ -15,86 +15,90 @@ func TestAutoParse(t *testing.T) {
 		expectedTempl          string
 		expectedRe             string
 		expectedReloadInterval time.Duration
-		expectedTo             []string
 	}{
 		{
 			`auto example.org {
 				directory /tmp
-				transfer to 127.0.0.1
 			}`,
-			false, "/tmp", "${1}", `db\.(.*)`, 60 * time.Second, []string{"127.0.0.1:53"},
+			false, "/tmp", "${1}", `db\.(.*)`, 60 * time.Second,
 		},
 		{
 			`auto 10.0.0.0/24 {
 				directory /tmp
 			}`,
-			false, "/tmp", "${1}", `db\.(.*)`, 60 * time.Second, nil,
+			false, "/tmp", "${1}", `db\.(.*)`, 60 * time.Second,
 		},
 		{
 			`auto {
 				directory /tmp
 				reload 0
 			}`,
-			false, "/tmp", "${1}", `db\.(.*)`, 0 * time.Second, nil,
+			false, "/tmp", "${1}", `db\.(.*)`, 0 * time.Second,
 		},
 		{
 			`auto {
 				directory /tmp (.*) bliep
 			}`,
-			false, "/tmp", "bliep", `(.*)`, 60 * time.Second, nil,
+			false, "/tmp", "bliep", `(.*)`, 60 * time.Second,
 		},
 		{
 			`auto {
 				directory /tmp (.*) bliep
 				reload 10s
 			}`,
-			false, "/tmp", "bliep", `(.*)`, 10 * time.Second, nil,
+			false, "/tmp", "bliep", `(.*)`, 10 * time.Second,
 		},
 		{
 			`auto {
 				directory /tmp (.*) bliep
-				transfer to 127.0.0.1
-				transfer to 127.0.0.2
 				upstream 8.8.8.8
 			}`,
-			false, "/tmp", "bliep", `(.*)`, 60 * time.Second, []string{"127.0.0.1:53", "127.0.0.2:53"},
+			false, "/tmp", "bliep", `(.*)`, 60 * time.Second,
 		},
 		// errors
+		// transfer is handled by the transfer plugin.
+		{
+			`auto {
+				directory /tmp
+				transfer to 127.0.0.1
+			}`,
+			true, "/tmp", "${1}", `db\.(.*)`, 60 * time.Second,
+		},
 		// NO_RELOAD has been deprecated.
 		{
 			`auto {
 				directory /tmp
 				no_reload
 			}`,
-			true, "/tmp", "${1}", `db\.(.*)`, 0 * time.Second, nil,
+			true, "/tmp", "${1}", `db\.(.*)`, 0 * time.Second,
 		},
 		// TIMEOUT has been deprecated.
 		{
 			`auto {
 				directory /tmp (.*) bliep 10
 			}`,
-			true, "/tmp", "bliep", `(.*)`, 10 * time.Second, nil,
+			true, "/tmp", "bliep", `(.*)`, 10 * time.Second,
 		},
 		// no directory specified.
 		{
 			`auto example.org {
 				directory
 			}`,
-			true, "", "${1}", `db\.(.*)`, 60 * time.Second, nil,
+			true, "", "${1}", `db\.(.*)`, 60 * time.Second,
 		},
 		// illegal REGEXP.
 		{
 			`auto example.org {
 				directory /tmp * {1}
 			}`,
-			true, "/tmp", "${1}", ``, 60 * time.Second, nil,
+			true, "/tmp", "${1}", ``, 60 * time.Second,
 		},
 		// unexpected argument.
 		{
 			`auto example.org {
 				directory /tmp (.*) {1} aa
 			}`,
-			true, "/tmp", "${1}", ``, 60 * time.Second, nil,
+			true, "/tmp", "${1}", ``, 60 * time.Second,
 		},
 	}
 
@@ -119,13 +123,6 @@ func TestAutoParse(t *testing.T) {
 			if a.loader.ReloadInterval != test.expectedReloadInterval {
 				t.Fatalf("Test %d expected %v, got %v", i, test.expectedReloadInterval, a.loader.ReloadInterval)
 			}
-			if test.expectedTo != nil {
-				for j, got := range a.loader.transferTo {
-					if got != test.expectedTo[j] {
-						t.Fatalf("Test %d expected %v, got %v", i, test.expectedTo[j], got)
-					}
-				}
-			}
 		}
 	}
 }
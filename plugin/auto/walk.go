@@ -53,7 +53,7 @@ func (a Auto) Walk() error {
 
 		zo.ReloadInterval = a.loader.ReloadInterval
 		zo.Upstream = a.loader.upstream
-		zo.TransferTo = a.loader.transferTo
+		zo.Xfr = a.transfer
 
 		a.Zones.Add(zo, origin)
 
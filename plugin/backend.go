@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"context"
+	"errors"
 
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/request"
@@ -29,21 +30,26 @@ type ServiceBackend interface {
 	// IsNameError return true if err indicated a record not found condition
 	IsNameError(err error) bool
 
-	Transferer
-}
-
-// Transferer defines an interface for backends that provide AXFR of all records.
-type Transferer interface {
 	// Serial returns a SOA serial number to construct a SOA record.
 	Serial(state request.Request) uint32
 
 	// MinTTL returns the minimum TTL to be used in the SOA record.
 	MinTTL(state request.Request) uint32
+}
 
-	// Transfer handles a zone transfer it writes to the client just
-	// like any other handler.
-	Transfer(ctx context.Context, state request.Request) (int, error)
+// Transferer defines an interface for plugins that can provide the records of a zone for a zone
+// transfer. The transfer plugin takes care of the wire protocol.
+type Transferer interface {
+	// Transfer returns a channel to which it writes the records of zone. The first record must
+	// be the zone's SOA record; the closing SOA record is added by the caller. If serial is not
+	// zero the request is an IXFR for that serial: if the zone has not changed since, only the
+	// current SOA record must be written. If the plugin is not authoritative for zone it must
+	// return ErrNotAuthoritative.
+	Transfer(zone string, serial uint32) (<-chan []dns.RR, error)
 }
 
+// ErrNotAuthoritative is returned by a Transferer when it is not authoritative for a zone.
+var ErrNotAuthoritative = errors.New("not authoritative for zone")
+
 // Options are extra options that can be specified for a lookup.
 type Options struct{}
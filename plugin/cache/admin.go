@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// admin is an HTTP server to inspect the cache and to purge items from it.
+type admin struct {
+	addr string
+	c    *Cache
+
+	ln  net.Listener
+	mux *http.ServeMux
+}
+
+// adminEntry is a cached item as returned by the admin API.
+type adminEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"` // Success or Denial
+	Rcode string `json:"rcode"`
+	DO    bool   `json:"do"`
+	TTL   int    `json:"ttl"` // remaining TTL, negative when expired
+	Hits  uint64 `json:"hits"`
+}
+
+// OnStartup starts the admin HTTP server.
+func (a *admin) OnStartup() error {
+	ln, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	a.ln = ln
+
+	a.mux = http.NewServeMux()
+	a.mux.HandleFunc(entriesPath, a.entries)
+	a.mux.HandleFunc(purgePath, a.purge)
+
+	go func() { http.Serve(a.ln, a.mux) }()
+	return nil
+}
+
+// OnFinalShutdown stops the admin HTTP server, it is also called on restart.
+func (a *admin) OnFinalShutdown() error {
+	if a.ln == nil {
+		return nil
+	}
+	err := a.ln.Close()
+	a.ln = nil
+	return err
+}
+
+// entries writes the cached items as JSON. The items can be filtered with the "name" and "type" query parameters.
+func (a *admin) entries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name != "" {
+		name = dns.Fqdn(strings.ToLower(name))
+	}
+	qtype := uint16(0)
+	if t := r.URL.Query().Get("type"); t != "" {
+		var ok bool
+		if qtype, ok = dns.StringToType[strings.ToUpper(t)]; !ok {
+			http.Error(w, "unknown type: "+t, http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := a.c.now()
+	entries := []adminEntry{}
+	walk := func(class string) func(uint64, interface{}) bool {
+		return func(_ uint64, el interface{}) bool {
+			i := el.(*item)
+			if (name != "" && i.name != name) || (qtype != 0 && i.qtype != qtype) {
+				return true
+			}
+			entries = append(entries, adminEntry{
+				Name:  i.name,
+				Type:  dns.Type(i.qtype).String(),
+				Class: class,
+				Rcode: dns.RcodeToString[i.Rcode],
+				DO:    i.do,
+				TTL:   i.ttl(now),
+				Hits:  i.hitCount(),
+			})
+			return true
+		}
+	}
+	a.c.pcache.Walk(walk(Success))
+	a.c.ncache.Walk(walk(Denial))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// purge removes items from the cache. The "name" query parameter removes the items for that name, "suffix" the
+// items for that name and all names below it, and "all" empties the cache. The number of purged items is returned.
+func (a *admin) purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var match func(*item) bool
+	switch {
+	case q.Get("name") != "":
+		name := dns.Fqdn(strings.ToLower(q.Get("name")))
+		match = func(i *item) bool { return i.name == name }
+	case q.Get("suffix") != "":
+		suffix := dns.Fqdn(strings.ToLower(q.Get("suffix")))
+		match = func(i *item) bool { return dns.IsSubDomain(suffix, i.name) }
+	case q.Get("all") == "true":
+		match = func(*item) bool { return true }
+	default:
+		http.Error(w, "one of name, suffix or all=true is required", http.StatusBadRequest)
+		return
+	}
+
+	remove := func(_ uint64, el interface{}) bool { return match(el.(*item)) }
+	n := a.c.pcache.RemoveFunc(remove) + a.c.ncache.RemoveFunc(remove)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Purged int `json:"purged"`
+	}{n})
+}
+
+const (
+	entriesPath = "/cache/entries"
+	purgePath   = "/cache/purge"
+)
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestAdmin(t *testing.T) {
+	c := New()
+	c.Next = snapshotHandler()
+	a := &admin{c: c}
+
+	for _, q := range []string{"short.example.org.", "long.example.org.", "nx.example.org.", "a.example.net."} {
+		req := new(dns.Msg)
+		req.SetQuestion(q, dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	// Hit long.example.org. twice.
+	for i := 0; i < 2; i++ {
+		req := new(dns.Msg)
+		req.SetQuestion("long.example.org.", dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+
+	entries := adminEntries(t, a, "")
+	if len(entries) != 4 {
+		t.Fatalf("Expected %d entries, got %d", 4, len(entries))
+	}
+
+	entries = adminEntries(t, a, "?name=LONG.example.org&type=a")
+	if len(entries) != 1 {
+		t.Fatalf("Expected %d entry, got %d", 1, len(entries))
+	}
+	if e := entries[0]; e.Name != "long.example.org." || e.Type != "A" || e.Class != Success || e.Hits != 2 || e.TTL != 300 {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	if entries := adminEntries(t, a, "?name=nx.example.org."); len(entries) != 1 || entries[0].Class != Denial || entries[0].Rcode != "NXDOMAIN" {
+		t.Errorf("Expected a denial entry for nx.example.org., got %+v", entries)
+	}
+	if entries := adminEntries(t, a, "?type=AAAA"); len(entries) != 0 {
+		t.Errorf("Expected no AAAA entries, got %d", len(entries))
+	}
+
+	rec := httptest.NewRecorder()
+	a.entries(rec, httptest.NewRequest(http.MethodGet, entriesPath+"?type=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown type, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		purged int
+		left   int
+	}{
+		{"?name=short.example.org", http.StatusOK, 1, 3},
+		{"?name=short.example.org", http.StatusOK, 0, 3},
+		{"?suffix=example.org.", http.StatusOK, 2, 1},
+		{"", http.StatusBadRequest, 0, 1},
+		{"?all=true", http.StatusOK, 1, 0},
+	}
+	for i, tc := range tests {
+		rec := httptest.NewRecorder()
+		a.purge(rec, httptest.NewRequest(http.MethodPost, purgePath+tc.query, nil))
+		if rec.Code != tc.status {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.status, rec.Code)
+			continue
+		}
+		if tc.status == http.StatusOK {
+			var resp struct {
+				Purged int `json:"purged"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Test %d: %s", i, err)
+			}
+			if resp.Purged != tc.purged {
+				t.Errorf("Test %d: expected %d purged, got %d", i, tc.purged, resp.Purged)
+			}
+		}
+		if x := c.pcache.Len() + c.ncache.Len(); x != tc.left {
+			t.Errorf("Test %d: expected %d items left, got %d", i, tc.left, x)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	a.purge(rec, httptest.NewRequest(http.MethodGet, purgePath+"?all=true", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for GET purge, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func adminEntries(t *testing.T, a *admin, query string) []adminEntry {
+	rec := httptest.NewRecorder()
+	a.entries(rec, httptest.NewRequest(http.MethodGet, entriesPath+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	entries := []adminEntry{}
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
@@ -36,6 +36,15 @@ type Cache struct {
 	duration   time.Duration
 	percentage int
 
+	// Serve stale, expired items are kept for this long, 0 disables it.
+	staleUpTo time.Duration
+
+	// Snapshots, when set the cache is written to and loaded from a file.
+	snap *snapshot
+
+	// Admin HTTP server, when set the cache can be inspected and purged.
+	admin *admin
+
 	// Testing.
 	now func() time.Time
 }
@@ -114,6 +123,9 @@ type ResponseWriter struct {
 
 	prefetch   bool // When true write nothing back to the client.
 	remoteAddr net.Addr
+
+	refresh bool     // When true the reply refreshes a stale item, server failures are not cached.
+	reply   *dns.Msg // When prefetching, the reply that would have been written to the client.
 }
 
 // newPrefetchResponseWriter returns a Cache ResponseWriter to be used in
@@ -170,6 +182,10 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 		duration = computeTTL(msgTTL, w.minpttl, w.pttl)
 	}
 
+	if w.refresh && mt == response.ServerError {
+		hasKey = false
+	}
+
 	if hasKey && duration > 0 {
 		if w.state.Match(res) {
 			w.set(res, key, mt, duration)
@@ -182,23 +198,33 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	}
 
 	if w.prefetch {
+		if w.refresh {
+			// The reply is copied as the cached item shares the records.
+			w.reply = res.Copy()
+			setTTL(w.reply, duration)
+		}
 		return nil
 	}
 
 	// Apply capped TTL to this reply to avoid jarring TTL experience 1799 -> 8 (e.g.)
+	setTTL(res, duration)
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// setTTL sets the TTL of all records in m, except the OPT record, to duration.
+func setTTL(m *dns.Msg, duration time.Duration) {
 	ttl := uint32(duration.Seconds())
-	for i := range res.Answer {
-		res.Answer[i].Header().Ttl = ttl
+	for i := range m.Answer {
+		m.Answer[i].Header().Ttl = ttl
 	}
-	for i := range res.Ns {
-		res.Ns[i].Header().Ttl = ttl
+	for i := range m.Ns {
+		m.Ns[i].Header().Ttl = ttl
 	}
-	for i := range res.Extra {
-		if res.Extra[i].Header().Rrtype != dns.TypeOPT {
-			res.Extra[i].Header().Ttl = ttl
+	for i := range m.Extra {
+		if m.Extra[i].Header().Rrtype != dns.TypeOPT {
+			m.Extra[i].Header().Ttl = ttl
 		}
 	}
-	return w.ResponseWriter.WriteMsg(res)
 }
 
 func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration time.Duration) {
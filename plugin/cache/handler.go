@@ -55,6 +55,12 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		return dns.RcodeSuccess, nil
 	}
 
+	if c.staleUpTo > 0 {
+		if i := c.staleItem(now, state); i != nil {
+			return c.serveStale(ctx, state, server, i, now)
+		}
+	}
+
 	crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server}
 	return plugin.NextOrFailure(c.Name(), c.Next, ctx, crr, r)
 }
@@ -67,11 +73,13 @@ func (c *Cache) get(now time.Time, state request.Request, server string) (*item,
 
 	if i, ok := c.ncache.Get(k); ok && i.(*item).ttl(now) > 0 {
 		cacheHits.WithLabelValues(server, Denial).Inc()
+		i.(*item).hit()
 		return i.(*item), true
 	}
 
 	if i, ok := c.pcache.Get(k); ok && i.(*item).ttl(now) > 0 {
 		cacheHits.WithLabelValues(server, Success).Inc()
+		i.(*item).hit()
 		return i.(*item), true
 	}
 	cacheMisses.WithLabelValues(server).Inc()
@@ -124,4 +132,11 @@ var (
 		Name:      "drops_total",
 		Help:      "The number responses that are not cached, because the reply is malformed.",
 	}, []string{"server"})
+
+	cacheServedStale = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "served_stale_total",
+		Help:      "The number of stale answers served because the next plugin failed or timed out.",
+	}, []string{"server"})
 )
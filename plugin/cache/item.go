@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin/cache/freq"
@@ -19,6 +21,13 @@ type item struct {
 	origTTL uint32
 	stored  time.Time
 
+	// The query this item is the reply for.
+	name  string
+	qtype uint16
+	do    bool
+
+	hits uint64 // accessed atomically
+
 	*freq.Freq
 }
 
@@ -42,6 +51,14 @@ func newItem(m *dns.Msg, now time.Time, d time.Duration) *item {
 	}
 	i.Extra = i.Extra[:j]
 
+	if len(m.Question) > 0 {
+		i.name = strings.ToLower(m.Question[0].Name)
+		i.qtype = m.Question[0].Qtype
+	}
+	if o := m.IsEdns0(); o != nil {
+		i.do = o.Do()
+	}
+
 	i.origTTL = uint32(d.Seconds())
 	i.stored = now.UTC()
 
@@ -82,6 +99,12 @@ func (i *item) toMsg(m *dns.Msg, now time.Time) *dns.Msg {
 	return m1
 }
 
+// hit records a cache hit for i.
+func (i *item) hit() { atomic.AddUint64(&i.hits, 1) }
+
+// hitCount returns the number of cache hits for i.
+func (i *item) hitCount() uint64 { return atomic.LoadUint64(&i.hits) }
+
 func (i *item) ttl(now time.Time) int {
 	ttl := int(i.origTTL) - int(now.UTC().Sub(i.stored).Seconds())
 	return ttl
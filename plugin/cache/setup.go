@@ -2,6 +2,8 @@ package cache
 
 import (
 	"fmt"
+	"net"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -36,15 +38,27 @@ func setup(c *caddy.Controller) error {
 	c.OnStartup(func() error {
 		metrics.MustRegister(c,
 			cacheSize, cacheHits, cacheMisses,
-			cachePrefetches, cacheDrops)
+			cachePrefetches, cacheDrops, cacheServedStale)
 		return nil
 	})
 
+	if ca.snap != nil {
+		c.OnStartup(ca.startSnapshots)
+		c.OnRestart(ca.stopSnapshots)
+		c.OnFinalShutdown(ca.stopSnapshots)
+	}
+	if ca.admin != nil {
+		c.OnStartup(ca.admin.OnStartup)
+		c.OnRestart(ca.admin.OnFinalShutdown)
+		c.OnFinalShutdown(ca.admin.OnFinalShutdown)
+	}
+
 	return nil
 }
 
 func cacheParse(c *caddy.Controller) (*Cache, error) {
 	ca := New()
+	config := dnsserver.GetConfig(c)
 
 	j := 0
 	for c.Next() {
@@ -181,6 +195,53 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					ca.percentage = num
 				}
 
+			case "serve_stale":
+				args := c.RemainingArgs()
+				if len(args) > 1 {
+					return nil, c.ArgErr()
+				}
+				ca.staleUpTo = defaultStaleUpTo
+				if len(args) == 1 {
+					d, err := time.ParseDuration(args[0])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("serve_stale duration must be positive: %s", d)
+					}
+					ca.staleUpTo = d
+				}
+
+			case "admin":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if _, _, err := net.SplitHostPort(args[0]); err != nil {
+					return nil, err
+				}
+				ca.admin = &admin{addr: args[0], c: ca}
+
+			case "snapshot":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				ca.snap = &snapshot{file: args[0]}
+				if !filepath.IsAbs(ca.snap.file) && config.Root != "" {
+					ca.snap.file = filepath.Join(config.Root, ca.snap.file)
+				}
+				if len(args) == 2 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("snapshot interval must be positive: %s", d)
+					}
+					ca.snap.interval = d
+				}
+
 			default:
 				return nil, c.ArgErr()
 			}
@@ -113,3 +113,103 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupServeStale(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		staleUpTo time.Duration
+	}{
+		{"serve_stale", false, defaultStaleUpTo},
+		{"serve_stale 20m", false, 20 * time.Minute},
+		{"serve_stale 1h20m", false, 80 * time.Minute},
+		{"serve_stale 0m", true, 0},
+		{"serve_stale -20m", true, 0},
+		{"serve_stale 5m 10m", true, 0},
+		{"serve_stale aaa", true, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", "cache {\n"+test.input+"\n}")
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.staleUpTo != test.staleUpTo {
+			t.Errorf("Test %v: Expected stale %v but found: %v", i, test.staleUpTo, ca.staleUpTo)
+		}
+	}
+}
+
+func TestSetupSnapshot(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		file      string
+		interval  time.Duration
+	}{
+		{"snapshot /var/lib/coredns/cache", false, "/var/lib/coredns/cache", 0},
+		{"snapshot /var/lib/coredns/cache 5m", false, "/var/lib/coredns/cache", 5 * time.Minute},
+		{"snapshot", true, "", 0},
+		{"snapshot /var/lib/coredns/cache 0s", true, "", 0},
+		{"snapshot /var/lib/coredns/cache aaa", true, "", 0},
+		{"snapshot /var/lib/coredns/cache 5m 10m", true, "", 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", "cache {\n"+test.input+"\n}")
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.snap.file != test.file {
+			t.Errorf("Test %v: Expected file %v but found: %v", i, test.file, ca.snap.file)
+		}
+		if ca.snap.interval != test.interval {
+			t.Errorf("Test %v: Expected interval %v but found: %v", i, test.interval, ca.snap.interval)
+		}
+	}
+}
+
+func TestSetupAdmin(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		addr      string
+	}{
+		{"admin localhost:8054", false, "localhost:8054"},
+		{"admin :8054", false, ":8054"},
+		{"admin", true, ""},
+		{"admin localhost", true, ""},
+		{"admin localhost:8054 :8055", true, ""},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", "cache {\n"+test.input+"\n}")
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.admin.addr != test.addr {
+			t.Errorf("Test %v: Expected addr %v but found: %v", i, test.addr, ca.admin.addr)
+		}
+	}
+}
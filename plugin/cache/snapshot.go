@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// snapshot writes the items of a Cache to a file, on shutdown and optionally every interval, so the cache can be
+// loaded again after a restart or reload.
+type snapshot struct {
+	file     string
+	interval time.Duration // 0 only writes the snapshot on shutdown
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// snapshotEntry is a cached item as it is stored in the snapshot.
+type snapshotEntry struct {
+	Denial bool
+	Key    uint64
+	Stored int64  // unix time the item was stored
+	TTL    uint32 // original TTL
+	Msg    []byte // the records and flags as a packed message
+}
+
+// The snapshot file starts with snapshotMagic, the version and a CRC32 of the rest of the file, which holds
+// the gob encoded entries.
+var snapshotMagic = []byte("CDNSSNAP")
+
+const (
+	snapshotVersion = 1
+	snapshotHeader  = 8 + 2 + 4
+)
+
+var errSnapshotVersion = errors.New("unsupported snapshot version")
+
+// startSnapshots loads the snapshot into c and, when an interval is set, starts writing snapshots.
+func (c *Cache) startSnapshots() error {
+	c.snap.stop = make(chan struct{})
+	if err := c.loadSnapshot(); err != nil {
+		// Not fatal, we just start with an empty cache.
+		log.Warningf("Skipping cache snapshot %q: %s", c.snap.file, err)
+	}
+	if c.snap.interval == 0 {
+		return nil
+	}
+
+	go func() {
+		tick := time.NewTicker(c.snap.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				if err := c.saveSnapshot(); err != nil {
+					log.Errorf("Failed to write cache snapshot %q: %s", c.snap.file, err)
+				}
+			case <-c.snap.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stopSnapshots stops the periodic snapshots and writes a final one. It is called on restart and on final
+// shutdown, only the first call does something.
+func (c *Cache) stopSnapshots() error {
+	var err error
+	c.snap.once.Do(func() {
+		close(c.snap.stop)
+		err = c.saveSnapshot()
+	})
+	return err
+}
+
+// saveSnapshot writes the items in c to the snapshot file. The file is written to a temporary file first that is
+// renamed, so a crash never leaves a partial snapshot.
+func (c *Cache) saveSnapshot() error {
+	var entries []snapshotEntry
+	add := func(denial bool) func(uint64, interface{}) bool {
+		return func(key uint64, el interface{}) bool {
+			i := el.(*item)
+			buf, err := i.pack()
+			if err != nil {
+				return true
+			}
+			entries = append(entries, snapshotEntry{Denial: denial, Key: key, Stored: i.stored.Unix(), TTL: i.origTTL, Msg: buf})
+			return true
+		}
+	}
+	c.pcache.Walk(add(false))
+	c.ncache.Walk(add(true))
+
+	payload := &bytes.Buffer{}
+	if err := gob.NewEncoder(payload).Encode(entries); err != nil {
+		return err
+	}
+
+	header := make([]byte, snapshotHeader)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint16(header[8:], snapshotVersion)
+	binary.BigEndian.PutUint32(header[10:], crc32.ChecksumIEEE(payload.Bytes()))
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.snap.file), filepath.Base(c.snap.file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(header); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(payload.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.snap.file)
+}
+
+// loadSnapshot adds the items from the snapshot file to c. Items that have expired, taking serve_stale into
+// account, are skipped. A missing file is not an error.
+func (c *Cache) loadSnapshot() error {
+	buf, err := ioutil.ReadFile(c.snap.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(buf) < snapshotHeader || !bytes.Equal(buf[:8], snapshotMagic) {
+		return fmt.Errorf("not a cache snapshot")
+	}
+	if v := binary.BigEndian.Uint16(buf[8:]); v != snapshotVersion {
+		return errSnapshotVersion
+	}
+	payload := buf[snapshotHeader:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(buf[10:]) {
+		return fmt.Errorf("checksum mismatch")
+	}
+
+	var entries []snapshotEntry
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&entries); err != nil {
+		return err
+	}
+
+	now := c.now()
+	for _, e := range entries {
+		m := new(dns.Msg)
+		if err := m.Unpack(e.Msg); err != nil {
+			continue
+		}
+		i := newItem(m, time.Unix(e.Stored, 0), time.Duration(e.TTL)*time.Second)
+		if time.Duration(-i.ttl(now))*time.Second >= c.staleUpTo {
+			continue
+		}
+
+		ca := c.pcache
+		if e.Denial {
+			ca = c.ncache
+		}
+		ca.Add(e.Key, i)
+	}
+	return nil
+}
+
+// pack returns i as a packed message, with the question and the DO bit of the query it is the reply for.
+func (i *item) pack() ([]byte, error) {
+	m := new(dns.Msg)
+	if i.name != "" {
+		m.SetQuestion(i.name, i.qtype)
+	}
+	m.Rcode = i.Rcode
+	m.Authoritative = i.Authoritative
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	m.Answer = i.Answer
+	m.Ns = i.Ns
+	m.Extra = i.Extra
+	if i.do {
+		m.Extra = append(m.Extra[:len(m.Extra):len(m.Extra)], &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}})
+		m.IsEdns0().SetDo()
+	}
+	return m.Pack()
+}
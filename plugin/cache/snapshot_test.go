@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.snap")
+
+	t0 := time.Now()
+	c := New()
+	c.snap = &snapshot{file: file}
+	c.now = func() time.Time { return t0 }
+	c.Next = snapshotHandler()
+
+	for _, q := range []string{"short.example.org.", "long.example.org.", "nx.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(q, dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if err := c.saveSnapshot(); err != nil {
+		t.Fatalf("Failed to write snapshot: %s", err)
+	}
+
+	c1 := New()
+	c1.snap = &snapshot{file: file}
+	c1.now = func() time.Time { return t0.Add(20 * time.Second) }
+	c1.Next = failHandler()
+	if err := c1.loadSnapshot(); err != nil {
+		t.Fatalf("Failed to load snapshot: %s", err)
+	}
+	if x := c1.pcache.Len(); x != 1 {
+		t.Errorf("Expected %d positive items, got %d", 1, x)
+	}
+	if x := c1.ncache.Len(); x != 1 {
+		t.Errorf("Expected %d negative items, got %d", 1, x)
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion("long.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c1.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+		t.Fatalf("Expected answer from the loaded snapshot")
+	}
+	if x := rec.Msg.Answer[0].Header().Ttl; x != 280 {
+		t.Errorf("Expected TTL %d, got %d", 280, x)
+	}
+}
+
+func TestSnapshotSkipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.snap")
+
+	c := New()
+	c.snap = &snapshot{file: file}
+	c.Next = snapshotHandler()
+	req := new(dns.Msg)
+	req.SetQuestion("long.example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	if err := c.saveSnapshot(); err != nil {
+		t.Fatalf("Failed to write snapshot: %s", err)
+	}
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := New()
+	c1.snap = &snapshot{file: filepath.Join(dir, "does-not-exist")}
+	if err := c1.loadSnapshot(); err != nil {
+		t.Errorf("Expected no error for a missing snapshot, got %s", err)
+	}
+
+	corrupt := append([]byte{}, buf...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	old := append([]byte{}, buf...)
+	binary.BigEndian.PutUint16(old[8:], snapshotVersion+1)
+
+	for i, b := range [][]byte{corrupt, old, buf[:10], []byte("garbage")} {
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		c1 := New()
+		c1.snap = &snapshot{file: file}
+		if err := c1.loadSnapshot(); err == nil {
+			t.Errorf("Test %d: expected error loading snapshot", i)
+		}
+		if x := c1.pcache.Len(); x != 0 {
+			t.Errorf("Test %d: expected empty cache, got %d items", i, x)
+		}
+	}
+}
+
+// snapshotHandler answers with a 10s TTL for short.example.org., NXDOMAIN for nx.example.org. and a 300s TTL
+// for anything else.
+func snapshotHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case "short.example.org.":
+			m.Answer = append(m.Answer, test.A("short.example.org. 10 IN A 127.0.0.1"))
+		case "nx.example.org.":
+			m.Rcode = dns.RcodeNameError
+			m.Ns = append(m.Ns, test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300"))
+		default:
+			m.Answer = append(m.Answer, test.A(r.Question[0].Name+" 300 IN A 127.0.0.1"))
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func failHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		return dns.RcodeServerFailure, nil
+	})
+}
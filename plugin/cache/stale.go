@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// staleItem returns the expired item for the query in state, if it expired less than c.staleUpTo ago. Cached
+// server failures are never served stale.
+func (c *Cache) staleItem(now time.Time, state request.Request) *item {
+	k := hash(state.Name(), state.QType(), state.Do())
+
+	var stale *item
+	for _, ca := range []*cache.Cache{c.ncache, c.pcache} {
+		i, ok := ca.Get(k)
+		if !ok {
+			continue
+		}
+		it := i.(*item)
+		if it.Rcode == dns.RcodeServerFailure || time.Duration(-it.ttl(now))*time.Second >= c.staleUpTo {
+			continue
+		}
+		if stale == nil || it.stored.After(stale.stored) {
+			stale = it
+		}
+	}
+	return stale
+}
+
+// serveStale resolves the query in state for which the expired item i is cached, see RFC 8767. When the next
+// plugin fails, or doesn't reply within staleTimeout, the client gets i with a TTL of staleTTL. The next plugin
+// keeps running in the background, its reply refreshes the cache.
+func (c *Cache) serveStale(ctx context.Context, state request.Request, server string, i *item, now time.Time) (int, error) {
+	cw := newPrefetchResponseWriter(server, state, c)
+	cw.refresh = true
+
+	done := make(chan *dns.Msg, 1)
+	go func() {
+		rcode, _ := plugin.NextOrFailure(c.Name(), c.Next, ctx, cw, state.Req)
+		if !plugin.ClientWrite(rcode) {
+			done <- nil
+			return
+		}
+		done <- cw.reply
+	}()
+
+	timer := time.NewTimer(staleTimeout)
+	defer timer.Stop()
+
+	select {
+	case m := <-done:
+		if m != nil && m.Rcode != dns.RcodeServerFailure && m.Rcode != dns.RcodeRefused {
+			state.W.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		}
+	case <-timer.C:
+	}
+
+	resp := i.toMsg(state.Req, now)
+	setTTL(resp, staleTTL)
+	if o := state.Req.IsEdns0(); o != nil {
+		resp.SetEdns0(o.UDPSize(), o.Do())
+		mo := resp.IsEdns0()
+		mo.Option = append(mo.Option, edns.NewEDE(edns.EDEStaleAnswer, ""))
+	}
+	state.W.WriteMsg(resp)
+
+	cacheServedStale.WithLabelValues(server).Inc()
+	return dns.RcodeSuccess, nil
+}
+
+const (
+	staleTTL         = 30 * time.Second        // TTL of stale answers, see section 4 of RFC 8767
+	staleTimeout     = 1800 * time.Millisecond // client response timer, see section 5 of RFC 8767
+	defaultStaleUpTo = 1 * time.Hour           // how long items are kept after their TTL has expired
+)
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeStale(t *testing.T) {
+	t0, err := time.Parse(time.RFC3339, "2018-01-01T14:00:00+00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	c.staleUpTo = 1 * time.Hour
+	up := true
+	c.Next = staleHandler(&up)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	req.SetEdns0(4096, false)
+
+	tests := []struct {
+		after time.Duration
+		up    bool
+		rcode int
+		ttl   uint32
+		stale bool
+	}{
+		{0, true, dns.RcodeSuccess, 10, false},
+		{20 * time.Second, false, dns.RcodeSuccess, uint32(staleTTL.Seconds()), true},
+		{30 * time.Minute, false, dns.RcodeSuccess, uint32(staleTTL.Seconds()), true},
+		{30 * time.Minute, true, dns.RcodeSuccess, 10, false},
+		{2 * time.Hour, false, dns.RcodeServerFailure, 0, false},
+	}
+
+	for i, tc := range tests {
+		c.now = func() time.Time { return t0.Add(tc.after) }
+		up = tc.up
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		rcode, _ := c.ServeDNS(context.TODO(), rec, req)
+		if !plugin.ClientWrite(rcode) {
+			if rcode != tc.rcode {
+				t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+			}
+			continue
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rec.Msg.Rcode)
+			continue
+		}
+		if x := rec.Msg.Answer[0].Header().Ttl; x != tc.ttl {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, tc.ttl, x)
+		}
+		if x := hasStaleEDE(rec.Msg); x != tc.stale {
+			t.Errorf("Test %d: expected stale answer EDE to be %t, got %t", i, tc.stale, x)
+		}
+	}
+}
+
+func TestServeStaleNoServerFailure(t *testing.T) {
+	c := New()
+	c.staleUpTo = 1 * time.Hour
+	c.Next = servFailHandler()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+	c.ServeDNS(context.TODO(), rec, req)
+	c.now = func() time.Time { return time.Now().Add(time.Minute) }
+	c.ServeDNS(context.TODO(), rec, req)
+
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected rcode %d, got %d", dns.RcodeServerFailure, rec.Msg.Rcode)
+	}
+	if hasStaleEDE(rec.Msg) {
+		t.Errorf("Expected no stale answer for a server failure")
+	}
+}
+
+func hasStaleEDE(m *dns.Msg) bool {
+	o := m.IsEdns0()
+	if o == nil {
+		return false
+	}
+	for _, e := range o.Option {
+		if l, ok := e.(*dns.EDNS0_LOCAL); ok && l.Code == edns.OptionEDE && len(l.Data) >= 2 && l.Data[1] == edns.EDEStaleAnswer {
+			return true
+		}
+	}
+	return false
+}
+
+// staleHandler answers with a 10s TTL when up is true, otherwise it fails like forward does when all
+// upstreams are down.
+func staleHandler(up *bool) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if !*up {
+			return dns.RcodeServerFailure, nil
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.A("example.org. 10 IN A 127.0.0.1"))
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func servFailHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return dns.RcodeServerFailure, nil
+	})
+}
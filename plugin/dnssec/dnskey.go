@@ -60,8 +60,9 @@ func ParseKeyFile(pubFile, privFile string) (*DNSKEY, error) {
 
 // getDNSKEY returns the correct DNSKEY to the client. Signatures are added when do is true.
 func (d Dnssec) getDNSKEY(state request.Request, zone string, do bool, server string) *dns.Msg {
-	keys := make([]dns.RR, len(d.keys))
-	for i, k := range d.keys {
+	published := d.keySet().publish
+	keys := make([]dns.RR, len(published))
+	for i, k := range published {
 		keys[i] = dns.Copy(k.K)
 		keys[i].Header().Name = zone
 	}
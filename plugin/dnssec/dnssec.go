@@ -18,24 +18,31 @@ import (
 type Dnssec struct {
 	Next plugin.Handler
 
-	zones     []string
-	keys      []*DNSKEY
-	splitkeys bool
-	inflight  *singleflight.Group
-	cache     *cache.Cache
+	zones    []string
+	keys     keySet
+	manager  *keyManager // If not nil, the keys are managed and taken from here.
+	inflight *singleflight.Group
+	cache    *cache.Cache
 }
 
 // New returns a new Dnssec.
 func New(zones []string, keys []*DNSKEY, splitkeys bool, next plugin.Handler, c *cache.Cache) Dnssec {
 	return Dnssec{Next: next,
-		zones:     zones,
-		keys:      keys,
-		splitkeys: splitkeys,
-		cache:     c,
-		inflight:  new(singleflight.Group),
+		zones:    zones,
+		keys:     newKeySet(keys, splitkeys),
+		cache:    c,
+		inflight: new(singleflight.Group),
 	}
 }
 
+// keySet returns the keys to use now.
+func (d Dnssec) keySet() keySet {
+	if d.manager != nil {
+		return d.manager.keySet()
+	}
+	return d.keys
+}
+
 // Sign signs the message in state. it takes care of negative or nodata responses. It
 // uses NSEC black lies for authenticated denial of existence. For delegations it
 // will insert DS records and sign those.
@@ -100,20 +107,13 @@ func (d Dnssec) sign(rrs []dns.RR, signerName string, ttl, incep, expir uint32,
 
 	sigs, err := d.inflight.Do(k, func() (interface{}, error) {
 		var sigs []dns.RR
-		for _, k := range d.keys {
-			if d.splitkeys {
-				if len(rrs) > 0 && rrs[0].Header().Rrtype == dns.TypeDNSKEY {
-					// We are signing a DNSKEY RRSet. With split keys, we need to use a KSK here.
-					if !k.isKSK() {
-						continue
-					}
-				} else {
-					// For non-DNSKEY RRSets, we want to use a ZSK.
-					if !k.isZSK() {
-						continue
-					}
-				}
-			}
+		ks := d.keySet()
+		keys := ks.zone
+		if len(rrs) > 0 && rrs[0].Header().Rrtype == dns.TypeDNSKEY {
+			// We are signing a DNSKEY RRSet. With split keys, we need to use a KSK here.
+			keys = ks.dnskey
+		}
+		for _, k := range keys {
 			sig := k.newRRSIG(signerName, ttl, incep, expir)
 			if e := sig.Sign(k.s, rrs); e != nil {
 				return sigs, e
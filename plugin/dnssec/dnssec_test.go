@@ -42,7 +42,7 @@ func TestZoneSigningDouble(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse key: %v\n", err)
 	}
-	d.keys = append(d.keys, key1)
+	d.keys = newKeySet(append(d.keys.publish, key1), false)
 
 	m := testMsg()
 	state := request.Request{Req: m, Zone: "miek.nl."}
@@ -75,6 +75,13 @@ var (
 		Name:      "cache_misses_total",
 		Help:      "The count of cache misses.",
 	}, []string{"server"})
+
+	keyStates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "dnssec",
+		Name:      "key_state",
+		Help:      "The state of the managed keys, with the DS record for key signing keys.",
+	}, []string{"zone", "tag", "role", "state", "ds"})
 )
 
 // Name implements the Handler interface.
@@ -0,0 +1,407 @@
+package dnssec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// keyManager generates the keys of a zone and rolls them over following the timing model of
+// RFC 7583. A key is published in the DNSKEY RRset, becomes ready once all caches have seen it,
+// is active when it signs, is retired when it no longer signs, and is removed from the DNSKEY
+// RRset once no cache can have signatures (or, for a key signing key, DS records) that need it.
+//
+// Zone signing keys are rolled with pre-publication, key signing keys and combined signing keys
+// with double-KSK, see RFC 7583 Sections 3.2 and 3.3. The DS records of the key signing keys that
+// are ready must be published at the parent; the schedule assumes that is done within dsDelay.
+type keyManager struct {
+	dir  string // Directory with the key files and the state file.
+	zone string // Owner name of the keys.
+	ksk  *keyPolicy
+	zsk  *keyPolicy // If nil, ksk is the policy for a combined signing key.
+
+	mu     sync.RWMutex
+	keys   []*managedKey
+	set    keySet
+	labels [][]string // Label values of the key state metrics that are set.
+
+	stop chan bool
+}
+
+// keyPolicy is the policy for one role of keys.
+type keyPolicy struct {
+	role      string // "ksk", "zsk" or "csk".
+	algorithm uint8
+	lifetime  time.Duration // Zero means the key is never rolled.
+}
+
+// managedKey is a key with its timing. The key is in the state for which the time has passed last.
+type managedKey struct {
+	*DNSKEY  `json:"-"`
+	File     string    `json:"file"` // Base name of the key files.
+	Tag      uint16    `json:"tag"`
+	Role     string    `json:"role"`
+	DS       string    `json:"ds,omitempty"` // The DS record for key signing keys.
+	Publish  time.Time `json:"publish"`
+	Ready    time.Time `json:"ready"`
+	Activate time.Time `json:"activate"`
+	Retire   time.Time `json:"retire,omitempty"` // Zero until a successor is scheduled.
+	Remove   time.Time `json:"remove,omitempty"`
+
+	last string // State seen at the previous update.
+}
+
+// Key states, see RFC 7583, Section 3.1.
+const (
+	statePublished = "published"
+	stateReady     = "ready"
+	stateActive    = "active"
+	stateRetired   = "retired"
+	stateRemoved   = "removed"
+)
+
+const (
+	keyTTL      = 3600 * time.Second // TTL of the DNSKEY records, see getDNSKEY.
+	propagation = 1 * time.Hour      // Time for a change to reach all secondaries.
+	dsDelay     = 48 * time.Hour     // Time to publish a DS record at the parent and for the parent's DS TTL to pass.
+	maxTTL      = 24 * time.Hour     // Maximum TTL of the signed records.
+
+	// A published key is ready once the DNSKEY RRset with it is in all caches.
+	publishInterval = propagation + keyTTL
+	// A retired zone signing key is removed once its signatures are gone from our cache and all other caches.
+	zskRetireInterval = propagation + eightDays - sixDays + maxTTL
+	// A retired key signing key is removed once its DS record is gone from all caches.
+	kskRetireInterval = dsDelay
+
+	stateFile = "keys.json"
+)
+
+// RolloverTickTime is how often the key manager checks if keys must be rolled.
+var RolloverTickTime = 1 * time.Hour
+
+// keySet is the set of keys used at a point in time.
+type keySet struct {
+	publish []*DNSKEY // The keys in the DNSKEY RRset.
+	dnskey  []*DNSKEY // The keys that sign the DNSKEY RRset.
+	zone    []*DNSKEY // The keys that sign all other RRsets.
+}
+
+// newKeySet returns the key set for the static keys.
+func newKeySet(keys []*DNSKEY, splitkeys bool) keySet {
+	ks := keySet{publish: keys, dnskey: keys, zone: keys}
+	if !splitkeys {
+		return ks
+	}
+	ks.dnskey, ks.zone = nil, nil
+	for _, k := range keys {
+		if k.isKSK() {
+			ks.dnskey = append(ks.dnskey, k)
+		} else if k.isZSK() {
+			ks.zone = append(ks.zone, k)
+		}
+	}
+	return ks
+}
+
+func newKeyManager(dir, zone string, ksk, zsk *keyPolicy) *keyManager {
+	return &keyManager{dir: dir, zone: zone, ksk: ksk, zsk: zsk, stop: make(chan bool)}
+}
+
+// state returns the state of k at time now.
+func (k *managedKey) state(now time.Time) string {
+	switch {
+	case !k.Remove.IsZero() && !now.Before(k.Remove):
+		return stateRemoved
+	case !k.Retire.IsZero() && !now.Before(k.Retire):
+		return stateRetired
+	case !now.Before(k.Activate):
+		return stateActive
+	case !now.Before(k.Ready):
+		return stateReady
+	}
+	return statePublished
+}
+
+// prepublish returns how long before the end of the lifetime of a key of role the successor must be published.
+func prepublish(role string) time.Duration {
+	if role == "zsk" {
+		return publishInterval
+	}
+	return publishInterval + dsDelay
+}
+
+// retireInterval returns how long a retired key of role stays published.
+func retireInterval(role string) time.Duration {
+	switch role {
+	case "zsk":
+		return zskRetireInterval
+	case "ksk":
+		return kskRetireInterval
+	}
+	if zskRetireInterval > kskRetireInterval {
+		return zskRetireInterval
+	}
+	return kskRetireInterval
+}
+
+// load reads the key state file and the key files of the keys that aren't removed.
+func (m *keyManager) load() error {
+	buf, err := ioutil.ReadFile(filepath.Join(m.dir, stateFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var keys []*managedKey
+	if err := json.Unmarshal(buf, &keys); err != nil {
+		return fmt.Errorf("failed to parse %s: %s", filepath.Join(m.dir, stateFile), err)
+	}
+	for _, k := range keys {
+		if k.Remove.IsZero() || time.Now().Before(k.Remove) {
+			base := filepath.Join(m.dir, k.File)
+			if k.DNSKEY, err = ParseKeyFile(base+".key", base+".private"); err != nil {
+				return err
+			}
+		}
+	}
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+// save writes the key state file.
+func (m *keyManager) save() error {
+	m.mu.RLock()
+	buf, err := json.MarshalIndent(m.keys, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(m.dir, stateFile)
+	if err := ioutil.WriteFile(name+".tmp", append(buf, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// roll generates the keys that are needed at time now, schedules the successors of the keys whose
+// lifetime ends and updates the key set.
+func (m *keyManager) roll(now time.Time) error {
+	policies := []*keyPolicy{m.ksk}
+	if m.zsk != nil {
+		policies = append(policies, m.zsk)
+	}
+
+	changed := false
+	for _, p := range policies {
+		cur, next := m.current(p.role, now)
+		if cur == nil && next == nil {
+			// Nothing to roll from, this key is used right away.
+			k, err := m.generate(p)
+			if err != nil {
+				return err
+			}
+			k.Publish, k.Ready, k.Activate = now, now, now
+			m.add(k)
+			changed = true
+			log.Infof("Generated %s %d for %s", strings.ToUpper(p.role), k.Tag, m.zone)
+			continue
+		}
+		if next != nil || p.lifetime == 0 || now.Before(cur.Activate.Add(p.lifetime-prepublish(p.role))) {
+			continue
+		}
+
+		k, err := m.generate(p)
+		if err != nil {
+			return err
+		}
+		k.Publish = now
+		k.Ready = now.Add(publishInterval)
+		k.Activate = cur.Activate.Add(p.lifetime)
+		if p.role != "zsk" && k.Activate.Before(k.Ready.Add(dsDelay)) {
+			k.Activate = k.Ready.Add(dsDelay)
+		} else if k.Activate.Before(k.Ready) {
+			k.Activate = k.Ready
+		}
+		m.mu.Lock()
+		cur.Retire = k.Activate
+		cur.Remove = k.Activate.Add(retireInterval(p.role))
+		m.mu.Unlock()
+		m.add(k)
+		changed = true
+		log.Infof("Generated %s %d for %s, it replaces %d at %s", strings.ToUpper(p.role), k.Tag, m.zone, cur.Tag, k.Activate.UTC().Format(time.RFC3339))
+	}
+
+	// Keys of a role that is no longer configured are retired.
+	m.mu.Lock()
+	for _, k := range m.keys {
+		if k.Retire.IsZero() && k.Role != m.ksk.role && (m.zsk == nil || k.Role != m.zsk.role) {
+			k.Retire = now
+			k.Remove = now.Add(retireInterval(k.Role))
+			changed = true
+		}
+	}
+	m.mu.Unlock()
+
+	if changed {
+		if err := m.save(); err != nil {
+			return err
+		}
+	}
+	m.update(now)
+	return nil
+}
+
+// current returns the active key of role at time now, and its successor if it's already scheduled.
+func (m *keyManager) current(role string, now time.Time) (cur, next *managedKey) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.Role != role {
+			continue
+		}
+		switch k.state(now) {
+		case stateActive:
+			if cur == nil || k.Activate.After(cur.Activate) {
+				cur = k
+			}
+		case statePublished, stateReady:
+			next = k
+		}
+	}
+	return cur, next
+}
+
+func (m *keyManager) add(k *managedKey) {
+	m.mu.Lock()
+	m.keys = append(m.keys, k)
+	m.mu.Unlock()
+}
+
+// generate generates a new key following policy p and writes it to the key directory.
+func (m *keyManager) generate(p *keyPolicy) (*managedKey, error) {
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: m.zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: uint32(keyTTL.Seconds())},
+		Flags:     dns.ZONE,
+		Protocol:  3,
+		Algorithm: p.algorithm,
+	}
+	if p.role != "zsk" {
+		k.Flags |= dns.SEP
+	}
+	priv, err := k.Generate(algorithms[p.algorithm])
+	if err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("K%s+%03d+%05d", m.zone, k.Algorithm, k.KeyTag())
+	name := filepath.Join(m.dir, base)
+	if err := ioutil.WriteFile(name+".key", []byte(k.String()+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(name+".private", []byte(k.PrivateKeyString(priv)), 0600); err != nil {
+		return nil, err
+	}
+	dk, err := ParseKeyFile(name+".key", name+".private")
+	if err != nil {
+		return nil, err
+	}
+
+	mk := &managedKey{DNSKEY: dk, File: base, Tag: dk.tag, Role: p.role}
+	if p.role != "zsk" {
+		mk.DS = strings.Join(strings.Fields(dk.D.String()), " ")
+	}
+	return mk, nil
+}
+
+// update sets the key set for time now and the metrics with the key states.
+func (m *keyManager) update(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range m.labels {
+		keyStates.DeleteLabelValues(l...)
+	}
+	m.labels = nil
+
+	ks := keySet{}
+	for _, k := range m.keys {
+		st := k.state(now)
+		if st != k.last {
+			if k.last != "" {
+				log.Infof("%s %d of %s is %s", strings.ToUpper(k.Role), k.Tag, m.zone, st)
+			}
+			if st == stateReady && k.DS != "" {
+				log.Infof("Publish the DS record of %s at the parent: %s", m.zone, k.DS)
+			}
+			k.last = st
+		}
+		l := []string{m.zone, strconv.Itoa(int(k.Tag)), k.Role, st, k.DS}
+		keyStates.WithLabelValues(l...).Set(1)
+		m.labels = append(m.labels, l)
+		if st == stateRemoved {
+			continue
+		}
+		ks.publish = append(ks.publish, k.DNSKEY)
+		if k.Role != "zsk" {
+			// A key signing key signs the DNSKEY RRset for as long as it's published, as its DS
+			// may be in caches.
+			ks.dnskey = append(ks.dnskey, k.DNSKEY)
+		}
+		if st == stateActive && (k.Role == "zsk" || m.zsk == nil) {
+			ks.zone = append(ks.zone, k.DNSKEY)
+		}
+	}
+	m.set = ks
+}
+
+// keySet returns the current key set.
+func (m *keyManager) keySet() keySet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.set
+}
+
+// start loads the keys from the key directory and generates the keys that are needed now.
+func (m *keyManager) start() error {
+	if err := m.load(); err != nil {
+		return err
+	}
+	return m.roll(time.Now())
+}
+
+// run rolls the keys every RolloverTickTime, until stop is closed.
+func (m *keyManager) run() {
+	tick := time.NewTicker(RolloverTickTime)
+	go func() {
+		for {
+			select {
+			case <-tick.C:
+				if err := m.roll(time.Now()); err != nil {
+					log.Errorf("Failed to roll the keys of %s: %s", m.zone, err)
+				}
+			case <-m.stop:
+				tick.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// algorithms holds the supported algorithms for generated keys, with their key size.
+var algorithms = map[uint8]int{
+	dns.RSASHA256:       2048,
+	dns.RSASHA512:       2048,
+	dns.ECDSAP256SHA256: 256,
+	dns.ECDSAP384SHA384: 384,
+}
@@ -0,0 +1,122 @@
+package dnssec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const day = 24 * time.Hour
+
+func TestRolloverZSK(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-dnssec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newKeyManager(dir, "example.org.",
+		&keyPolicy{role: "ksk", algorithm: dns.ECDSAP256SHA256},
+		&keyPolicy{role: "zsk", algorithm: dns.ECDSAP256SHA256, lifetime: 30 * day})
+	now := time.Now()
+
+	steps := []struct {
+		at      time.Duration
+		publish int
+		zone    int // index in the generated keys of the key that signs the zone
+	}{
+		{0, 2, 1},
+		{30*day - publishInterval - time.Minute, 2, 1},
+		{30*day - publishInterval, 3, 1}, // successor is published
+		{30 * day, 3, 2},                 // and signs the zone
+		{30*day + zskRetireInterval, 2, 2},
+	}
+	for i, s := range steps {
+		if err := m.roll(now.Add(s.at)); err != nil {
+			t.Fatalf("Step %d: %s", i, err)
+		}
+		ks := m.keySet()
+		if len(ks.publish) != s.publish {
+			t.Errorf("Step %d: expected %d published keys, got %d", i, s.publish, len(ks.publish))
+		}
+		if len(ks.zone) != 1 || ks.zone[0] != m.keys[s.zone].DNSKEY {
+			t.Errorf("Step %d: expected key %d to sign the zone", i, s.zone)
+		}
+		if len(ks.dnskey) != 1 || !ks.dnskey[0].isKSK() {
+			t.Errorf("Step %d: expected the KSK to sign the DNSKEY RRset", i)
+		}
+	}
+
+	// The state survives a restart.
+	m1 := newKeyManager(dir, "example.org.", m.ksk, m.zsk)
+	if err := m1.load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.roll(now.Add(30*day + zskRetireInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if len(m1.keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %d", len(m1.keys))
+	}
+	if ks := m1.keySet(); len(ks.zone) != 1 || ks.zone[0].tag != m.keys[2].Tag {
+		t.Errorf("Expected key %d to sign the zone after a restart", m.keys[2].Tag)
+	}
+}
+
+func TestRolloverCSK(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-dnssec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newKeyManager(dir, "example.org.", &keyPolicy{role: "csk", algorithm: dns.ECDSAP256SHA256, lifetime: 60 * day}, nil)
+	now := time.Now()
+
+	steps := []struct {
+		at     time.Duration
+		dnskey int
+		zone   int
+		state  string // state of the successor
+	}{
+		{0, 1, 0, ""},
+		{60*day - prepublish("csk"), 2, 0, statePublished},
+		{60*day - dsDelay, 2, 0, stateReady}, // DS is to be published
+		{60 * day, 2, 1, stateActive},
+		{60*day + retireInterval("csk"), 1, 1, stateActive},
+	}
+	for i, s := range steps {
+		at := now.Add(s.at)
+		if err := m.roll(at); err != nil {
+			t.Fatalf("Step %d: %s", i, err)
+		}
+		ks := m.keySet()
+		if len(ks.dnskey) != s.dnskey {
+			t.Errorf("Step %d: expected %d keys signing the DNSKEY RRset, got %d", i, s.dnskey, len(ks.dnskey))
+		}
+		if len(ks.zone) != 1 || ks.zone[0] != m.keys[s.zone].DNSKEY {
+			t.Errorf("Step %d: expected key %d to sign the zone", i, s.zone)
+		}
+		if s.state != "" {
+			if x := m.keys[1].state(at); x != s.state {
+				t.Errorf("Step %d: expected the new key to be %s, got %s", i, s.state, x)
+			}
+		}
+	}
+
+	// The DS records are in the state file.
+	buf, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range m.keys {
+		if k.DS == "" || !strings.Contains(string(buf), k.DS) {
+			t.Errorf("Expected the DS record of key %d in the state file", k.Tag)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -13,6 +14,7 @@ import (
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("dnssec")
@@ -25,35 +27,51 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	zones, keys, capacity, splitkeys, err := dnssecParse(c)
+	zones, keys, manager, capacity, splitkeys, err := dnssecParse(c)
 	if err != nil {
 		return plugin.Error("dnssec", err)
 	}
 
 	ca := cache.New(capacity)
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
-		return New(zones, keys, splitkeys, next, ca)
+		d := New(zones, keys, splitkeys, next, ca)
+		d.manager = manager
+		return d
 	})
 
 	c.OnStartup(func() error {
-		metrics.MustRegister(c, cacheSize, cacheHits, cacheMisses)
+		metrics.MustRegister(c, cacheSize, cacheHits, cacheMisses, keyStates)
+		if manager != nil {
+			if err := manager.start(); err != nil {
+				return plugin.Error("dnssec", err)
+			}
+			manager.run()
+		}
 		return nil
 	})
+	if manager != nil {
+		c.OnShutdown(func() error {
+			close(manager.stop)
+			return nil
+		})
+	}
 
 	return nil
 }
 
-func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
+func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, *keyManager, int, bool, error) {
 	zones := []string{}
 
 	keys := []*DNSKEY{}
+	dir := ""
+	var kskPolicy, zskPolicy *keyPolicy
 
 	capacity := defaultCap
 
 	i := 0
 	for c.Next() {
 		if i > 0 {
-			return nil, nil, 0, false, plugin.ErrOnce
+			return nil, nil, nil, 0, false, plugin.ErrOnce
 		}
 		i++
 
@@ -69,23 +87,36 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 
 			switch x := c.Val(); x {
 			case "key":
-				k, e := keyParse(c)
+				k, d, e := keyParse(c)
 				if e != nil {
-					return nil, nil, 0, false, e
+					return nil, nil, nil, 0, false, e
 				}
 				keys = append(keys, k...)
+				if d != "" {
+					dir = d
+				}
+			case "ksk", "zsk":
+				p, e := policyParse(c)
+				if e != nil {
+					return nil, nil, nil, 0, false, e
+				}
+				if x == "ksk" {
+					kskPolicy = p
+				} else {
+					zskPolicy = p
+				}
 			case "cache_capacity":
 				if !c.NextArg() {
-					return nil, nil, 0, false, c.ArgErr()
+					return nil, nil, nil, 0, false, c.ArgErr()
 				}
 				value := c.Val()
 				cacheCap, err := strconv.Atoi(value)
 				if err != nil {
-					return nil, nil, 0, false, err
+					return nil, nil, nil, 0, false, err
 				}
 				capacity = cacheCap
 			default:
-				return nil, nil, 0, false, c.Errf("unknown property '%s'", x)
+				return nil, nil, nil, 0, false, c.Errf("unknown property '%s'", x)
 			}
 
 		}
@@ -94,6 +125,14 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 		zones[i] = plugin.Host(zones[i]).Normalize()
 	}
 
+	manager, err := managerParse(zones, dir, kskPolicy, zskPolicy)
+	if err != nil {
+		return nil, nil, nil, 0, false, err
+	}
+	if manager != nil && len(keys) > 0 {
+		return nil, nil, nil, 0, false, fmt.Errorf("key file and key directory can not be used together")
+	}
+
 	// Check if we have both KSKs and ZSKs.
 	zsk, ksk := 0, 0
 	for _, k := range keys {
@@ -116,25 +155,36 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 			}
 		}
 		if !ok {
-			return zones, keys, capacity, splitkeys, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
+			return zones, keys, manager, capacity, splitkeys, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
 		}
 	}
 
-	return zones, keys, capacity, splitkeys, nil
+	return zones, keys, manager, capacity, splitkeys, nil
 }
 
-func keyParse(c *caddy.Controller) ([]*DNSKEY, error) {
+func keyParse(c *caddy.Controller) ([]*DNSKEY, string, error) {
 	keys := []*DNSKEY{}
 	config := dnsserver.GetConfig(c)
 
 	if !c.NextArg() {
-		return nil, c.ArgErr()
+		return nil, "", c.ArgErr()
 	}
 	value := c.Val()
+	if value == "directory" {
+		ds := c.RemainingArgs()
+		if len(ds) != 1 {
+			return nil, "", c.ArgErr()
+		}
+		dir := ds[0]
+		if !filepath.IsAbs(dir) && config.Root != "" {
+			dir = filepath.Join(config.Root, dir)
+		}
+		return nil, dir, nil
+	}
 	if value == "file" {
 		ks := c.RemainingArgs()
 		if len(ks) == 0 {
-			return nil, c.ArgErr()
+			return nil, "", c.ArgErr()
 		}
 
 		for _, k := range ks {
@@ -151,10 +201,81 @@ func keyParse(c *caddy.Controller) ([]*DNSKEY, error) {
 			}
 			k, err := ParseKeyFile(base+".key", base+".private")
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
 			keys = append(keys, k)
 		}
 	}
-	return keys, nil
+	return keys, "", nil
+}
+
+// policyParse parses the ALGORITHM and optional LIFETIME of a ksk or zsk property.
+func policyParse(c *caddy.Controller) (*keyPolicy, error) {
+	role := c.Val()
+	args := c.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return nil, c.ArgErr()
+	}
+	alg, ok := dns.StringToAlgorithm[strings.ToUpper(args[0])]
+	if _, supported := algorithms[alg]; !ok || !supported {
+		return nil, c.Errf("unsupported algorithm for %s: %s", role, args[0])
+	}
+	p := &keyPolicy{role: role, algorithm: alg}
+	if len(args) == 2 {
+		d, err := parseLifetime(args[1])
+		if err != nil {
+			return nil, c.Errf("invalid lifetime for %s: %s", role, args[1])
+		}
+		p.lifetime = d
+	}
+	return p, nil
+}
+
+// parseLifetime parses a duration, which may also be given in days, e.g. "90d".
+func parseLifetime(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
+
+// managerParse returns the key manager for the keys in dir, which is nil if dir is empty. The keys
+// are loaded and generated when the manager is started.
+func managerParse(zones []string, dir string, ksk, zsk *keyPolicy) (*keyManager, error) {
+	if dir == "" {
+		if ksk != nil || zsk != nil {
+			return nil, fmt.Errorf("ksk and zsk can only be used with key directory")
+		}
+		return nil, nil
+	}
+	if len(zones) != 1 {
+		return nil, fmt.Errorf("key directory needs exactly one zone, got %d", len(zones))
+	}
+
+	if ksk == nil {
+		ksk = &keyPolicy{algorithm: dns.ECDSAP256SHA256}
+	}
+	ksk.role = "ksk"
+	if zsk == nil {
+		// Without zone signing keys, the key signing key signs the zone as well.
+		ksk.role = "csk"
+	}
+	for _, p := range []*keyPolicy{ksk, zsk} {
+		if p == nil || p.lifetime == 0 {
+			continue
+		}
+		if min := prepublish(p.role) + retireInterval(p.role); p.lifetime < min {
+			return nil, fmt.Errorf("lifetime of %s must be at least %s", p.role, min)
+		}
+	}
+
+	return newKeyManager(dir, zones[0], ksk, zsk), nil
 }
@@ -82,7 +82,7 @@ func TestSetupDnssec(t *testing.T) {
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		zones, keys, capacity, splitkeys, err := dnssecParse(c)
+		zones, keys, _, capacity, splitkeys, err := dnssecParse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found %s for input %s", i, err, test.input)
@@ -159,3 +159,75 @@ Created: 20170901060531
 Publish: 20170901060531
 Activate: 20170901060531
 `
+
+func TestSetupKeyDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-dnssec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		keys      int
+	}{
+		{`dnssec example.org {
+			key directory ` + dir + `
+		}`, false, 1},
+		{`dnssec example.org {
+			key directory ` + dir + `
+			ksk ECDSAP384SHA384 365d
+			zsk ECDSAP256SHA256 720h
+		}`, false, 3}, // the CSK generated before is retired
+		// fails
+		{`dnssec example.org {
+			zsk ECDSAP256SHA256
+		}`, true, 0},
+		{`dnssec example.org {
+			key directory ` + dir + `
+			zsk ED448
+		}`, true, 0},
+		{`dnssec example.org {
+			key directory ` + dir + `
+			zsk ECDSAP256SHA256 1d
+		}`, true, 0},
+		{`dnssec example.org {
+			key directory ` + dir + `
+			zsk ECDSAP256SHA256 lifetime
+		}`, true, 0},
+		{`dnssec example.org {
+			key directory
+		}`, true, 0},
+		{`dnssec example.org example.net {
+			key directory ` + dir + `
+		}`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		_, _, m, _, _, err := dnssecParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil for input %s", i, test.input)
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+		}
+		if test.shouldErr || err != nil {
+			continue
+		}
+		if i == 0 {
+			// Keys are generated on startup, not while parsing.
+			if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+				t.Errorf("Test %d: Expected no files in the key directory after parsing, got %d", i, len(files))
+			}
+		}
+		if err := m.start(); err != nil {
+			t.Fatalf("Test %d: Expected no error starting the key manager, got %s", i, err)
+		}
+		if len(m.keys) != test.keys {
+			t.Errorf("Test %d: Expected %d keys, got %d", i, test.keys, len(m.keys))
+		}
+	}
+}
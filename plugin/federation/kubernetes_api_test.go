@@ -18,6 +18,11 @@ func (APIConnFederationTest) Stop() error                               { return
 func (APIConnFederationTest) SvcIndexReverse(string) []*object.Service  { return nil }
 func (APIConnFederationTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnFederationTest) Modified() int64                           { return 0 }
+func (APIConnFederationTest) ServiceImportList() []*object.Service      { return nil }
+func (APIConnFederationTest) SvcImportIndex(string) []*object.Service   { return nil }
+func (APIConnFederationTest) McEpIndex(string) []*object.Endpoints      { return nil }
+func (APIConnFederationTest) NodeIndex(string) []*object.Node           { return nil }
+func (APIConnFederationTest) HostIndex(string) []*object.Ingress        { return nil }
 
 func (APIConnFederationTest) PodIndex(string) []*object.Pod {
 	return []*object.Pod{
@@ -0,0 +1,106 @@
+package file
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// journal holds the differences between consecutive versions of a zone. It is used to answer IXFR
+// requests (RFC 1995) with only the changes since the serial the client has. The journal is bounded,
+// when it is full the oldest difference is dropped.
+type journal struct {
+	sync.RWMutex
+	size  int
+	diffs []*diff // oldest first
+}
+
+// diff holds the records that were deleted and added to go from the zone version with SOA from to
+// the version with SOA to.
+type diff struct {
+	from, to *dns.SOA
+	del, add []dns.RR
+}
+
+// defaultJournalSize is the number of differences kept when not configured otherwise.
+const defaultJournalSize = 10
+
+func newJournal(size int) *journal { return &journal{size: size} }
+
+// add adds d to the journal. If d does not follow the last difference in the journal, the journal
+// is reset as older differences can not be used anymore.
+func (j *journal) add(d *diff) {
+	if j == nil || j.size <= 0 {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+
+	if l := len(j.diffs); l > 0 && j.diffs[l-1].to.Serial != d.from.Serial {
+		j.diffs = nil
+	}
+	j.diffs = append(j.diffs, d)
+	if len(j.diffs) > j.size {
+		j.diffs = j.diffs[len(j.diffs)-j.size:]
+	}
+}
+
+// since returns the differences needed to go from serial to serial to. The boolean is false when
+// the journal does not hold (all of) them.
+func (j *journal) since(serial, to uint32) ([]*diff, bool) {
+	if j == nil {
+		return nil, false
+	}
+	j.RLock()
+	defer j.RUnlock()
+
+	for i, d := range j.diffs {
+		if d.from.Serial != serial {
+			continue
+		}
+		diffs := j.diffs[i:]
+		if diffs[len(diffs)-1].to.Serial != to {
+			return nil, false
+		}
+		return diffs, true
+	}
+	return nil, false
+}
+
+// ixfr returns the diffs as a sequence of records as used in an IXFR reply: for each difference
+// the old SOA, the deleted records, the new SOA and the added records.
+func ixfr(diffs []*diff) []dns.RR {
+	rrs := []dns.RR{}
+	for _, d := range diffs {
+		rrs = append(rrs, d.from)
+		rrs = append(rrs, d.del...)
+		rrs = append(rrs, d.to)
+		rrs = append(rrs, d.add...)
+	}
+	return rrs
+}
+
+// newDiff returns the difference between two versions of a zone. The first record of both before and
+// after must be the SOA record, as returned by Zone.All.
+func newDiff(before, after []dns.RR) *diff {
+	d := &diff{from: before[0].(*dns.SOA), to: after[0].(*dns.SOA)}
+
+	seen := make(map[string]struct{}, len(before))
+	for _, rr := range before[1:] {
+		seen[rr.String()] = struct{}{}
+	}
+	kept := make(map[string]struct{}, len(after))
+	for _, rr := range after[1:] {
+		s := rr.String()
+		kept[s] = struct{}{}
+		if _, ok := seen[s]; !ok {
+			d.add = append(d.add, rr)
+		}
+	}
+	for _, rr := range before[1:] {
+		if _, ok := kept[rr.String()]; !ok {
+			d.del = append(d.del, rr)
+		}
+	}
+	return d
+}
@@ -0,0 +1,145 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestJournalDiff(t *testing.T) {
+	z1, err := Parse(strings.NewReader(journalZone1), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z2, err := Parse(strings.NewReader(journalZone2), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDiff(z1.All(), z2.All())
+	if d.from.Serial != 1 || d.to.Serial != 2 {
+		t.Errorf("Expected diff from serial 1 to 2, got %d to %d", d.from.Serial, d.to.Serial)
+	}
+	if len(d.del) != 1 || d.del[0].Header().Name != "b.example.org." {
+		t.Errorf("Expected b.example.org. to be deleted, got %v", d.del)
+	}
+	if len(d.add) != 2 {
+		t.Errorf("Expected 2 records to be added, got %v", d.add)
+	}
+}
+
+func TestJournalSince(t *testing.T) {
+	j := newJournal(2)
+	j.add(testDiff(1, 2))
+	j.add(testDiff(2, 3))
+	j.add(testDiff(3, 4))
+
+	if _, ok := j.since(1, 4); ok {
+		t.Errorf("Expected serial 1 to be dropped from the journal")
+	}
+	diffs, ok := j.since(2, 4)
+	if !ok || len(diffs) != 2 {
+		t.Errorf("Expected 2 diffs since serial 2, got %d", len(diffs))
+	}
+	if _, ok := j.since(3, 5); ok {
+		t.Errorf("Expected no diffs up to serial 5")
+	}
+
+	// A gap resets the journal.
+	j.add(testDiff(10, 11))
+	if _, ok := j.since(3, 4); ok {
+		t.Errorf("Expected the journal to be reset")
+	}
+	if _, ok := j.since(10, 11); !ok {
+		t.Errorf("Expected diff since serial 10")
+	}
+
+	// A disabled journal holds nothing.
+	j = newJournal(0)
+	j.add(testDiff(1, 2))
+	if _, ok := j.since(1, 2); ok {
+		t.Errorf("Expected a disabled journal to be empty")
+	}
+}
+
+func TestZoneTransferIXFR(t *testing.T) {
+	z, err := Parse(strings.NewReader(journalZone1), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z2, err := Parse(strings.NewReader(journalZone2), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.journal.add(newDiff(z.All(), z2.All()))
+	z.Tree, z.Apex = z2.Tree, z2.Apex
+
+	ch, err := z.Transfer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rrs := []dns.RR{}
+	for r := range ch {
+		rrs = append(rrs, r...)
+	}
+
+	// SOA(2), SOA(1), deleted b, SOA(2), added c and d.
+	expected := []struct {
+		name   string
+		typ    uint16
+		serial uint32
+	}{
+		{"example.org.", dns.TypeSOA, 2},
+		{"example.org.", dns.TypeSOA, 1},
+		{"b.example.org.", dns.TypeA, 0},
+		{"example.org.", dns.TypeSOA, 2},
+		{"c.example.org.", dns.TypeA, 0},
+		{"d.example.org.", dns.TypeA, 0},
+	}
+	if len(rrs) != len(expected) {
+		t.Fatalf("Expected %d records, got %d: %v", len(expected), len(rrs), rrs)
+	}
+	for i, e := range expected {
+		if rrs[i].Header().Name != e.name || rrs[i].Header().Rrtype != e.typ {
+			t.Errorf("Record %d: expected %s %s, got %s", i, e.name, dns.TypeToString[e.typ], rrs[i])
+		}
+		if soa, ok := rrs[i].(*dns.SOA); ok && soa.Serial != e.serial {
+			t.Errorf("Record %d: expected serial %d, got %d", i, e.serial, soa.Serial)
+		}
+	}
+
+	// A serial the journal does not know about gets the full zone.
+	ch, _ = z.Transfer(0xFFFFFFF0)
+	rrs = []dns.RR{}
+	for r := range ch {
+		rrs = append(rrs, r...)
+	}
+	if len(rrs) != len(z.All()) {
+		t.Errorf("Expected full zone with %d records, got %d", len(z.All()), len(rrs))
+	}
+}
+
+func testDiff(from, to uint32) *diff {
+	return &diff{
+		from: &dns.SOA{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeSOA, Class: dns.ClassINET}, Serial: from},
+		to:   &dns.SOA{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeSOA, Class: dns.ClassINET}, Serial: to},
+	}
+}
+
+const journalZone1 = `$ORIGIN example.org.
+@	3600	IN	SOA	ns.example.org. hostmaster.example.org. 1 7200 1800 86400 100
+@	3600	IN	NS	ns.example.org.
+ns	3600	IN	A	127.0.0.1
+a	3600	IN	A	127.0.0.2
+b	3600	IN	A	127.0.0.3
+`
+
+const journalZone2 = `$ORIGIN example.org.
+@	3600	IN	SOA	ns.example.org. hostmaster.example.org. 2 7200 1800 86400 100
+@	3600	IN	NS	ns.example.org.
+ns	3600	IN	A	127.0.0.1
+a	3600	IN	A	127.0.0.2
+c	3600	IN	A	127.0.0.4
+d	3600	IN	A	127.0.0.5
+`
@@ -141,6 +141,10 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 			glue := z.Glue(nsrrs, do)
 			if do {
 				dss := z.typeFromElem(elem, dns.TypeDS, do)
+				if len(dss) == 0 && z.nsec3.Len() > 0 {
+					// Insecure delegation, prove there is no DS.
+					dss = z.nsec3Match(elem.Name(), do)
+				}
 				nsrrs = append(nsrrs, dss...)
 			}
 
@@ -168,7 +172,7 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 		if len(rrs) == 0 {
 			ret := z.soa(do)
 			if do {
-				nsec := z.typeFromElem(elem, dns.TypeNSEC, do)
+				nsec := z.denyNoData(elem, do)
 				ret = append(ret, nsec...)
 			}
 			return nil, ret, nil, NoData
@@ -203,7 +207,9 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 		// NODATA response.
 		if len(rrs) == 0 {
 			ret := z.soa(do)
-			if do {
+			if do && z.nsec3.Len() > 0 {
+				ret = append(ret, z.nsec3Wildcard(qname, wildElem, true, do)...)
+			} else if do {
 				nsec := z.typeFromElem(wildElem, dns.TypeNSEC, do)
 				ret = append(ret, nsec...)
 			}
@@ -212,7 +218,9 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 
 		if do {
 			// An NSEC is needed to say no longer name exists under this wildcard.
-			if deny, found := z.Tree.Prev(qname); found {
+			if z.nsec3.Len() > 0 {
+				auth = append(auth, z.nsec3Wildcard(qname, wildElem, false, do)...)
+			} else if deny, found := z.Tree.Prev(qname); found {
 				nsec := z.typeFromElem(deny, dns.TypeNSEC, do)
 				auth = append(auth, nsec...)
 			}
@@ -236,7 +244,14 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 	}
 
 	ret := z.soa(do)
-	if do {
+	if do && z.nsec3.Len() > 0 {
+		// An empty-non-terminal has an NSEC3 record of its own.
+		if rcode == NameError {
+			ret = append(ret, z.nsec3Deny(qname, do)...)
+		} else {
+			ret = append(ret, z.nsec3Match(qname, do)...)
+		}
+	} else if do {
 		deny, found := z.Tree.Prev(qname)
 		if !found {
 			goto Out
@@ -0,0 +1,123 @@
+package file
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// The NSEC3 records and their signatures are kept in their own tree, keyed by the hashed owner name. They
+// don't take part in the normal lookup, they are only used to deny the existence of names and types.
+
+// isNSEC3 returns true if rr belongs in the NSEC3 tree: an NSEC3 record or a signature over one.
+func isNSEC3(rr dns.RR) bool {
+	switch x := rr.(type) {
+	case *dns.NSEC3:
+		return true
+	case *dns.RRSIG:
+		return x.TypeCovered == dns.TypeNSEC3
+	}
+	return false
+}
+
+// hashName returns the hashed owner name of name, using the parameters of the NSEC3 chain of the zone.
+func (z *Zone) hashName(name string) string {
+	nsec3 := z.nsec3.Min().Types(dns.TypeNSEC3)[0].(*dns.NSEC3)
+	return strings.ToLower(dns.HashName(name, nsec3.Hash, nsec3.Iterations, nsec3.Salt)) + "." + z.origin
+}
+
+// nsec3Match returns the NSEC3 record, and its signatures when do is true, that matches name.
+func (z *Zone) nsec3Match(name string, do bool) []dns.RR {
+	elem, found := z.nsec3.Search(z.hashName(name))
+	if !found {
+		return nil
+	}
+	return z.typeFromElem(elem, dns.TypeNSEC3, do)
+}
+
+// nsec3Cover returns the NSEC3 record, and its signatures when do is true, that covers name.
+func (z *Zone) nsec3Cover(name string, do bool) []dns.RR {
+	elem, found := z.nsec3.Prev(z.hashName(name))
+	if !found {
+		// Smaller than the first hash, this is covered by the last NSEC3 record in the chain.
+		elem = z.nsec3.Max()
+	}
+	return z.typeFromElem(elem, dns.TypeNSEC3, do)
+}
+
+// nsec3ClosestEncloser returns the closest encloser of qname, i.e. the longest existing ancestor,
+// which may be an empty-non-terminal, and the next closer name; see RFC 5155, Section 7.2.1.
+func (z *Zone) nsec3ClosestEncloser(qname string) (string, string) {
+	next := qname
+	for name := qname; dns.IsSubDomain(z.origin, name); {
+		if _, found := z.nsec3.Search(z.hashName(name)); found {
+			return name, next
+		}
+		next = name
+		off, end := dns.NextLabel(name, 0)
+		if end {
+			break
+		}
+		name = name[off:]
+	}
+	return z.origin, next
+}
+
+// nsec3Deny returns the NSEC3 records that prove qname doesn't exist: the closest encloser proof and
+// the record covering the wildcard at the closest encloser; see RFC 5155, Section 7.2.2.
+func (z *Zone) nsec3Deny(qname string, do bool) []dns.RR {
+	ce, next := z.nsec3ClosestEncloser(qname)
+	return z.nsec3Unique(
+		z.nsec3Match(ce, do),
+		z.nsec3Cover(next, do),
+		z.nsec3Cover("*."+ce, do),
+	)
+}
+
+// nsec3Wildcard returns the NSEC3 records that prove that no closer match than wildcard exists for
+// qname. For a NODATA response the record matching the wildcard is added as well; see RFC 5155,
+// Sections 7.2.5 and 7.2.6.
+func (z *Zone) nsec3Wildcard(qname string, wildcard *tree.Elem, nodata, do bool) []dns.RR {
+	ce := wildcard.Name()[2:] // strip "*."
+	next := qname
+	for {
+		off, end := dns.NextLabel(next, 0)
+		if end || dns.CountLabel(next[off:]) <= dns.CountLabel(ce) {
+			break
+		}
+		next = next[off:]
+	}
+	if !nodata {
+		return z.nsec3Cover(next, do)
+	}
+	return z.nsec3Unique(
+		z.nsec3Match(ce, do),
+		z.nsec3Cover(next, do),
+		z.nsec3Match(wildcard.Name(), do),
+	)
+}
+
+// nsec3Unique concatenates the sets of records, leaving out sets that are already added.
+func (z *Zone) nsec3Unique(sets ...[]dns.RR) []dns.RR {
+	var rrs []dns.RR
+	seen := map[string]bool{}
+	for _, set := range sets {
+		if len(set) == 0 || seen[set[0].Header().Name] {
+			continue
+		}
+		seen[set[0].Header().Name] = true
+		rrs = append(rrs, set...)
+	}
+	return rrs
+}
+
+// denyNoData returns the NSEC or NSEC3 records, and their signatures when do is true, that show the
+// types that exist at the name of elem.
+func (z *Zone) denyNoData(elem *tree.Elem, do bool) []dns.RR {
+	if z.nsec3.Len() > 0 {
+		return z.nsec3Match(elem.Name(), do)
+	}
+	return z.typeFromElem(elem, dns.TypeNSEC, do)
+}
@@ -6,16 +6,26 @@ import (
 )
 
 func TestParseNSEC3PARAM(t *testing.T) {
-	_, err := Parse(strings.NewReader(nsec3paramTest), "miek.nl", "stdin", 0)
-	if err == nil {
-		t.Fatalf("Expected error when reading zone, got nothing")
+	z, err := Parse(strings.NewReader(nsec3paramTest), "miek.nl", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	if _, found := z.Tree.Search("miek.nl."); !found {
+		t.Fatalf("Expected the NSEC3PARAM record at the apex")
 	}
 }
 
 func TestParseNSEC3(t *testing.T) {
-	_, err := Parse(strings.NewReader(nsec3Test), "miek.nl", "stdin", 0)
-	if err == nil {
-		t.Fatalf("Expected error when reading zone, got nothing")
+	z, err := Parse(strings.NewReader(nsec3Test), "example.org", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	// The NSEC3 record and its signature are kept out of the tree.
+	if z.Tree.Len() != 0 {
+		t.Errorf("Expected no records in the tree, got %d", z.Tree.Len())
+	}
+	if x := len(z.All()); x != 3 {
+		t.Errorf("Expected 3 records in the zone, got %d", x)
 	}
 }
 
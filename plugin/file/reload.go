@@ -36,6 +36,12 @@ func (z *Zone) Reload() error {
 				}
 
 				serial := z.SOASerialIfDefined()
+				if z.sign != nil {
+					// The served zone has the serial of the signed zone.
+					z.updateMu.Lock()
+					serial = int64(z.sign.serial)
+					z.updateMu.Unlock()
+				}
 				zone, err := Parse(reader, z.origin, zFile, serial)
 				if err != nil {
 					if _, ok := err.(*serialErr); !ok {
@@ -44,10 +50,20 @@ func (z *Zone) Reload() error {
 					continue
 				}
 
+				if z.sign != nil {
+					if err := z.resign(zone.All(), time.Now()); err != nil {
+						log.Errorf("Failed to sign zone %q: %v", z.origin, err)
+					}
+					continue
+				}
+
+				z.journal.add(newDiff(z.All(), zone.All()))
+
 				// copy elements we need
 				z.reloadMu.Lock()
 				z.Apex = zone.Apex
 				z.Tree = zone.Tree
+				z.nsec3 = zone.nsec3
 				z.reloadMu.Unlock()
 
 				log.Infof("Successfully reloaded zone %q in %q with serial %d", z.origin, zFile, z.Apex.SOA.Serial)
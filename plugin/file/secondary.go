@@ -1,61 +1,230 @@
 package file
 
 import (
+	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
 )
 
-// TransferIn retrieves the zone from the masters, parses it and sets it live.
+// TransferIn retrieves the zone from the primaries, parses it and sets it live. If we already have
+// a version of the zone, an IXFR is tried first. When that fails (or is refused) a full AXFR is
+// done. The primaries are tried in order until one succeeds.
 func (z *Zone) TransferIn() error {
 	if len(z.TransferFrom) == 0 {
 		return nil
 	}
+
+	var Err error
+	for _, tr := range z.TransferFrom {
+		if serial := z.SOASerialIfDefined(); serial >= 0 {
+			err := z.transferInIxfr(tr, uint32(serial))
+			if err == nil {
+				return nil
+			}
+			log.Warningf("Failed to IXFR `%s' from %q, trying AXFR: %v", z.origin, tr, err)
+		}
+
+		Err = z.transferInAxfr(tr)
+		if Err == nil {
+			return nil
+		}
+	}
+	return Err
+}
+
+// transferInAxfr retrieves the full zone from primary tr.
+func (z *Zone) transferInAxfr(tr string) error {
 	m := new(dns.Msg)
 	m.SetAxfr(z.origin)
 
+	rrs, err := z.transferInRecords(m, tr)
+	if err != nil {
+		return err
+	}
+
 	z1 := z.CopyWithoutApex()
-	var (
-		Err error
-		tr  string
-	)
+	for _, rr := range rrs {
+		if err := z1.Insert(rr); err != nil {
+			log.Errorf("Failed to parse transfer `%s' from: %q: %v", z.origin, tr, err)
+			return err
+		}
+	}
 
-Transfer:
-	for _, tr = range z.TransferFrom {
-		t := new(dns.Transfer)
-		c, err := t.In(m, tr)
-		if err != nil {
-			log.Errorf("Failed to setup transfer `%s' with `%q': %v", z.origin, tr, err)
-			Err = err
-			continue Transfer
+	z.swap(z1, nil)
+	log.Infof("Transferred: %s from %s", z.origin, tr)
+	return nil
+}
+
+// transferInIxfr retrieves the changes since serial from primary tr and applies them. A primary
+// that replies with the full zone is handled as if it replied to an AXFR.
+func (z *Zone) transferInIxfr(tr string, serial uint32) error {
+	m := new(dns.Msg)
+	m.SetIxfr(z.origin, serial, z.Apex.SOA.Ns, z.Apex.SOA.Mbox)
+
+	rrs, err := z.transferInRecords(m, tr)
+	if err != nil {
+		return err
+	}
+
+	soa, ok := rrs[0].(*dns.SOA)
+	if !ok {
+		return fmt.Errorf("IXFR reply does not start with a SOA record")
+	}
+	if len(rrs) == 1 {
+		if less(serial, soa.Serial) {
+			return fmt.Errorf("IXFR reply has newer serial %d but no changes", soa.Serial)
 		}
-		for env := range c {
-			if env.Error != nil {
-				log.Errorf("Failed to transfer `%s' from %q: %v", z.origin, tr, env.Error)
-				Err = env.Error
-				continue Transfer
-			}
-			for _, rr := range env.RR {
-				if err := z1.Insert(rr); err != nil {
-					log.Errorf("Failed to parse transfer `%s' from: %q: %v", z.origin, tr, err)
-					Err = err
-					continue Transfer
-				}
+		return nil // up to date
+	}
+
+	z1 := z.CopyWithoutApex()
+
+	if _, ok := rrs[1].(*dns.SOA); !ok {
+		// AXFR style reply, i.e. the full zone.
+		for _, rr := range rrs[:len(rrs)-1] {
+			if err := z1.Insert(rr); err != nil {
+				return err
 			}
 		}
-		Err = nil
-		break
+		z.swap(z1, nil)
+		log.Infof("Transferred: %s from %s (full zone in IXFR)", z.origin, tr)
+		return nil
+	}
+
+	diffs, err := parseIxfr(rrs)
+	if err != nil {
+		return err
+	}
+	if diffs[0].from.Serial != serial {
+		return fmt.Errorf("IXFR reply starts at serial %d, we have %d", diffs[0].from.Serial, serial)
+	}
+
+	// Apply all differences to the records we have now and load the result into a new zone.
+	records := make(map[string]dns.RR)
+	for _, rr := range z.All()[1:] {
+		records[rrKey(rr)] = rr
+	}
+	for _, d := range diffs {
+		for _, rr := range d.del {
+			delete(records, rrKey(rr))
+		}
+		for _, rr := range d.add {
+			records[rrKey(rr)] = rr
+		}
+	}
+
+	if err := z1.Insert(soa); err != nil {
+		return err
+	}
+	for _, rr := range records {
+		if err := z1.Insert(rr); err != nil {
+			return err
+		}
+	}
+
+	z.swap(z1, diffs)
+	log.Infof("Transferred: %s from %s (%d changes)", z.origin, tr, len(diffs))
+	return nil
+}
+
+// transferInRecords sends the transfer request m to tr and returns all records received.
+func (z *Zone) transferInRecords(m *dns.Msg, tr string) ([]dns.RR, error) {
+	t := new(dns.Transfer)
+	if z.TransferTimeout > 0 {
+		t.DialTimeout = z.TransferTimeout
+		t.ReadTimeout = z.TransferTimeout
+		t.WriteTimeout = z.TransferTimeout
+	}
+	if k := z.TransferTsig; k != nil {
+		t.TsigSecret = k.Secrets()
+		k.Sign(m)
+	}
+	c, err := t.In(m, tr)
+	if err != nil {
+		log.Errorf("Failed to setup transfer `%s' with `%q': %v", z.origin, tr, err)
+		return nil, err
 	}
-	if Err != nil {
-		return Err
+
+	rrs := []dns.RR{}
+	for env := range c {
+		if env.Error != nil {
+			log.Errorf("Failed to transfer `%s' from %q: %v", z.origin, tr, env.Error)
+			return nil, env.Error
+		}
+		rrs = append(rrs, env.RR...)
+	}
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("empty transfer of `%s' from %q", z.origin, tr)
 	}
+	return rrs, nil
+}
 
+// swap sets the contents of z1 live in z and adds diffs to the journal. If diffs is nil and z
+// already had a version of the zone, the difference between the two is added instead.
+func (z *Zone) swap(z1 *Zone, diffs []*diff) {
+	if diffs == nil && z.Apex.SOA != nil && z1.Apex.SOA != nil {
+		diffs = []*diff{newDiff(z.All(), z1.All())}
+	}
+
+	z.reloadMu.Lock()
 	z.Tree = z1.Tree
 	z.Apex = z1.Apex
+	z.nsec3 = z1.nsec3
+	z.reloadMu.Unlock()
 	*z.Expired = false
-	log.Infof("Transferred: %s from %s", z.origin, tr)
-	return nil
+
+	for _, d := range diffs {
+		z.journal.add(d)
+	}
+}
+
+// parseIxfr parses an incremental IXFR reply into its differences. The reply starts and ends with the
+// current SOA, in between are sequences of the old SOA, deleted records, the new SOA and the added records.
+func parseIxfr(rrs []dns.RR) ([]*diff, error) {
+	if len(rrs) < 3 {
+		return nil, fmt.Errorf("IXFR reply too short")
+	}
+	var (
+		diffs []*diff
+		d     *diff
+	)
+	for _, rr := range rrs[1 : len(rrs)-1] {
+		soa, ok := rr.(*dns.SOA)
+		switch {
+		case !ok && d == nil:
+			return nil, fmt.Errorf("IXFR reply has records before the first sequence")
+		case ok && (d == nil || d.to != nil):
+			d = &diff{from: soa}
+			diffs = append(diffs, d)
+		case ok:
+			d.to = soa
+		case d.to == nil:
+			d.del = append(d.del, rr)
+		default:
+			d.add = append(d.add, rr)
+		}
+	}
+	for _, d := range diffs {
+		if d.to == nil {
+			return nil, fmt.Errorf("IXFR reply has incomplete sequence for serial %d", d.from.Serial)
+		}
+	}
+	if diffs[len(diffs)-1].to.Serial != rrs[0].(*dns.SOA).Serial {
+		return nil, fmt.Errorf("IXFR reply does not end at serial %d", rrs[0].(*dns.SOA).Serial)
+	}
+	return diffs, nil
+}
+
+// rrKey returns a key to compare records received in a transfer with records in a zone. Names are
+// lowercased when inserted in a zone, so the key is lowercased as well. The TTL is not part of the
+// key, a deletion matches a record regardless of its TTL.
+func rrKey(rr dns.RR) string {
+	rr = dns.Copy(rr)
+	rr.Header().Ttl = 0
+	return strings.ToLower(rr.String())
 }
 
 // shouldTransfer checks the primaries of zone, retrieves the SOA record, checks the current serial
@@ -63,8 +232,12 @@ Transfer:
 func (z *Zone) shouldTransfer() (bool, error) {
 	c := new(dns.Client)
 	c.Net = "tcp" // do this query over TCP to minimize spoofing
+	c.Timeout = z.TransferTimeout
 	m := new(dns.Msg)
 	m.SetQuestion(z.origin, dns.TypeSOA)
+	if k := z.TransferTsig; k != nil {
+		c.TsigSecret = k.Secrets()
+	}
 
 	var Err error
 	serial := -1
@@ -72,7 +245,13 @@ func (z *Zone) shouldTransfer() (bool, error) {
 Transfer:
 	for _, tr := range z.TransferFrom {
 		Err = nil
-		ret, _, err := c.Exchange(m, tr)
+		mm := m
+		if k := z.TransferTsig; k != nil {
+			// Writing a signed message strips its TSIG record, sign a copy for each primary.
+			mm = m.Copy()
+			k.Sign(mm)
+		}
+		ret, _, err := c.Exchange(mm, tr)
 		if err != nil || ret.Rcode != dns.RcodeSuccess {
 			Err = err
 			continue
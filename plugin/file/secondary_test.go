@@ -2,9 +2,13 @@ package file
 
 import (
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/plugin/tsig"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -112,6 +116,40 @@ func TestShouldTransfer(t *testing.T) {
 	}
 }
 
+func TestShouldTransferSigned(t *testing.T) {
+	var signed int32
+	dns.HandleFunc(testZone, func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.IsTsig() != nil {
+			atomic.AddInt32(&signed, 1)
+		}
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(testZone)
+
+	s1, addr1, err := test.TCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer s1.Shutdown()
+	s2, addr2, err := test.TCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer s2.Shutdown()
+
+	z := new(Zone)
+	z.origin = testZone
+	z.TransferFrom = []string{addr1, addr2}
+	z.TransferTsig = &tsig.Key{Name: "key.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"}
+
+	z.shouldTransfer()
+	if n := atomic.LoadInt32(&signed); n != 2 {
+		t.Fatalf("Expected both primaries to get a signed query, got %d", n)
+	}
+}
+
 func TestTransferIn(t *testing.T) {
 	soa := soa{250}
 
@@ -162,3 +200,159 @@ func newRequest(zone string, qtype uint16) request.Request {
 	m.SetEdns0(4097, true)
 	return request.Request{W: &test.ResponseWriter{}, Req: m}
 }
+
+// ixfrPrimary is a primary that answers IXFR requests for serial 250 with a single change to serial 251,
+// and refuses them when refuse is set.
+type ixfrPrimary struct {
+	refuse bool
+}
+
+func (x *ixfrPrimary) Handler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	soa250 := test.SOA(fmt.Sprintf("%s IN SOA bla. bla. 250 0 0 0 0 ", testZone))
+	soa251 := test.SOA(fmt.Sprintf("%s IN SOA bla. bla. 251 0 0 0 0 ", testZone))
+	switch req.Question[0].Qtype {
+	case dns.TypeIXFR:
+		if x.refuse {
+			m.Rcode = dns.RcodeRefused
+			break
+		}
+		m.Answer = []dns.RR{
+			soa251,
+			soa250,
+			test.A(fmt.Sprintf("a.%s IN A 127.0.0.1", testZone)),
+			soa251,
+			test.A(fmt.Sprintf("b.%s IN A 127.0.0.2", testZone)),
+			soa251,
+		}
+	case dns.TypeAXFR:
+		m.Answer = []dns.RR{
+			soa251,
+			test.A(fmt.Sprintf("c.%s IN A 127.0.0.3", testZone)),
+			soa251,
+		}
+	}
+	w.WriteMsg(m)
+}
+
+func newSecondaryZone(t *testing.T, primaries ...string) *Zone {
+	z, err := Parse(strings.NewReader(fmt.Sprintf(`$ORIGIN %s
+@	IN	SOA	bla. bla. 250 0 0 0 0
+a	IN	A	127.0.0.1
+`, testZone)), testZone, "stdin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.TransferFrom = primaries
+	z.TransferTimeout = 500 * time.Millisecond
+	return z
+}
+
+func TestTransferInIxfr(t *testing.T) {
+	x := &ixfrPrimary{}
+	dns.HandleFunc(testZone, x.Handler)
+	defer dns.HandleRemove(testZone)
+
+	s, addrstr, err := test.TCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer s.Shutdown()
+
+	z := newSecondaryZone(t, addrstr)
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Unable to run TransferIn: %v", err)
+	}
+	if z.Apex.SOA.Serial != 251 {
+		t.Fatalf("Expected serial 251, got %d", z.Apex.SOA.Serial)
+	}
+	if _, ok := z.Tree.Search("a." + testZone); ok {
+		t.Errorf("Expected a.%s to be deleted", testZone)
+	}
+	if _, ok := z.Tree.Search("b." + testZone); !ok {
+		t.Errorf("Expected b.%s to be added", testZone)
+	}
+	if _, ok := z.journal.since(250, 251); !ok {
+		t.Errorf("Expected the change to be in the journal")
+	}
+}
+
+func TestTransferInIxfrRefused(t *testing.T) {
+	x := &ixfrPrimary{refuse: true}
+	dns.HandleFunc(testZone, x.Handler)
+	defer dns.HandleRemove(testZone)
+
+	s, addrstr, err := test.TCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer s.Shutdown()
+
+	z := newSecondaryZone(t, addrstr)
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Unable to run TransferIn: %v", err)
+	}
+	if z.Apex.SOA.Serial != 251 {
+		t.Fatalf("Expected serial 251, got %d", z.Apex.SOA.Serial)
+	}
+	// AXFR replaced the zone.
+	if _, ok := z.Tree.Search("c." + testZone); !ok {
+		t.Errorf("Expected c.%s from the AXFR", testZone)
+	}
+	if _, ok := z.Tree.Search("a." + testZone); ok {
+		t.Errorf("Expected a.%s to be gone after the AXFR", testZone)
+	}
+}
+
+func TestTransferInFailover(t *testing.T) {
+	x := &ixfrPrimary{}
+	dns.HandleFunc(testZone, x.Handler)
+	defer dns.HandleRemove(testZone)
+
+	s, addrstr, err := test.TCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer s.Shutdown()
+
+	// Nothing listens on the first primary.
+	z := newSecondaryZone(t, "127.0.0.1:1", addrstr)
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Unable to run TransferIn: %v", err)
+	}
+	if z.Apex.SOA.Serial != 251 {
+		t.Fatalf("Expected serial 251, got %d", z.Apex.SOA.Serial)
+	}
+}
+
+func TestParseIxfr(t *testing.T) {
+	soa := func(serial int) dns.RR {
+		return test.SOA(fmt.Sprintf("%s IN SOA bla. bla. %d 0 0 0 0 ", testZone, serial))
+	}
+	a := test.A(fmt.Sprintf("a.%s IN A 127.0.0.1", testZone))
+
+	tests := []struct {
+		rrs       []dns.RR
+		diffs     int
+		shouldErr bool
+	}{
+		{[]dns.RR{soa(3), soa(1), a, soa(2), soa(2), soa(3), a, soa(3)}, 2, false},
+		{[]dns.RR{soa(3), soa(1), soa(3), a, soa(3)}, 1, false},
+		// errors
+		{[]dns.RR{soa(3), soa(1), a, soa(3)}, 0, true},         // incomplete sequence
+		{[]dns.RR{soa(3), soa(1), soa(2), a, soa(3)}, 0, true}, // does not end at current serial
+	}
+	for i, tc := range tests {
+		diffs, err := parseIxfr(tc.rrs)
+		if err == nil && tc.shouldErr {
+			t.Errorf("Test %d: expected error, got none", i)
+		}
+		if err != nil && !tc.shouldErr {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+		if len(diffs) != tc.diffs {
+			t.Errorf("Test %d: expected %d diffs, got %d", i, tc.diffs, len(diffs))
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package file
 
 import (
+	"encoding/hex"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
-	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/coredns/coredns/plugin/tsig"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -26,17 +32,28 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("file", err)
 	}
 
-	// Add startup functions to notify the master(s).
+	// Add startup functions to notify the secondaries.
 	for _, n := range zones.Names {
 		z := zones.Z[n]
 		c.OnStartup(func() error {
+			var err error
 			z.StartupOnce.Do(func() {
-				if len(z.TransferTo) > 0 {
-					z.Notify()
+				// Signed updates are only verified when the keys are known to the server.
+				for _, k := range z.UpdateKeys {
+					if _, err = tsig.Lookup(c, k); err != nil {
+						return
+					}
 				}
+				if err = z.Sign(); err != nil {
+					err = plugin.Error("file", err)
+					return
+				}
+				z.Xfr, _ = dnsserver.GetConfig(c).Handler("transfer").(*transfer.Transfer)
+				z.Notify()
 				z.Reload()
+				z.Resign()
 			})
-			return nil
+			return err
 		})
 	}
 	for _, n := range zones.Names {
@@ -44,6 +61,12 @@ func setup(c *caddy.Controller) error {
 		c.OnShutdown(z.OnShutdown)
 	}
 
+	for _, n := range zones.Names {
+		if len(zones.Z[n].UpdateFrom) > 0 {
+			dnsserver.GetConfig(c).Updates = true
+		}
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		return File{Next: next, Zones: zones}
 	})
@@ -94,17 +117,17 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 
 		reload := 1 * time.Minute
 		upstr := upstream.New()
-		t := []string{}
-		var e error
+		journal := defaultJournalSize
+		var updateFrom, updateKeys []string
+		var (
+			keys       []*signKey
+			nsec3      bool
+			iterations uint16
+			salt       string
+		)
 
 		for c.NextBlock() {
 			switch c.Val() {
-			case "transfer":
-				t, _, e = parse.Transfer(c, false)
-				if e != nil {
-					return Zones{}, e
-				}
-
 			case "reload":
 				d, err := time.ParseDuration(c.RemainingArgs()[0])
 				if err != nil {
@@ -112,6 +135,82 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 				}
 				reload = d
 
+			case "journal":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return Zones{}, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return Zones{}, plugin.Error("file", err)
+				}
+				if n < 0 {
+					return Zones{}, c.Errf("journal size can not be negative: %d", n)
+				}
+				journal = n
+
+			case "update":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return Zones{}, c.ArgErr()
+				}
+				for _, a := range args {
+					if a == "*" {
+						updateFrom = append(updateFrom, a)
+						continue
+					}
+					if _, _, err := net.ParseCIDR(a); err == nil {
+						updateFrom = append(updateFrom, a)
+						continue
+					}
+					if net.ParseIP(a) == nil {
+						return Zones{}, c.Errf("not an IP address or network: %s", a)
+					}
+					updateFrom = append(updateFrom, a)
+				}
+
+			case "update_key":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return Zones{}, c.ArgErr()
+				}
+				for _, a := range args {
+					updateKeys = append(updateKeys, dns.Fqdn(strings.ToLower(a)))
+				}
+
+			case "sign":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return Zones{}, c.ArgErr()
+				}
+				for _, a := range args {
+					k, err := keyParse(a, config.Root)
+					if err != nil {
+						return Zones{}, plugin.Error("file", err)
+					}
+					keys = append(keys, k)
+				}
+
+			case "nsec3":
+				args := c.RemainingArgs()
+				if len(args) > 2 {
+					return Zones{}, c.ArgErr()
+				}
+				nsec3 = true
+				if len(args) > 0 {
+					n, err := strconv.ParseUint(args[0], 10, 16)
+					if err != nil {
+						return Zones{}, plugin.Error("file", err)
+					}
+					iterations = uint16(n)
+				}
+				if len(args) > 1 && args[1] != "-" {
+					if _, err := hex.DecodeString(args[1]); err != nil || len(args[1]) > 2*255 {
+						return Zones{}, c.Errf("invalid NSEC3 salt: %s", args[1])
+					}
+					salt = strings.ToUpper(args[1])
+				}
+
 			case "upstream":
 				// ignore args, will be error later.
 				c.RemainingArgs() // clear buffer
@@ -121,13 +220,40 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 			}
 
 			for _, origin := range origins {
-				if t != nil {
-					z[origin].TransferTo = append(z[origin].TransferTo, t...)
-				}
 				z[origin].ReloadInterval = reload
 				z[origin].Upstream = upstr
+				z[origin].journal = newJournal(journal)
+				z[origin].UpdateFrom = updateFrom
+				z[origin].UpdateKeys = updateKeys
+			}
+		}
+
+		if nsec3 && len(keys) == 0 {
+			return Zones{}, c.Errf("nsec3 can only be used with sign")
+		}
+		if len(keys) > 0 {
+			for _, origin := range origins {
+				zone := z[origin]
+				zone.sign = &signer{
+					keys:       keys,
+					nsec3:      nsec3,
+					iterations: iterations,
+					salt:       salt,
+					file:       filepath.Join(filepath.Dir(fileName), "db."+origin+"signed"),
+					shutdown:   make(chan bool),
+				}
 			}
 		}
 	}
 	return Zones{Z: z, Names: names}, nil
 }
+
+// keyParse parses the DNSSEC key with the base name k, as generated by dnssec-keygen. The ".key"
+// or ".private" extension may be given. Relative names are relative to root.
+func keyParse(k, root string) (*signKey, error) {
+	base := strings.TrimSuffix(strings.TrimSuffix(k, ".key"), ".private")
+	if !filepath.IsAbs(base) && root != "" {
+		base = filepath.Join(root, base)
+	}
+	return newSignKey(base+".key", base+".private")
+}
@@ -1,6 +1,9 @@
 package file
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/coredns/coredns/plugin/test"
@@ -97,3 +100,153 @@ func TestFileParse(t *testing.T) {
 		}
 	}
 }
+
+func TestFileParseJournal(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		inputFileRules string
+		shouldErr      bool
+		expectedSize   int
+	}{
+		{`file ` + zoneFileName + ` miek.nl.`, false, defaultJournalSize},
+		{`file ` + zoneFileName + ` miek.nl. {
+			journal 100
+		}`, false, 100},
+		{`file ` + zoneFileName + ` miek.nl. {
+			journal 0
+		}`, false, 0},
+		// errors.
+		{`file ` + zoneFileName + ` miek.nl. {
+			journal -1
+		}`, true, 0},
+		{`file ` + zoneFileName + ` miek.nl. {
+			journal
+		}`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.inputFileRules)
+		z, err := fileParse(c)
+
+		if err == nil && test.shouldErr {
+			t.Fatalf("Test %d expected errors, but got no error", i)
+		} else if err != nil && !test.shouldErr {
+			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
+		}
+		if test.shouldErr {
+			continue
+		}
+		if x := z.Z["miek.nl."].journal.size; x != test.expectedSize {
+			t.Errorf("Test %d expected journal size %d, got %d", i, test.expectedSize, x)
+		}
+	}
+}
+
+func TestFileParseUpdate(t *testing.T) {
+	zoneFileName, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		inputFileRules string
+		shouldErr      bool
+		expectedFrom   []string
+		expectedKeys   []string
+	}{
+		{`file ` + zoneFileName + ` miek.nl.`, false, nil, nil},
+		{`file ` + zoneFileName + ` miek.nl. {
+			update 10.0.0.1 10.1.0.0/16 ::1
+		}`, false, []string{"10.0.0.1", "10.1.0.0/16", "::1"}, nil},
+		{`file ` + zoneFileName + ` miek.nl. {
+			update *
+			update_key DHCP.Key
+		}`, false, []string{"*"}, []string{"dhcp.key."}},
+		// errors.
+		{`file ` + zoneFileName + ` miek.nl. {
+			update
+		}`, true, nil, nil},
+		{`file ` + zoneFileName + ` miek.nl. {
+			update dhcp.example.org
+		}`, true, nil, nil},
+		{`file ` + zoneFileName + ` miek.nl. {
+			update_key
+		}`, true, nil, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.inputFileRules)
+		z, err := fileParse(c)
+
+		if err == nil && test.shouldErr {
+			t.Fatalf("Test %d expected errors, but got no error", i)
+		} else if err != nil && !test.shouldErr {
+			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
+		}
+		if test.shouldErr {
+			continue
+		}
+		if x := z.Z["miek.nl."].UpdateFrom; !reflect.DeepEqual(x, test.expectedFrom) {
+			t.Errorf("Test %d expected update from %v, got %v", i, test.expectedFrom, x)
+		}
+		if x := z.Z["miek.nl."].UpdateKeys; !reflect.DeepEqual(x, test.expectedKeys) {
+			t.Errorf("Test %d expected update keys %v, got %v", i, test.expectedKeys, x)
+		}
+	}
+}
+
+func TestFileParseSign(t *testing.T) {
+	dir, ksk, _ := newSignDir(t)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "db.example.org")
+
+	tests := []struct {
+		inputFileRules string
+		shouldErr      bool
+	}{
+		{`file ` + db + ` example.org. {
+			sign ` + ksk + `
+			nsec3 10 AABB
+		}`, false},
+		// errors.
+		{`file ` + db + ` example.org. {
+			sign
+		}`, true},
+		{`file ` + db + ` example.org. {
+			sign ` + filepath.Join(dir, "Kexample.org.+013+00000") + `
+		}`, true},
+		{`file ` + db + ` example.org. {
+			nsec3
+		}`, true},
+		{`file ` + db + ` example.org. {
+			sign ` + ksk + `
+			nsec3 10 salt
+		}`, true},
+		{`file ` + db + ` example.org. {
+			sign ` + ksk + `
+			nsec3 100000
+		}`, true},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.inputFileRules)
+		_, err := fileParse(c)
+
+		if err == nil && test.shouldErr {
+			t.Fatalf("Test %d expected errors, but got no error", i)
+		} else if err != nil && !test.shouldErr {
+			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
+		}
+	}
+
+	// Signing happens on startup, parsing doesn't write the signed zone.
+	if _, err := os.Stat(filepath.Join(dir, "db.example.org.signed")); !os.IsNotExist(err) {
+		t.Errorf("Expected no signed zone after parsing, got %v", err)
+	}
+}
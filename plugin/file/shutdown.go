@@ -5,5 +5,8 @@ func (z *Zone) OnShutdown() error {
 	if 0 < z.ReloadInterval {
 		z.reloadShutdown <- true
 	}
+	if z.sign != nil {
+		close(z.sign.shutdown)
+	}
 	return nil
 }
@@ -0,0 +1,491 @@
+package file
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// signer pre-signs a zone with the DNSSEC keys, adds the NSEC or NSEC3 chain and writes the signed
+// zone to file. The signed zone is served instead of the unsigned zone from the zone file.
+type signer struct {
+	keys       []*signKey
+	nsec3      bool   // Use NSEC3 instead of NSEC.
+	iterations uint16 // Extra NSEC3 hash iterations.
+	salt       string // NSEC3 salt, in hex, empty means no salt.
+	file       string // File the signed zone is written to.
+
+	serial   uint32    // Serial of the unsigned zone that is signed.
+	refresh  time.Time // Time at which the signatures must be refreshed.
+	shutdown chan bool
+}
+
+const (
+	signatureValidity = 4 * 7 * 24 * time.Hour // Signatures are valid for 4 weeks,
+	signatureRefresh  = 2 * 7 * 24 * time.Hour // and refreshed when they expire within 2 weeks.
+)
+
+// signKey is a DNSSEC key used for signing zones.
+type signKey struct {
+	K *dns.DNSKEY
+	s crypto.Signer
+}
+
+// newSignKey reads the DNSSEC key from the files pubFile and privFile, as generated by dnssec-keygen.
+func newSignKey(pubFile, privFile string) (*signKey, error) {
+	f, err := os.Open(pubFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rr, err := dns.ReadRR(f, pubFile)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, fmt.Errorf("no public key found in %q", pubFile)
+	}
+
+	f1, err := os.Open(privFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f1.Close()
+	p, err := k.ReadPrivateKey(f1, privFile)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := p.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("no private key found in " + privFile)
+	}
+	return &signKey{K: k, s: s}, nil
+}
+
+// Sign signs the RRset rrs and returns the signature, which is valid from incep until expir.
+func (k *signKey) Sign(rrs []dns.RR, signerName string, incep, expir uint32) (*dns.RRSIG, error) {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Rrtype: dns.TypeRRSIG, Ttl: rrs[0].Header().Ttl},
+		Algorithm:  k.K.Algorithm,
+		KeyTag:     k.K.KeyTag(),
+		SignerName: signerName,
+		OrigTtl:    rrs[0].Header().Ttl,
+		Inception:  incep,
+		Expiration: expir,
+	}
+	if err := sig.Sign(k.s, rrs); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignTickTime is how often is checked if the signatures of a signed zone need to be refreshed.
+var SignTickTime = 1 * time.Hour
+
+// signZone signs the records rrs, of which the SOA record must be the first. Any DNSSEC records in
+// rrs are replaced. If the signed zone file holds the same records, signed with the same keys, and
+// its signatures don't need to be refreshed, it is used as-is. Otherwise the zone is signed with a
+// serial that is newer than both served and the serial in the signed zone file, and written to the
+// signed zone file. Served is -1 if no zone is served yet.
+func (z *Zone) signZone(rrs []dns.RR, served int64, now time.Time) (*Zone, error) {
+	s := z.sign
+	unsigned := stripDNSSEC(rrs, z.origin)
+	serial := unsigned[0].(*dns.SOA).Serial
+
+	var prev *Zone
+	if f, err := os.Open(s.file); err == nil {
+		prev, err = Parse(f, z.origin, s.file, 0)
+		f.Close()
+		if err != nil {
+			log.Warningf("Failed to parse signed zone %q, signing it again: %s", s.file, err)
+			prev = nil
+		}
+	}
+
+	if prev != nil && s.current(prev, unsigned, now) {
+		s.serial = serial
+		s.refresh = refresh(prev.All())
+		return prev, nil
+	}
+
+	signed := serial
+	previous := []int64{served}
+	if prev != nil {
+		previous = append(previous, int64(prev.Apex.SOA.Serial))
+	}
+	for _, p := range previous {
+		if p >= 0 && !less(uint32(p), signed) {
+			signed = uint32(p) + 1
+		}
+	}
+
+	signedRRs, err := s.sign(z.origin, unsigned, signed, now)
+	if err != nil {
+		return nil, err
+	}
+	z1 := z.CopyWithoutApex()
+	for _, rr := range signedRRs {
+		if err := z1.Insert(rr); err != nil {
+			return nil, err
+		}
+	}
+	all := z1.All()
+	comment := fmt.Sprintf("; Zone %s, signed by CoreDNS from %s with serial %d", z.origin, z.file, serial)
+	if err := writeZone(s.file, comment, all); err != nil {
+		return nil, err
+	}
+
+	s.serial = serial
+	s.refresh = refresh(all)
+	return z1, nil
+}
+
+// Sign signs the zone when it was loaded, before it is served. It does nothing for zones that are
+// not signed by us.
+func (z *Zone) Sign() error {
+	if z.sign == nil {
+		return nil
+	}
+	z1, err := z.signZone(z.All(), -1, time.Now())
+	if err != nil {
+		return err
+	}
+	z.Apex, z.Tree, z.nsec3 = z1.Apex, z1.Tree, z1.nsec3
+	return nil
+}
+
+// resign signs the records rrs, of which the SOA record must be the first, and sets the signed zone live.
+func (z *Zone) resign(rrs []dns.RR, now time.Time) error {
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	served := z.SOASerialIfDefined()
+	z1, err := z.signZone(rrs, served, now)
+	if err != nil {
+		return err
+	}
+	if int64(z1.Apex.SOA.Serial) == served {
+		return nil
+	}
+	z.swap(z1, nil)
+	log.Infof("Successfully signed zone %q in %q with serial %d", z.origin, z.sign.file, z1.Apex.SOA.Serial)
+	z.Notify()
+	return nil
+}
+
+// Resign refreshes the signatures of a zone that is signed by us before they expire.
+func (z *Zone) Resign() {
+	if z.sign == nil {
+		return
+	}
+	tick := time.NewTicker(SignTickTime)
+
+	go func() {
+		for {
+			select {
+			case <-tick.C:
+				z.updateMu.Lock()
+				refresh := z.sign.refresh
+				z.updateMu.Unlock()
+				if time.Now().Before(refresh) {
+					continue
+				}
+				if err := z.resign(z.All(), time.Now()); err != nil {
+					log.Errorf("Failed to sign zone %q: %v", z.origin, err)
+				}
+
+			case <-z.sign.shutdown:
+				tick.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// current returns true if the zone prev holds the records unsigned, is signed with our keys and
+// chain, and its signatures don't need to be refreshed yet.
+func (s *signer) current(prev *Zone, unsigned []dns.RR, now time.Time) bool {
+	all := prev.All()
+	if !now.Before(refresh(all)) {
+		return false
+	}
+
+	// The serial differs, as it's bumped on every signing.
+	soa := dns.Copy(unsigned[0]).(*dns.SOA)
+	soa.Serial = prev.Apex.SOA.Serial
+	d := newDiff(stripDNSSEC(all, prev.origin), append([]dns.RR{soa}, unsigned[1:]...))
+	if len(d.add) > 0 || len(d.del) > 0 {
+		return false
+	}
+
+	tags := map[uint16]bool{}
+	for _, rr := range rrset(all, prev.origin, dns.TypeDNSKEY) {
+		tags[rr.(*dns.DNSKEY).KeyTag()] = true
+	}
+	if len(tags) != len(s.keys) {
+		return false
+	}
+	for _, k := range s.keys {
+		if !tags[k.K.KeyTag()] {
+			return false
+		}
+	}
+
+	params := rrset(all, prev.origin, dns.TypeNSEC3PARAM)
+	if !s.nsec3 {
+		return len(params) == 0
+	}
+	if len(params) != 1 || prev.nsec3.Len() == 0 {
+		return false
+	}
+	p := params[0].(*dns.NSEC3PARAM)
+	return p.Iterations == s.iterations && strings.EqualFold(p.Salt, s.salt)
+}
+
+// sign signs the records rrs, of which the SOA record must be the first, and returns them together
+// with the DNSKEY records, the NSEC or NSEC3 chain and the signatures. The SOA record gets serial.
+func (s *signer) sign(origin string, rrs []dns.RR, serial uint32, now time.Time) ([]dns.RR, error) {
+	soa := dns.Copy(rrs[0]).(*dns.SOA)
+	soa.Serial = serial
+	// The TTL of the NSEC and NSEC3 records is the negative TTL, see RFC 9077.
+	ttl := soa.Minttl
+	if soa.Hdr.Ttl < ttl {
+		ttl = soa.Hdr.Ttl
+	}
+
+	t := &tree.Tree{}
+	t.Insert(soa)
+	for _, rr := range rrs[1:] {
+		t.Insert(rr)
+	}
+	for _, k := range s.keys {
+		key := dns.Copy(k.K)
+		key.Header().Name = origin
+		key.Header().Ttl = soa.Hdr.Ttl
+		t.Insert(key)
+	}
+	if s.nsec3 {
+		t.Insert(&dns.NSEC3PARAM{
+			Hdr:        dns.RR_Header{Name: origin, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET, Ttl: 0},
+			Hash:       dns.SHA1,
+			Iterations: s.iterations,
+			SaltLength: uint8(len(s.salt) / 2),
+			Salt:       s.salt,
+		})
+	}
+
+	var (
+		signed []dns.RR
+		names  []*tree.Elem // Authoritative names, in canonical order.
+		cut    string       // Current delegation, names below it are not authoritative.
+	)
+	for _, e := range t.All() {
+		if cut != "" && dns.IsSubDomain(cut, e.Name()) {
+			// Glue, neither signed nor part of the chain.
+			signed = append(signed, e.All()...)
+			continue
+		}
+		cut = ""
+		if e.Name() != origin && e.Types(dns.TypeNS) != nil {
+			cut = e.Name()
+		}
+		names = append(names, e)
+	}
+
+	incep := uint32(now.Add(-3 * time.Hour).Unix()) // -(2+1) hours, be sure to catch daylight saving time and such
+	expir := uint32(now.Add(signatureValidity).Unix())
+	split := s.split()
+	signSet := func(set []dns.RR) error {
+		for _, k := range s.keys {
+			ksk := k.K.Flags&dns.SEP == dns.SEP
+			if split && ksk != (set[0].Header().Rrtype == dns.TypeDNSKEY) {
+				continue
+			}
+			sig, err := k.Sign(set, origin, incep, expir)
+			if err != nil {
+				return err
+			}
+			signed = append(signed, sig)
+		}
+		signed = append(signed, set...)
+		return nil
+	}
+
+	for _, e := range names {
+		delegation := e.Name() != origin && e.Types(dns.TypeNS) != nil
+		for _, set := range rrsets(e) {
+			if delegation && set[0].Header().Rrtype != dns.TypeDS {
+				// Only the DS records at a delegation are signed.
+				signed = append(signed, set...)
+				continue
+			}
+			if err := signSet(set); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var chain []dns.RR
+	if s.nsec3 {
+		chain = s.nsec3Chain(origin, names, ttl)
+	} else {
+		chain = nsecChain(origin, names, ttl)
+	}
+	for _, rr := range chain {
+		if err := signSet([]dns.RR{rr}); err != nil {
+			return nil, err
+		}
+	}
+	return signed, nil
+}
+
+// split returns true if we have both key signing and zone signing keys. If so, the key signing keys
+// only sign the DNSKEY records and the zone signing keys sign the rest of the zone.
+func (s *signer) split() bool {
+	ksk, zsk := false, false
+	for _, k := range s.keys {
+		if k.K.Flags&dns.SEP == dns.SEP {
+			ksk = true
+		} else {
+			zsk = true
+		}
+	}
+	return ksk && zsk
+}
+
+// nsecChain returns the NSEC records for names, which must be in canonical order.
+func nsecChain(origin string, names []*tree.Elem, ttl uint32) []dns.RR {
+	chain := make([]dns.RR, len(names))
+	for i, e := range names {
+		next := names[(i+1)%len(names)].Name()
+		chain[i] = &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: e.Name(), Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: ttl},
+			NextDomain: next,
+			TypeBitMap: bitmap(e, dns.TypeRRSIG, dns.TypeNSEC),
+		}
+	}
+	return chain
+}
+
+// nsec3Chain returns the NSEC3 records for names, which must be in canonical order, and the
+// empty-non-terminals between them and the origin.
+func (s *signer) nsec3Chain(origin string, names []*tree.Elem, ttl uint32) []dns.RR {
+	types := map[string][]uint16{}
+	for _, e := range names {
+		var bm []uint16
+		if e.Name() != origin && e.Types(dns.TypeNS) != nil && e.Types(dns.TypeDS) == nil {
+			bm = bitmap(e) // Insecure delegation, nothing is signed.
+		} else {
+			bm = bitmap(e, dns.TypeRRSIG)
+		}
+		types[dns.HashName(e.Name(), dns.SHA1, s.iterations, s.salt)] = bm
+
+		// A parent is always seen before its children, so a parent that isn't there is an
+		// empty-non-terminal.
+		for name := e.Name(); name != origin; {
+			off, _ := dns.NextLabel(name, 0)
+			name = name[off:]
+			h := dns.HashName(name, dns.SHA1, s.iterations, s.salt)
+			if _, ok := types[h]; !ok {
+				types[h] = nil
+			}
+		}
+	}
+
+	hashes := make([]string, 0, len(types))
+	for h := range types {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	chain := make([]dns.RR, len(hashes))
+	for i, h := range hashes {
+		chain[i] = &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(h) + "." + origin, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: ttl},
+			Hash:       dns.SHA1,
+			Iterations: s.iterations,
+			SaltLength: uint8(len(s.salt) / 2),
+			Salt:       s.salt,
+			HashLength: 20,
+			NextDomain: hashes[(i+1)%len(hashes)],
+			TypeBitMap: types[h],
+		}
+	}
+	return chain
+}
+
+// rrsets returns the RRsets of e, ordered by type.
+func rrsets(e *tree.Elem) [][]dns.RR {
+	sets := map[uint16][]dns.RR{}
+	for _, rr := range e.All() {
+		sets[rr.Header().Rrtype] = append(sets[rr.Header().Rrtype], rr)
+	}
+	var ret [][]dns.RR
+	for _, t := range sortedTypes(sets, nil) {
+		ret = append(ret, sets[t])
+	}
+	return ret
+}
+
+// bitmap returns the types of e, together with extra, in ascending order.
+func bitmap(e *tree.Elem, extra ...uint16) []uint16 {
+	sets := map[uint16][]dns.RR{}
+	for _, rr := range e.All() {
+		sets[rr.Header().Rrtype] = nil
+	}
+	return sortedTypes(sets, extra)
+}
+
+func sortedTypes(sets map[uint16][]dns.RR, extra []uint16) []uint16 {
+	for _, t := range extra {
+		sets[t] = nil
+	}
+	types := make([]uint16, 0, len(sets))
+	for t := range sets {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// refresh returns the time at which the signatures in rrs must be refreshed, which is a while
+// before the first one expires.
+func refresh(rrs []dns.RR) time.Time {
+	var first *dns.RRSIG
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && (first == nil || less(sig.Expiration, first.Expiration)) {
+			first = sig
+		}
+	}
+	if first == nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(first.Expiration), 0).Add(-signatureRefresh)
+}
+
+// stripDNSSEC returns rrs without the records added when signing the zone: the RRSIG, NSEC, NSEC3
+// and NSEC3PARAM records and the DNSKEY records at the apex.
+func stripDNSSEC(rrs []dns.RR, origin string) []dns.RR {
+	unsigned := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+			continue
+		case dns.TypeDNSKEY:
+			if strings.EqualFold(rr.Header().Name, origin) {
+				continue
+			}
+		}
+		unsigned = append(unsigned, rr)
+	}
+	return unsigned
+}
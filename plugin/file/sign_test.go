@@ -0,0 +1,322 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+const dbSign = `
+$TTL    30M
+$ORIGIN example.org.
+@       IN      SOA     ns.example.org. admin.example.org. 1000 7200 3600 1209600 3600
+@       IN      NS      ns.example.org.
+ns      IN      A       127.0.0.1
+www     IN      A       127.0.0.2
+a.b.c   IN      A       127.0.0.3
+*.w     IN      TXT     "wildcard"
+sub     IN      NS      ns.sub.example.org.
+ns.sub  IN      A       127.0.0.4
+`
+
+// newKeyFiles writes a new key for example.org. with flags to dir and returns its base name.
+func newKeyFiles(t *testing.T, dir string, flags uint16) string {
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, fmt.Sprintf("Kexample.org.+013+%05d", k.KeyTag()))
+	if err := ioutil.WriteFile(base+".key", []byte(k.String()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(base+".private", []byte(k.PrivateKeyString(priv)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+// newSignDir returns a directory with the zone file for example.org. and a key signing and a zone
+// signing key.
+func newSignDir(t *testing.T) (dir, ksk, zsk string) {
+	dir, err := ioutil.TempDir("", "coredns-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "db.example.org"), []byte(dbSign), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, newKeyFiles(t, dir, 257), newKeyFiles(t, dir, 256)
+}
+
+func signParse(t *testing.T, dir, options string) *Zone {
+	c := caddy.NewTestController("dns", `file `+filepath.Join(dir, "db.example.org")+` example.org. {
+		`+options+`
+	}`)
+	zones, err := fileParse(c)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	z := zones.Z["example.org."]
+	if err := z.Sign(); err != nil {
+		t.Fatalf("Failed to sign: %s", err)
+	}
+	return z
+}
+
+func TestSign(t *testing.T) {
+	dir, ksk, zsk := newSignDir(t)
+	defer os.RemoveAll(dir)
+
+	z := signParse(t, dir, "sign "+ksk+" "+zsk+".key\nnsec3 1 AABBCCDD")
+	all := z.All()
+
+	keys := map[uint16]*dns.DNSKEY{}
+	for _, rr := range rrset(all, "example.org.", dns.TypeDNSKEY) {
+		keys[rr.(*dns.DNSKEY).KeyTag()] = rr.(*dns.DNSKEY)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 DNSKEY records, got %d", len(keys))
+	}
+	params := rrset(all, "example.org.", dns.TypeNSEC3PARAM)
+	if len(params) != 1 || params[0].(*dns.NSEC3PARAM).Iterations != 1 || params[0].(*dns.NSEC3PARAM).Salt != "AABBCCDD" {
+		t.Fatalf("Expected NSEC3PARAM with 1 iteration and salt AABBCCDD, got %v", params)
+	}
+	// 6 names, including the delegation, and 3 empty-non-terminals: c, b.c and w.
+	if x := z.nsec3.Len(); x != 9 {
+		t.Errorf("Expected 9 NSEC3 records, got %d", x)
+	}
+
+	// Every RRset is signed, except the delegation and the glue.
+	sets := map[string][]dns.RR{}
+	var sigs []*dns.RRSIG
+	for _, rr := range all {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+			continue
+		}
+		key := rr.Header().Name + "/" + dns.TypeToString[rr.Header().Rrtype]
+		sets[key] = append(sets[key], rr)
+	}
+	for key, set := range sets {
+		signed := 0
+		for _, sig := range sigs {
+			if sig.Header().Name != set[0].Header().Name || sig.TypeCovered != set[0].Header().Rrtype {
+				continue
+			}
+			if err := sig.Verify(keys[sig.KeyTag], set); err != nil {
+				t.Errorf("Failed to verify signature for %s: %s", key, err)
+			}
+			if ksk := keys[sig.KeyTag].Flags&dns.SEP == dns.SEP; ksk != (set[0].Header().Rrtype == dns.TypeDNSKEY) {
+				t.Errorf("Expected %s to be signed with the right key, got key %d", key, sig.KeyTag)
+			}
+			signed++
+		}
+		unsigned := key == "sub.example.org./NS" || key == "ns.sub.example.org./A"
+		if unsigned && signed > 0 {
+			t.Errorf("Expected %s not to be signed", key)
+		}
+		if !unsigned && signed != 1 {
+			t.Errorf("Expected 1 signature for %s, got %d", key, signed)
+		}
+	}
+
+	// The signed zone is written and can be served as-is.
+	f, err := os.Open(filepath.Join(dir, "db.example.org.signed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z1, err := Parse(f, "example.org.", "db.example.org.signed", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse the signed zone: %s", err)
+	}
+	if len(z1.All()) != len(all) {
+		t.Errorf("Expected %d records in the signed zone file, got %d", len(all), len(z1.All()))
+	}
+}
+
+func TestSignNSEC(t *testing.T) {
+	dir, ksk, _ := newSignDir(t)
+	defer os.RemoveAll(dir)
+
+	z := signParse(t, dir, "sign "+ksk)
+	var chain []*dns.NSEC
+	for _, rr := range z.All() {
+		if nsec, ok := rr.(*dns.NSEC); ok {
+			chain = append(chain, nsec)
+		}
+	}
+	// No empty-non-terminals and no glue.
+	if len(chain) != 6 {
+		t.Fatalf("Expected 6 NSEC records, got %d", len(chain))
+	}
+	if z.nsec3.Len() != 0 {
+		t.Errorf("Expected no NSEC3 records, got %d", z.nsec3.Len())
+	}
+	for _, nsec := range chain {
+		// www.example.org. sorts after the names in w.example.org.
+		if nsec.Header().Name == "www.example.org." && nsec.NextDomain != "example.org." {
+			t.Errorf("Expected the last NSEC record to point to the apex, got %s", nsec.NextDomain)
+		}
+	}
+}
+
+func TestSignReuse(t *testing.T) {
+	dir, ksk, _ := newSignDir(t)
+	defer os.RemoveAll(dir)
+
+	z := signParse(t, dir, "sign "+ksk+"\nnsec3")
+	sig := z.Apex.SIGSOA[0].String()
+	if z.Apex.SOA.Serial != 1000 {
+		t.Fatalf("Expected serial 1000, got %d", z.Apex.SOA.Serial)
+	}
+
+	// A restart picks up the signed zone.
+	z = signParse(t, dir, "sign "+ksk+"\nnsec3")
+	if x := z.Apex.SIGSOA[0].String(); x != sig {
+		t.Errorf("Expected the signed zone to be reused, got signature %s", x)
+	}
+
+	// Different parameters mean signing again.
+	z = signParse(t, dir, "sign "+ksk+"\nnsec3 5")
+	if z.Apex.SOA.Serial != 1001 {
+		t.Errorf("Expected serial 1001, got %d", z.Apex.SOA.Serial)
+	}
+
+	// As does a changed zone, even without a new serial.
+	db := filepath.Join(dir, "db.example.org")
+	if err := ioutil.WriteFile(db, []byte(dbSign+"mail IN A 127.0.0.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	z = signParse(t, dir, "sign "+ksk+"\nnsec3 5")
+	if z.Apex.SOA.Serial != 1002 {
+		t.Errorf("Expected serial 1002, got %d", z.Apex.SOA.Serial)
+	}
+	if _, found := z.Tree.Search("mail.example.org."); !found {
+		t.Errorf("Expected mail.example.org. in the signed zone")
+	}
+
+	// Signatures that expire soon are refreshed.
+	if err := z.resign(z.All(), time.Now().Add(signatureValidity-signatureRefresh)); err != nil {
+		t.Fatal(err)
+	}
+	if z.Apex.SOA.Serial != 1003 {
+		t.Errorf("Expected serial 1003, got %d", z.Apex.SOA.Serial)
+	}
+}
+
+func TestSignLookupNSEC3(t *testing.T) {
+	dir, ksk, _ := newSignDir(t)
+	defer os.RemoveAll(dir)
+
+	z := signParse(t, dir, "sign "+ksk+"\nnsec3 0 -")
+	f := File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		match  []string // names the NSEC3 records in the authority section must match
+		cover  []string // names the NSEC3 records in the authority section must cover
+		answer int
+	}{
+		{"nope.example.org.", dns.TypeA, dns.RcodeNameError, []string{"example.org."}, []string{"nope.example.org.", "*.example.org."}, 0},
+		{"x.y.a.b.c.example.org.", dns.TypeA, dns.RcodeNameError, []string{"a.b.c.example.org."}, []string{"y.a.b.c.example.org.", "*.a.b.c.example.org."}, 0},
+		{"www.example.org.", dns.TypeAAAA, dns.RcodeSuccess, []string{"www.example.org."}, nil, 0},
+		{"b.c.example.org.", dns.TypeA, dns.RcodeSuccess, []string{"b.c.example.org."}, nil, 0},
+		{"x.w.example.org.", dns.TypeTXT, dns.RcodeSuccess, nil, []string{"x.w.example.org."}, 2},
+		{"x.w.example.org.", dns.TypeA, dns.RcodeSuccess, []string{"w.example.org.", "*.w.example.org."}, []string{"x.w.example.org."}, 0},
+		{"www.sub.example.org.", dns.TypeA, dns.RcodeSuccess, []string{"sub.example.org."}, nil, 0},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		f.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if len(rec.Msg.Answer) != tc.answer {
+			t.Errorf("Test %d: expected %d answers, got %d", i, tc.answer, len(rec.Msg.Answer))
+		}
+		var nsec3s []*dns.NSEC3
+		for _, rr := range rec.Msg.Ns {
+			if x, ok := rr.(*dns.NSEC3); ok {
+				nsec3s = append(nsec3s, x)
+			}
+		}
+		for _, name := range tc.match {
+			if !anyNSEC3(nsec3s, func(x *dns.NSEC3) bool { return x.Match(name) }) {
+				t.Errorf("Test %d: expected an NSEC3 record matching %s", i, name)
+			}
+		}
+		for _, name := range tc.cover {
+			if !anyNSEC3(nsec3s, func(x *dns.NSEC3) bool { return x.Cover(name) }) {
+				t.Errorf("Test %d: expected an NSEC3 record covering %s", i, name)
+			}
+		}
+	}
+}
+
+func TestSignUpdate(t *testing.T) {
+	dir, ksk, _ := newSignDir(t)
+	defer os.RemoveAll(dir)
+
+	z := signParse(t, dir, "sign "+ksk+"\nnsec3\nupdate *")
+	f := File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Ns = []dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.5")}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	elem, found := z.Tree.Search("mail.example.org.")
+	if !found || len(elem.Types(dns.TypeRRSIG)) == 0 {
+		t.Errorf("Expected a signed mail.example.org.")
+	}
+	if z.Apex.SOA.Serial != 1001 {
+		t.Errorf("Expected serial 1001, got %d", z.Apex.SOA.Serial)
+	}
+
+	// The unsigned zone file gets the update, without signatures.
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "db.example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), "mail.example.org.") || strings.Contains(string(buf), "RRSIG") {
+		t.Errorf("Expected the update without signatures in the zone file, got %s", buf)
+	}
+}
+
+func anyNSEC3(nsec3s []*dns.NSEC3, f func(*dns.NSEC3) bool) bool {
+	for _, x := range nsec3s {
+		if f(x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,383 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// update applies the dynamic update (RFC 2136) in state to the zone and returns the rcode for the
+// response. An update that is accepted is set live, written to the zone file and the secondaries
+// are notified. A zone that is signed by us is signed again, other signed zones can't be updated.
+func (z *Zone) update(state request.Request) int {
+	r := state.Req
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError
+	}
+	if r.Question[0].Qclass != dns.ClassINET || !strings.EqualFold(r.Question[0].Name, z.origin) {
+		return dns.RcodeNotAuth
+	}
+	if len(z.UpdateFrom) == 0 || len(z.TransferFrom) > 0 {
+		// Updates are not enabled, or this is a secondary zone.
+		return dns.RcodeNotImplemented
+	}
+	if !z.updateAllowed(state) {
+		log.Warningf("Refusing update for %s from %s", z.origin, state.IP())
+		return dns.RcodeRefused
+	}
+	if len(z.Apex.SIGSOA) > 0 && z.sign == nil {
+		log.Warningf("Refusing update for %s from %s: zone is signed", z.origin, state.IP())
+		return dns.RcodeRefused
+	}
+	if z.Expired != nil && *z.Expired {
+		return dns.RcodeServerFailure
+	}
+
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	rrs := z.All()
+	if z.sign != nil {
+		// Apply the update to the unsigned zone, which is signed again afterwards.
+		rrs = stripDNSSEC(rrs, z.origin)
+	}
+	if rcode := z.prerequisites(rrs, r.Answer); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if rcode := z.prescan(r.Ns); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+
+	rrs1 := z.apply(rrs, r.Ns)
+	d := newDiff(rrs, rrs1)
+	if len(d.del) == 0 && len(d.add) == 0 && d.from == d.to {
+		return dns.RcodeSuccess
+	}
+
+	// Bump the serial, unless the update did that already.
+	soa := rrs1[0].(*dns.SOA)
+	if soa.Serial == z.Apex.SOA.Serial {
+		soa = dns.Copy(soa).(*dns.SOA)
+		soa.Serial++
+		rrs1[0] = soa
+	}
+
+	z1 := z.CopyWithoutApex()
+	for _, rr := range rrs1 {
+		if err := z1.Insert(dns.Copy(rr)); err != nil {
+			log.Errorf("Failed to apply update for %s from %s: %s", z.origin, state.IP(), err)
+			return dns.RcodeServerFailure
+		}
+	}
+	// Write the zone first, so a reload can't bring back the previous version.
+	if err := z.write(z1.All()); err != nil {
+		log.Errorf("Failed to write zone %s to %s after update: %s", z.origin, z.file, err)
+		return dns.RcodeServerFailure
+	}
+	if z.sign != nil {
+		var err error
+		if z1, err = z.signZone(z1.All(), z.SOASerialIfDefined(), time.Now()); err != nil {
+			log.Errorf("Failed to sign zone %s after update: %s", z.origin, err)
+			return dns.RcodeServerFailure
+		}
+	}
+
+	z.swap(z1, nil)
+	log.Infof("Updated zone %s from %s, serial is now %d", z.origin, state.IP(), z1.Apex.SOA.Serial)
+	z.Notify()
+	return dns.RcodeSuccess
+}
+
+// updateAllowed checks if the source and the TSIG key of the update are allowed.
+func (z *Zone) updateAllowed(state request.Request) bool {
+	if len(z.UpdateKeys) > 0 {
+		// Transports that can't verify TSIG, gRPC and DoH, always report an error here.
+		t := state.Req.IsTsig()
+		if t == nil || state.W.TsigStatus() != nil {
+			return false
+		}
+		ok := false
+		for _, k := range z.UpdateKeys {
+			if strings.EqualFold(k, t.Hdr.Name) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	ip := net.ParseIP(state.IP())
+	for _, from := range z.UpdateFrom {
+		if from == "*" {
+			return true
+		}
+		if _, n, err := net.ParseCIDR(from); err == nil {
+			if n.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if net.ParseIP(from).Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// prerequisites checks the prerequisites of an update against the records of the zone, see
+// RFC 2136, Section 3.2.
+func (z *Zone) prerequisites(rrs, prereqs []dns.RR) int {
+	// RRsets that must exist with exactly these records, keyed by name and type.
+	exact := map[string][]dns.RR{}
+
+	for _, rr := range prereqs {
+		h := rr.Header()
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(z.origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if !nameInUse(rrs, h.Name) {
+					return dns.RcodeNameError
+				}
+				continue
+			}
+			if len(rrset(rrs, h.Name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeANY {
+				if nameInUse(rrs, h.Name) {
+					return dns.RcodeYXDomain
+				}
+				continue
+			}
+			if len(rrset(rrs, h.Name, h.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := strings.ToLower(h.Name) + "/" + dns.TypeToString[h.Rrtype]
+			exact[key] = append(exact[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	for _, want := range exact {
+		h := want[0].Header()
+		if !sameRecords(rrset(rrs, h.Name, h.Rrtype), want) {
+			return dns.RcodeNXRrset
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// prescan checks the records in the update section, see RFC 2136, Section 3.4.1.
+func (z *Zone) prescan(updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+		if !dns.IsSubDomain(z.origin, h.Name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassINET:
+			if isMeta(h.Rrtype) || h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 || (isMeta(h.Rrtype) && h.Rrtype != dns.TypeANY) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 || isMeta(h.Rrtype) || h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// apply applies the updates to the records rrs and returns the new records, see RFC 2136,
+// Section 3.4.2. The SOA record stays the first record. The prescan must have been done.
+func (z *Zone) apply(rrs, updates []dns.RR) []dns.RR {
+	rrs = append([]dns.RR{}, rrs...)
+	apex := func(name string) bool { return strings.EqualFold(name, z.origin) }
+
+	for _, rr := range updates {
+		h := rr.Header()
+		switch h.Class {
+		case dns.ClassINET:
+			if h.Rrtype == dns.TypeSOA {
+				soa := rr.(*dns.SOA)
+				if apex(h.Name) && less(rrs[0].(*dns.SOA).Serial, soa.Serial) {
+					rrs[0] = soa
+				}
+				continue
+			}
+
+			cname := rrset(rrs, h.Name, dns.TypeCNAME)
+			if h.Rrtype == dns.TypeCNAME {
+				if len(cname) == 0 && nameInUse(rrs, h.Name) {
+					continue // other data exists at this name
+				}
+				rrs = remove(rrs, func(x dns.RR) bool { return isRRset(x, h.Name, dns.TypeCNAME) })
+			} else if len(cname) > 0 {
+				continue
+			}
+
+			// An existing record only gets the TTL of the new one.
+			rrs = remove(rrs, func(x dns.RR) bool { return isRRset(x, h.Name, h.Rrtype) && sameRecord(x, rr) })
+			rrs = append(rrs, rr)
+
+		case dns.ClassANY:
+			rrs = remove(rrs, func(x dns.RR) bool {
+				xt := x.Header().Rrtype
+				if !strings.EqualFold(x.Header().Name, h.Name) {
+					return false
+				}
+				if apex(h.Name) && (xt == dns.TypeSOA || xt == dns.TypeNS) {
+					return false
+				}
+				return h.Rrtype == dns.TypeANY || xt == h.Rrtype
+			})
+
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeSOA {
+				continue
+			}
+			if apex(h.Name) && h.Rrtype == dns.TypeNS && len(rrset(rrs, h.Name, dns.TypeNS)) <= 1 {
+				continue // never delete the last NS record
+			}
+			rrs = remove(rrs, func(x dns.RR) bool { return isRRset(x, h.Name, h.Rrtype) && sameRecord(x, rr) })
+		}
+	}
+	return rrs
+}
+
+// write writes the records rrs to the zone file.
+func (z *Zone) write(rrs []dns.RR) error {
+	return writeZone(z.file, fmt.Sprintf("; Zone %s, written by CoreDNS after a dynamic update", z.origin), rrs)
+}
+
+// writeZone writes the records rrs, preceded by comment, to file. It first writes a temporary file,
+// which is then renamed, so file is never partially written. An existing file keeps its mode.
+func writeZone(file, comment string, rrs []dns.RR) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails once the file is renamed
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, comment)
+	for _, rr := range rrs {
+		fmt.Fprintln(w, rr.String())
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// nameInUse returns true if there are records with name.
+func nameInUse(rrs []dns.RR, name string) bool {
+	for _, rr := range rrs {
+		if strings.EqualFold(rr.Header().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rrset returns the records with name and type qtype.
+func rrset(rrs []dns.RR, name string, qtype uint16) []dns.RR {
+	var set []dns.RR
+	for _, rr := range rrs {
+		if isRRset(rr, name, qtype) {
+			set = append(set, rr)
+		}
+	}
+	return set
+}
+
+func isRRset(rr dns.RR, name string, qtype uint16) bool {
+	return rr.Header().Rrtype == qtype && strings.EqualFold(rr.Header().Name, name)
+}
+
+// remove returns rrs without the records for which del returns true.
+func remove(rrs []dns.RR, del func(dns.RR) bool) []dns.RR {
+	kept := rrs[:0]
+	for _, rr := range rrs {
+		if !del(rr) {
+			kept = append(kept, rr)
+		}
+	}
+	return kept
+}
+
+// sameRecord returns true if a and b have the same name, type and rdata. Class and TTL are ignored.
+func sameRecord(a, b dns.RR) bool { return updateKey(a) == updateKey(b) }
+
+// sameRecords returns true if a and b hold the same records, ignoring class and TTL.
+func sameRecords(a, b []dns.RR) bool {
+	keys := map[string]bool{}
+	for _, rr := range a {
+		keys[updateKey(rr)] = true
+	}
+	seen := map[string]bool{}
+	for _, rr := range b {
+		k := updateKey(rr)
+		if !keys[k] {
+			return false
+		}
+		seen[k] = true
+	}
+	return len(seen) == len(keys)
+}
+
+// updateKey is like rrKey, but also ignores the class, as updates use it to signal what to do.
+func updateKey(rr dns.RR) string {
+	rr = dns.Copy(rr)
+	rr.Header().Class = dns.ClassINET
+	return rrKey(rr)
+}
+
+// isMeta returns true for query types that can't be in a zone.
+func isMeta(qtype uint16) bool {
+	switch qtype {
+	case dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeANY, dns.TypeOPT, dns.TypeTSIG:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package file
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/pb"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc/peer"
+)
+
+const dbUpdate = `
+$TTL    30M
+$ORIGIN example.org.
+@       IN      SOA     ns.example.org. admin.example.org. 1000 7200 3600 1209600 3600
+@       IN      NS      ns.example.org.
+ns      IN      A       127.0.0.1
+www     IN      A       127.0.0.2
+www     IN      A       127.0.0.3
+alias   IN      CNAME   www.example.org.
+`
+
+func newUpdateZone(t *testing.T) (File, func()) {
+	name, rm, err := test.TempFile(".", dbUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z, err := Parse(f, "example.org.", name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.UpdateFrom = []string{"10.240.0.0/24"} // test.ResponseWriter uses 10.240.0.1
+	return File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}, rm
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		prereqs []dns.RR
+		updates []dns.RR
+		rcode   int
+		serial  uint32
+		exists  []dns.RR // records that must be in the zone afterwards
+		absent  []dns.RR // records that must not be in the zone afterwards
+	}{
+		{ // add a record
+			nil,
+			[]dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")},
+			dns.RcodeSuccess, 1001,
+			[]dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")}, nil,
+		},
+		{ // add an existing record, no change
+			nil,
+			[]dns.RR{test.A("www.example.org. 1800 IN A 127.0.0.2")},
+			dns.RcodeSuccess, 1000,
+			nil, nil,
+		},
+		{ // name must exist
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "mail.example.org.", Rrtype: dns.TypeANY, Class: dns.ClassANY}}},
+			[]dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")},
+			dns.RcodeNameError, 1000,
+			nil, []dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")},
+		},
+		{ // name must not exist
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeANY, Class: dns.ClassNONE}}},
+			nil,
+			dns.RcodeYXDomain, 1000,
+			nil, nil,
+		},
+		{ // RRset must not exist
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeA, Class: dns.ClassNONE}}},
+			nil,
+			dns.RcodeYXRrset, 1000,
+			nil, nil,
+		},
+		{ // RRset must exist
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeAAAA, Class: dns.ClassANY}}},
+			nil,
+			dns.RcodeNXRrset, 1000,
+			nil, nil,
+		},
+		{ // RRset must exist with these values, delete one of them
+			[]dns.RR{
+				test.A("www.example.org. 0 IN A 127.0.0.2"),
+				test.A("www.example.org. 0 IN A 127.0.0.3"),
+			},
+			[]dns.RR{test.A("www.example.org. 0 NONE A 127.0.0.3")},
+			dns.RcodeSuccess, 1001,
+			[]dns.RR{test.A("www.example.org. 1800 IN A 127.0.0.2")},
+			[]dns.RR{test.A("www.example.org. 1800 IN A 127.0.0.3")},
+		},
+		{ // RRset values don't match
+			[]dns.RR{test.A("www.example.org. 0 IN A 127.0.0.2")},
+			nil,
+			dns.RcodeNXRrset, 1000,
+			nil, nil,
+		},
+		{ // delete an RRset
+			nil,
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeA, Class: dns.ClassANY}}},
+			dns.RcodeSuccess, 1001,
+			nil,
+			[]dns.RR{test.A("www.example.org. 1800 IN A 127.0.0.2"), test.A("www.example.org. 1800 IN A 127.0.0.3")},
+		},
+		{ // deleting all at the apex keeps SOA and NS
+			nil,
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeANY, Class: dns.ClassANY}}},
+			dns.RcodeSuccess, 1000,
+			[]dns.RR{test.NS("example.org. 1800 IN NS ns.example.org.")}, nil,
+		},
+		{ // the last NS can't be deleted
+			nil,
+			[]dns.RR{test.NS("example.org. 0 NONE NS ns.example.org.")},
+			dns.RcodeSuccess, 1000,
+			[]dns.RR{test.NS("example.org. 1800 IN NS ns.example.org.")}, nil,
+		},
+		{ // no other data next to a CNAME
+			nil,
+			[]dns.RR{test.A("alias.example.org. 300 IN A 127.0.0.5")},
+			dns.RcodeSuccess, 1000,
+			nil, []dns.RR{test.A("alias.example.org. 300 IN A 127.0.0.5")},
+		},
+		{ // set a new serial
+			nil,
+			[]dns.RR{test.SOA("example.org. 1800 IN SOA ns.example.org. admin.example.org. 2000 7200 3600 1209600 3600")},
+			dns.RcodeSuccess, 2000,
+			nil, nil,
+		},
+		// errors
+		{ // not in zone
+			nil,
+			[]dns.RR{test.A("www.example.net. 300 IN A 127.0.0.4")},
+			dns.RcodeNotZone, 1000,
+			nil, nil,
+		},
+		{ // meta type
+			nil,
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeAXFR, Class: dns.ClassINET}}},
+			dns.RcodeFormatError, 1000,
+			nil, nil,
+		},
+		{ // prerequisite with TTL
+			[]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeANY, Class: dns.ClassANY, Ttl: 10}}},
+			nil,
+			dns.RcodeFormatError, 1000,
+			nil, nil,
+		},
+	}
+
+	for i, tc := range tests {
+		f, rm := newUpdateZone(t)
+		z := f.Zones.Z["example.org."]
+
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		m.Answer = tc.prereqs
+		m.Ns = tc.updates
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		f.ServeDNS(context.TODO(), rec, m)
+		rm()
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if z.Apex.SOA.Serial != tc.serial {
+			t.Errorf("Test %d: expected serial %d, got %d", i, tc.serial, z.Apex.SOA.Serial)
+		}
+		all := z.All()
+		for _, rr := range tc.exists {
+			if !containsRecord(all, rr) {
+				t.Errorf("Test %d: expected %s in zone", i, rr)
+			}
+		}
+		for _, rr := range tc.absent {
+			if containsRecord(all, rr) {
+				t.Errorf("Test %d: expected %s not to be in zone", i, rr)
+			}
+		}
+	}
+}
+
+func TestUpdateRefused(t *testing.T) {
+	f, rm := newUpdateZone(t)
+	defer rm()
+	z := f.Zones.Z["example.org."]
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Ns = []dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")}
+
+	// not from an allowed network
+	z.UpdateFrom = []string{"10.0.0.1"}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	// not signed
+	z.UpdateFrom = []string{"*"}
+	z.UpdateKeys = []string{"dhcp.key."}
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	// updates not enabled
+	z.UpdateFrom = nil
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeNotImplemented {
+		t.Errorf("Expected NOTIMP, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	// not the zone's apex
+	z.UpdateFrom = []string{"*"}
+	z.UpdateKeys = nil
+	m.SetUpdate("sub.example.org.")
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	if z.Apex.SOA.Serial != 1000 {
+		t.Errorf("Expected serial 1000, got %d", z.Apex.SOA.Serial)
+	}
+}
+
+func TestUpdateSignedgRPC(t *testing.T) {
+	f, rm := newUpdateZone(t)
+	defer rm()
+	z := f.Zones.Z["example.org."]
+	z.UpdateFrom = []string{"*"}
+	z.UpdateKeys = []string{"dhcp.key."}
+
+	c := &dnsserver.Config{Zone: "example.org.", Transport: "grpc", ListenHosts: []string{"127.0.0.1"}, Port: "53", Updates: true}
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler { return f })
+	s, err := dnsserver.NewServergRPC("127.0.0.1:53", []*dnsserver.Config{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gRPC doesn't verify TSIG, so this forged signature must not let the update through.
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Ns = []dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")}
+	m.SetTsig("dhcp.key.", dns.HmacSHA256, 300, time.Now().Unix())
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.TODO(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}})
+	p, err := s.Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(p.Msg); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Rcode != dns.RcodeRefused && resp.Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected REFUSED or NOTAUTH, got %s", dns.RcodeToString[resp.Rcode])
+	}
+	if z.Apex.SOA.Serial != 1000 {
+		t.Errorf("Expected serial 1000, got %d", z.Apex.SOA.Serial)
+	}
+}
+
+func TestUpdateWritesZone(t *testing.T) {
+	f, rm := newUpdateZone(t)
+	defer rm()
+	z := f.Zones.Z["example.org."]
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Ns = []dns.RR{test.A("mail.example.org. 300 IN A 127.0.0.4")}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	// The zone file must have the update and the new serial.
+	r, err := os.Open(z.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	z1, err := Parse(r, "example.org.", z.file, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse written zone: %s", err)
+	}
+	if z1.Apex.SOA.Serial != 1001 {
+		t.Errorf("Expected serial 1001 in zone file, got %d", z1.Apex.SOA.Serial)
+	}
+	if !containsRecord(z1.All(), test.A("mail.example.org. 300 IN A 127.0.0.4")) {
+		t.Errorf("Expected the added record in the zone file")
+	}
+
+	// And the change is in the journal, for IXFR.
+	if _, ok := z.journal.since(1000, 1001); !ok {
+		t.Errorf("Expected the update in the journal")
+	}
+}
+
+func containsRecord(rrs []dns.RR, rr dns.RR) bool {
+	for _, x := range rrs {
+		if sameRecord(x, rr) {
+			return true
+		}
+	}
+	return false
+}
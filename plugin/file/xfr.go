@@ -1,61 +1,51 @@
 package file
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/coredns/coredns/plugin"
-	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
 
-// Xfr serves up an AXFR.
-type Xfr struct {
-	*Zone
+// Transfer implements the plugin.Transferer interface.
+func (f File) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
+	z, ok := f.Zones.Z[zone]
+	if !ok || z == nil {
+		return nil, plugin.ErrNotAuthoritative
+	}
+	return z.Transfer(serial)
 }
 
-// ServeDNS implements the plugin.Handler interface.
-func (x Xfr) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	state := request.Request{W: w, Req: r}
-	if !x.TransferAllowed(state) {
-		return dns.RcodeServerFailure, nil
-	}
-	if state.QType() != dns.TypeAXFR && state.QType() != dns.TypeIXFR {
-		return 0, plugin.Error(x.Name(), fmt.Errorf("xfr called with non transfer type: %d", state.QType()))
+// Transfer returns a channel to which it writes all records of the zone. If serial is not zero and
+// not older than the zone's current serial, only the SOA record is written, signalling to an IXFR
+// client that it is up to date. If serial is older and the journal holds all changes since, only
+// those are written in the IXFR format of RFC 1995.
+func (z *Zone) Transfer(serial uint32) (<-chan []dns.RR, error) {
+	if z.Expired != nil && *z.Expired {
+		return nil, fmt.Errorf("zone %s is expired", z.origin)
 	}
-
-	records := x.All()
-	if len(records) == 0 {
-		return dns.RcodeServerFailure, nil
+	if z.SOASerialIfDefined() == -1 {
+		return nil, fmt.Errorf("zone %s has no SOA record", z.origin)
 	}
 
-	ch := make(chan *dns.Envelope)
-	defer close(ch)
-	tr := new(dns.Transfer)
-	go tr.Out(w, r, ch)
+	records := z.All()
 
-	j, l := 0, 0
-	records = append(records, records[0]) // add closing SOA to the end
-	log.Infof("Outgoing transfer of %d records of zone %s to %s started", len(records), x.origin, state.IP())
-	for i, r := range records {
-		l += dns.Len(r)
-		if l > transferLength {
-			ch <- &dns.Envelope{RR: records[j:i]}
-			l = 0
-			j = i
+	ch := make(chan []dns.RR)
+	go func() {
+		defer close(ch)
+		soa := records[0].(*dns.SOA)
+		if serial != 0 && !less(serial, soa.Serial) {
+			ch <- []dns.RR{soa}
+			return
 		}
-	}
-	if j < len(records) {
-		ch <- &dns.Envelope{RR: records[j:]}
-	}
-
-	w.Hijack()
-	// w.Close() // Client closes connection
-	return dns.RcodeSuccess, nil
+		if serial != 0 {
+			if diffs, ok := z.journal.since(serial, soa.Serial); ok {
+				ch <- append([]dns.RR{soa}, ixfr(diffs)...)
+				return
+			}
+		}
+		ch <- records
+	}()
+	return ch, nil
 }
-
-// Name implements the plugin.Handler interface.
-func (x Xfr) Name() string { return "xfr" }
-
-const transferLength = 1000 // Start a new envelop after message reaches this size in bytes. Intentionally small to test multi envelope parsing.
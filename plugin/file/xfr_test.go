@@ -3,6 +3,11 @@ package file
 import (
 	"fmt"
 	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
 )
 
 func ExampleZone_All() {
@@ -32,3 +37,41 @@ func ExampleZone_All() {
 	// xfr_test.go:15: a.miek.nl.	1800	IN	A	139.162.196.78
 	// xfr_test.go:15: a.miek.nl.	1800	IN	AAAA	2a01:7e00::f03c:91ff:fef1:6735
 }
+
+func TestZoneTransfer(t *testing.T) {
+	zone, err := Parse(strings.NewReader(dbMiekNL), testzone, "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	f := File{Zones: Zones{Z: map[string]*Zone{testzone: zone}, Names: []string{testzone}}}
+
+	tests := []struct {
+		serial   uint32
+		expected int
+	}{
+		{0, 18},          // AXFR
+		{1282630056, 18}, // IXFR, older serial gets the full zone
+		{1282630057, 1},  // IXFR, up to date
+		{1282630058, 1},  // IXFR, newer serial
+	}
+	for i, tc := range tests {
+		ch, err := f.Transfer(testzone, tc.serial)
+		if err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		records := []dns.RR{}
+		for rrs := range ch {
+			records = append(records, rrs...)
+		}
+		if len(records) != tc.expected {
+			t.Errorf("Test %d: expected %d records, got %d", i, tc.expected, len(records))
+		}
+		if records[0].Header().Rrtype != dns.TypeSOA {
+			t.Errorf("Test %d: expected first record to be SOA, got %s", i, records[0])
+		}
+	}
+
+	if _, err := f.Transfer("example.org.", 0); err != plugin.ErrNotAuthoritative {
+		t.Errorf("Expected %q, got %v", plugin.ErrNotAuthoritative, err)
+	}
+}
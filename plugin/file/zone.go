@@ -1,8 +1,6 @@
 package file
 
 import (
-	"fmt"
-	"net"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -10,7 +8,8 @@ import (
 
 	"github.com/coredns/coredns/plugin/file/tree"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
-	"github.com/coredns/coredns/request"
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/coredns/coredns/plugin/tsig"
 
 	"github.com/miekg/dns"
 )
@@ -21,12 +20,22 @@ type Zone struct {
 	origLen int
 	file    string
 	*tree.Tree
-	Apex Apex
+	Apex  Apex
+	nsec3 *tree.Tree // NSEC3 records and their signatures, keyed by hashed owner name.
 
-	TransferTo   []string
-	StartupOnce  sync.Once
-	TransferFrom []string
-	Expired      *bool
+	StartupOnce     sync.Once
+	TransferFrom    []string
+	TransferTimeout time.Duration // Timeout for each transfer attempt from a primary, zero means the default.
+	TransferTsig    *tsig.Key     // Key to sign the requests to the primaries with, nil means unsigned.
+	Expired         *bool
+	Xfr             *transfer.Transfer // Transfer plugin used for sending notifies, may be nil.
+	journal         *journal           // Differences between the loaded versions of the zone, for IXFR.
+
+	UpdateFrom []string   // Addresses and networks allowed to send dynamic updates, empty means updates are disabled.
+	UpdateKeys []string   // TSIG keys of which one must have signed an update, empty means unsigned updates are fine.
+	updateMu   sync.Mutex // Serializes dynamic updates and signing.
+
+	sign *signer // Signer that pre-signs the zone, nil if the zone isn't signed by us.
 
 	ReloadInterval time.Duration
 	LastReloaded   time.Time
@@ -50,9 +59,11 @@ func NewZone(name, file string) *Zone {
 		origLen:        dns.CountLabel(dns.Fqdn(name)),
 		file:           filepath.Clean(file),
 		Tree:           &tree.Tree{},
+		nsec3:          &tree.Tree{},
 		Expired:        new(bool),
 		reloadShutdown: make(chan bool),
 		LastReloaded:   time.Now(),
+		journal:        newJournal(defaultJournalSize),
 	}
 	*z.Expired = false
 
@@ -62,9 +73,12 @@ func NewZone(name, file string) *Zone {
 // Copy copies a zone.
 func (z *Zone) Copy() *Zone {
 	z1 := NewZone(z.origin, z.file)
-	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferTimeout = z.TransferTimeout
+	z1.TransferTsig = z.TransferTsig
+	z1.Xfr = z.Xfr
 	z1.Expired = z.Expired
+	z1.sign = z.sign
 
 	z1.Apex = z.Apex
 	return z1
@@ -73,9 +87,12 @@ func (z *Zone) Copy() *Zone {
 // CopyWithoutApex copies zone z without the Apex records.
 func (z *Zone) CopyWithoutApex() *Zone {
 	z1 := NewZone(z.origin, z.file)
-	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferTimeout = z.TransferTimeout
+	z1.TransferTsig = z.TransferTsig
+	z1.Xfr = z.Xfr
 	z1.Expired = z.Expired
+	z1.sign = z.sign
 
 	return z1
 }
@@ -84,6 +101,11 @@ func (z *Zone) CopyWithoutApex() *Zone {
 func (z *Zone) Insert(r dns.RR) error {
 	r.Header().Name = strings.ToLower(r.Header().Name)
 
+	if isNSEC3(r) {
+		z.nsec3.Insert(r)
+		return nil
+	}
+
 	switch h := r.Header().Rrtype; h {
 	case dns.TypeNS:
 		r.(*dns.NS).Ns = strings.ToLower(r.(*dns.NS).Ns)
@@ -98,8 +120,6 @@ func (z *Zone) Insert(r dns.RR) error {
 
 		z.Apex.SOA = r.(*dns.SOA)
 		return nil
-	case dns.TypeNSEC3, dns.TypeNSEC3PARAM:
-		return fmt.Errorf("NSEC3 zone is not supported, dropping RR: %s for zone: %s", r.Header().Name, z.origin)
 	case dns.TypeRRSIG:
 		x := r.(*dns.RRSIG)
 		switch x.TypeCovered {
@@ -125,7 +145,13 @@ func (z *Zone) Insert(r dns.RR) error {
 }
 
 // Delete deletes r from z.
-func (z *Zone) Delete(r dns.RR) { z.Tree.Delete(r) }
+func (z *Zone) Delete(r dns.RR) {
+	if isNSEC3(r) {
+		z.nsec3.Delete(r)
+		return
+	}
+	z.Tree.Delete(r)
+}
 
 // File retrieves the file path in a safe way
 func (z *Zone) File() string {
@@ -141,28 +167,8 @@ func (z *Zone) SetFile(path string) {
 	z.reloadMu.Unlock()
 }
 
-// TransferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
-func (z *Zone) TransferAllowed(state request.Request) bool {
-	for _, t := range z.TransferTo {
-		if t == "*" {
-			return true
-		}
-		// If remote IP matches we accept.
-		remote := state.IP()
-		to, _, err := net.SplitHostPort(t)
-		if err != nil {
-			continue
-		}
-		if to == remote {
-			return true
-		}
-	}
-	// TODO(miek): future matching against IP/CIDR notations
-	return false
-}
-
 // All returns all records from the zone, the first record will be the SOA record,
-// otionally followed by all RRSIG(SOA)s.
+// otionally followed by all RRSIG(SOA)s. The NSEC3 records, if any, come last.
 func (z *Zone) All() []dns.RR {
 	if z.ReloadInterval > 0 {
 		z.reloadMu.RLock()
@@ -174,6 +180,9 @@ func (z *Zone) All() []dns.RR {
 	for _, a := range allNodes {
 		records = append(records, a.All()...)
 	}
+	for _, a := range z.nsec3.All() {
+		records = append(records, a.All()...)
+	}
 
 	if len(z.Apex.SIGNS) > 0 {
 		records = append(z.Apex.SIGNS, records...)
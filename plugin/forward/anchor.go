@@ -0,0 +1,338 @@
+package forward
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// anchor is the trust anchor of a zone: the configured DS records and the trusted keys.
+type anchor struct {
+	zone string
+	ds   []*dns.DS
+	keys []*dns.DNSKEY
+
+	// pending holds the keys, by key tag, that are seen in the DNSKEY RRset but aren't trusted yet;
+	// see RFC 5011, Section 2.4.1.
+	pending map[uint16]time.Time
+
+	sync.RWMutex
+}
+
+// anchors are the trust anchors of a validator.
+type anchors struct {
+	file string // when set the trust anchors are written back to file when they change
+	list []*anchor
+}
+
+// rootAnchor is the DS record of the root KSK-2017, the trust anchor used when none are configured.
+const rootAnchor = ". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+
+// addHoldDown is the time a new key must be seen before it's trusted; see RFC 5011, Section 2.4.1.
+const addHoldDown = 30 * 24 * time.Hour
+
+// pendingPrefix starts the comments in which the keys that aren't trusted yet are saved, so their
+// hold-down time survives a restart: "; pending ZONE TAG FIRST-SEEN".
+const pendingPrefix = "; pending "
+
+// parseAnchors reads the trust anchors, DS and DNSKEY records in zone file format, from file. When
+// file is empty the root trust anchor is used.
+func parseAnchors(file string) (*anchors, error) {
+	a := &anchors{file: file}
+	if file == "" {
+		rr, _ := dns.NewRR(rootAnchor)
+		a.add(rr)
+		return a, nil
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	zp := dns.NewZoneParser(bytes.NewReader(buf), ".", file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+			a.add(rr)
+		default:
+			return nil, fmt.Errorf("trust anchor must be a DS or DNSKEY record: %s", rr)
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if len(a.list) == 0 {
+		return nil, fmt.Errorf("no trust anchors in %s", file)
+	}
+
+	for _, l := range strings.Split(string(buf), "\n") {
+		if !strings.HasPrefix(l, pendingPrefix) {
+			continue
+		}
+		if err := a.addPending(strings.Fields(strings.TrimPrefix(l, pendingPrefix))); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	return a, nil
+}
+
+// addPending adds the pending key in f, the fields of a pendingPrefix comment, to its trust anchor.
+func (a *anchors) addPending(f []string) error {
+	if len(f) != 3 {
+		return fmt.Errorf("invalid pending key: %s", strings.Join(f, " "))
+	}
+	tag, err := strconv.ParseUint(f[1], 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid key tag: %s", f[1])
+	}
+	first, err := time.Parse(time.RFC3339, f[2])
+	if err != nil {
+		return err
+	}
+	zone := dns.Fqdn(strings.ToLower(f[0]))
+	for _, an := range a.list {
+		if an.zone == zone {
+			an.pending[uint16(tag)] = first
+			return nil
+		}
+	}
+	return fmt.Errorf("pending key %d for %s, which has no trust anchor", tag, zone)
+}
+
+// add adds the DS or DNSKEY record rr to the trust anchor of its zone.
+func (a *anchors) add(rr dns.RR) {
+	zone := strings.ToLower(rr.Header().Name)
+	var an *anchor
+	for _, x := range a.list {
+		if x.zone == zone {
+			an = x
+		}
+	}
+	if an == nil {
+		an = &anchor{zone: zone, pending: map[uint16]time.Time{}}
+		a.list = append(a.list, an)
+	}
+	switch x := rr.(type) {
+	case *dns.DS:
+		an.ds = append(an.ds, x)
+	case *dns.DNSKEY:
+		an.keys = append(an.keys, x)
+	}
+}
+
+// closest returns the trust anchor closest to name, or nil if none of the trust anchors is for a zone
+// that contains name.
+func (a *anchors) closest(name string) *anchor {
+	var c *anchor
+	for _, an := range a.list {
+		if !dns.IsSubDomain(an.zone, name) {
+			continue
+		}
+		if c == nil || dns.CountLabel(an.zone) > dns.CountLabel(c.zone) {
+			c = an
+		}
+	}
+	return c
+}
+
+// save writes the trust anchors to the file they were read from.
+func (a *anchors) save() error {
+	if a.file == "" {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "; Trust anchors, updated by the forward plugin at %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, an := range a.list {
+		an.RLock()
+		for _, ds := range an.ds {
+			fmt.Fprintln(buf, ds.String())
+		}
+		for _, k := range an.keys {
+			fmt.Fprintln(buf, k.String())
+		}
+		tags := make([]int, 0, len(an.pending))
+		for tag := range an.pending {
+			tags = append(tags, int(tag))
+		}
+		sort.Ints(tags)
+		for _, tag := range tags {
+			fmt.Fprintf(buf, "%s%s %d %s\n", pendingPrefix, an.zone, tag, an.pending[uint16(tag)].UTC().Format(time.RFC3339))
+		}
+		an.RUnlock()
+	}
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(a.file); err == nil {
+		mode = fi.Mode()
+	}
+	if err := ioutil.WriteFile(a.file+".tmp", buf.Bytes(), mode); err != nil {
+		return err
+	}
+	return os.Rename(a.file+".tmp", a.file)
+}
+
+// supported returns true if at least one of the trust anchors uses an algorithm we can validate.
+func (an *anchor) supported() bool {
+	an.RLock()
+	defer an.RUnlock()
+	for _, ds := range an.ds {
+		if supportedDS(ds) {
+			return true
+		}
+	}
+	for _, k := range an.keys {
+		if supportedAlgorithm(k.Algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+// trusts returns true if the key k is trusted by this anchor.
+func (an *anchor) trusts(k *dns.DNSKEY) bool {
+	if k.Flags&dns.REVOKE != 0 {
+		return false
+	}
+	an.RLock()
+	defer an.RUnlock()
+	return an.index(k) >= 0 || matchDS(k, an.ds)
+}
+
+// index returns the index of k in the trusted keys, ignoring the REVOKE flag, or -1 if it isn't trusted.
+func (an *anchor) index(k *dns.DNSKEY) int {
+	for i, t := range an.keys {
+		if t.Algorithm == k.Algorithm && t.Protocol == k.Protocol && t.Flags&^dns.REVOKE == k.Flags&^dns.REVOKE &&
+			t.PublicKey == k.PublicKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// update processes the DNSKEY RRset keys, which is validated with one of the trusted keys, as described in
+// RFC 5011: keys that match a configured DS record are trusted immediately, new keys are trusted once they
+// have been seen for the add hold-down time and revoked keys are removed. It returns true if the trusted
+// keys or the pending keys have changed.
+func (an *anchor) update(keys []dns.RR, sigs []*dns.RRSIG, now time.Time) bool {
+	an.Lock()
+	defer an.Unlock()
+
+	changed := false
+	seen := map[uint16]bool{}
+	for _, rr := range keys {
+		k := rr.(*dns.DNSKEY)
+		if k.Flags&dns.SEP == 0 {
+			continue
+		}
+
+		if k.Flags&dns.REVOKE != 0 {
+			i := an.index(k)
+			if i < 0 || !selfSigned(k, keys, sigs, now) {
+				continue
+			}
+			log.Infof("Trust anchor %d for %s is revoked", an.keys[i].KeyTag(), an.zone)
+			an.keys = append(an.keys[:i], an.keys[i+1:]...)
+			changed = true
+			continue
+		}
+
+		if an.index(k) >= 0 {
+			continue
+		}
+		if matchDS(k, an.ds) {
+			an.keys = append(an.keys, k)
+			an.ds = removeDS(an.ds, k)
+			changed = true
+			continue
+		}
+
+		tag := k.KeyTag()
+		seen[tag] = true
+		first, ok := an.pending[tag]
+		if !ok {
+			log.Infof("New key %d for %s, it will be trusted after %s", tag, an.zone, addHoldDown)
+			an.pending[tag] = now
+			changed = true
+			continue
+		}
+		if now.Sub(first) >= addHoldDown {
+			log.Infof("Key %d for %s is now a trust anchor", tag, an.zone)
+			an.keys = append(an.keys, k)
+			delete(an.pending, tag)
+			changed = true
+		}
+	}
+
+	// A key that is removed before the hold-down expires must be seen for the full time again.
+	for tag := range an.pending {
+		if !seen[tag] {
+			delete(an.pending, tag)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// selfSigned returns true if the DNSKEY RRset keys is signed by k.
+func selfSigned(k *dns.DNSKEY, keys []dns.RR, sigs []*dns.RRSIG, now time.Time) bool {
+	for _, sig := range sigs {
+		if sig.KeyTag == k.KeyTag() && sig.Verify(k, keys) == nil && sig.ValidityPeriod(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDS returns true if one of the DS records is for k.
+func matchDS(k *dns.DNSKEY, ds []*dns.DS) bool {
+	for _, d := range ds {
+		if d.KeyTag != k.KeyTag() || d.Algorithm != k.Algorithm || !supportedDS(d) {
+			continue
+		}
+		if x := k.ToDS(d.DigestType); x != nil && strings.EqualFold(x.Digest, d.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDS returns ds without the records for k.
+func removeDS(ds []*dns.DS, k *dns.DNSKEY) []*dns.DS {
+	var rest []*dns.DS
+	for _, d := range ds {
+		if !matchDS(k, []*dns.DS{d}) {
+			rest = append(rest, d)
+		}
+	}
+	return rest
+}
+
+// supportedAlgorithm returns true if signatures made with alg can be validated.
+func supportedAlgorithm(alg uint8) bool {
+	switch alg {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512, dns.ECDSAP256SHA256, dns.ECDSAP384SHA384, dns.ED25519:
+		return true
+	}
+	return false
+}
+
+// supportedDS returns true if the algorithm and the digest type of ds are supported.
+func supportedDS(ds *dns.DS) bool {
+	if !supportedAlgorithm(ds.Algorithm) {
+		return false
+	}
+	switch ds.DigestType {
+	case dns.SHA1, dns.SHA256, dns.SHA384:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package forward
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestAnchorUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old, next := newTestKey(t, "example.org."), newTestKey(t, "example.org.")
+	file := filepath.Join(dir, "anchors")
+	if err := ioutil.WriteFile(file, []byte(old.k.ToDS(dns.SHA256).String()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := parseAnchors(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	an := a.list[0]
+	now := time.Now()
+
+	// The key for the DS record is trusted right away.
+	keys := []dns.RR{old.k}
+	if !an.update(keys, []*dns.RRSIG{old.signSet(t, now.Add(-time.Hour), now.Add(60*24*time.Hour), keys)}, now) {
+		t.Fatal("Expected the key of the DS record to be trusted")
+	}
+	if len(an.ds) != 0 || len(an.keys) != 1 {
+		t.Fatalf("Expected the DS record to be replaced by the key, got %d DS records and %d keys", len(an.ds), len(an.keys))
+	}
+
+	// A new key is only trusted after the hold-down time.
+	keys = []dns.RR{old.k, next.k}
+	sigs := []*dns.RRSIG{old.signSet(t, now.Add(-time.Hour), now.Add(60*24*time.Hour), keys)}
+	if !an.update(keys, sigs, now) {
+		t.Fatal("Expected the new key to be pending")
+	}
+	if an.trusts(next.k) {
+		t.Fatal("Expected the new key not to be trusted right away")
+	}
+
+	// The time the new key was first seen is saved with the trust anchors.
+	if err := a.save(); err != nil {
+		t.Fatal(err)
+	}
+	a, err = parseAnchors(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	an = a.list[0]
+	if first, ok := an.pending[next.k.KeyTag()]; !ok || !first.Equal(now.Truncate(time.Second)) {
+		t.Fatalf("Expected the new key to be pending since %s, got %s", now.Truncate(time.Second), first)
+	}
+
+	if an.update(keys, sigs, now.Add(addHoldDown-time.Hour)) {
+		t.Fatal("Expected the new key not to be trusted before the hold-down time")
+	}
+	if !an.update(keys, sigs, now.Add(addHoldDown)) {
+		t.Fatal("Expected the new key to be trusted after the hold-down time")
+	}
+	if !an.trusts(next.k) {
+		t.Error("Expected the new key to be trusted")
+	}
+
+	// The old key is revoked.
+	revoked := *old.k
+	revoked.Flags |= dns.REVOKE
+	keys = []dns.RR{&revoked, next.k}
+	r := &testKey{k: &revoked, priv: old.priv}
+	sigs = []*dns.RRSIG{r.signSet(t, now.Add(-time.Hour), now.Add(60*24*time.Hour), keys)}
+	if !an.update(keys, sigs, now.Add(addHoldDown)) {
+		t.Fatal("Expected the old key to be revoked")
+	}
+	if an.trusts(old.k) {
+		t.Error("Expected the old key not to be trusted")
+	}
+
+	if err := a.save(); err != nil {
+		t.Fatal(err)
+	}
+	a, err = parseAnchors(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if an := a.list[0]; len(an.keys) != 1 || !an.trusts(next.k) {
+		t.Errorf("Expected only the new key in the saved trust anchors")
+	}
+}
+
+func TestAnchorsClosest(t *testing.T) {
+	a, err := parseAnchors("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, _ := dns.NewRR("example.org. IN DS 1 13 2 " + "0000000000000000000000000000000000000000000000000000000000000000")
+	a.add(rr)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"www.example.org.", "example.org."},
+		{"example.org.", "example.org."},
+		{"example.net.", "."},
+	}
+	for i, tc := range tests {
+		if x := a.closest(tc.name).zone; x != tc.expected {
+			t.Errorf("Test %d: expected trust anchor %s for %s, got %s", i, tc.expected, tc.name, x)
+		}
+	}
+}
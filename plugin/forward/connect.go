@@ -7,7 +7,9 @@ package forward
 import (
 	"context"
 	"io"
+	"net"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -73,6 +75,19 @@ func (t *Transport) Dial(proto string) (*dns.Conn, bool, error) {
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
 	start := time.Now()
 
+	if p.exchanger != nil {
+		ret, err := p.exchanger.Exchange(ctx, state.Req)
+		if err != nil {
+			return nil, err
+		}
+		if !matches(state.Req, ret) {
+			p.badReply()
+			return nil, ErrBadReply
+		}
+		p.updateMetrics(ret, start)
+		return ret, nil
+	}
+
 	proto := ""
 	switch {
 	case opts.forceTCP: // TCP flag has precedence over UDP flag
@@ -94,8 +109,19 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		conn.UDPSize = 512
 	}
 
+	req := state.Req
+	if opts.tsig != nil {
+		// Replace the client's TSIG, if any, with our own.
+		req = req.Copy()
+		if req.IsTsig() != nil {
+			req.Extra = req.Extra[:len(req.Extra)-1]
+		}
+		opts.tsig.Sign(req)
+		conn.TsigSecret = opts.tsig.Secrets()
+	}
+
 	conn.SetWriteDeadline(time.Now().Add(maxTimeout))
-	if err := conn.WriteMsg(state.Req); err != nil {
+	if err := conn.WriteMsg(req); err != nil {
 		conn.Close() // not giving it back
 		if err == io.EOF && cached {
 			return nil, ErrCachedClosed
@@ -105,23 +131,59 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	var ret *dns.Msg
 	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	stop := cancelRead(ctx, conn)
 	for {
 		ret, err = conn.ReadMsg()
 		if err != nil {
+			if stop() {
+				// Cancelled, because a hedged query to another upstream won. A UDP conn can be reused, the
+				// late reply is dropped as its ID doesn't match; a TCP conn may be halfway a message.
+				if proto == "udp" {
+					p.transport.Yield(conn)
+				} else {
+					conn.Close()
+				}
+				return nil, ctx.Err()
+			}
 			conn.Close() // not giving it back
 			if err == io.EOF && cached {
 				return nil, ErrCachedClosed
 			}
+			if _, ok := err.(net.Error); !ok && err != io.EOF {
+				// The reply could be read, but not parsed.
+				p.badReply()
+			}
 			return ret, err
 		}
 		// drop out-of-order responses
-		if state.Req.Id == ret.Id {
-			break
+		if state.Req.Id != ret.Id {
+			continue
 		}
+		// drop responses for another question, these are broken or spoofed
+		if !matches(state.Req, ret) {
+			p.badReply()
+			continue
+		}
+		break
 	}
+	stop()
 
 	p.transport.Yield(conn)
 
+	if opts.tsig != nil {
+		// ReadMsg has verified the TSIG, remove it so the response can be signed for our client.
+		if ret.IsTsig() == nil {
+			return nil, ErrUnsigned
+		}
+		ret.Extra = ret.Extra[:len(ret.Extra)-1]
+	}
+
+	p.updateMetrics(ret, start)
+	return ret, nil
+}
+
+// updateMetrics records the rcode and the duration of an upstream exchange that started at start.
+func (p *Proxy) updateMetrics(ret *dns.Msg, start time.Time) {
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
 		rc = strconv.Itoa(ret.Rcode)
@@ -129,9 +191,60 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
-	RequestDuration.WithLabelValues(p.addr).Observe(time.Since(start).Seconds())
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr).Observe(rtt.Seconds())
+	p.updateRTT(rtt)
+	p.goodReply()
+}
 
-	return ret, nil
+// matches returns true if ret is a reply to req, i.e. is a response with the same ID and question. The case of
+// the name may differ, as some upstreams randomize it. Errors like FORMERR, NOTIMP and REFUSED may come without
+// a question section, those only need to match the ID.
+func matches(req, ret *dns.Msg) bool {
+	if !ret.Response || ret.Id != req.Id || len(req.Question) != 1 {
+		return false
+	}
+	if len(ret.Question) == 0 {
+		return ret.Rcode != dns.RcodeSuccess && ret.Rcode != dns.RcodeNameError
+	}
+	if len(ret.Question) != 1 {
+		return false
+	}
+	q, r := req.Question[0], ret.Question[0]
+	return q.Qtype == r.Qtype && q.Qclass == r.Qclass && strings.EqualFold(q.Name, r.Name)
+}
+
+// cancelRead interrupts reading from conn when ctx is cancelled. The returned function must be called when
+// reading is done, it returns true when the read was interrupted; after it returns conn isn't touched anymore.
+func cancelRead(ctx context.Context, conn *dns.Conn) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+
+	done := make(chan struct{})
+	cancelled := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+			cancelled <- true
+		case <-done:
+			cancelled <- false
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-cancelled
+	}
+}
+
+// isTimeout returns true if err is a timeout.
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
 }
 
 const cumulativeAvgWeight = 4
@@ -0,0 +1,67 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+
+	"github.com/miekg/dns"
+)
+
+// dohExchanger sends queries to a DNS-over-HTTPS (RFC 8484) upstream. The connections are kept by the
+// http.Transport, which uses HTTP/2 so concurrent queries are multiplexed on the same connection.
+type dohExchanger struct {
+	addr   string
+	client *http.Client
+}
+
+func newDoH(addr string) *dohExchanger {
+	d := &dohExchanger{addr: addr}
+	d.client = &http.Client{
+		Timeout: maxTimeout + readTimeout,
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSClientConfig:     new(tls.Config),
+			TLSHandshakeTimeout: maxTimeout,
+			MaxIdleConnsPerHost: 1,
+			IdleConnTimeout:     defaultExpire,
+		},
+	}
+	return d
+}
+
+// Exchange implements the exchanger interface.
+func (d *dohExchanger) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	req, err := doh.NewRequest(http.MethodPost, d.addr, m)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status from %s: %d", d.addr, resp.StatusCode)
+	}
+
+	return doh.ResponseToMsg(resp)
+}
+
+// SetTLSConfig implements the exchanger interface.
+func (d *dohExchanger) SetTLSConfig(cfg *tls.Config) {
+	d.transport().TLSClientConfig = cfg.Clone()
+}
+
+// SetExpire implements the exchanger interface.
+func (d *dohExchanger) SetExpire(expire time.Duration) { d.transport().IdleConnTimeout = expire }
+
+// Close implements the exchanger interface.
+func (d *dohExchanger) Close() { d.transport().CloseIdleConnections() }
+
+func (d *dohExchanger) transport() *http.Transport { return d.client.Transport.(*http.Transport) }
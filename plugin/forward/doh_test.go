@@ -0,0 +1,90 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestDoH(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("Expected HTTP/2, got %s", r.Proto)
+		}
+		m, err := doh.RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(m)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		buf, _ := ret.Pack()
+		w.Header().Set("content-type", doh.MimeType)
+		w.Write(buf)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	p := NewProxy(s.Listener.Addr().String(), transport.HTTPS)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.exchanger.Close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+
+	for i := 0; i < 2; i++ {
+		ret, err := p.Connect(context.TODO(), state, options{})
+		if err != nil {
+			t.Fatalf("Expected to receive reply, but didn't: %s", err)
+		}
+		if ret.Id != req.Id {
+			t.Errorf("Expected ID %d, got %d", req.Id, ret.Id)
+		}
+		if x := ret.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected healthy upstream, got %s", err)
+	}
+}
+
+func TestDoHStatus(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not here", http.StatusNotFound)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	p := NewProxy(s.Listener.Addr().String(), transport.HTTPS)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.exchanger.Close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+
+	if _, err := p.Connect(context.TODO(), state, options{}); err == nil {
+		t.Errorf("Expected error for HTTP status %d", http.StatusNotFound)
+	}
+	if err := p.health.Check(p); err == nil {
+		t.Errorf("Expected failed health check")
+	}
+	if p.fails != 1 {
+		t.Errorf("Expected 1 fail, got %d", p.fails)
+	}
+}
@@ -0,0 +1,137 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqExchanger sends queries to a DNS-over-QUIC (RFC 9250) upstream. A single QUIC connection is kept and each
+// query is sent on its own stream; when the connection is closed (or idle for longer than expire) it is redialed.
+type doqExchanger struct {
+	addr      string
+	tlsConfig *tls.Config
+	expire    time.Duration
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+func newDoQ(addr string) *doqExchanger {
+	return &doqExchanger{addr: addr, tlsConfig: doqTLSConfig(new(tls.Config)), expire: defaultExpire}
+}
+
+// Exchange implements the exchanger interface.
+func (d *doqExchanger) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, maxTimeout+readTimeout)
+	defer cancel()
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		// The connection may have been closed by the upstream, forget it so the next query redials.
+		d.forget(conn)
+		return nil, err
+	}
+	defer stream.CancelRead(0)
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	// The message ID must be 0 on the wire, see RFC 9250 section 4.2.1.
+	id := m.Id
+	m = m.Copy()
+	m.Id = 0
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each message is prefixed with its length, after the query we close our side of the stream.
+	msg := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(msg, uint16(len(buf)))
+	copy(msg[2:], buf)
+	if _, err := stream.Write(msg); err != nil {
+		return nil, err
+	}
+	stream.Close()
+
+	var l [2]byte
+	if _, err := io.ReadFull(stream, l[:]); err != nil {
+		return nil, err
+	}
+	buf = make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+
+	ret := new(dns.Msg)
+	if err := ret.Unpack(buf); err != nil {
+		return nil, err
+	}
+	ret.Id = id
+	return ret, nil
+}
+
+// dial returns the cached connection, or dials a new one when there is none or it has been closed.
+func (d *doqExchanger) dial(ctx context.Context) (quic.Connection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conn != nil && d.conn.Context().Err() == nil {
+		return d.conn, nil
+	}
+
+	conn, err := quic.DialAddr(ctx, d.addr, d.tlsConfig, &quic.Config{MaxIdleTimeout: d.expire})
+	if err != nil {
+		return nil, err
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// forget drops conn from the cache and closes it.
+func (d *doqExchanger) forget(conn quic.Connection) {
+	d.mu.Lock()
+	if d.conn == conn {
+		d.conn = nil
+	}
+	d.mu.Unlock()
+	conn.CloseWithError(doqNoError, "")
+}
+
+// SetTLSConfig implements the exchanger interface.
+func (d *doqExchanger) SetTLSConfig(cfg *tls.Config) { d.tlsConfig = doqTLSConfig(cfg) }
+
+// SetExpire implements the exchanger interface.
+func (d *doqExchanger) SetExpire(expire time.Duration) { d.expire = expire }
+
+// Close implements the exchanger interface.
+func (d *doqExchanger) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		d.conn.CloseWithError(doqNoError, "")
+		d.conn = nil
+	}
+}
+
+// doqTLSConfig returns a copy of cfg that negotiates the DoQ ALPN token.
+func doqTLSConfig(cfg *tls.Config) *tls.Config {
+	cfg = cfg.Clone()
+	cfg.NextProtos = []string{"doq"}
+	return cfg
+}
+
+// doqNoError is the DOQ_NO_ERROR application error code.
+const doqNoError = 0
@@ -0,0 +1,114 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+func TestDoQ(t *testing.T) {
+	tmpdir, rmFunc, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatalf("Could not write PEM files: %s", err)
+	}
+	defer rmFunc()
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(tmpdir, "cert.pem"), filepath.Join(tmpdir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Could not load certificate: %s", err)
+	}
+
+	l, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"doq"}}, nil)
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	defer l.Close()
+
+	conns := uint32(0)
+	go func() {
+		for {
+			conn, err := l.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			atomic.AddUint32(&conns, 1)
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go serveDoQ(t, stream)
+				}
+			}()
+		}
+	}()
+
+	p := NewProxy(l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.exchanger.Close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+
+	for i := 0; i < 3; i++ {
+		ret, err := p.Connect(context.TODO(), state, options{})
+		if err != nil {
+			t.Fatalf("Expected to receive reply, but didn't: %s", err)
+		}
+		if ret.Id != req.Id {
+			t.Errorf("Expected ID %d, got %d", req.Id, ret.Id)
+		}
+		if x := ret.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected healthy upstream, got %s", err)
+	}
+	if x := atomic.LoadUint32(&conns); x != 1 {
+		t.Errorf("Expected 1 connection, got %d", x)
+	}
+}
+
+func serveDoQ(t *testing.T, stream quic.Stream) {
+	defer stream.Close()
+
+	var l [2]byte
+	if _, err := io.ReadFull(stream, l[:]); err != nil {
+		return
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return
+	}
+	if m.Id != 0 {
+		t.Errorf("Expected ID 0, got %d", m.Id)
+	}
+
+	ret := new(dns.Msg)
+	ret.SetReply(m)
+	ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+	buf, _ = ret.Pack()
+	out := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(out, uint16(len(buf)))
+	copy(out[2:], buf)
+	stream.Write(out)
+}
@@ -8,11 +8,12 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
-	"github.com/coredns/coredns/plugin/debug"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/tsig"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -24,6 +25,8 @@ var log = clog.NewWithPlugin("forward")
 // Forward represents a plugin instance that can proxy requests to another (DNS) server. It has a list
 // of proxies each representing one upstream proxy.
 type Forward struct {
+	concurrent int64 // atomic counters need to be first in struct for proper alignment
+
 	proxies    []*Proxy
 	p          Policy
 	hcInterval time.Duration
@@ -31,6 +34,8 @@ type Forward struct {
 	from    string
 	ignored []string
 
+	table *table // when set queries are routed to groups of upstreams
+
 	tlsConfig     *tls.Config
 	tlsServerName string
 	maxfails      uint32
@@ -38,12 +43,28 @@ type Forward struct {
 
 	opts options // also here for testing
 
+	// maxConcurrent limits the number of queries in flight, maxConcurrentUpstream the number in flight to
+	// each upstream. When exceeded queries are answered with limitRcode.
+	maxConcurrent         int64
+	maxConcurrentUpstream int64
+	limitRcode            int
+
+	// When hedge is set, a query is also sent to a second upstream when the first one hasn't replied after
+	// hedgeDelay; with a hedgeDelay of 0 both are queried right away.
+	hedge      bool
+	hedgeDelay time.Duration
+
+	validator *validator // when set responses are DNSSEC validated
+	rebind    *rebind    // when set private addresses are filtered from the responses
+
+	rebindAllow []string // zones allowed to have private addresses, only used during setup
+
 	Next plugin.Handler
 }
 
 // New returns a new Forward.
 func New() *Forward {
-	f := &Forward{maxfails: 2, tlsConfig: new(tls.Config), expire: defaultExpire, p: new(random), from: ".", hcInterval: hcInterval}
+	f := &Forward{maxfails: 2, tlsConfig: new(tls.Config), expire: defaultExpire, p: new(random), from: ".", hcInterval: hcInterval, limitRcode: dns.RcodeRefused}
 	return f
 }
 
@@ -67,34 +88,82 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
 	}
 
+	if f.maxConcurrent > 0 {
+		count := atomic.AddInt64(&f.concurrent, 1)
+		defer atomic.AddInt64(&f.concurrent, -1)
+		if count > f.maxConcurrent {
+			MaxConcurrentRejectCount.Add(1)
+			return f.limitRcode, ErrLimitExceeded
+		}
+	}
+
+	// When validating, the upstream gets a copy of the query with the DO and CD bits set; a client that sets
+	// CD does its own validation.
+	validate := f.validator != nil && !r.CheckingDisabled
+	upstream := state
+	if validate {
+		upstream = request.Request{W: w, Req: f.validator.request(r)}
+	}
+
 	fails := 0
+	busy := 0 // proxies in a row that were at their concurrency limit
 	var span, child ot.Span
 	var upstreamErr error
 	span = ot.SpanFromContext(ctx)
 	i := 0
-	list := f.List()
+	proxies, pol := f.route(state.Name())
+	if len(proxies) == 0 {
+		// Not routed to a group and there are no default upstreams.
+		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
+	}
+	list := pol.List(proxies)
 	deadline := time.Now().Add(defaultTimeout)
 	start := time.Now()
-	for time.Now().Before(deadline) {
-		if i >= len(list) {
-			// reached the end of list, reset to begin
-			i = 0
-			fails = 0
-		}
 
-		proxy := list[i]
-		i++
-		if proxy.Down(f.maxfails) {
-			fails++
-			if fails < len(f.proxies) {
-				continue
+	// next returns the next proxy to use, it returns false when all proxies are at their concurrency limit.
+	// The returned proxy must be released.
+	next := func() (*Proxy, bool) {
+		for {
+			if i >= len(list) {
+				// reached the end of list, reset to begin
+				i = 0
+				fails = 0
+			}
+
+			proxy := list[i]
+			i++
+			if proxy.Down(f.maxfails) {
+				fails++
+				if fails < len(proxies) {
+					continue
+				}
+				// All upstream proxies are dead, assume healtcheck is completely broken and randomly
+				// select an upstream to connect to.
+				r := new(random)
+				proxy = r.List(proxies)[0]
+
+				HealthcheckBrokenCount.Add(1)
+			}
+
+			if !proxy.acquire() {
+				// This upstream has too many queries in flight, try the next one. When none of them can
+				// take the query, shed it.
+				busy++
+				if busy < len(list) {
+					continue
+				}
+				return nil, false
 			}
-			// All upstream proxies are dead, assume healtcheck is completely broken and randomly
-			// select an upstream to connect to.
-			r := new(random)
-			proxy = r.List(f.proxies)[0]
+			busy = 0
+			return proxy, true
+		}
+	}
 
-			HealthcheckBrokenCount.Add(1)
+	for time.Now().Before(deadline) {
+		proxy, ok := next()
+		if !ok {
+			MaxConcurrentRejectCount.Add(1)
+			return f.limitRcode, ErrLimitExceeded
 		}
 
 		if span != nil {
@@ -106,21 +175,11 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			ret *dns.Msg
 			err error
 		)
-		opts := f.opts
-		for {
-			ret, err = proxy.Connect(ctx, state, opts)
-			if err == nil {
-				break
-			}
-			if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
-				continue
-			}
-			// Retry with TCP if truncated and prefer_udp configured.
-			if ret != nil && ret.Truncated && !opts.forceTCP && f.opts.preferUDP {
-				opts.forceTCP = true
-				continue
-			}
-			break
+		if f.hedge {
+			ret, proxy, err = f.hedged(ctx, upstream, proxy, next)
+		} else {
+			ret, err = f.connect(ctx, proxy, upstream)
+			proxy.release()
 		}
 
 		if child != nil {
@@ -131,25 +190,26 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		upstreamErr = err
 
 		if err != nil {
+			if isTimeout(err) {
+				proxy.timedOut()
+			}
 			// Kick off health check to see if *our* upstream is broken.
 			if f.maxfails != 0 {
 				proxy.Healthcheck()
 			}
 
-			if fails < len(f.proxies) {
+			if fails < len(proxies) {
 				continue
 			}
 			break
 		}
 
-		// Check if the reply is correct; if not return FormErr.
-		if !state.Match(ret) {
-			debug.Hexdumpf(ret, "Wrong reply for id: %d, %s %d", ret.Id, state.QName(), state.QType())
-
-			formerr := new(dns.Msg)
-			formerr.SetRcode(state.Req, dns.RcodeFormatError)
-			w.WriteMsg(formerr)
-			return 0, taperr
+		// Connect has made sure ret is a reply to our question, see matches.
+		if validate {
+			ret = f.validator.reply(ctx, state, ret)
+		}
+		if f.rebind != nil && f.rebind.filter(state, ret) {
+			RebindCount.WithLabelValues(proxy.addr).Add(1)
 		}
 
 		w.WriteMsg(ret)
@@ -163,6 +223,27 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	return dns.RcodeServerFailure, ErrNoHealthy
 }
 
+// connect sends the query to proxy, when the cached connection was closed it retries and when the reply is
+// truncated it retries over TCP if prefer_udp is set.
+func (f *Forward) connect(ctx context.Context, proxy *Proxy, state request.Request) (*dns.Msg, error) {
+	opts := f.opts
+	for {
+		ret, err := proxy.Connect(ctx, state, opts)
+		if err == nil {
+			return ret, nil
+		}
+		if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
+			continue
+		}
+		// Retry with TCP if truncated and prefer_udp configured.
+		if ret != nil && ret.Truncated && !opts.forceTCP && f.opts.preferUDP {
+			opts.forceTCP = true
+			continue
+		}
+		return ret, err
+	}
+}
+
 func (f *Forward) match(state request.Request) bool {
 	if !plugin.Name(f.from).Matches(state.Name()) || !f.isAllowedDomain(state.Name()) {
 		return false
@@ -200,6 +281,12 @@ var (
 	ErrNoForward = errors.New("no forwarder defined")
 	// ErrCachedClosed means cached connection was closed by peer.
 	ErrCachedClosed = errors.New("cached connection was closed by peer")
+	// ErrUnsigned means the upstream did not sign the response to a signed query.
+	ErrUnsigned = errors.New("response to TSIG signed query is not signed")
+	// ErrBadReply means the upstream's reply doesn't match the query.
+	ErrBadReply = errors.New("reply does not match the query")
+	// ErrLimitExceeded means the query was dropped because there are too many queries in flight.
+	ErrLimitExceeded = errors.New("concurrent queries exceeded maximum")
 )
 
 // policy tells forward what policy for selecting upstream it uses.
@@ -215,6 +302,7 @@ const (
 type options struct {
 	forceTCP  bool
 	preferUDP bool
+	tsig      *tsig.Key // sign queries with this key, only the name is set until startup
 }
 
 const defaultTimeout = 5 * time.Second
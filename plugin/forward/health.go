@@ -1,6 +1,7 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,9 @@ func NewHealthChecker(trans string) HealthChecker {
 		c.WriteTimeout = 1 * time.Second
 
 		return &dnsHc{c: c}
+
+	case transport.HTTPS, transport.QUIC:
+		return &exchangeHc{}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -45,7 +49,11 @@ func (h *dnsHc) SetTLSConfig(cfg *tls.Config) {
 
 // Check is used as the up.Func in the up.Probe.
 func (h *dnsHc) Check(p *Proxy) error {
-	err := h.send(p.addr)
+	return p.checked(h.send(p.addr))
+}
+
+// checked updates the fail count of p with the result of a health check.
+func (p *Proxy) checked(err error) error {
 	if err != nil {
 		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
 		atomic.AddUint32(&p.fails, 1)
@@ -71,3 +79,24 @@ func (h *dnsHc) send(addr string) error {
 
 	return err
 }
+
+// exchangeHc is a health checker for DoH and DoQ endpoints, it sends the ping through the proxy's exchanger, so
+// the health check reuses (or reestablishes) the connection that is used for the queries.
+type exchangeHc struct{}
+
+// SetTLSConfig is a noop, the TLS config is set in the exchanger itself.
+func (h *exchangeHc) SetTLSConfig(cfg *tls.Config) {}
+
+// Check is used as the up.Func in the up.Probe.
+func (h *exchangeHc) Check(p *Proxy) error {
+	ping := new(dns.Msg)
+	ping.SetQuestion(".", dns.TypeNS)
+
+	ctx, cancel := context.WithTimeout(context.Background(), hcTimeout)
+	defer cancel()
+
+	_, err := p.exchanger.Exchange(ctx, ping)
+	return p.checked(err)
+}
+
+const hcTimeout = 1 * time.Second
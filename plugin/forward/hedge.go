@@ -0,0 +1,66 @@
+package forward
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// hedged sends the query to proxy and, when it hasn't replied after f.hedgeDelay, to the proxy returned by
+// next as well. The first valid reply wins and the other query is cancelled. When both fail the last error
+// is returned. The proxies are released when their query is done.
+func (f *Forward) hedged(ctx context.Context, state request.Request, proxy *Proxy, next func() (*Proxy, bool)) (*dns.Msg, *Proxy, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2) // buffered, so the losing query doesn't block
+	query := func(p *Proxy) {
+		ret, err := f.connect(ctx, p, state)
+		p.release()
+		results <- hedgeResult{ret: ret, proxy: p, err: err}
+	}
+
+	go query(proxy)
+	inflight := 1
+
+	timer := time.NewTimer(f.hedgeDelay)
+	defer timer.Stop()
+
+	var res hedgeResult
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			p, ok := next()
+			if !ok {
+				continue
+			}
+			if p == proxy {
+				// There is no other upstream to hedge to.
+				p.release()
+				continue
+			}
+			HedgeCount.WithLabelValues(p.addr).Add(1)
+			go query(p)
+			inflight++
+
+		case res = <-results:
+			inflight--
+			if res.err == nil && state.Match(res.ret) {
+				return res.ret, res.proxy, nil
+			}
+			if inflight == 0 {
+				return res.ret, res.proxy, res.err
+			}
+		}
+	}
+	return res.ret, res.proxy, res.err
+}
+
+type hedgeResult struct {
+	ret   *dns.Msg
+	proxy *Proxy
+	err   error
+}
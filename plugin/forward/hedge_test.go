@@ -0,0 +1,106 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestHedge(t *testing.T) {
+	// dnstest servers share a handler, tell the upstreams apart by their port.
+	block := make(chan struct{})
+	var slowPort atomic.Value
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if _, port, _ := net.SplitHostPort(w.LocalAddr().String()); port == slowPort.Load() {
+			<-block
+			ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		} else {
+			ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.2"))
+		}
+		w.WriteMsg(ret)
+	}
+	slow := dnstest.NewServer(handler)
+	defer slow.Close()
+	defer close(block)
+	fast := dnstest.NewServer(handler)
+	defer fast.Close()
+	_, port, _ := net.SplitHostPort(slow.Addr)
+	slowPort.Store(port)
+
+	for _, hedge := range []string{"50ms", "race"} {
+		c := caddy.NewTestController("dns", "forward . "+slow.Addr+" "+fast.Addr+" {\npolicy sequential\nhedge "+hedge+"\n}")
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Failed to create forwarder: %s", err)
+		}
+		f.OnStartup()
+		defer f.OnShutdown()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		start := time.Now()
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Hedge %s: expected reply, got error: %s", hedge, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("Hedge %s: expected a fast reply, took %s", hedge, time.Since(start))
+		}
+		if x := rec.Msg.Answer[0].(*dns.A).A.String(); x != "127.0.0.2" {
+			t.Errorf("Hedge %s: expected reply from the fast upstream, got %s", hedge, x)
+		}
+
+		// The query to the slow upstream is cancelled and its connection given back.
+		slowProxy := f.proxies[0]
+		for i := 0; atomic.LoadInt64(&slowProxy.inflight) != 0; i++ {
+			if i > 100 {
+				t.Fatalf("Hedge %s: expected the query to the slow upstream to be cancelled", hedge)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		conn, cached, _ := slowProxy.transport.Dial("udp")
+		if !cached {
+			t.Errorf("Hedge %s: expected the cancelled connection to be given back", hedge)
+		}
+		conn.Close()
+	}
+}
+
+func TestHedgeFail(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	// The first upstream doesn't exist, the query should still be answered by the second.
+	c := caddy.NewTestController("dns", "forward . 127.0.0.1:1 "+s.Addr+" {\npolicy sequential\nhedge 1s\nforce_tcp\n}")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected reply, got error: %s", err)
+	}
+	if len(rec.Msg.Answer) != 1 {
+		t.Errorf("Expected an answer, got %d", len(rec.Msg.Answer))
+	}
+}
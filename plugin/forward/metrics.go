@@ -45,4 +45,40 @@ var (
 		Name:      "sockets_open",
 		Help:      "Gauge of open sockets per upstream.",
 	}, []string{"to"})
+	InflightGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "inflight_queries",
+		Help:      "Gauge of queries in flight per upstream.",
+	}, []string{"to"})
+	MaxConcurrentRejectCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "max_concurrent_rejects_total",
+		Help:      "Counter of queries rejected because the concurrency limits were reached.",
+	})
+	HedgeCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "hedged_requests_total",
+		Help:      "Counter of hedged requests made per upstream.",
+	}, []string{"to"})
+	BadReplyCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "bad_reply_count_total",
+		Help:      "Counter of malformed replies, or replies to another question, per upstream.",
+	}, []string{"to"})
+	RebindCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "rebind_filtered_count_total",
+		Help:      "Counter of replies with private addresses that were filtered per upstream.",
+	}, []string{"to"})
+	ValidationCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "dnssec_validation_count_total",
+		Help:      "Counter of DNSSEC validation results.",
+	}, []string{"result"})
 )
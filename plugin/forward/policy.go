@@ -2,6 +2,7 @@ package forward
 
 import (
 	"math/rand"
+	"sort"
 	"sync/atomic"
 )
 
@@ -62,3 +63,69 @@ func (r *sequential) String() string { return "sequential" }
 func (r *sequential) List(p []*Proxy) []*Proxy {
 	return p
 }
+
+// fastest is a policy that selects the upstream with the lowest moving average of the round trip time.
+// Upstreams that timed out recently are tried last. Upstreams that haven't replied yet are tried first,
+// so every upstream gets measured.
+type fastest struct{}
+
+func (r *fastest) String() string { return "fastest" }
+
+func (r *fastest) List(p []*Proxy) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+
+	// Shuffle first, so upstreams with the same RTT (i.e. unmeasured ones) share the load.
+	fast := shuffle(p)
+	sort.SliceStable(fast, func(i, j int) bool {
+		ti, tj := fast[i].recentlyTimedOut(), fast[j].recentlyTimedOut()
+		if ti != tj {
+			return tj
+		}
+		return fast[i].RTT() < fast[j].RTT()
+	})
+	return fast
+}
+
+// powerOfTwo is a policy that picks two random upstreams and selects the least loaded one, the load being
+// the number of queries in flight weighted by the moving average of the round trip time. The other upstreams
+// follow in random order. An upstream that timed out recently always loses from one that didn't.
+type powerOfTwo struct{}
+
+func (r *powerOfTwo) String() string { return "power_of_two" }
+
+func (r *powerOfTwo) List(p []*Proxy) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+
+	rnd := shuffle(p)
+	if better(rnd[1], rnd[0]) {
+		rnd[0], rnd[1] = rnd[1], rnd[0]
+	}
+	return rnd
+}
+
+// better returns true when a is less loaded than b.
+func better(a, b *Proxy) bool {
+	ta, tb := a.recentlyTimedOut(), b.recentlyTimedOut()
+	if ta != tb {
+		return tb
+	}
+	return load(a) < load(b)
+}
+
+// load returns the load of p, an unmeasured upstream has no load.
+func load(p *Proxy) int64 {
+	return (atomic.LoadInt64(&p.inflight) + 1) * int64(p.RTT())
+}
+
+// shuffle returns a copy of p in random order.
+func shuffle(p []*Proxy) []*Proxy {
+	rnd := make([]*Proxy, len(p))
+	for i, p1 := range rand.Perm(len(p)) {
+		rnd[i] = p[p1]
+	}
+	return rnd
+}
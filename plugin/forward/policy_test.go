@@ -0,0 +1,86 @@
+package forward
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestFastest(t *testing.T) {
+	p := []*Proxy{NewProxy("10.0.0.1:53", transport.DNS), NewProxy("10.0.0.2:53", transport.DNS), NewProxy("10.0.0.3:53", transport.DNS)}
+	p[0].updateRTT(30 * time.Millisecond)
+	p[1].updateRTT(10 * time.Millisecond)
+	p[2].updateRTT(20 * time.Millisecond)
+
+	f := &fastest{}
+	for i := 0; i < 10; i++ {
+		list := f.List(p)
+		if list[0] != p[1] || list[1] != p[2] || list[2] != p[0] {
+			t.Fatalf("Expected upstreams ordered by RTT, got %s %s %s", list[0].addr, list[1].addr, list[2].addr)
+		}
+	}
+
+	// The fastest upstream times out, it should be tried last.
+	p[1].timedOut()
+	if list := f.List(p); list[2] != p[1] {
+		t.Errorf("Expected %s to be last, got %s", p[1].addr, list[2].addr)
+	}
+
+	// An unmeasured upstream is tried first.
+	p = append(p, NewProxy("10.0.0.4:53", transport.DNS))
+	if list := f.List(p); list[0] != p[3] {
+		t.Errorf("Expected %s to be first, got %s", p[3].addr, list[0].addr)
+	}
+
+	// An upstream that only timed out stays slow after the penalty; it goes before 10.0.0.2, which still has one.
+	p[3].timedOut()
+	atomic.StoreInt64(&p[3].lastTimeout, time.Now().Add(-timeoutPenalty).UnixNano())
+	if list := f.List(p); list[2] != p[3] {
+		t.Errorf("Expected %s to be third, got %s", p[3].addr, list[2].addr)
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	p := []*Proxy{NewProxy("10.0.0.1:53", transport.DNS), NewProxy("10.0.0.2:53", transport.DNS)}
+	p[0].updateRTT(10 * time.Millisecond)
+	p[1].updateRTT(10 * time.Millisecond)
+	p[1].acquire()
+	defer p[1].release()
+
+	r := &powerOfTwo{}
+	for i := 0; i < 10; i++ {
+		if list := r.List(p); list[0] != p[0] {
+			t.Fatalf("Expected the least loaded upstream %s, got %s", p[0].addr, list[0].addr)
+		}
+	}
+
+	p[0].timedOut()
+	for i := 0; i < 10; i++ {
+		if list := r.List(p); list[0] != p[1] {
+			t.Fatalf("Expected the upstream that didn't time out %s, got %s", p[1].addr, list[0].addr)
+		}
+	}
+
+	// With more upstreams the result is one of the upstreams, and all upstreams are listed.
+	p = append(p, NewProxy("10.0.0.3:53", transport.DNS), NewProxy("10.0.0.4:53", transport.DNS))
+	if list := r.List(p); len(list) != len(p) {
+		t.Errorf("Expected %d upstreams, got %d", len(p), len(list))
+	}
+}
+
+func TestUpdateRTT(t *testing.T) {
+	p := NewProxy("10.0.0.1:53", transport.DNS)
+	if x := p.RTT(); x != 0 {
+		t.Errorf("Expected no RTT, got %s", x)
+	}
+	p.updateRTT(100 * time.Millisecond)
+	if x := p.RTT(); x != 100*time.Millisecond {
+		t.Errorf("Expected RTT %s, got %s", 100*time.Millisecond, x)
+	}
+	p.updateRTT(20 * time.Millisecond)
+	if x := p.RTT(); x != 80*time.Millisecond {
+		t.Errorf("Expected RTT %s, got %s", 80*time.Millisecond, x)
+	}
+}
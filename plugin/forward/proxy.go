@@ -1,17 +1,27 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
 	"runtime"
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
+
+	"github.com/miekg/dns"
 )
 
 // Proxy defines an upstream host.
 type Proxy struct {
-	fails uint32
+	// atomic counters need to be first in struct for proper alignment
+	inflight    int64
+	rtt         int64 // moving average of the round trip time in nanoseconds, 0 until the first reply
+	lastTimeout int64 // unix nanoseconds of the last timeout
+	lastBad     int64 // unix nanoseconds of the last bad reply
+	fails       uint32
+	bad         uint32 // bad replies in a row
 
 	addr string
 
@@ -19,6 +29,12 @@ type Proxy struct {
 	expire    time.Duration
 	transport *Transport
 
+	// exchanger is used instead of transport for DNS-over-HTTPS and DNS-over-QUIC.
+	exchanger exchanger
+
+	// maxConcurrent limits the number of queries in flight to this upstream, 0 is no limit.
+	maxConcurrent int64
+
 	// health checking
 	probe  *up.Probe
 	health HealthChecker
@@ -32,6 +48,12 @@ func NewProxy(addr, trans string) *Proxy {
 		probe:     up.New(),
 		transport: newTransport(addr),
 	}
+	switch trans {
+	case transport.HTTPS:
+		p.exchanger = newDoH(addr)
+	case transport.QUIC:
+		p.exchanger = newDoQ(addr)
+	}
 	p.health = NewHealthChecker(trans)
 	runtime.SetFinalizer(p, (*Proxy).finalizer)
 	return p
@@ -39,12 +61,21 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	if p.exchanger != nil {
+		p.exchanger.SetTLSConfig(cfg)
+		return
+	}
 	p.transport.SetTLSConfig(cfg)
 	p.health.SetTLSConfig(cfg)
 }
 
 // SetExpire sets the expire duration in the lower p.transport.
-func (p *Proxy) SetExpire(expire time.Duration) { p.transport.SetExpire(expire) }
+func (p *Proxy) SetExpire(expire time.Duration) {
+	if p.exchanger != nil {
+		p.exchanger.SetExpire(expire)
+	}
+	p.transport.SetExpire(expire)
+}
 
 // Healthcheck kicks of a round of health checks for this proxy.
 func (p *Proxy) Healthcheck() {
@@ -65,12 +96,85 @@ func (p *Proxy) Down(maxfails uint32) bool {
 	}
 
 	fails := atomic.LoadUint32(&p.fails)
-	return fails > maxfails
+	if fails > maxfails {
+		return true
+	}
+
+	// An upstream that keeps sending bad replies is down for badReplyPenalty, after that it gets another chance.
+	if atomic.LoadUint32(&p.bad) <= maxfails {
+		return false
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&p.lastBad))) < badReplyPenalty
+}
+
+// badReply records a malformed reply, or a reply to another question, from this upstream.
+func (p *Proxy) badReply() {
+	atomic.AddUint32(&p.bad, 1)
+	atomic.StoreInt64(&p.lastBad, time.Now().UnixNano())
+	BadReplyCount.WithLabelValues(p.addr).Add(1)
+}
+
+// goodReply records a valid reply from this upstream.
+func (p *Proxy) goodReply() {
+	if atomic.LoadUint32(&p.bad) != 0 {
+		atomic.StoreUint32(&p.bad, 0)
+	}
+}
+
+// SetMaxConcurrent sets the maximum number of queries in flight to this upstream, 0 disables the limit.
+func (p *Proxy) SetMaxConcurrent(max int64) { p.maxConcurrent = max }
+
+// acquire registers a query in flight to this upstream. It returns false, and registers nothing, when the
+// upstream already has the maximum number of queries in flight. Each successful acquire must be followed
+// by a release.
+func (p *Proxy) acquire() bool {
+	n := atomic.AddInt64(&p.inflight, 1)
+	if p.maxConcurrent > 0 && n > p.maxConcurrent {
+		atomic.AddInt64(&p.inflight, -1)
+		return false
+	}
+	InflightGauge.WithLabelValues(p.addr).Set(float64(n))
+	return true
+}
+
+// release unregisters a query in flight to this upstream.
+func (p *Proxy) release() {
+	n := atomic.AddInt64(&p.inflight, -1)
+	InflightGauge.WithLabelValues(p.addr).Set(float64(n))
+}
+
+// RTT returns the moving average of the round trip time to this upstream, 0 when it hasn't replied yet.
+func (p *Proxy) RTT() time.Duration { return time.Duration(atomic.LoadInt64(&p.rtt)) }
+
+// updateRTT adds an observed round trip time to the moving average.
+func (p *Proxy) updateRTT(d time.Duration) {
+	if atomic.CompareAndSwapInt64(&p.rtt, 0, int64(d)) {
+		return
+	}
+	averageTimeout(&p.rtt, d, rttWeight)
+}
+
+// timedOut records that a query to this upstream timed out. The timeout counts as a round trip of
+// maxTimeout, so an upstream that only times out doesn't look fast once timeoutPenalty has passed.
+func (p *Proxy) timedOut() {
+	atomic.StoreInt64(&p.lastTimeout, time.Now().UnixNano())
+	p.updateRTT(maxTimeout)
+}
+
+// recentlyTimedOut returns true if a query to this upstream timed out in the last timeoutPenalty.
+func (p *Proxy) recentlyTimedOut() bool {
+	last := atomic.LoadInt64(&p.lastTimeout)
+	return last != 0 && time.Since(time.Unix(0, last)) < timeoutPenalty
 }
 
 // close stops the health checking goroutine.
-func (p *Proxy) close()     { p.probe.Stop() }
-func (p *Proxy) finalizer() { p.transport.Stop() }
+func (p *Proxy) close() { p.probe.Stop() }
+func (p *Proxy) finalizer() {
+	p.transport.Stop()
+	if p.exchanger != nil {
+		p.exchanger.Close()
+	}
+}
 
 // start starts the proxy's healthchecking.
 func (p *Proxy) start(duration time.Duration) {
@@ -78,8 +182,25 @@ func (p *Proxy) start(duration time.Duration) {
 	p.transport.Start()
 }
 
+// exchanger sends a message to an upstream and returns its reply. It is implemented by the DNS-over-HTTPS
+// and DNS-over-QUIC clients, these manage (and reuse) their own connections.
+type exchanger interface {
+	Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error)
+	SetTLSConfig(*tls.Config)
+	SetExpire(time.Duration)
+	Close()
+}
+
 const (
 	maxTimeout = 2 * time.Second
 	minTimeout = 200 * time.Millisecond
 	hcInterval = 500 * time.Millisecond
+
+	// rttWeight is the weight of the moving average of the round trip time, a new observation counts
+	// for 1/rttWeight.
+	rttWeight = 4
+	// timeoutPenalty is how long an upstream that timed out is tried last by the latency aware policies.
+	timeoutPenalty = 30 * time.Second
+	// badReplyPenalty is how long an upstream is down after more than max_fails bad replies in a row.
+	badReplyPenalty = 30 * time.Second
 )
@@ -2,7 +2,9 @@ package forward
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/pkg/transport"
@@ -121,3 +123,173 @@ func TestProtocolSelection(t *testing.T) {
 		}
 	}
 }
+
+func TestMaxConcurrent(t *testing.T) {
+	block := make(chan struct{})
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		<-block
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+	defer close(block)
+
+	tests := []struct {
+		input string
+		rcode int
+	}{
+		{"max_concurrent_upstream 1", dns.RcodeRefused},
+		{"max_concurrent 1\nlimit_response servfail", dns.RcodeServerFailure},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\n"+tc.input+"\n}")
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create forwarder: %s", i, err)
+		}
+		f.OnStartup()
+		defer f.OnShutdown()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		go f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+
+		// Wait for the first query to be in flight.
+		for atomic.LoadInt64(&f.proxies[0].inflight) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		rcode, err := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+		if err != ErrLimitExceeded {
+			t.Errorf("Test %d: expected error %q, got %v", i, ErrLimitExceeded, err)
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+		}
+	}
+}
+
+func TestProxyBadReply(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		// A reply for another question, with the same ID, before the real reply.
+		spoof := new(dns.Msg)
+		spoof.SetReply(r)
+		spoof.Question[0].Name = "attacker.example.net."
+		spoof.Answer = append(spoof.Answer, test.A("example.org. IN A 10.0.0.1"))
+		w.WriteMsg(spoof)
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	p := NewProxy(s.Addr, transport.DNS)
+	p.start(hcInterval)
+	defer p.close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+	ret, err := p.Connect(context.TODO(), state, options{})
+	if err != nil {
+		t.Fatalf("Expected a reply, got error: %s", err)
+	}
+	if x := ret.Question[0].Name; x != "example.org." {
+		t.Errorf("Expected the reply for %s, got %s", "example.org.", x)
+	}
+	if x := atomic.LoadUint32(&p.bad); x != 0 {
+		t.Errorf("Expected the bad replies to be reset by the good reply, got %d", x)
+	}
+}
+
+func TestProxyBadReplyDown(t *testing.T) {
+	p := NewProxy("127.0.0.1:53", transport.DNS)
+	for i := 0; i < 3; i++ {
+		if p.Down(2) {
+			t.Fatalf("Expected the proxy to be up after %d bad replies", i)
+		}
+		p.badReply()
+	}
+	if !p.Down(2) {
+		t.Errorf("Expected the proxy to be down after 3 bad replies")
+	}
+	if p.Down(0) {
+		t.Errorf("Expected the proxy to be up when max_fails is 0")
+	}
+
+	atomic.StoreInt64(&p.lastBad, time.Now().Add(-badReplyPenalty).UnixNano())
+	if p.Down(2) {
+		t.Errorf("Expected the proxy to be up after the penalty")
+	}
+
+	p.badReply()
+	p.goodReply()
+	if p.Down(2) {
+		t.Errorf("Expected the proxy to be up after a good reply")
+	}
+}
+
+func TestProxyReplyWithoutQuestion(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetRcode(r, dns.RcodeRefused)
+		ret.Question = nil
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	p := NewProxy(s.Addr, transport.DNS)
+	p.start(hcInterval)
+	defer p.close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+	ret, err := p.Connect(context.TODO(), state, options{})
+	if err != nil {
+		t.Fatalf("Expected a reply, got error: %s", err)
+	}
+	if ret.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[ret.Rcode])
+	}
+}
+
+func TestMatches(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+
+	reply := func(f func(m *dns.Msg)) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		f(m)
+		return m
+	}
+
+	tests := []struct {
+		ret      *dns.Msg
+		expected bool
+	}{
+		{reply(func(m *dns.Msg) {}), true},
+		{reply(func(m *dns.Msg) { m.Question[0].Name = "EXAMPLE.org." }), true},
+		{reply(func(m *dns.Msg) { m.Question[0].Name = "example.net." }), false},
+		{reply(func(m *dns.Msg) { m.Question[0].Qtype = dns.TypeAAAA }), false},
+		{reply(func(m *dns.Msg) { m.Response = false }), false},
+		{reply(func(m *dns.Msg) { m.Id++ }), false},
+		// Errors without a question section.
+		{reply(func(m *dns.Msg) { m.Question = nil; m.Rcode = dns.RcodeFormatError }), true},
+		{reply(func(m *dns.Msg) { m.Question = nil; m.Rcode = dns.RcodeNotImplemented }), true},
+		{reply(func(m *dns.Msg) { m.Question = nil; m.Rcode = dns.RcodeRefused }), true},
+		{reply(func(m *dns.Msg) { m.Question = nil; m.Rcode = dns.RcodeRefused; m.Id++ }), false},
+		{reply(func(m *dns.Msg) { m.Question = nil }), false},
+		{reply(func(m *dns.Msg) { m.Question = nil; m.Rcode = dns.RcodeNameError }), false},
+	}
+
+	for i, tc := range tests {
+		if x := matches(req, tc.ret); x != tc.expected {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.expected, x)
+		}
+	}
+}
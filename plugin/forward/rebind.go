@@ -0,0 +1,102 @@
+package forward
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// rebind protects clients against DNS rebinding: upstreams may not return private addresses, except for
+// the names in the allowed zones.
+type rebind struct {
+	rewrite bool     // rewrite private addresses to 0.0.0.0 and ::, instead of removing the records
+	allow   []string // zones that may have private addresses
+}
+
+// filter removes, or rewrites, the A and AAAA records with a private address from the answer section of m,
+// the reply to the query in state. It returns true when m was changed.
+func (r *rebind) filter(state request.Request, m *dns.Msg) bool {
+	if plugin.Zones(r.allow).Matches(state.Name()) != "" {
+		return false
+	}
+
+	filtered := false
+	answer := m.Answer[:0]
+	for _, rr := range m.Answer {
+		ip := address(rr)
+		if ip == nil || !private(ip) {
+			answer = append(answer, rr)
+			continue
+		}
+
+		filtered = true
+		if !r.rewrite {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.A:
+			rr.A = net.IPv4zero
+		case *dns.AAAA:
+			rr.AAAA = net.IPv6zero
+		}
+		answer = append(answer, rr)
+	}
+	m.Answer = answer
+	if !filtered {
+		return false
+	}
+
+	// The reply isn't what the upstream sent anymore.
+	m.AuthenticatedData = false
+	if o := m.IsEdns0(); o != nil {
+		ede := edns.NewEDE(edns.EDEFiltered, "private address filtered")
+		o.Option = append(o.Option, ede)
+	}
+	return true
+}
+
+// address returns the address of rr, or nil when rr is not an A or AAAA record.
+func address(rr dns.RR) net.IP {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return rr.A
+	case *dns.AAAA:
+		return rr.AAAA
+	}
+	return nil
+}
+
+// private returns true if ip is in one of the private, loopback, link-local or otherwise non-public ranges.
+func private(ip net.IP) bool {
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "this" network
+		"10.0.0.0/8",     // RFC 1918
+		"100.64.0.0/10",  // shared address space, RFC 6598
+		"127.0.0.0/8",    // loopback
+		"169.254.0.0/16", // link-local
+		"172.16.0.0/12",  // RFC 1918
+		"192.168.0.0/16", // RFC 1918
+		"::/128",         // unspecified
+		"::1/128",        // loopback
+		"fc00::/7",       // unique local, RFC 4193
+		"fe80::/10",      // link-local
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
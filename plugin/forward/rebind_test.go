@@ -0,0 +1,119 @@
+package forward
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestRebindFilter(t *testing.T) {
+	tests := []struct {
+		qname    string
+		rewrite  bool
+		answer   []dns.RR
+		filtered bool
+		expected []string
+	}{
+		{"example.org.", false, []dns.RR{test.A("example.org. IN A 192.0.2.1")}, false, []string{"192.0.2.1"}},
+		{"example.org.", false, []dns.RR{test.A("example.org. IN A 10.0.0.1"), test.A("example.org. IN A 192.0.2.1")}, true, []string{"192.0.2.1"}},
+		{"example.org.", false, []dns.RR{test.A("example.org. IN A 127.0.0.1")}, true, nil},
+		{"example.org.", false, []dns.RR{test.A("example.org. IN A 100.64.1.1")}, true, nil},
+		{"example.org.", false, []dns.RR{test.AAAA("example.org. IN AAAA fd00::1"), test.AAAA("example.org. IN AAAA 2001:db8::1")}, true, []string{"2001:db8::1"}},
+		{"example.org.", false, []dns.RR{test.AAAA("example.org. IN AAAA ::ffff:192.168.1.1")}, true, nil},
+		{"example.org.", false, []dns.RR{test.CNAME("example.org. IN CNAME a.example.net."), test.A("a.example.net. IN A 172.16.0.1")}, true, []string{"a.example.net."}},
+		{"example.org.", true, []dns.RR{test.A("example.org. IN A 10.0.0.1"), test.AAAA("example.org. IN AAAA ::1")}, true, []string{"0.0.0.0", "::"}},
+		{"www.corp.example.org.", false, []dns.RR{test.A("www.corp.example.org. IN A 10.0.0.1")}, false, []string{"10.0.0.1"}},
+	}
+
+	r := &rebind{allow: []string{"corp.example.org."}}
+	for i, tc := range tests {
+		r.rewrite = tc.rewrite
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, dns.TypeA)
+		req.SetEdns0(4096, false)
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.SetEdns0(4096, false)
+		m.AuthenticatedData = true
+		m.Answer = tc.answer
+
+		filtered := r.filter(request.Request{W: &test.ResponseWriter{}, Req: req}, m)
+		if filtered != tc.filtered {
+			t.Errorf("Test %d: expected filtered %t, got %t", i, tc.filtered, filtered)
+		}
+		if len(m.Answer) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d answers, got %d", i, len(tc.expected), len(m.Answer))
+			continue
+		}
+		for j, rr := range m.Answer {
+			x := ""
+			switch rr := rr.(type) {
+			case *dns.A:
+				x = rr.A.String()
+			case *dns.AAAA:
+				x = rr.AAAA.String()
+			case *dns.CNAME:
+				x = rr.Target
+			}
+			if x != tc.expected[j] {
+				t.Errorf("Test %d: expected answer %s, got %s", i, tc.expected[j], x)
+			}
+		}
+		if filtered {
+			if m.AuthenticatedData {
+				t.Errorf("Test %d: expected the AD bit to be cleared", i)
+			}
+			if x := extendedError(m); x != edns.EDEFiltered {
+				t.Errorf("Test %d: expected extended error %d, got %d", i, edns.EDEFiltered, x)
+			}
+		}
+	}
+}
+
+func TestSetupRebind(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		rewrite     bool
+		allow       []string
+		expectedErr string
+	}{
+		{"forward . 127.0.0.1 {\nrebind_protection\n}", false, false, nil, ""},
+		{"forward . 127.0.0.1 {\nrebind_protection drop\n}", false, false, nil, ""},
+		{"forward . 127.0.0.1 {\nrebind_protection rewrite\nrebind_allow corp.example.org Internal.\n}", false, true, []string{"corp.example.org.", "internal."}, ""},
+		{"forward . 127.0.0.1 {\nrebind_protection block\n}", true, false, nil, "unknown rebind_protection action"},
+		{"forward . 127.0.0.1 {\nrebind_protection drop rewrite\n}", true, false, nil, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nrebind_allow corp.example.org\n}", true, false, nil, "needs rebind_protection"},
+		{"forward . 127.0.0.1 {\nrebind_protection\nrebind_allow\n}", true, false, nil, "Wrong argument count"},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		f, err := parseForward(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			} else if !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, tc.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+			continue
+		}
+		if f.rebind.rewrite != tc.rewrite {
+			t.Errorf("Test %d: expected rewrite %t, got %t", i, tc.rewrite, f.rebind.rewrite)
+		}
+		if !reflect.DeepEqual(f.rebind.allow, tc.allow) {
+			t.Errorf("Test %d: expected allowed zones %v, got %v", i, tc.allow, f.rebind.allow)
+		}
+	}
+}
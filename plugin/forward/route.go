@@ -0,0 +1,193 @@
+package forward
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// group is a named set of upstreams with its own policy and TLS settings.
+type group struct {
+	name       string
+	proxies    []*Proxy
+	transports []string // the transport of each proxy
+	p          Policy
+
+	tlsConfig     *tls.Config
+	tlsServerName string
+}
+
+// table routes queries to groups: a query goes to the group of the longest zone that matches its name. The
+// routes come from the Corefile and, optionally, from a file that is reloaded when it changes.
+type table struct {
+	groups map[string]*group
+	routes [][]string        // the route properties, a group name followed by zones
+	inline map[string]*group // routes from the Corefile
+
+	file   string
+	reload time.Duration // 0 disables reloading
+
+	sync.RWMutex
+	zones map[string]*group
+	mtime time.Time
+	size  int64
+
+	stop chan struct{}
+}
+
+func newTable() *table {
+	return &table{groups: make(map[string]*group), inline: make(map[string]*group), zones: make(map[string]*group), reload: defaultRoutesReload}
+}
+
+// lookup returns the group for name, or nil when no zone matches. It is safe to call on a nil table.
+func (t *table) lookup(name string) *group {
+	if t == nil {
+		return nil
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+	if len(t.zones) == 0 {
+		return nil
+	}
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if g, ok := t.zones[name[off:]]; ok {
+			return g
+		}
+	}
+	// NextLabel stops at the last label, the root isn't part of the loop.
+	return t.zones["."]
+}
+
+// addRoute routes the zones to the group named name.
+func (t *table) addRoute(routes map[string]*group, name string, zones []string) error {
+	g, ok := t.groups[name]
+	if !ok {
+		return fmt.Errorf("unknown group %q", name)
+	}
+	for _, z := range zones {
+		z = plugin.Host(z).Normalize()
+		if r, ok := routes[z]; ok && r != g {
+			return fmt.Errorf("zone %q is routed to both %q and %q", z, r.name, g.name)
+		}
+		routes[z] = g
+	}
+	return nil
+}
+
+// load (re)reads the routes file, when it has changed since the last load. The routes from the file are
+// merged with the ones from the Corefile, which take precedence.
+func (t *table) load() error {
+	if t.file == "" {
+		t.Lock()
+		t.zones = t.inline
+		t.Unlock()
+		return nil
+	}
+
+	file, err := os.Open(t.file)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	t.RLock()
+	unchanged := t.mtime.Equal(stat.ModTime()) && t.size == stat.Size()
+	t.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	zones, err := t.parse(file)
+	if err != nil {
+		return err
+	}
+	for z, g := range t.inline {
+		zones[z] = g
+	}
+
+	t.Lock()
+	t.zones = zones
+	t.mtime = stat.ModTime()
+	t.size = stat.Size()
+	t.Unlock()
+	return nil
+}
+
+// parse parses a routes file: each line holds a group name followed by the zones routed to it, like the
+// route property. Empty lines and comments (starting with #) are skipped.
+func (t *table) parse(r io.Reader) (map[string]*group, error) {
+	zones := make(map[string]*group)
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected a group and at least one zone", t.file, i)
+		}
+		if err := t.addRoute(zones, fields[0], fields[1:]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", t.file, i, err)
+		}
+	}
+	return zones, scanner.Err()
+}
+
+// start loads the routes and starts reloading the routes file.
+func (t *table) start() error {
+	t.stop = make(chan struct{})
+	if err := t.load(); err != nil {
+		return err
+	}
+	if t.file == "" || t.reload == 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(t.reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.load(); err != nil {
+					log.Errorf("Failed to reload routes, keeping the current ones: %s", err)
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// close stops reloading the routes file.
+func (t *table) close() { close(t.stop) }
+
+// route returns the upstreams and the policy for name: those of the group it is routed to, or the default
+// ones from the TO list.
+func (f *Forward) route(name string) ([]*Proxy, Policy) {
+	if g := f.table.lookup(name); g != nil {
+		return g.proxies, g.p
+	}
+	return f.proxies, f.p
+}
+
+const defaultRoutesReload = 5 * time.Second
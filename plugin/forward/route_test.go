@@ -0,0 +1,242 @@
+package forward
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestRoute(t *testing.T) {
+	// dnstest servers share a handler, tell the upstreams apart by their port: the nth server answers
+	// with 127.0.0.n+1.
+	var mu sync.Mutex
+	ports := map[string]string{}
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		_, port, _ := net.SplitHostPort(w.LocalAddr().String())
+		mu.Lock()
+		ret.Answer = append(ret.Answer, test.A(r.Question[0].Name+" IN A "+ports[port]))
+		mu.Unlock()
+		w.WriteMsg(ret)
+	}
+	servers := make([]*dnstest.Server, 3)
+	for i := range servers {
+		servers[i] = dnstest.NewServer(handler)
+		defer servers[i].Close()
+		_, port, _ := net.SplitHostPort(servers[i].Addr)
+		mu.Lock()
+		ports[port] = "127.0.0." + strconv.Itoa(i+1)
+		mu.Unlock()
+	}
+
+	c := caddy.NewTestController("dns", `forward . `+servers[0].Addr+` {
+		group corp `+servers[1].Addr+` {
+			policy sequential
+		}
+		group lab `+servers[2].Addr+`
+		route corp corp.example.org internal
+		route lab lab.corp.example.org
+	}`)
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	if err := f.OnStartup(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.OnShutdown()
+
+	tests := []struct {
+		qname string
+		a     string
+	}{
+		{"example.org.", "127.0.0.1"},
+		{"corp.example.org.", "127.0.0.2"},
+		{"www.corp.example.org.", "127.0.0.2"},
+		{"host.internal.", "127.0.0.2"},
+		{"lab.corp.example.org.", "127.0.0.3"},
+		{"a.b.lab.corp.example.org.", "127.0.0.3"},
+		{"xcorp.example.org.", "127.0.0.1"},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected reply, got error: %s", i, err)
+		}
+		if x := rec.Msg.Answer[0].(*dns.A).A.String(); x != tc.a {
+			t.Errorf("Test %d: expected %s for %s, got %s", i, tc.a, tc.qname, x)
+		}
+	}
+
+	if x := f.table.groups["corp"].p.String(); x != "sequential" {
+		t.Errorf("Expected policy %s for group corp, got %s", "sequential", x)
+	}
+}
+
+func TestRouteLookup(t *testing.T) {
+	corp, root := &group{name: "corp"}, &group{name: "root"}
+	tb := &table{zones: map[string]*group{"corp.example.org.": corp, ".": root}}
+
+	tests := []struct {
+		qname string
+		group *group
+	}{
+		{"www.corp.example.org.", corp},
+		{"example.org.", root},
+		{"org.", root},
+		{".", root},
+	}
+	for i, tc := range tests {
+		if g := tb.lookup(tc.qname); g != tc.group {
+			t.Errorf("Test %d: expected group %v for %s, got %v", i, tc.group, tc.qname, g)
+		}
+	}
+}
+
+func TestRouteNoDefault(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . {\ngroup corp "+s.Addr+"\nroute corp corp.example.org\n}")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	// Without a next plugin this results in SERVFAIL.
+	if rcode, _ := f.ServeDNS(context.TODO(), rec, m); rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected %s for a query that isn't routed, got %s", dns.RcodeToString[dns.RcodeServerFailure], dns.RcodeToString[rcode])
+	}
+}
+
+func TestRoutesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "routes")
+	if err := ioutil.WriteFile(file, []byte("# routes\ncorp corp.example.org internal\nlab lab.example.org # the lab\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "forward . 127.0.0.1 {\ngroup corp 127.0.0.2\ngroup lab 127.0.0.3\nroute lab internal\nroutes "+file+" 0\n}")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	if err := f.OnStartup(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.OnShutdown()
+
+	tests := []struct {
+		qname string
+		group string
+	}{
+		{"www.corp.example.org.", "corp"},
+		{"www.lab.example.org.", "lab"},
+		{"host.internal.", "lab"}, // the Corefile takes precedence
+		{"example.org.", ""},
+	}
+	for i, tc := range tests {
+		g := f.table.lookup(tc.qname)
+		if (g == nil && tc.group != "") || (g != nil && g.name != tc.group) {
+			t.Errorf("Test %d: expected group %q for %s, got %v", i, tc.group, tc.qname, g)
+		}
+	}
+
+	// A broken file keeps the current routes.
+	if err := ioutil.WriteFile(file, []byte("unknown example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.table.load(); err == nil || !strings.Contains(err.Error(), "unknown group") {
+		t.Errorf("Expected unknown group error, got %v", err)
+	}
+	if g := f.table.lookup("www.corp.example.org."); g == nil || g.name != "corp" {
+		t.Errorf("Expected the routes to be kept")
+	}
+
+	if err := ioutil.WriteFile(file, []byte("lab example.org corp.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.table.load(); err != nil {
+		t.Fatal(err)
+	}
+	if g := f.table.lookup("www.corp.example.org."); g == nil || g.name != "lab" {
+		t.Errorf("Expected the reloaded routes")
+	}
+}
+
+func TestSetupRoute(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{"forward . 127.0.0.1 {\ngroup corp 127.0.0.2 {\npolicy round_robin\ntls_servername corp.example.org\n}\nroute corp example.org\n}", ""},
+		{"forward . {\ngroup corp tls://127.0.0.2 {\ntls\n}\n}", ""},
+		{"forward . {\n}", "Wrong argument count"},
+		{"forward . 127.0.0.1 {\ngroup corp\n}", "Wrong argument count"},
+		{"forward . 127.0.0.1 {\ngroup corp 127.0.0.2\ngroup corp 127.0.0.3\n}", "defined twice"},
+		{"forward . 127.0.0.1 {\ngroup corp 127.0.0.2 {\nforce_tcp\n}\n}", "unknown group property"},
+		{"forward . 127.0.0.1 {\ngroup corp 127.0.0.2 {\npolicy fast\n}\n}", "unknown policy"},
+		{"forward . 127.0.0.1 {\nroute corp example.org\n}", "unknown group"},
+		{"forward . 127.0.0.1 {\ngroup corp 127.0.0.2\ngroup lab 127.0.0.3\nroute corp example.org\nroute lab example.org\n}", "routed to both"},
+		{"forward . 127.0.0.1 {\nroutes\n}", "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nroutes /etc/routes -1s\n}", "can't be negative"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		_, err := parseForward(c)
+		if test.expectedErr == "" {
+			if err != nil {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expectedErr) {
+			t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErr, err)
+		}
+	}
+
+	c := caddy.NewTestController("dns", "forward . tls://127.0.0.1 {\ntls_servername dns.example.org\ngroup corp tls://127.0.0.2 {\ntls_servername corp.example.org\n}\n}")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := f.proxies[0].transport.tlsConfig.ServerName; x != "dns.example.org" {
+		t.Errorf("Expected server name %s, got %s", "dns.example.org", x)
+	}
+	if x := f.table.groups["corp"].proxies[0].transport.tlsConfig.ServerName; x != "corp.example.org" {
+		t.Errorf("Expected server name %s for group corp, got %s", "corp.example.org", x)
+	}
+}
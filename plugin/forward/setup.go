@@ -1,8 +1,11 @@
 package forward
 
 import (
+	"crypto/tls"
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
@@ -11,9 +14,11 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/tsig"
 
 	"github.com/mholt/caddy"
 	"github.com/mholt/caddy/caddyfile"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -38,7 +43,15 @@ func setup(c *caddy.Controller) error {
 	})
 
 	c.OnStartup(func() error {
-		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge)
+		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge, InflightGauge, MaxConcurrentRejectCount, HedgeCount, BadReplyCount, RebindCount, ValidationCount)
+		// Only the name of the key is known after parsing, get the key itself from the tsig plugin.
+		if f.opts.tsig != nil {
+			k, err := tsig.Lookup(c, f.opts.tsig.Name)
+			if err != nil {
+				return plugin.Error("forward", err)
+			}
+			f.opts.tsig = k
+		}
 		return f.OnStartup()
 	})
 
@@ -51,20 +64,46 @@ func setup(c *caddy.Controller) error {
 
 // OnStartup starts a goroutines for all proxies.
 func (f *Forward) OnStartup() (err error) {
-	for _, p := range f.proxies {
+	for _, p := range f.allProxies() {
 		p.start(f.hcInterval)
 	}
+	if f.table != nil {
+		if err := f.table.start(); err != nil {
+			return err
+		}
+	}
+	if f.validator != nil {
+		f.validator.run()
+	}
 	return nil
 }
 
 // OnShutdown stops all configured proxies.
 func (f *Forward) OnShutdown() error {
-	for _, p := range f.proxies {
+	for _, p := range f.allProxies() {
 		p.close()
 	}
+	if f.table != nil {
+		f.table.close()
+	}
+	if f.validator != nil {
+		close(f.validator.stop)
+	}
 	return nil
 }
 
+// allProxies returns the proxies from the TO list and those of all groups.
+func (f *Forward) allProxies() []*Proxy {
+	if f.table == nil {
+		return f.proxies
+	}
+	all := append([]*Proxy(nil), f.proxies...)
+	for _, g := range f.table.groups {
+		all = append(all, g.proxies...)
+	}
+	return all
+}
+
 // Close is a synonym for OnShutdown().
 func (f *Forward) Close() { f.OnShutdown() }
 
@@ -83,6 +122,9 @@ func parseForward(c *caddy.Controller) (*Forward, error) {
 		if err != nil {
 			return nil, err
 		}
+		if f.table != nil && f.table.file != "" && !filepath.IsAbs(f.table.file) {
+			f.table.file = filepath.Join(dnsserver.GetConfig(c).Root, f.table.file)
+		}
 	}
 	return f, nil
 }
@@ -97,40 +139,99 @@ func ParseForwardStanza(c *caddyfile.Dispenser) (*Forward, error) {
 	f.from = plugin.Host(f.from).Normalize()
 
 	to := c.RemainingArgs()
-	if len(to) == 0 {
+	var (
+		transports []string
+		err        error
+	)
+	if len(to) > 0 {
+		f.proxies, transports, err = newProxies(to)
+		if err != nil {
+			return f, err
+		}
+	}
+
+	for c.NextBlock() {
+		if err := parseBlock(c, f); err != nil {
+			return f, err
+		}
+	}
+	if f.rebindAllow != nil {
+		if f.rebind == nil {
+			return f, fmt.Errorf("rebind_allow needs rebind_protection")
+		}
+		f.rebind.allow = f.rebindAllow
+	}
+	// Without a TO list all queries must be routed to groups.
+	if len(f.proxies) == 0 && f.table == nil {
 		return f, c.ArgErr()
 	}
 
+	if f.tlsServerName != "" {
+		f.tlsConfig.ServerName = f.tlsServerName
+	}
+	if err := f.configure(f.proxies, transports, f.tlsConfig); err != nil {
+		return f, err
+	}
+
+	if f.table == nil {
+		return f, nil
+	}
+	for _, g := range f.table.groups {
+		tlsConfig := f.tlsConfig
+		if g.tlsConfig != nil {
+			tlsConfig = g.tlsConfig
+		}
+		if g.tlsServerName != "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = g.tlsServerName
+		}
+		if err := f.configure(g.proxies, g.transports, tlsConfig); err != nil {
+			return f, err
+		}
+	}
+	for _, r := range f.table.routes {
+		if err := f.table.addRoute(f.table.inline, r[0], r[1:]); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// newProxies returns the proxies, and their transports, for the hosts in to.
+func newProxies(to []string) ([]*Proxy, []string, error) {
 	toHosts, err := parse.HostPortOrFile(to...)
 	if err != nil {
-		return f, err
+		return nil, nil, err
+	}
+	if len(toHosts) > max {
+		return nil, nil, fmt.Errorf("more than %d TOs configured: %d", max, len(toHosts))
 	}
 
+	proxies := make([]*Proxy, len(toHosts))
 	transports := make([]string, len(toHosts))
 	for i, host := range toHosts {
 		trans, h := parse.Transport(host)
-		p := NewProxy(h, trans)
-		f.proxies = append(f.proxies, p)
+		proxies[i] = NewProxy(h, trans)
 		transports[i] = trans
 	}
+	return proxies, transports, nil
+}
 
-	for c.NextBlock() {
-		if err := parseBlock(c, f); err != nil {
-			return f, err
-		}
-	}
-
-	if f.tlsServerName != "" {
-		f.tlsConfig.ServerName = f.tlsServerName
-	}
-	for i := range f.proxies {
+// configure applies tlsConfig and the settings of f to the proxies.
+func (f *Forward) configure(proxies []*Proxy, transports []string, tlsConfig *tls.Config) error {
+	for i := range proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
-			f.proxies[i].SetTLSConfig(f.tlsConfig)
+		switch transports[i] {
+		case transport.TLS, transport.HTTPS, transport.QUIC:
+			proxies[i].SetTLSConfig(tlsConfig)
+		}
+		if f.opts.tsig != nil && proxies[i].exchanger != nil {
+			return fmt.Errorf("tsig is not supported for %s upstreams: %s", transports[i], proxies[i].addr)
 		}
-		f.proxies[i].SetExpire(f.expire)
+		proxies[i].SetExpire(f.expire)
+		proxies[i].SetMaxConcurrent(f.maxConcurrentUpstream)
 	}
-	return f, nil
+	return nil
 }
 
 func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
@@ -178,6 +279,25 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return c.ArgErr()
 		}
 		f.opts.preferUDP = true
+	case "tsig":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		f.opts.tsig = &tsig.Key{Name: dns.Fqdn(strings.ToLower(c.Val()))}
+	case "validate":
+		args := c.RemainingArgs()
+		if len(args) > 1 {
+			return c.ArgErr()
+		}
+		file := ""
+		if len(args) == 1 {
+			file = args[0]
+		}
+		a, err := parseAnchors(file)
+		if err != nil {
+			return err
+		}
+		f.validator = newValidator(a, f.exchange)
 	case "tls":
 		args := c.RemainingArgs()
 		if len(args) > 3 {
@@ -206,19 +326,121 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return fmt.Errorf("expire can't be negative: %s", dur)
 		}
 		f.expire = dur
-	case "policy":
+	case "max_concurrent", "max_concurrent_upstream":
+		name := c.Val()
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return fmt.Errorf("%s must be positive: %d", name, n)
+		}
+		if name == "max_concurrent" {
+			f.maxConcurrent = int64(n)
+		} else {
+			f.maxConcurrentUpstream = int64(n)
+		}
+	case "limit_response":
 		if !c.NextArg() {
 			return c.ArgErr()
 		}
-		switch x := c.Val(); x {
-		case "random":
-			f.p = &random{}
-		case "round_robin":
-			f.p = &roundRobin{}
-		case "sequential":
-			f.p = &sequential{}
+		switch x := strings.ToUpper(c.Val()); x {
+		case "REFUSED":
+			f.limitRcode = dns.RcodeRefused
+		case "SERVFAIL":
+			f.limitRcode = dns.RcodeServerFailure
 		default:
-			return c.Errf("unknown policy '%s'", x)
+			return c.Errf("unknown limit_response '%s'", c.Val())
+		}
+	case "hedge":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		f.hedge = true
+		if c.Val() == "race" {
+			f.hedgeDelay = 0
+			break
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return fmt.Errorf("hedge delay must be positive: %s", dur)
+		}
+		f.hedgeDelay = dur
+	case "policy":
+		p, err := parsePolicy(c)
+		if err != nil {
+			return err
+		}
+		f.p = p
+	case "rebind_protection":
+		args := c.RemainingArgs()
+		if len(args) > 1 {
+			return c.ArgErr()
+		}
+		f.rebind = &rebind{}
+		if len(args) == 1 {
+			switch args[0] {
+			case "drop":
+			case "rewrite":
+				f.rebind.rewrite = true
+			default:
+				return c.Errf("unknown rebind_protection action '%s'", args[0])
+			}
+		}
+	case "rebind_allow":
+		allow := c.RemainingArgs()
+		if len(allow) == 0 {
+			return c.ArgErr()
+		}
+		for i := range allow {
+			allow[i] = plugin.Host(allow[i]).Normalize()
+		}
+		f.rebindAllow = append(f.rebindAllow, allow...)
+	case "group":
+		g, err := parseGroup(c)
+		if err != nil {
+			return err
+		}
+		if f.table == nil {
+			f.table = newTable()
+		}
+		if _, ok := f.table.groups[g.name]; ok {
+			return c.Errf("group '%s' is defined twice", g.name)
+		}
+		f.table.groups[g.name] = g
+	case "route":
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return c.ArgErr()
+		}
+		if f.table == nil {
+			f.table = newTable()
+		}
+		f.table.routes = append(f.table.routes, args)
+	case "routes":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		if f.table == nil {
+			f.table = newTable()
+		}
+		f.table.file = args[0]
+		if len(args) == 2 {
+			dur, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			if dur < 0 {
+				return fmt.Errorf("routes reload can't be negative: %s", dur)
+			}
+			f.table.reload = dur
 		}
 
 	default:
@@ -228,4 +450,70 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 	return nil
 }
 
+// parsePolicy parses the argument of the policy property.
+func parsePolicy(c *caddyfile.Dispenser) (Policy, error) {
+	if !c.NextArg() {
+		return nil, c.ArgErr()
+	}
+	switch x := c.Val(); x {
+	case "random":
+		return &random{}, nil
+	case "round_robin":
+		return &roundRobin{}, nil
+	case "sequential":
+		return &sequential{}, nil
+	case "fastest":
+		return &fastest{}, nil
+	case "power_of_two":
+		return &powerOfTwo{}, nil
+	default:
+		return nil, c.Errf("unknown policy '%s'", x)
+	}
+}
+
+// parseGroup parses a group property: the name and the upstreams of the group, optionally followed by a
+// block with its policy and TLS settings.
+func parseGroup(c *caddyfile.Dispenser) (*group, error) {
+	args := c.RemainingArgs()
+	if len(args) < 2 {
+		return nil, c.ArgErr()
+	}
+
+	g := &group{name: args[0], p: new(random)}
+	var err error
+	g.proxies, g.transports, err = newProxies(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// RemainingArgs stops before an opening brace on the same line.
+	if !c.NextArg() {
+		return g, nil
+	}
+	for c.Next() && c.Val() != "}" {
+		switch c.Val() {
+		case "policy":
+			if g.p, err = parsePolicy(c); err != nil {
+				return nil, err
+			}
+		case "tls":
+			args := c.RemainingArgs()
+			if len(args) > 3 {
+				return nil, c.ArgErr()
+			}
+			if g.tlsConfig, err = pkgtls.NewTLSConfigFromArgs(args...); err != nil {
+				return nil, err
+			}
+		case "tls_servername":
+			if !c.NextArg() {
+				return nil, c.ArgErr()
+			}
+			g.tlsServerName = c.Val()
+		default:
+			return nil, c.Errf("unknown group property '%s'", c.Val())
+		}
+	}
+	return g, nil
+}
+
 const max = 15 // Maximum number of upstreams.
@@ -6,8 +6,12 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/tsig"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 func TestSetup(t *testing.T) {
@@ -31,9 +35,15 @@ func TestSetup(t *testing.T) {
 		{"forward . 127.0.0.1:8080", false, ".", nil, 2, options{}, ""},
 		{"forward . [::1]:53", false, ".", nil, 2, options{}, ""},
 		{"forward . [2003::1]:53", false, ".", nil, 2, options{}, ""},
+		{"forward . 127.0.0.1 {\ntsig Forward.Key\n}\n", false, ".", nil, 2, options{tsig: &tsig.Key{Name: "forward.key."}}, ""},
+		{"forward . https://1.1.1.1 quic://[2003::1]:8853", false, ".", nil, 2, options{}, ""},
 		// negative
 		{"forward . a27.0.0.1", true, "", nil, 0, options{}, "not an IP"},
 		{"forward . 127.0.0.1 {\nblaatl\n}\n", true, "", nil, 0, options{}, "unknown property"},
+		{"forward . 127.0.0.1 {\ntsig\n}\n", true, "", nil, 0, options{}, "Wrong argument count"},
+		{"forward . https://1.1.1.1 {\ntsig forward.key\n}\n", true, "", nil, 0, options{}, "tsig is not supported"},
+		{"forward . 127.0.0.1 {\nvalidate a b\n}\n", true, "", nil, 0, options{}, "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nvalidate /does/not/exist\n}\n", true, "", nil, 0, options{}, "no such file"},
 		{`forward . ::1
 		forward com ::2`, true, "", nil, 0, options{}, "plugin"},
 	}
@@ -67,12 +77,106 @@ func TestSetup(t *testing.T) {
 		if !test.shouldErr && f.maxfails != test.expectedFails {
 			t.Errorf("Test %d: expected: %d, got: %d", i, test.expectedFails, f.maxfails)
 		}
-		if !test.shouldErr && f.opts != test.expectedOpts {
+		if !test.shouldErr && !reflect.DeepEqual(f.opts, test.expectedOpts) {
 			t.Errorf("Test %d: expected: %v, got: %v", i, test.expectedOpts, f.opts)
 		}
 	}
 }
 
+func TestSetupValidate(t *testing.T) {
+	c := caddy.NewTestController("dns", "forward . 127.0.0.1 {\nvalidate\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.validator == nil {
+		t.Fatal("Expected a validator")
+	}
+	if an := f.validator.anchors.closest("example.org."); an == nil || an.zone != "." || len(an.ds) != 1 {
+		t.Errorf("Expected the root trust anchor")
+	}
+}
+
+func TestSetupMaxConcurrent(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		max            int64
+		maxUpstream    int64
+		limitRcode     int
+		expectedErrStr string
+	}{
+		{"forward . 127.0.0.1", false, 0, 0, dns.RcodeRefused, ""},
+		{"forward . 127.0.0.1 {\nmax_concurrent 1000\n}", false, 1000, 0, dns.RcodeRefused, ""},
+		{"forward . 127.0.0.1 {\nmax_concurrent_upstream 100\nlimit_response SERVFAIL\n}", false, 0, 100, dns.RcodeServerFailure, ""},
+		{"forward . 127.0.0.1 {\nlimit_response refused\n}", false, 0, 0, dns.RcodeRefused, ""},
+		{"forward . 127.0.0.1 {\nmax_concurrent 0\n}", true, 0, 0, 0, "must be positive"},
+		{"forward . 127.0.0.1 {\nmax_concurrent_upstream -1\n}", true, 0, 0, 0, "must be positive"},
+		{"forward . 127.0.0.1 {\nmax_concurrent many\n}", true, 0, 0, 0, "invalid syntax"},
+		{"forward . 127.0.0.1 {\nlimit_response nxdomain\n}", true, 0, 0, 0, "unknown limit_response"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErrStr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v", i, test.expectedErrStr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.maxConcurrent != test.max {
+			t.Errorf("Test %d: expected max_concurrent %d, got %d", i, test.max, f.maxConcurrent)
+		}
+		if x := f.proxies[0].maxConcurrent; x != test.maxUpstream {
+			t.Errorf("Test %d: expected max_concurrent_upstream %d, got %d", i, test.maxUpstream, x)
+		}
+		if f.limitRcode != test.limitRcode {
+			t.Errorf("Test %d: expected limit rcode %d, got %d", i, test.limitRcode, f.limitRcode)
+		}
+	}
+}
+
+func TestSetupHedge(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		hedge     bool
+		delay     time.Duration
+	}{
+		{"forward . 127.0.0.1", false, false, 0},
+		{"forward . 127.0.0.1 {\nhedge race\n}", false, true, 0},
+		{"forward . 127.0.0.1 {\nhedge 100ms\n}", false, true, 100 * time.Millisecond},
+		{"forward . 127.0.0.1 {\nhedge\n}", true, false, 0},
+		{"forward . 127.0.0.1 {\nhedge 0s\n}", true, false, 0},
+		{"forward . 127.0.0.1 {\nhedge soon\n}", true, false, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			continue
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			continue
+		}
+		if f.hedge != test.hedge || f.hedgeDelay != test.delay {
+			t.Errorf("Test %d: expected hedge %t with delay %s, got %t with %s", i, test.hedge, test.delay, f.hedge, f.hedgeDelay)
+		}
+	}
+}
+
 func TestSetupTLS(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -0,0 +1,844 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/singleflight"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// A validator makes forward a validating resolver. Queries are sent upstream with the DO and CD bits set and
+// the responses are validated by following the chain of trust from the closest trust anchor down to the zone
+// that signed the data; see RFC 4035, Section 5 and RFC 5155, Section 8.
+type validator struct {
+	anchors  *anchors
+	exchange func(context.Context, *dns.Msg) (*dns.Msg, error)
+
+	cache *cache.Cache // validated zones, keyed by name
+	group singleflight.Group
+
+	stop chan bool
+}
+
+// zone is a zone in the chain of trust. A zone without keys is insecure, as is everything below it.
+type zone struct {
+	name string
+	keys []*dns.DNSKEY
+}
+
+type zoneEntry struct {
+	z      *zone
+	expire time.Time
+}
+
+// bogus is the error returned when validation fails, code is the Extended DNS Error code.
+type bogus struct {
+	code uint16
+	msg  string
+}
+
+func (b *bogus) Error() string { return b.msg }
+
+func bogusf(code uint16, format string, a ...interface{}) error {
+	return &bogus{code: code, msg: fmt.Sprintf(format, a...)}
+}
+
+func newValidator(a *anchors, exchange func(context.Context, *dns.Msg) (*dns.Msg, error)) *validator {
+	return &validator{anchors: a, exchange: exchange, cache: cache.New(validateCapacity), stop: make(chan bool)}
+}
+
+// run refreshes the trust anchors periodically, so key rollovers are followed even without queries.
+func (v *validator) run() {
+	go func() {
+		wait := anchorRetry
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-time.After(wait):
+				wait = v.refresh(context.Background(), time.Now())
+			}
+		}
+	}()
+}
+
+// refresh fetches the DNSKEY RRsets of all trust anchors and returns the time until the next refresh, see
+// RFC 5011, Section 2.3.
+func (v *validator) refresh(ctx context.Context, now time.Time) time.Duration {
+	next := anchorRefresh
+	for _, an := range v.anchors.list {
+		z, ttl, err := v.fetchAnchor(ctx, an, now)
+		if err != nil {
+			log.Warningf("Failed to refresh trust anchor for %s: %s", an.zone, err)
+			next = anchorRetry
+			continue
+		}
+		v.store(an.zone, z, ttl, now)
+		if d := time.Duration(ttl) * time.Second / 2; d < next {
+			next = d
+		}
+	}
+	if next < anchorRetry {
+		next = anchorRetry
+	}
+	return next
+}
+
+// request returns a copy of r with the DO and CD bits set, so the upstream returns the records we need for
+// validation and doesn't validate itself.
+func (v *validator) request(r *dns.Msg) *dns.Msg {
+	r = r.Copy()
+	r.CheckingDisabled = true
+	if o := r.IsEdns0(); o != nil {
+		o.SetDo()
+		if o.UDPSize() < validateSize {
+			o.SetUDPSize(validateSize)
+		}
+		return r
+	}
+	o := &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+	o.SetUDPSize(validateSize)
+	o.SetDo()
+	// The OPT record goes before the TSIG record, if any.
+	r.Extra = append([]dns.RR{o}, r.Extra...)
+	return r
+}
+
+// reply validates ret, the response to the query in state, and returns the message for the client. Bogus
+// responses are replaced with a SERVFAIL carrying an Extended DNS Error.
+func (v *validator) reply(ctx context.Context, state request.Request, ret *dns.Msg) *dns.Msg {
+	secure := false
+	if ret.Rcode == dns.RcodeSuccess || ret.Rcode == dns.RcodeNameError {
+		var err error
+		secure, err = v.validate(ctx, state, ret, time.Now())
+		if err != nil {
+			ValidationCount.WithLabelValues("bogus").Add(1)
+			log.Debugf("Validation of %s/%s failed: %s", state.Name(), state.Type(), err)
+			return servfail(state, err)
+		}
+		if secure {
+			ValidationCount.WithLabelValues("secure").Add(1)
+		} else {
+			ValidationCount.WithLabelValues("insecure").Add(1)
+		}
+	}
+
+	ret.AuthenticatedData = secure && (state.Do() || state.Req.AuthenticatedData)
+	ret.CheckingDisabled = false
+
+	o := state.Req.IsEdns0()
+	if o == nil || !o.Do() {
+		qtype := state.QType()
+		ret.Answer = stripDNSSEC(ret.Answer, qtype)
+		ret.Ns = stripDNSSEC(ret.Ns, qtype)
+		ret.Extra = stripDNSSEC(ret.Extra, qtype)
+	}
+	// The OPT record must reflect the client's query, not ours.
+	for i, rr := range ret.Extra {
+		if ro, ok := rr.(*dns.OPT); ok {
+			if o == nil {
+				ret.Extra = append(ret.Extra[:i], ret.Extra[i+1:]...)
+				break
+			}
+			ro.SetDo(o.Do())
+			break
+		}
+	}
+	return ret
+}
+
+// servfail returns a SERVFAIL response for the query in state, with the reason in an Extended DNS Error
+// when the client supports EDNS0.
+func servfail(state request.Request, err error) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, dns.RcodeServerFailure)
+	o := state.Req.IsEdns0()
+	if o == nil {
+		return m
+	}
+	m.SetEdns0(o.UDPSize(), o.Do())
+
+	code := uint16(edns.EDEBogus)
+	if b, ok := err.(*bogus); ok {
+		code = b.code
+	}
+	mo := m.IsEdns0()
+	mo.Option = append(mo.Option, edns.NewEDE(code, err.Error()))
+	return m
+}
+
+// validate validates m, the response to the query in state, it returns true if m is secure and false if it's
+// insecure. When m is bogus the error says why.
+func (v *validator) validate(ctx context.Context, state request.Request, m *dns.Msg, now time.Time) (bool, error) {
+	secure := true
+
+	sets, sigs := rrsets(m.Answer)
+	for _, set := range sets {
+		h := set[0].Header()
+		s := sigs[setKey(h.Name, h.Rrtype)]
+		if len(s) == 0 && h.Rrtype == dns.TypeCNAME && synthesized(h.Name, sets) {
+			// The CNAME synthesized from a DNAME isn't signed, the DNAME is.
+			continue
+		}
+
+		name := h.Name
+		if len(s) > 0 {
+			name = s[0].SignerName
+			if !dns.IsSubDomain(name, h.Name) {
+				return false, bogusf(edns.EDEBogus, "signer %s is not a parent of %s", name, h.Name)
+			}
+		} else if h.Rrtype == dns.TypeDS {
+			name = parent(name)
+		}
+		z, err := v.zoneOf(ctx, name, now)
+		if err != nil {
+			return false, err
+		}
+		if z.keys == nil {
+			secure = false
+			continue
+		}
+		if err := z.verify(set, s, now); err != nil {
+			return false, err
+		}
+
+		// An answer synthesized from a wildcard needs the proof that there is no closer match.
+		if labels := int(s[0].Labels); labels < dns.CountLabel(h.Name) {
+			if err := z.verifyAll(m.Ns, now); err != nil {
+				return false, err
+			}
+			if !coverWildcard(m.Ns, h.Name, labels) {
+				return false, bogusf(edns.EDENSECMissing, "no proof that %s doesn't exist", h.Name)
+			}
+		}
+	}
+
+	target, qtype := follow(m.Answer, state.Name()), state.QType()
+	if m.Rcode == dns.RcodeSuccess && answered(m.Answer, target, qtype) {
+		return secure, nil
+	}
+
+	// Negative answer, the zone of target must prove that the name or type doesn't exist.
+	name := target
+	if qtype == dns.TypeDS {
+		name = parent(name)
+	}
+	z, err := v.zoneOf(ctx, name, now)
+	if err != nil {
+		return false, err
+	}
+	if z.keys == nil {
+		return false, nil
+	}
+	if err := z.verifyAll(m.Ns, now); err != nil {
+		return false, err
+	}
+	ok, optOut := deny(m.Ns, target, qtype, m.Rcode == dns.RcodeNameError)
+	if !ok {
+		return false, bogusf(edns.EDENSECMissing, "no proof of the denial of %s/%s", target, state.Type())
+	}
+	return secure && !optOut, nil
+}
+
+// zoneOf returns the zone name is in, following the chain of trust from the closest trust anchor.
+func (v *validator) zoneOf(ctx context.Context, name string, now time.Time) (*zone, error) {
+	name = strings.ToLower(name)
+	an := v.anchors.closest(name)
+	if an == nil {
+		return &zone{name: "."}, nil
+	}
+
+	z, err := v.cached(an.zone, now, func() (*zone, uint32, error) { return v.fetchAnchor(ctx, an, now) })
+	if err != nil {
+		return nil, err
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - dns.CountLabel(an.zone) - 1; i >= 0 && z.keys != nil; i-- {
+		child := dns.Fqdn(strings.Join(labels[i:], "."))
+		p := z
+		z, err = v.cached(child, now, func() (*zone, uint32, error) { return v.fetchCut(ctx, p, child, now) })
+		if err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+// cached returns the zone for name from the cache, or calls fetch and caches the result.
+func (v *validator) cached(name string, now time.Time, fetch func() (*zone, uint32, error)) (*zone, error) {
+	key := cache.Hash([]byte(name))
+	if e, ok := v.cache.Get(key); ok && now.Before(e.(*zoneEntry).expire) {
+		return e.(*zoneEntry).z, nil
+	}
+
+	z, err := v.group.Do(key, func() (interface{}, error) {
+		z, ttl, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		v.store(name, z, ttl, now)
+		return z, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return z.(*zone), nil
+}
+
+func (v *validator) store(name string, z *zone, ttl uint32, now time.Time) {
+	d := time.Duration(ttl) * time.Second
+	if d > maxValidateTTL {
+		d = maxValidateTTL
+	}
+	v.cache.Add(cache.Hash([]byte(name)), &zoneEntry{z: z, expire: now.Add(d)})
+}
+
+// fetchAnchor returns the zone of the trust anchor an, with its validated keys. The DNSKEY RRset is used to
+// update the trust anchor.
+func (v *validator) fetchAnchor(ctx context.Context, an *anchor, now time.Time) (*zone, uint32, error) {
+	if !an.supported() {
+		return &zone{name: an.zone}, maxTTL, nil
+	}
+	m, err := v.query(ctx, an.zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, 0, err
+	}
+	keys, sigs := rrset(m.Answer, an.zone, dns.TypeDNSKEY)
+	z, err := verifyKeys(an.zone, keys, sigs, an.trusts, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	if an.update(keys, sigs, now) {
+		if err := v.anchors.save(); err != nil {
+			log.Warningf("Failed to save the trust anchors: %s", err)
+		}
+	}
+	return z, minTTL(keys), nil
+}
+
+// fetchCut returns the zone child is in: the secure zone child when there is a signed delegation, an
+// insecure zone when the delegation isn't signed, or parent when child is no zone cut.
+func (v *validator) fetchCut(ctx context.Context, parent *zone, child string, now time.Time) (*zone, uint32, error) {
+	m, err := v.query(ctx, child, dns.TypeDS)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ds, sigs := rrset(m.Answer, child, dns.TypeDS)
+	if len(ds) > 0 {
+		if err := parent.verify(ds, sigs, now); err != nil {
+			return nil, 0, err
+		}
+		var supported []*dns.DS
+		for _, rr := range ds {
+			if d := rr.(*dns.DS); supportedDS(d) {
+				supported = append(supported, d)
+			}
+		}
+		if len(supported) == 0 {
+			// RFC 4035, Section 5.2: treat the zone as unsigned.
+			return &zone{name: child}, minTTL(ds), nil
+		}
+
+		m, err := v.query(ctx, child, dns.TypeDNSKEY)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys, sigs := rrset(m.Answer, child, dns.TypeDNSKEY)
+		z, err := verifyKeys(child, keys, sigs, func(k *dns.DNSKEY) bool { return matchDS(k, supported) }, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		return z, minTTL(append(ds, keys...)), nil
+	}
+
+	if err := parent.verifyAll(m.Ns, now); err != nil {
+		return nil, 0, err
+	}
+	ok, cut := denyDS(m.Ns, child)
+	if !ok {
+		return nil, 0, bogusf(edns.EDENSECMissing, "no proof of the denial of %s/DS", child)
+	}
+	if cut {
+		return &zone{name: child}, minTTL(m.Ns), nil
+	}
+	return parent, minTTL(m.Ns), nil
+}
+
+// query sends a query for name and qtype upstream.
+func (v *validator) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.CheckingDisabled = true
+	m.SetEdns0(validateSize, true)
+
+	r, err := v.exchange(ctx, m)
+	if err != nil {
+		return nil, bogusf(edns.EDENetworkError, "query for %s/%s failed: %s", name, dns.TypeToString[qtype], err)
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, bogusf(edns.EDENoReachableAuthority, "query for %s/%s returned %s", name, dns.TypeToString[qtype], dns.RcodeToString[r.Rcode])
+	}
+	return r, nil
+}
+
+// verifyKeys validates the DNSKEY RRset of zone name: it must be signed by one of its keys that is trusted.
+func verifyKeys(name string, keys []dns.RR, sigs []*dns.RRSIG, trusted func(*dns.DNSKEY) bool, now time.Time) (*zone, error) {
+	z := &zone{name: name}
+	for _, rr := range keys {
+		z.keys = append(z.keys, rr.(*dns.DNSKEY))
+	}
+
+	err := bogusf(edns.EDEDNSKEYMissing, "no trusted key for %s", name)
+	for _, k := range z.keys {
+		if !trusted(k) {
+			continue
+		}
+		if err = (&zone{name: name, keys: []*dns.DNSKEY{k}}).verify(keys, sigs, now); err == nil {
+			return z, nil
+		}
+	}
+	return nil, err
+}
+
+// verify validates the RRset with the signatures sigs, made with the keys of z.
+func (z *zone) verify(set []dns.RR, sigs []*dns.RRSIG, now time.Time) error {
+	h := set[0].Header()
+	if len(sigs) == 0 {
+		return bogusf(edns.EDERRSIGsMissing, "no signatures for %s/%s", h.Name, dns.TypeToString[h.Rrtype])
+	}
+
+	err := bogusf(edns.EDEDNSKEYMissing, "no key of %s for the signatures of %s/%s", z.name, h.Name, dns.TypeToString[h.Rrtype])
+	for _, sig := range sigs {
+		if !strings.EqualFold(sig.SignerName, z.name) {
+			continue
+		}
+		for _, k := range z.keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm || k.Flags&dns.REVOKE != 0 {
+				continue
+			}
+			if e := sig.Verify(k, set); e != nil {
+				err = bogusf(edns.EDEBogus, "signature of %s/%s with key %d: %s", h.Name, dns.TypeToString[h.Rrtype], sig.KeyTag, e)
+				continue
+			}
+			if !sig.ValidityPeriod(now) {
+				if int64(sig.Inception) > now.Unix() {
+					err = bogusf(edns.EDESignatureNotYetValid, "signature of %s/%s is not yet valid", h.Name, dns.TypeToString[h.Rrtype])
+				} else {
+					err = bogusf(edns.EDESignatureExpired, "signature of %s/%s is expired", h.Name, dns.TypeToString[h.Rrtype])
+				}
+				continue
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+// verifyAll validates the SOA, NSEC and NSEC3 RRsets in rrs with the keys of z.
+func (z *zone) verifyAll(rrs []dns.RR, now time.Time) error {
+	sets, sigs := rrsets(rrs)
+	for _, set := range sets {
+		h := set[0].Header()
+		switch h.Rrtype {
+		case dns.TypeSOA, dns.TypeNSEC, dns.TypeNSEC3:
+			if err := z.verify(set, sigs[setKey(h.Name, h.Rrtype)], now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deny returns true if the NSEC or NSEC3 records in rrs prove that name doesn't exist, when nxdomain is true,
+// or that it has no records of type qtype. When the proof relies on an opt-out NSEC3 record optOut is true.
+func deny(rrs []dns.RR, name string, qtype uint16, nxdomain bool) (ok, optOut bool) {
+	nsec, nsec3 := denials(rrs)
+	if len(nsec3) > 0 {
+		return deny3(nsec3, name, qtype, nxdomain)
+	}
+
+	if !nxdomain {
+		for _, n := range nsec {
+			if strings.EqualFold(n.Hdr.Name, name) {
+				return !hasType(n.TypeBitMap, qtype) && !hasType(n.TypeBitMap, dns.TypeCNAME), false
+			}
+		}
+	}
+	c := coverNSEC(nsec, name)
+	if c == nil {
+		return false, false
+	}
+	if !nxdomain && dns.IsSubDomain(name, c.NextDomain) {
+		// name is an empty non-terminal.
+		return true, false
+	}
+	// No wildcard must exist at the closest encloser, or for NODATA the wildcard doesn't have the type.
+	ce := closestEncloser(name, c)
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+	if nxdomain {
+		return coverNSEC(nsec, wildcard) != nil, false
+	}
+	for _, n := range nsec {
+		if strings.EqualFold(n.Hdr.Name, wildcard) {
+			return !hasType(n.TypeBitMap, qtype) && !hasType(n.TypeBitMap, dns.TypeCNAME), false
+		}
+	}
+	return false, false
+}
+
+// deny3 is deny for NSEC3 records; see RFC 5155, Section 8.
+func deny3(nsec3 []*dns.NSEC3, name string, qtype uint16, nxdomain bool) (ok, optOut bool) {
+	if !nxdomain {
+		for _, n := range nsec3 {
+			if n.Match(name) {
+				return !hasType(n.TypeBitMap, qtype) && !hasType(n.TypeBitMap, dns.TypeCNAME), false
+			}
+		}
+	}
+
+	// Closest encloser proof, RFC 5155, Section 8.3.
+	ce, next := "", ""
+	for c, n := name, name; ; {
+		if match3(nsec3, c) != nil {
+			ce, next = c, n
+			break
+		}
+		off, end := dns.NextLabel(c, 0)
+		if end {
+			return false, false
+		}
+		c, n = c[off:], c
+	}
+	if ce == name {
+		return false, false
+	}
+	cover := cover3(nsec3, next)
+	if cover == nil {
+		return false, false
+	}
+	optOut = cover.Flags&1 == 1
+	if !nxdomain && qtype == dns.TypeDS && optOut {
+		// Section 8.6: an insecure delegation in an opt-out span.
+		return true, true
+	}
+
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+	if nxdomain {
+		return cover3(nsec3, wildcard) != nil, optOut
+	}
+	if w := match3(nsec3, wildcard); w != nil {
+		return !hasType(w.TypeBitMap, qtype) && !hasType(w.TypeBitMap, dns.TypeCNAME), optOut
+	}
+	return false, false
+}
+
+// denyDS returns true if the NSEC or NSEC3 records in rrs prove that name has no DS records, cut is true if
+// name is an (insecure) delegation.
+func denyDS(rrs []dns.RR, name string) (ok, cut bool) {
+	nsec, nsec3 := denials(rrs)
+	for _, n := range nsec {
+		if strings.EqualFold(n.Hdr.Name, name) {
+			if hasType(n.TypeBitMap, dns.TypeDS) {
+				return false, false
+			}
+			return true, hasType(n.TypeBitMap, dns.TypeNS) && !hasType(n.TypeBitMap, dns.TypeSOA)
+		}
+	}
+	if coverNSEC(nsec, name) != nil {
+		return true, false
+	}
+
+	if n := match3(nsec3, name); n != nil {
+		if hasType(n.TypeBitMap, dns.TypeDS) {
+			return false, false
+		}
+		return true, hasType(n.TypeBitMap, dns.TypeNS) && !hasType(n.TypeBitMap, dns.TypeSOA)
+	}
+	if n := cover3(nsec3, name); n != nil {
+		// In an opt-out span there may be an insecure delegation.
+		return true, n.Flags&1 == 1
+	}
+	return false, false
+}
+
+// coverWildcard returns true if the NSEC or NSEC3 records in rrs prove that there is no closer match for
+// name than the wildcard with labels labels.
+func coverWildcard(rrs []dns.RR, name string, labels int) bool {
+	nsec, nsec3 := denials(rrs)
+	if len(nsec3) > 0 {
+		idx := dns.Split(name)
+		next := name[idx[len(idx)-labels-1]:]
+		return cover3(nsec3, next) != nil
+	}
+	return coverNSEC(nsec, name) != nil
+}
+
+func denials(rrs []dns.RR) ([]*dns.NSEC, []*dns.NSEC3) {
+	var (
+		nsec  []*dns.NSEC
+		nsec3 []*dns.NSEC3
+	)
+	for _, rr := range rrs {
+		switch x := rr.(type) {
+		case *dns.NSEC:
+			nsec = append(nsec, x)
+		case *dns.NSEC3:
+			nsec3 = append(nsec3, x)
+		}
+	}
+	return nsec, nsec3
+}
+
+// coverNSEC returns the NSEC record that covers name.
+func coverNSEC(nsec []*dns.NSEC, name string) *dns.NSEC {
+	for _, n := range nsec {
+		owner, next := n.Hdr.Name, n.NextDomain
+		if canonicalLess(owner, name) && (canonicalLess(name, next) || !canonicalLess(owner, next)) {
+			return n
+		}
+	}
+	return nil
+}
+
+func match3(nsec3 []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3 {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+func cover3(nsec3 []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3 {
+		if n.Cover(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// closestEncloser returns the longest ancestor of name that exists according to the NSEC record c that
+// covers name.
+func closestEncloser(name string, c *dns.NSEC) string {
+	ce := ""
+	for _, x := range []string{c.Hdr.Name, c.NextDomain} {
+		n := dns.CompareDomainName(name, x)
+		if n == 0 {
+			continue
+		}
+		idx := dns.Split(name)
+		if a := name[idx[len(idx)-n]:]; len(a) > len(ce) {
+			ce = a
+		}
+	}
+	if ce == "" {
+		return "."
+	}
+	return ce
+}
+
+// canonicalLess returns true if a sorts before b in the canonical DNS name order; see RFC 4034, Section 6.1.
+func canonicalLess(a, b string) bool {
+	la, lb := dns.SplitDomainName(strings.ToLower(a)), dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if la[i] != lb[j] {
+			return la[i] < lb[j]
+		}
+	}
+	return len(la) < len(lb)
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+// rrsets groups the records in rrs, except the signatures, into RRsets. The signatures are returned
+// by the name and type they cover.
+func rrsets(rrs []dns.RR) ([][]dns.RR, map[string][]*dns.RRSIG) {
+	var sets [][]dns.RR
+	idx := map[string]int{}
+	sigs := map[string][]*dns.RRSIG{}
+	for _, rr := range rrs {
+		h := rr.Header()
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			k := setKey(h.Name, sig.TypeCovered)
+			sigs[k] = append(sigs[k], sig)
+			continue
+		}
+		if h.Rrtype == dns.TypeOPT {
+			continue
+		}
+		k := setKey(h.Name, h.Rrtype)
+		i, ok := idx[k]
+		if !ok {
+			i = len(sets)
+			idx[k] = i
+			sets = append(sets, nil)
+		}
+		sets[i] = append(sets[i], rr)
+	}
+	return sets, sigs
+}
+
+// rrset returns the RRset of name and qtype in rrs and its signatures.
+func rrset(rrs []dns.RR, name string, qtype uint16) ([]dns.RR, []*dns.RRSIG) {
+	var (
+		set  []dns.RR
+		sigs []*dns.RRSIG
+	)
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == qtype {
+			sigs = append(sigs, sig)
+			continue
+		}
+		if rr.Header().Rrtype == qtype {
+			set = append(set, rr)
+		}
+	}
+	return set, sigs
+}
+
+func setKey(name string, t uint16) string { return strings.ToLower(name) + "/" + dns.TypeToString[t] }
+
+// synthesized returns true if the CNAME at name is synthesized from one of the DNAME RRsets in sets.
+func synthesized(name string, sets [][]dns.RR) bool {
+	for _, set := range sets {
+		if h := set[0].Header(); h.Rrtype == dns.TypeDNAME && dns.IsSubDomain(h.Name, name) && !strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// follow follows the CNAME chain in rrs from name and returns the name it ends at.
+func follow(rrs []dns.RR, name string) string {
+	for i := 0; i < maxCNAME; i++ {
+		found := false
+		for _, rr := range rrs {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, name) {
+				name, found = c.Target, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return name
+}
+
+// answered returns true if rrs holds records of type qtype for name.
+func answered(rrs []dns.RR, name string, qtype uint16) bool {
+	for _, rr := range rrs {
+		h := rr.Header()
+		if strings.EqualFold(h.Name, name) && (h.Rrtype == qtype || qtype == dns.TypeANY) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripDNSSEC returns rrs without the DNSSEC records, unless they are of type qtype.
+func stripDNSSEC(rrs []dns.RR, qtype uint16) []dns.RR {
+	j := 0
+	for _, rr := range rrs {
+		switch t := rr.Header().Rrtype; t {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			if t != qtype {
+				continue
+			}
+		}
+		rrs[j] = rr
+		j++
+	}
+	return rrs[:j]
+}
+
+func minTTL(rrs []dns.RR) uint32 {
+	ttl := uint32(maxTTL)
+	for _, rr := range rrs {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return ttl
+}
+
+func parent(name string) string {
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return "."
+	}
+	return name[off:]
+}
+
+// exchange sends m to the upstreams, in the order of the policy, and returns the first response.
+func (f *Forward) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	state := request.Request{W: &validateWriter{}, Req: m}
+	err := ErrNoHealthy
+	proxies, pol := f.route(strings.ToLower(m.Question[0].Name))
+	for _, proxy := range pol.List(proxies) {
+		opts := f.opts
+		opts.preferUDP = true
+		for {
+			var ret *dns.Msg
+			ret, err = proxy.Connect(ctx, state, opts)
+			if err == ErrCachedClosed {
+				continue
+			}
+			if ret != nil && ret.Truncated && !opts.forceTCP {
+				opts.forceTCP = true
+				continue
+			}
+			if err == nil {
+				return ret, nil
+			}
+			break
+		}
+	}
+	return nil, err
+}
+
+// validateWriter is the writer for the queries of the validator. Its remote address is a TCP address so
+// the maximum message size is used.
+type validateWriter struct{ nonwriter.Writer }
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (*validateWriter) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv6loopback} }
+
+const (
+	validateSize     = 1232 // EDNS0 buffer size for upstream queries
+	validateCapacity = 10000
+	maxValidateTTL   = time.Hour
+	maxTTL           = 86400
+	maxCNAME         = 8
+
+	anchorRefresh = 15 * 24 * time.Hour // upper bound of the refresh of the trust anchors
+	anchorRetry   = time.Hour           // lower bound, and the interval after a failure
+)
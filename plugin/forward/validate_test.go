@@ -0,0 +1,302 @@
+package forward
+
+import (
+	"context"
+	"crypto"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+type testKey struct {
+	k    *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestKey(t *testing.T, zone string) *testKey {
+	k := &dns.DNSKEY{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags: 257, Protocol: 3, Algorithm: dns.ECDSAP256SHA256}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{k: k, priv: priv.(crypto.Signer)}
+}
+
+// sign parses the records in rrs and returns them with a signature that is valid from an hour ago for 60 days.
+func (k *testKey) sign(t *testing.T, rrs ...string) []dns.RR {
+	now := time.Now()
+	return k.signAt(t, now.Add(-time.Hour), now.Add(60*24*time.Hour), rrs...)
+}
+
+func (k *testKey) signAt(t *testing.T, incep, expir time.Time, rrs ...string) []dns.RR {
+	var set []dns.RR
+	for _, s := range rrs {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set = append(set, rr)
+	}
+	return append(set, k.signSet(t, incep, expir, set))
+}
+
+func (k *testKey) signSet(t *testing.T, incep, expir time.Time, set []dns.RR) *dns.RRSIG {
+	sig := &dns.RRSIG{Hdr: dns.RR_Header{Ttl: set[0].Header().Ttl}, KeyTag: k.k.KeyTag(), SignerName: k.k.Hdr.Name,
+		Algorithm: k.k.Algorithm, Inception: uint32(incep.Unix()), Expiration: uint32(expir.Unix())}
+	if err := sig.Sign(k.priv, set); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+type testReply struct {
+	rcode  int
+	answer []dns.RR
+	ns     []dns.RR
+}
+
+// newTestUpstream returns an upstream that serves the signed zone example.org., with the signed child zone
+// secure.example.org. and the unsigned child zone insecure.example.org., and the unsigned example.net.
+func newTestUpstream(t *testing.T, org, sec *testKey, dnskeyCount *int32) *dnstest.Server {
+	concat := func(sets ...[]dns.RR) []dns.RR {
+		var rrs []dns.RR
+		for _, s := range sets {
+			rrs = append(rrs, s...)
+		}
+		return rrs
+	}
+	now := time.Now()
+	soa := org.sign(t, "example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600")
+	nodata := func(nsec string) testReply { return testReply{ns: concat(soa, org.sign(t, nsec))} }
+
+	bogus := org.sign(t, "bogus.example.org. 3600 IN A 127.0.0.1")
+	bogus[0].(*dns.A).A[3] = 2 // invalidates the signature
+
+	secSOA := sec.sign(t, "secure.example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600")
+	wildcard := sec.sign(t, "*.secure.example.org. 3600 IN A 127.0.0.3")
+	wildcard[0].Header().Name = "w.secure.example.org."
+	wildcard[1].Header().Name = "w.secure.example.org."
+	wildcardNSEC := sec.sign(t, "*.secure.example.org. 3600 IN NSEC www.secure.example.org. A RRSIG NSEC")
+	nx := testReply{rcode: dns.RcodeNameError, ns: concat(soa,
+		org.sign(t, "example.org. 3600 IN NSEC bogus.example.org. NS SOA RRSIG NSEC DNSKEY"),
+		org.sign(t, "insecure.example.org. 3600 IN NSEC secure.example.org. NS RRSIG NSEC"))}
+	nx2 := testReply{rcode: dns.RcodeNameError, ns: soa}
+
+	replies := map[string]testReply{
+		"example.org./DNSKEY": {answer: org.sign(t, org.k.String())},
+		"www.example.org./A":  {answer: org.sign(t, "www.example.org. 3600 IN A 127.0.0.1")},
+		"www.example.org./MX": nodata("www.example.org. 3600 IN NSEC example.org. A RRSIG NSEC"),
+		"www.example.org./DS": nodata("www.example.org. 3600 IN NSEC example.org. A RRSIG NSEC"),
+
+		"bogus.example.org./A":    {answer: bogus},
+		"bogus.example.org./DS":   nodata("bogus.example.org. 3600 IN NSEC expired.example.org. A RRSIG NSEC"),
+		"expired.example.org./A":  {answer: org.signAt(t, now.Add(-48*time.Hour), now.Add(-24*time.Hour), "expired.example.org. 3600 IN A 127.0.0.1")},
+		"expired.example.org./DS": nodata("expired.example.org. 3600 IN NSEC insecure.example.org. A RRSIG NSEC"),
+
+		"insecure.example.org./DS":    nodata("insecure.example.org. 3600 IN NSEC secure.example.org. NS RRSIG NSEC"),
+		"www.insecure.example.org./A": {answer: []dns.RR{test.A("www.insecure.example.org. 3600 IN A 127.0.0.1")}},
+
+		"secure.example.org./DS":     {answer: org.sign(t, sec.k.ToDS(dns.SHA256).String())},
+		"secure.example.org./DNSKEY": {answer: sec.sign(t, sec.k.String())},
+		"www.secure.example.org./A":  {answer: sec.sign(t, "www.secure.example.org. 3600 IN A 127.0.0.2")},
+		"www.secure.example.org./DS": {ns: concat(secSOA, sec.sign(t, "www.secure.example.org. 3600 IN NSEC secure.example.org. A RRSIG NSEC"))},
+		"w.secure.example.org./A":    {answer: wildcard, ns: wildcardNSEC},
+		"w.secure.example.org./DS":   {ns: concat(secSOA, wildcardNSEC)},
+
+		"nx.example.org./A":   nx,
+		"nx.example.org./DS":  nx,
+		"nx2.example.org./A":  nx2,
+		"nx2.example.org./DS": nx2,
+
+		"example.net./A": {answer: []dns.RR{test.A("example.net. 3600 IN A 127.0.0.1")}},
+	}
+
+	return dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		q := r.Question[0]
+		if q.Qtype == dns.TypeDNSKEY {
+			atomic.AddInt32(dnskeyCount, 1)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		reply, ok := replies[q.Name+"/"+dns.TypeToString[q.Qtype]]
+		if !ok {
+			reply.rcode = dns.RcodeNameError
+		}
+		ret.Rcode = reply.rcode
+		ret.Answer = reply.answer
+		ret.Ns = reply.ns
+		ret.SetEdns0(4096, true)
+		w.WriteMsg(ret)
+	})
+}
+
+func newTestValidator(t *testing.T, anchors string) (*Forward, *int32, func()) {
+	org, sec := newTestKey(t, "example.org."), newTestKey(t, "secure.example.org.")
+	dnskeyCount := new(int32)
+	s := newTestUpstream(t, org, sec, dnskeyCount)
+
+	dir, err := ioutil.TempDir("", "coredns-forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "anchors")
+	if anchors == "" {
+		anchors = org.k.String()
+	}
+	if err := ioutil.WriteFile(file, []byte(anchors+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\nvalidate "+file+"\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.OnStartup()
+	return f, dnskeyCount, func() {
+		f.OnShutdown()
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f, dnskeyCount, cleanup := newTestValidator(t, "")
+	defer cleanup()
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		do    bool
+		cd    bool
+
+		rcode int
+		ad    bool
+		sigs  bool
+		ede   int // -1 for no Extended DNS Error
+	}{
+		{"www.example.org.", dns.TypeA, true, false, dns.RcodeSuccess, true, true, -1},
+		{"www.example.org.", dns.TypeA, false, false, dns.RcodeSuccess, false, false, -1},
+		{"www.example.org.", dns.TypeMX, true, false, dns.RcodeSuccess, true, false, -1},
+		{"www.secure.example.org.", dns.TypeA, true, false, dns.RcodeSuccess, true, true, -1},
+		{"w.secure.example.org.", dns.TypeA, true, false, dns.RcodeSuccess, true, true, -1},
+		{"www.insecure.example.org.", dns.TypeA, true, false, dns.RcodeSuccess, false, false, -1},
+		{"nx.example.org.", dns.TypeA, true, false, dns.RcodeNameError, true, false, -1},
+		{"example.net.", dns.TypeA, true, false, dns.RcodeSuccess, false, false, -1},
+		{"bogus.example.org.", dns.TypeA, true, false, dns.RcodeServerFailure, false, false, edns.EDEBogus},
+		{"expired.example.org.", dns.TypeA, true, false, dns.RcodeServerFailure, false, false, edns.EDESignatureExpired},
+		{"nx2.example.org.", dns.TypeA, true, false, dns.RcodeServerFailure, false, false, edns.EDENSECMissing},
+		// Checking disabled, the client gets the bogus data.
+		{"bogus.example.org.", dns.TypeA, true, true, dns.RcodeSuccess, false, true, -1},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.SetEdns0(4096, tc.do)
+		m.CheckingDisabled = tc.cd
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(ctx, rec, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		r := rec.Msg
+		if r.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[r.Rcode])
+		}
+		if r.AuthenticatedData != tc.ad {
+			t.Errorf("Test %d: expected AD to be %t", i, tc.ad)
+		}
+		sigs := false
+		for _, rr := range r.Answer {
+			if rr.Header().Rrtype == dns.TypeRRSIG {
+				sigs = true
+			}
+		}
+		if sigs != tc.sigs {
+			t.Errorf("Test %d: expected signatures in the answer to be %t", i, tc.sigs)
+		}
+		if x := extendedError(r); x != tc.ede {
+			t.Errorf("Test %d: expected extended error %d, got %d", i, tc.ede, x)
+		}
+		if o := r.IsEdns0(); o == nil || o.Do() != tc.do {
+			t.Errorf("Test %d: expected an OPT record with DO %t", i, tc.do)
+		}
+	}
+
+	// The keys are cached: one query for each zone.
+	if x := atomic.LoadInt32(dnskeyCount); x != 2 {
+		t.Errorf("Expected 2 DNSKEY queries, got %d", x)
+	}
+}
+
+func TestValidateNoEDNS(t *testing.T) {
+	f, _, cleanup := newTestValidator(t, "")
+	defer cleanup()
+
+	for _, qname := range []string{"www.example.org.", "bogus.example.org."} {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		m.AuthenticatedData = true
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		f.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg.IsEdns0() != nil {
+			t.Errorf("Expected no OPT record in the response for %s", qname)
+		}
+		for _, rr := range rec.Msg.Answer {
+			if rr.Header().Rrtype == dns.TypeRRSIG {
+				t.Errorf("Expected no signatures in the response for %s", qname)
+			}
+		}
+		if qname == "www.example.org." && !rec.Msg.AuthenticatedData {
+			t.Errorf("Expected AD for %s, the query has the AD bit", qname)
+		}
+	}
+}
+
+func TestValidateUntrusted(t *testing.T) {
+	// A trust anchor that matches none of the keys.
+	f, _, cleanup := newTestValidator(t, "example.org. IN DS 1 13 2 "+strings.Repeat("00", 32))
+	defer cleanup()
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	m.SetEdns0(4096, true)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	f.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+	if x := extendedError(rec.Msg); x != edns.EDEDNSKEYMissing {
+		t.Errorf("Expected extended error %d, got %d", edns.EDEDNSKEYMissing, x)
+	}
+}
+
+func extendedError(m *dns.Msg) int {
+	o := m.IsEdns0()
+	if o == nil {
+		return -1
+	}
+	for _, e := range o.Option {
+		if l, ok := e.(*dns.EDNS0_LOCAL); ok && l.Code == edns.OptionEDE {
+			return int(binary.BigEndian.Uint16(l.Data))
+		}
+	}
+	return -1
+}
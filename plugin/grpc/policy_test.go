@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFastest(t *testing.T) {
+	p := []*Proxy{{addr: "10.0.0.1:53"}, {addr: "10.0.0.2:53"}, {addr: "10.0.0.3:53"}}
+	p[0].updateRTT(30 * time.Millisecond)
+	p[1].updateRTT(10 * time.Millisecond)
+	p[2].updateRTT(20 * time.Millisecond)
+
+	f := &fastest{}
+	if list := f.List(p); list[0] != p[1] || list[1] != p[2] || list[2] != p[0] {
+		t.Fatalf("Expected upstreams ordered by RTT, got %s %s %s", list[0].addr, list[1].addr, list[2].addr)
+	}
+
+	p[1].timedOut()
+	if list := f.List(p); list[2] != p[1] {
+		t.Errorf("Expected %s to be last, got %s", p[1].addr, list[2].addr)
+	}
+
+	// An upstream that only timed out stays slow after the penalty; it goes before 10.0.0.2, which still has one.
+	p = append(p, &Proxy{addr: "10.0.0.4:53"})
+	p[3].timedOut()
+	atomic.StoreInt64(&p[3].lastTimeout, time.Now().Add(-timeoutPenalty).UnixNano())
+	if list := f.List(p); list[2] != p[3] {
+		t.Errorf("Expected %s to be third, got %s", p[3].addr, list[2].addr)
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	p := []*Proxy{{addr: "10.0.0.1:53"}, {addr: "10.0.0.2:53"}}
+	p[0].updateRTT(10 * time.Millisecond)
+	p[1].updateRTT(10 * time.Millisecond)
+	atomic.AddInt64(&p[1].inflight, 1)
+
+	r := &powerOfTwo{}
+	for i := 0; i < 10; i++ {
+		if list := r.List(p); list[0] != p[0] {
+			t.Fatalf("Expected the least loaded upstream %s, got %s", p[0].addr, list[0].addr)
+		}
+	}
+
+	p[0].timedOut()
+	for i := 0; i < 10; i++ {
+		if list := r.List(p); list[0] != p[1] {
+			t.Fatalf("Expected the upstream that didn't time out %s, got %s", p[1].addr, list[0].addr)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/pb"
@@ -17,6 +18,11 @@ import (
 
 // Proxy defines an upstream host.
 type Proxy struct {
+	// atomic counters need to be first in struct for proper alignment
+	inflight    int64
+	rtt         int64 // moving average of the round trip time in nanoseconds, 0 until the first reply
+	lastTimeout int64 // unix nanoseconds of the last timeout
+
 	addr string
 
 	// connection
@@ -48,6 +54,8 @@ func newProxy(addr string, tlsConfig *tls.Config) (*Proxy, error) {
 // query sends the request and waits for a response.
 func (p *Proxy) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 	start := time.Now()
+	atomic.AddInt64(&p.inflight, 1)
+	defer atomic.AddInt64(&p.inflight, -1)
 
 	msg, err := req.Pack()
 	if err != nil {
@@ -61,6 +69,9 @@ func (p *Proxy) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 			m := new(dns.Msg).SetRcode(req, dns.RcodeNameError)
 			return m, nil
 		}
+		if status.Code(err) == codes.DeadlineExceeded {
+			p.timedOut()
+		}
 		return nil, err
 	}
 	ret := new(dns.Msg)
@@ -75,7 +86,42 @@ func (p *Proxy) query(ctx context.Context, req *dns.Msg) (*dns.Msg, error) {
 
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
-	RequestDuration.WithLabelValues(p.addr).Observe(time.Since(start).Seconds())
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr).Observe(rtt.Seconds())
+	p.updateRTT(rtt)
 
 	return ret, nil
 }
+
+// RTT returns the moving average of the round trip time to this upstream, 0 when it hasn't replied yet.
+func (p *Proxy) RTT() time.Duration { return time.Duration(atomic.LoadInt64(&p.rtt)) }
+
+// updateRTT adds an observed round trip time to the moving average.
+func (p *Proxy) updateRTT(d time.Duration) {
+	if atomic.CompareAndSwapInt64(&p.rtt, 0, int64(d)) {
+		return
+	}
+	avg := atomic.LoadInt64(&p.rtt)
+	atomic.AddInt64(&p.rtt, (int64(d)-avg)/rttWeight)
+}
+
+// timedOut records that a query to this upstream timed out. The timeout counts as a round trip of
+// defaultTimeout, so an upstream that only times out doesn't look fast once timeoutPenalty has passed.
+func (p *Proxy) timedOut() {
+	atomic.StoreInt64(&p.lastTimeout, time.Now().UnixNano())
+	p.updateRTT(defaultTimeout)
+}
+
+// recentlyTimedOut returns true if a query to this upstream timed out in the last timeoutPenalty.
+func (p *Proxy) recentlyTimedOut() bool {
+	last := atomic.LoadInt64(&p.lastTimeout)
+	return last != 0 && time.Since(time.Unix(0, last)) < timeoutPenalty
+}
+
+const (
+	// rttWeight is the weight of the moving average of the round trip time, a new observation counts
+	// for 1/rttWeight.
+	rttWeight = 4
+	// timeoutPenalty is how long an upstream that timed out is tried last by the latency aware policies.
+	timeoutPenalty = 30 * time.Second
+)
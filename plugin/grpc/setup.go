@@ -143,6 +143,10 @@ func parseBlock(c *caddyfile.Dispenser, g *GRPC) error {
 			g.p = &roundRobin{}
 		case "sequential":
 			g.p = &sequential{}
+		case "fastest":
+			g.p = &fastest{}
+		case "power_of_two":
+			g.p = &powerOfTwo{}
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
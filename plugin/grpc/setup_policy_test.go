@@ -18,6 +18,8 @@ func TestSetupPolicy(t *testing.T) {
 		{"grpc . 127.0.0.1 {\npolicy random\n}\n", false, "random", ""},
 		{"grpc . 127.0.0.1 {\npolicy round_robin\n}\n", false, "round_robin", ""},
 		{"grpc . 127.0.0.1 {\npolicy sequential\n}\n", false, "sequential", ""},
+		{"grpc . 127.0.0.1 {\npolicy fastest\n}\n", false, "fastest", ""},
+		{"grpc . 127.0.0.1 {\npolicy power_of_two\n}\n", false, "power_of_two", ""},
 		// negative
 		{"grpc . 127.0.0.1 {\npolicy random2\n}\n", true, "random", "unknown policy"},
 	}
@@ -93,7 +93,7 @@ func (e *External) soa(state request.Request) *dns.SOA {
 	soa := &dns.SOA{Hdr: header,
 		Mbox:    dnsutil.Join(e.hostmaster, e.apex, state.Zone),
 		Ns:      dnsutil.Join("ns1", e.apex, state.Zone),
-		Serial:  12345, // Also dynamic?
+		Serial:  e.serial(state),
 		Refresh: 7200,
 		Retry:   1800,
 		Expire:  86400,
@@ -102,6 +102,14 @@ func (e *External) soa(state request.Request) *dns.SOA {
 	return soa
 }
 
+// serial returns the serial of the zone, this is 12345 when the backend doesn't tell us.
+func (e *External) serial(state request.Request) uint32 {
+	if e.externalSerialFunc == nil {
+		return 12345
+	}
+	return e.externalSerialFunc(state.Zone)
+}
+
 func (e *External) ns(state request.Request) *dns.NS {
 	header := dns.RR_Header{Name: state.Zone, Rrtype: dns.TypeNS, Ttl: e.ttl, Class: dns.ClassINET}
 	ns := &dns.NS{Hdr: header, Ns: dnsutil.Join("ns1", e.apex, state.Zone)}
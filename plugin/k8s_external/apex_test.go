@@ -105,3 +105,27 @@ var testsApex = []test.Case{
 		},
 	},
 }
+
+func TestApexSerial(t *testing.T) {
+	k := kubernetes.New([]string{"cluster.local."})
+	k.APIConn = &external{}
+
+	e := New()
+	e.Zones = []string{"example.com."}
+	e.externalFunc = k.External
+	e.externalAddrFunc = externalAddress // internal test function
+	e.externalSerialFunc = func(string) uint32 { return 1556212397 }
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeSOA)
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := e.ServeDNS(context.TODO(), w, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Msg.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(w.Msg.Answer))
+	}
+	if serial := w.Msg.Answer[0].(*dns.SOA).Serial; serial != 1556212397 {
+		t.Errorf("Expected serial %d, got %d", 1556212397, serial)
+	}
+}
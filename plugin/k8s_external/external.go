@@ -3,7 +3,8 @@ Package external implements external names for kubernetes clusters.
 
 This plugin only handles three qtypes (except the apex queries, because those are handled
 differently). We support A, AAAA and SRV request, for all other types we return NODATA or
-NXDOMAIN depending on the state of the cluster.
+NXDOMAIN depending on the state of the cluster. Services whose load balancer only has a hostname
+are returned as a CNAME, for any qtype.
 
 A plugin willing to provide these services must implement the Externaler interface, although it
 likely only makes sense for the *kubernetes* plugin.
@@ -16,6 +17,7 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -28,6 +30,8 @@ type Externaler interface {
 	External(request.Request) ([]msg.Service, int)
 	// ExternalAddress should return a string slice of addresses for the nameserving endpoint.
 	ExternalAddress(state request.Request) []dns.RR
+	// ExternalSerial returns the serial of the zone, it should change when the services change.
+	ExternalSerial(string) uint32
 }
 
 // External resolves Ingress and Loadbalance IPs from kubernetes clusters.
@@ -39,8 +43,11 @@ type External struct {
 	apex       string
 	ttl        uint32
 
-	externalFunc     func(request.Request) ([]msg.Service, int)
-	externalAddrFunc func(request.Request) []dns.RR
+	upstream *upstream.Upstream // when set, CNAME targets are resolved
+
+	externalFunc       func(request.Request) ([]msg.Service, int)
+	externalAddrFunc   func(request.Request) []dns.RR
+	externalSerialFunc func(string) uint32
 }
 
 // New returns a new and initialized *External.
@@ -90,13 +97,13 @@ func (e *External) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 
 	switch state.QType() {
 	case dns.TypeA:
-		m.Answer = e.a(svc, state)
+		m.Answer = e.a(ctx, svc, state)
 	case dns.TypeAAAA:
-		m.Answer = e.aaaa(svc, state)
+		m.Answer = e.aaaa(ctx, svc, state)
 	case dns.TypeSRV:
-		m.Answer, m.Extra = e.srv(svc, state)
+		m.Answer, m.Extra = e.srv(ctx, svc, state)
 	default:
-		m.Ns = []dns.RR{e.soa(state)}
+		m.Answer = e.cname(svc, state)
 	}
 
 	// If we did have records, but queried for the wrong qtype return a nodata response.
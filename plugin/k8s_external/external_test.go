@@ -135,6 +135,44 @@ var tests = []test.Case{
 			test.AAAA("svc6.testns.example.com.	5	IN	AAAA	1:2::5"),
 		},
 	},
+	// Load balancer with a hostname
+	{
+		Qname: "svc7.testns.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("svc7.testns.example.com.	5	IN	CNAME	lb.example.net."),
+		},
+	},
+	{
+		Qname: "svc7.testns.example.com.", Qtype: dns.TypeAAAA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("svc7.testns.example.com.	5	IN	CNAME	lb.example.net."),
+		},
+	},
+	{
+		Qname: "svc7.testns.example.com.", Qtype: dns.TypeTXT, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("svc7.testns.example.com.	5	IN	CNAME	lb.example.net."),
+		},
+	},
+	{
+		Qname: "_http._tcp.svc7.testns.example.com.", Qtype: dns.TypeSRV, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("_http._tcp.svc7.testns.example.com.	5	IN	SRV	0 100 80 lb.example.net."),
+		},
+	},
+	{
+		Qname: "svc7.testns.example.com.", Qtype: dns.TypeSRV, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("svc7.testns.example.com.	5	IN	CNAME	lb.example.net."),
+		},
+	},
+	// Load balancer with a hostname and an IP
+	{
+		Qname: "svc8.testns.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("svc8.testns.example.com.	5	IN	A	1.2.3.8"),
+		},
+	},
 	{
 		Qname: "testns.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
 		Ns: []dns.RR{
@@ -157,6 +195,11 @@ func (external) Stop() error                                  { return nil }
 func (external) EpIndexReverse(string) []*object.Endpoints    { return nil }
 func (external) SvcIndexReverse(string) []*object.Service     { return nil }
 func (external) Modified() int64                              { return 0 }
+func (external) ServiceImportList() []*object.Service         { return nil }
+func (external) SvcImportIndex(string) []*object.Service      { return nil }
+func (external) McEpIndex(string) []*object.Endpoints         { return nil }
+func (external) NodeIndex(string) []*object.Node              { return nil }
+func (external) HostIndex(string) []*object.Ingress           { return nil }
 func (external) EpIndex(s string) []*object.Endpoints         { return nil }
 func (external) EndpointsList() []*object.Endpoints           { return nil }
 func (external) GetNodeByName(name string) (*api.Node, error) { return nil, nil }
@@ -192,6 +235,30 @@ var svcIndexExternal = map[string][]*object.Service{
 			Ports:       []api.ServicePort{{Name: "http", Protocol: "tcp", Port: 80}},
 		},
 	},
+	"svc7.testns": {
+		{
+			Name:              "svc7",
+			Namespace:         "testns",
+			Type:              api.ServiceTypeLoadBalancer,
+			ClusterIP:         "10.0.0.7",
+			ExternalHostnames: []string{"lb.example.net"},
+			Ports: []api.ServicePort{
+				{Name: "http", Protocol: "tcp", Port: 80},
+				{Name: "https", Protocol: "tcp", Port: 443},
+			},
+		},
+	},
+	"svc8.testns": {
+		{
+			Name:              "svc8",
+			Namespace:         "testns",
+			Type:              api.ServiceTypeLoadBalancer,
+			ClusterIP:         "10.0.0.8",
+			ExternalIPs:       []string{"1.2.3.8"},
+			ExternalHostnames: []string{"lb.example.net"},
+			Ports:             []api.ServicePort{{Name: "http", Protocol: "tcp", Port: 80}},
+		},
+	},
 }
 
 func (external) ServiceList() []*object.Service {
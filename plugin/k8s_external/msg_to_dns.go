@@ -1,7 +1,9 @@
 package external
 
 import (
+	"context"
 	"math"
+	"strings"
 
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/request"
@@ -9,7 +11,7 @@ import (
 	"github.com/miekg/dns"
 )
 
-func (e *External) a(services []msg.Service, state request.Request) (records []dns.RR) {
+func (e *External) a(ctx context.Context, services []msg.Service, state request.Request) (records []dns.RR) {
 	dup := make(map[string]struct{})
 
 	for _, s := range services {
@@ -18,7 +20,7 @@ func (e *External) a(services []msg.Service, state request.Request) (records []d
 
 		switch what {
 		case dns.TypeCNAME:
-			// can't happen
+			return e.chase(ctx, s, state)
 
 		case dns.TypeA:
 			if _, ok := dup[s.Host]; !ok {
@@ -35,7 +37,7 @@ func (e *External) a(services []msg.Service, state request.Request) (records []d
 	return records
 }
 
-func (e *External) aaaa(services []msg.Service, state request.Request) (records []dns.RR) {
+func (e *External) aaaa(ctx context.Context, services []msg.Service, state request.Request) (records []dns.RR) {
 	dup := make(map[string]struct{})
 
 	for _, s := range services {
@@ -44,7 +46,7 @@ func (e *External) aaaa(services []msg.Service, state request.Request) (records
 
 		switch what {
 		case dns.TypeCNAME:
-			// can't happen
+			return e.chase(ctx, s, state)
 
 		case dns.TypeA:
 			// nada
@@ -61,8 +63,9 @@ func (e *External) aaaa(services []msg.Service, state request.Request) (records
 	return records
 }
 
-func (e *External) srv(services []msg.Service, state request.Request) (records, extra []dns.RR) {
+func (e *External) srv(ctx context.Context, services []msg.Service, state request.Request) (records, extra []dns.RR) {
 	dup := make(map[item]struct{})
+	lookup := make(map[string]struct{})
 
 	// Looping twice to get the right weight vs priority. This might break because we may drop duplicate SRV records latter on.
 	w := make(map[int]int)
@@ -95,7 +98,27 @@ func (e *External) srv(services []msg.Service, state request.Request) (records,
 
 		switch what {
 		case dns.TypeCNAME:
-			// can't happen
+			// The service's name has a CNAME, so it can't have SRV records as well, see RFC 1034 section
+			// 3.6.2. Only _port._proto names get the SRV records that point to the hostname.
+			if !strings.HasPrefix(state.Name(), "_") {
+				return e.chase(ctx, s, state), nil
+			}
+			srv := s.NewSRV(state.QName(), weight)
+			srv.Hdr.Ttl = e.ttl
+			if ok := isDuplicate(dup, srv.Target, "", srv.Port); !ok {
+				records = append(records, srv)
+			}
+
+			if _, ok := lookup[srv.Target]; ok || e.upstream == nil {
+				break
+			}
+			lookup[srv.Target] = struct{}{}
+
+			for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+				if m, err := e.upstream.Lookup(ctx, state, srv.Target, t); err == nil {
+					extra = append(extra, m.Answer...)
+				}
+			}
 
 		case dns.TypeA, dns.TypeAAAA:
 			addr := s.Host
@@ -121,6 +144,35 @@ func (e *External) srv(services []msg.Service, state request.Request) (records,
 	return records, extra
 }
 
+// cname returns the CNAME record for services that resolve to a hostname.
+func (e *External) cname(services []msg.Service, state request.Request) []dns.RR {
+	for _, s := range services {
+		if what, _ := s.HostType(); what == dns.TypeCNAME {
+			rr := s.NewCNAME(state.QName(), s.Host)
+			rr.Hdr.Ttl = e.ttl
+			return []dns.RR{rr}
+		}
+	}
+	return nil
+}
+
+// chase returns the CNAME record for s, which resolves to a hostname, followed by the records of the
+// hostname's qtype when we have an upstream to resolve it.
+func (e *External) chase(ctx context.Context, s msg.Service, state request.Request) []dns.RR {
+	rr := s.NewCNAME(state.QName(), s.Host)
+	rr.Hdr.Ttl = e.ttl
+	records := []dns.RR{rr}
+	if e.upstream == nil {
+		return records
+	}
+
+	m, err := e.upstream.Lookup(ctx, state, rr.Target, state.QType())
+	if err != nil {
+		return records
+	}
+	return append(records, m.Answer...)
+}
+
 // not sure if this is even needed.
 
 // item holds records.
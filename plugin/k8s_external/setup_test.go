@@ -12,12 +12,19 @@ func TestSetup(t *testing.T) {
 		shouldErr    bool
 		expectedZone string
 		expectedApex string
+		upstream     bool
 	}{
-		{`k8s_external`, false, "", "dns"},
-		{`k8s_external example.org`, false, "example.org.", "dns"},
+		{`k8s_external`, false, "", "dns", false},
+		{`k8s_external example.org`, false, "example.org.", "dns", false},
 		{`k8s_external example.org {
 			apex testdns
-}`, false, "example.org.", "testdns"},
+}`, false, "example.org.", "testdns", false},
+		{`k8s_external example.org {
+			upstream
+}`, false, "example.org.", "dns", true},
+		{`k8s_external example.org {
+			upstream 8.8.8.8
+}`, true, "", "", false},
 	}
 
 	for i, test := range tests {
@@ -43,6 +50,9 @@ func TestSetup(t *testing.T) {
 			if test.expectedApex != e.apex {
 				t.Errorf("Test %d, expected apex %q for input %s, got: %q", i, test.expectedApex, test.input, e.apex)
 			}
+			if test.upstream != (e.upstream != nil) {
+				t.Errorf("Test %d, expected upstream %t for input %s", i, test.upstream, test.input)
+			}
 		}
 	}
 }
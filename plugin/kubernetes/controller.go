@@ -3,10 +3,15 @@ package kubernetes
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
+	"github.com/coredns/coredns/plugin/kubernetes/gateway"
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+	"github.com/coredns/coredns/plugin/kubernetes/networking"
 	"github.com/coredns/coredns/plugin/kubernetes/object"
 
 	api "k8s.io/api/core/v1"
@@ -23,6 +28,8 @@ const (
 	svcIPIndex            = "ServiceIP"
 	epNameNamespaceIndex  = "EndpointNameNamespace"
 	epIPIndex             = "EndpointsIP"
+	sliceServiceIndex     = "EndpointSliceService"
+	hostIndex             = "Host"
 )
 
 type dnsController interface {
@@ -34,6 +41,13 @@ type dnsController interface {
 	EpIndex(string) []*object.Endpoints
 	EpIndexReverse(string) []*object.Endpoints
 
+	ServiceImportList() []*object.Service
+	SvcImportIndex(string) []*object.Service
+	McEpIndex(string) []*object.Endpoints
+
+	NodeIndex(string) []*object.Node
+	HostIndex(string) []*object.Ingress
+
 	GetNodeByName(string) (*api.Node, error)
 	GetNamespaceByName(string) (*api.Namespace, error)
 
@@ -52,6 +66,7 @@ type dnsControl struct {
 	modified int64
 
 	client kubernetes.Interface
+	slices discovery.Interface
 
 	selector          labels.Selector
 	namespaceSelector labels.Selector
@@ -66,6 +81,22 @@ type dnsControl struct {
 	epLister  cache.Indexer
 	nsLister  cache.Store
 
+	// The ServiceImports and the merged EndpointSlices of the ServiceImports, for multicluster zones.
+	svcImportController cache.Controller
+	mcEpController      cache.Controller
+	svcImportLister     cache.Indexer
+	mcEpLister          cache.Indexer
+
+	// The Nodes, and the Ingresses, Gateways and HTTPRoutes, for node records and ingress zones.
+	nodeController    cache.Controller
+	ingressController cache.Controller
+	gatewayController cache.Controller
+	routeController   cache.Controller
+	nodeLister        cache.Indexer
+	ingressLister     cache.Indexer
+	gatewayLister     cache.Indexer
+	routeLister       cache.Indexer
+
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
 	// allowing concurrent stoppers leads to stack traces.
@@ -83,6 +114,19 @@ type dnsControlOpts struct {
 	resyncPeriod       time.Duration
 	ignoreEmptyService bool
 
+	// endpointSlices, when set, is used to watch EndpointSlices instead of Endpoints.
+	endpointSlices discovery.Interface
+
+	// serviceImports, when set, is used to watch ServiceImports and importSlices to watch their EndpointSlices.
+	serviceImports multicluster.Interface
+	importSlices   discovery.Interface
+
+	// initNodeCache watches the Nodes. ingresses and gateways, when set, are used to watch the Ingresses, and
+	// the Gateways and HTTPRoutes.
+	initNodeCache bool
+	ingresses     networking.Interface
+	gateways      gateway.Interface
+
 	// Label handling.
 	labelSelector          *meta.LabelSelector
 	selector               labels.Selector
@@ -97,6 +141,7 @@ type dnsControlOpts struct {
 func newdnsController(kubeClient kubernetes.Interface, opts dnsControlOpts) *dnsControl {
 	dns := dnsControl{
 		client:            kubeClient,
+		slices:            opts.endpointSlices,
 		selector:          opts.selector,
 		namespaceSelector: opts.namespaceSelector,
 		stopCh:            make(chan struct{}),
@@ -130,7 +175,21 @@ func newdnsController(kubeClient kubernetes.Interface, opts dnsControlOpts) *dns
 		)
 	}
 
-	if opts.initEndpointsCache {
+	if opts.initEndpointsCache && dns.slices != nil {
+		m := &sliceMerger{dns: &dns}
+		dns.epLister = newEndpointsIndexer()
+		m.endpoints = dns.epLister
+		m.slices, dns.epController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  endpointSliceListFunc(dns.slices, api.NamespaceAll, dns.selector),
+				WatchFunc: endpointSliceWatchFunc(dns.slices, api.NamespaceAll, dns.selector),
+			},
+			&discovery.EndpointSlice{},
+			opts.resyncPeriod,
+			m,
+			cache.Indexers{sliceServiceIndex: sliceServiceIndexFunc},
+			object.ToEndpointSlice)
+	} else if opts.initEndpointsCache {
 		dns.epLister, dns.epController = object.NewIndexerInformer(
 			&cache.ListWatch{
 				ListFunc:  endpointsListFunc(dns.client, api.NamespaceAll, dns.selector),
@@ -143,6 +202,91 @@ func newdnsController(kubeClient kubernetes.Interface, opts dnsControlOpts) *dns
 			object.ToEndpoints)
 	}
 
+	if opts.serviceImports != nil {
+		dns.svcImportLister, dns.svcImportController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  serviceImportListFunc(opts.serviceImports, api.NamespaceAll),
+				WatchFunc: serviceImportWatchFunc(opts.serviceImports, api.NamespaceAll),
+			},
+			&multicluster.ServiceImport{},
+			opts.resyncPeriod,
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{svcNameNamespaceIndex: svcNameNamespaceIndexFunc},
+			object.ToServiceImport,
+		)
+	}
+
+	if opts.serviceImports != nil && opts.importSlices != nil {
+		// The EndpointSlices of ServiceImports have their own service name label.
+		selector, _ := labels.Parse(multicluster.LabelServiceName)
+		m := &sliceMerger{dns: &dns}
+		dns.mcEpLister = newEndpointsIndexer()
+		m.endpoints = dns.mcEpLister
+		m.slices, dns.mcEpController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  endpointSliceListFunc(opts.importSlices, api.NamespaceAll, selector),
+				WatchFunc: endpointSliceWatchFunc(opts.importSlices, api.NamespaceAll, selector),
+			},
+			&discovery.EndpointSlice{},
+			opts.resyncPeriod,
+			m,
+			cache.Indexers{sliceServiceIndex: sliceServiceIndexFunc},
+			object.ToMultiClusterEndpointSlice)
+	}
+
+	if opts.initNodeCache {
+		dns.nodeLister, dns.nodeController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  nodeListFunc(dns.client),
+				WatchFunc: nodeWatchFunc(dns.client),
+			},
+			&api.Node{},
+			opts.resyncPeriod,
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{},
+			object.ToNode,
+		)
+	}
+
+	if opts.ingresses != nil {
+		dns.ingressLister, dns.ingressController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  ingressListFunc(opts.ingresses, api.NamespaceAll),
+				WatchFunc: ingressWatchFunc(opts.ingresses, api.NamespaceAll),
+			},
+			&networking.Ingress{},
+			opts.resyncPeriod,
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{hostIndex: ingressHostIndexFunc},
+			object.ToIngress,
+		)
+	}
+
+	if opts.gateways != nil {
+		dns.gatewayLister, dns.gatewayController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  gatewayListFunc(opts.gateways, api.NamespaceAll),
+				WatchFunc: gatewayWatchFunc(opts.gateways, api.NamespaceAll),
+			},
+			&gateway.Gateway{},
+			opts.resyncPeriod,
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{hostIndex: ingressHostIndexFunc},
+			object.ToGateway,
+		)
+		dns.routeLister, dns.routeController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  httpRouteListFunc(opts.gateways, api.NamespaceAll),
+				WatchFunc: httpRouteWatchFunc(opts.gateways, api.NamespaceAll),
+			},
+			&gateway.HTTPRoute{},
+			opts.resyncPeriod,
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{hostIndex: routeHostIndexFunc},
+			object.ToHTTPRoute,
+		)
+	}
+
 	dns.nsLister, dns.nsController = cache.NewInformer(
 		&cache.ListWatch{
 			ListFunc:  namespaceListFunc(dns.client, dns.namespaceSelector),
@@ -199,6 +343,22 @@ func epIPIndexFunc(obj interface{}) ([]string, error) {
 	return ep.IndexIP, nil
 }
 
+func ingressHostIndexFunc(obj interface{}) ([]string, error) {
+	i, ok := obj.(*object.Ingress)
+	if !ok {
+		return nil, errObj
+	}
+	return i.Hosts, nil
+}
+
+func routeHostIndexFunc(obj interface{}) ([]string, error) {
+	r, ok := obj.(*object.HTTPRoute)
+	if !ok {
+		return nil, errObj
+	}
+	return r.Hosts, nil
+}
+
 func serviceListFunc(c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -229,6 +389,51 @@ func endpointsListFunc(c kubernetes.Interface, ns string, s labels.Selector) fun
 	}
 }
 
+func endpointSliceListFunc(c discovery.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		if s != nil {
+			opts.LabelSelector = s.String()
+		}
+		list, err := c.List(ns, opts)
+		return list, err
+	}
+}
+
+func serviceImportListFunc(c multicluster.Interface, ns string) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		list, err := c.List(ns, opts)
+		return list, err
+	}
+}
+
+func nodeListFunc(c kubernetes.Interface) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		listV1, err := c.CoreV1().Nodes().List(opts)
+		return listV1, err
+	}
+}
+
+func ingressListFunc(c networking.Interface, ns string) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		list, err := c.List(ns, opts)
+		return list, err
+	}
+}
+
+func gatewayListFunc(c gateway.Interface, ns string) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		list, err := c.ListGateways(ns, opts)
+		return list, err
+	}
+}
+
+func httpRouteListFunc(c gateway.Interface, ns string) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		list, err := c.ListHTTPRoutes(ns, opts)
+		return list, err
+	}
+}
+
 func namespaceListFunc(c kubernetes.Interface, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -264,6 +469,22 @@ func (dns *dnsControl) Run() {
 	if dns.podController != nil {
 		go dns.podController.Run(dns.stopCh)
 	}
+	if dns.svcImportController != nil {
+		go dns.svcImportController.Run(dns.stopCh)
+	}
+	if dns.mcEpController != nil {
+		go dns.mcEpController.Run(dns.stopCh)
+	}
+	if dns.nodeController != nil {
+		go dns.nodeController.Run(dns.stopCh)
+	}
+	if dns.ingressController != nil {
+		go dns.ingressController.Run(dns.stopCh)
+	}
+	if dns.gatewayController != nil {
+		go dns.gatewayController.Run(dns.stopCh)
+		go dns.routeController.Run(dns.stopCh)
+	}
 	go dns.nsController.Run(dns.stopCh)
 	<-dns.stopCh
 }
@@ -280,7 +501,27 @@ func (dns *dnsControl) HasSynced() bool {
 		c = dns.podController.HasSynced()
 	}
 	d := dns.nsController.HasSynced()
-	return a && b && c && d
+	e := true
+	if dns.svcImportController != nil {
+		e = dns.svcImportController.HasSynced()
+	}
+	f := true
+	if dns.mcEpController != nil {
+		f = dns.mcEpController.HasSynced()
+	}
+	g := true
+	if dns.nodeController != nil {
+		g = dns.nodeController.HasSynced()
+	}
+	h := true
+	if dns.ingressController != nil {
+		h = dns.ingressController.HasSynced()
+	}
+	i := true
+	if dns.gatewayController != nil {
+		i = dns.gatewayController.HasSynced() && dns.routeController.HasSynced()
+	}
+	return a && b && c && d && e && f && g && h && i
 }
 
 func (dns *dnsControl) ServiceList() (svcs []*object.Service) {
@@ -383,9 +624,126 @@ func (dns *dnsControl) EpIndexReverse(ip string) (ep []*object.Endpoints) {
 	return ep
 }
 
+// ServiceImportList returns the ServiceImports, converted to Services.
+func (dns *dnsControl) ServiceImportList() (svcs []*object.Service) {
+	if dns.svcImportLister == nil {
+		return nil
+	}
+	os := dns.svcImportLister.List()
+	for _, o := range os {
+		s, ok := o.(*object.Service)
+		if !ok {
+			continue
+		}
+		svcs = append(svcs, s)
+	}
+	return svcs
+}
+
+// SvcImportIndex returns the ServiceImport, converted to a Service, with the key idx.
+func (dns *dnsControl) SvcImportIndex(idx string) (svcs []*object.Service) {
+	if dns.svcImportLister == nil {
+		return nil
+	}
+	os, err := dns.svcImportLister.ByIndex(svcNameNamespaceIndex, idx)
+	if err != nil {
+		return nil
+	}
+	for _, o := range os {
+		s, ok := o.(*object.Service)
+		if !ok {
+			continue
+		}
+		svcs = append(svcs, s)
+	}
+	return svcs
+}
+
+// McEpIndex returns the endpoints, in all clusters, of the ServiceImport with the key idx.
+func (dns *dnsControl) McEpIndex(idx string) (ep []*object.Endpoints) {
+	if dns.mcEpLister == nil {
+		return nil
+	}
+	os, err := dns.mcEpLister.ByIndex(epNameNamespaceIndex, idx)
+	if err != nil {
+		return nil
+	}
+	for _, o := range os {
+		e, ok := o.(*object.Endpoints)
+		if !ok {
+			continue
+		}
+		ep = append(ep, e)
+	}
+	return ep
+}
+
+// NodeIndex returns the node with the name name.
+func (dns *dnsControl) NodeIndex(name string) (nodes []*object.Node) {
+	if dns.nodeLister == nil {
+		return nil
+	}
+	o, exists, err := dns.nodeLister.GetByKey(name)
+	if err != nil || !exists {
+		return nil
+	}
+	n, ok := o.(*object.Node)
+	if !ok {
+		return nil
+	}
+	return []*object.Node{n}
+}
+
+// HostIndex returns the Ingresses and Gateways that serve host, either directly or, for Gateways, through
+// an HTTPRoute. A wildcard, *.example.org, matches one label: a.example.org, but not a.b.example.org.
+func (dns *dnsControl) HostIndex(host string) (ings []*object.Ingress) {
+	hosts := []string{host}
+	if i := strings.Index(host, "."); i > 0 {
+		hosts = append(hosts, "*"+host[i:])
+	}
+
+	seen := make(map[*object.Ingress]bool)
+	add := func(os []interface{}) {
+		for _, o := range os {
+			i, ok := o.(*object.Ingress)
+			if !ok || seen[i] {
+				continue
+			}
+			seen[i] = true
+			ings = append(ings, i)
+		}
+	}
+
+	for _, h := range hosts {
+		if dns.ingressLister != nil {
+			os, _ := dns.ingressLister.ByIndex(hostIndex, h)
+			add(os)
+		}
+		if dns.gatewayLister == nil {
+			continue
+		}
+		os, _ := dns.gatewayLister.ByIndex(hostIndex, h)
+		add(os)
+
+		routes, _ := dns.routeLister.ByIndex(hostIndex, h)
+		for _, o := range routes {
+			r, ok := o.(*object.HTTPRoute)
+			if !ok {
+				continue
+			}
+			for _, key := range r.Gateways {
+				if o, exists, err := dns.gatewayLister.GetByKey(key); err == nil && exists {
+					add([]interface{}{o})
+				}
+			}
+		}
+	}
+	return ings
+}
+
 // GetNodeByName return the node by name. If nothing is found an error is
 // returned. This query causes a roundtrip to the k8s API server, so use
-// sparingly. Currently this is only used for Federation.
+// sparingly. This is used for Federation and the zones of topology aware answers.
 func (dns *dnsControl) GetNodeByName(name string) (*api.Node, error) {
 	v1node, err := dns.client.CoreV1().Nodes().Get(name, meta.GetOptions{})
 	return v1node, err
@@ -436,6 +794,18 @@ func (dns *dnsControl) detectChanges(oldObj, newObj interface{}) {
 		dns.updateModifed()
 	case *object.Pod:
 		dns.updateModifed()
+	case *object.Node:
+		if newObj == nil || oldObj == nil {
+			dns.updateModifed()
+			return
+		}
+		// Nodes update their status all the time, only the addresses matter to us.
+		if strings.Join(oldObj.(*object.Node).IPs, ",") == strings.Join(ob.IPs, ",") {
+			return
+		}
+		dns.updateModifed()
+	case *object.Ingress, *object.HTTPRoute:
+		dns.updateModifed()
 	default:
 		log.Warningf("Updates for %T not supported.", ob)
 	}
@@ -464,6 +834,9 @@ func subsetsEquivalent(sa, sb object.EndpointSubset) bool {
 			return false
 		}
 	}
+	if sa.Cluster != sb.Cluster {
+		return false
+	}
 
 	for port, aport := range sa.Ports {
 		bport := sb.Ports[port]
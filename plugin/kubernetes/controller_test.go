@@ -3,14 +3,19 @@ package kubernetes
 import (
 	"context"
 	"net"
+	"reflect"
+	"sort"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -166,3 +171,121 @@ func createExternalSvc(suffix int, client kubernetes.Interface, ip net.IP) {
 		},
 	})
 }
+
+// fakeSlices is a discovery.Interface that serves the EndpointSlices returned by list, and the ones sent on
+// its watch.
+type fakeSlices struct {
+	list func() []discovery.EndpointSlice
+	w    *watch.FakeWatcher
+}
+
+func (f *fakeSlices) List(string, meta.ListOptions) (*discovery.EndpointSliceList, error) {
+	if f.list == nil {
+		return &discovery.EndpointSliceList{}, nil
+	}
+	return &discovery.EndpointSliceList{Items: f.list()}, nil
+}
+func (f *fakeSlices) Watch(string, meta.ListOptions) (watch.Interface, error) { return f.w, nil }
+
+func endpointSlice(name, svc string, ports []int32, eps ...discovery.Endpoint) *discovery.EndpointSlice {
+	s := &discovery.EndpointSlice{
+		ObjectMeta: meta.ObjectMeta{
+			Name:            name,
+			Namespace:       "testns",
+			ResourceVersion: name,
+			Labels:          map[string]string{discovery.LabelServiceName: svc},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Endpoints:   eps,
+	}
+	for _, p := range ports {
+		p := p
+		s.Ports = append(s.Ports, discovery.EndpointPort{Port: &p})
+	}
+	return s
+}
+
+func endpoint(ip string, ready, serving bool) discovery.Endpoint {
+	return discovery.Endpoint{Addresses: []string{ip}, Conditions: discovery.EndpointConditions{Ready: &ready, Serving: &serving}}
+}
+
+func TestEndpointSlices(t *testing.T) {
+	slices := &fakeSlices{w: watch.NewFake()}
+	controller := newdnsController(fake.NewSimpleClientset(), dnsControlOpts{initEndpointsCache: true, endpointSlices: slices})
+	go controller.Run()
+	defer controller.Stop()
+
+	addrs := func() []string {
+		var ips []string
+		for _, ep := range controller.EpIndex("svc1.testns") {
+			if ep.Name != "svc1" {
+				t.Errorf("Expected endpoints for svc1, got %q", ep.Name)
+			}
+			for _, ss := range ep.Subsets {
+				for _, a := range ss.Addresses {
+					ips = append(ips, a.IP)
+				}
+			}
+		}
+		sort.Strings(ips)
+		return ips
+	}
+	wait := func(expect ...string) {
+		t.Helper()
+		var got []string
+		for i := 0; i < 100; i++ {
+			if got = addrs(); reflect.DeepEqual(got, expect) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected addresses %v, got %v", expect, got)
+	}
+
+	// The slices of a service are merged, without duplicates and without endpoints that aren't ready. The
+	// watched objects are zeroed when converted, so every event gets a fresh one.
+	a := func() *discovery.EndpointSlice {
+		return endpointSlice("svc1-a", "svc1", []int32{80}, endpoint("10.0.0.1", true, true), endpoint("10.0.0.2", false, false))
+	}
+	b := func() *discovery.EndpointSlice {
+		return endpointSlice("svc1-b", "svc1", []int32{80}, endpoint("10.0.0.3", true, true), endpoint("10.0.0.1", true, true))
+	}
+	slices.w.Add(a())
+	slices.w.Add(b())
+	slices.w.Add(endpointSlice("svc2-a", "svc2", []int32{80}, endpoint("10.0.0.4", true, true)))
+	wait("10.0.0.1", "10.0.0.3")
+	if eps := controller.EpIndex("svc1.testns"); len(eps) != 1 || len(eps[0].Subsets) != 1 {
+		t.Errorf("Expected one merged endpoints with one subset, got %v", eps)
+	}
+	if eps := controller.EpIndexReverse("10.0.0.3"); len(eps) != 1 || eps[0].Name != "svc1" {
+		t.Errorf("Expected reverse lookup to find svc1, got %v", eps)
+	}
+
+	// Without ready endpoints, the terminating ones that are still serving are used.
+	terminating := endpointSlice("svc1-a", "svc1", []int32{80}, endpoint("10.0.0.1", false, true), endpoint("10.0.0.2", false, false))
+	terminating.ResourceVersion = "2"
+	slices.w.Modify(terminating)
+	slices.w.Delete(b())
+	wait("10.0.0.1")
+
+	slices.w.Delete(a())
+	wait()
+	if eps := controller.EpIndex("svc2.testns"); len(eps) != 1 {
+		t.Errorf("Expected svc2 endpoints to be kept, got %v", eps)
+	}
+}
+
+func TestEndpointSlicesSupported(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if discovery.Supported(client.Discovery()) {
+		t.Errorf("Expected EndpointSlices not to be supported")
+	}
+
+	client.Fake.Resources = []*meta.APIResourceList{{
+		GroupVersion: "discovery.k8s.io/v1",
+		APIResources: []meta.APIResource{{Name: "endpointslices", Namespaced: true, Kind: "EndpointSlice"}},
+	}}
+	if !discovery.Supported(client.Discovery()) {
+		t.Errorf("Expected EndpointSlices to be supported")
+	}
+}
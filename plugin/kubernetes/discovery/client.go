@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// SchemeGroupVersion is the group and version of the EndpointSlice API.
+var SchemeGroupVersion = schema.GroupVersion{Group: "discovery.k8s.io", Version: "v1"}
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	scheme.AddKnownTypes(SchemeGroupVersion, &EndpointSlice{}, &EndpointSliceList{})
+	meta.AddToGroupVersion(scheme, SchemeGroupVersion)
+}
+
+// Interface lists and watches EndpointSlices.
+type Interface interface {
+	List(namespace string, opts meta.ListOptions) (*EndpointSliceList, error)
+	Watch(namespace string, opts meta.ListOptions) (watch.Interface, error)
+}
+
+// Client is an Interface that talks to the API server.
+type Client struct {
+	rest rest.Interface
+}
+
+var _ Interface = &Client{}
+
+// NewForConfig returns a Client for the API server in c.
+func NewForConfig(c *rest.Config) (*Client, error) {
+	config := *c
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	// We only have JSON tags on our types, not protobuf ones.
+	config.ContentType = runtime.ContentTypeJSON
+	config.AcceptContentTypes = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	r, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rest: r}, nil
+}
+
+// List lists the EndpointSlices in namespace.
+func (c *Client) List(namespace string, opts meta.ListOptions) (*EndpointSliceList, error) {
+	list := &EndpointSliceList{}
+	err := c.rest.Get().
+		Namespace(namespace).
+		Resource("endpointslices").
+		VersionedParams(&opts, parameterCodec).
+		Do().
+		Into(list)
+	return list, err
+}
+
+// Watch watches the EndpointSlices in namespace.
+func (c *Client) Watch(namespace string, opts meta.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.rest.Get().
+		Namespace(namespace).
+		Resource("endpointslices").
+		VersionedParams(&opts, parameterCodec).
+		Watch()
+}
+
+// Supported returns true when the API server behind d serves EndpointSlices.
+func Supported(d discovery.ServerResourcesInterface) bool {
+	resources, err := d.ServerResourcesForGroupVersion(SchemeGroupVersion.String())
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "endpointslices" {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns false when c is forbidden to list EndpointSlices, as it is with a ClusterRole that
+// was written for the Endpoints API. Other errors are left to the informer, which retries.
+func Allowed(c Interface) bool {
+	_, err := c.List(meta.NamespaceAll, meta.ListOptions{Limit: 1})
+	return !errors.IsForbidden(err)
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+const sliceJSON = `{"kind":"EndpointSlice","apiVersion":"discovery.k8s.io/v1",
+"metadata":{"name":"svc1-a","namespace":"testns","resourceVersion":"1","labels":{"kubernetes.io/service-name":"svc1"}},
+"addressType":"IPv4",
+"endpoints":[{"addresses":["10.0.0.1"],"conditions":{"ready":true},"zone":"z1","hints":{"forZones":[{"name":"z1"}]}}],
+"ports":[{"name":"http","protocol":"TCP","port":80}]}`
+
+func TestClient(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/testns/endpointslices" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			fmt.Fprintf(w, `{"type":"ADDED","object":%s}`+"\n", sliceJSON)
+			return
+		}
+		fmt.Fprintf(w, `{"kind":"EndpointSliceList","apiVersion":"discovery.k8s.io/v1","metadata":{"resourceVersion":"1"},"items":[%s]}`, sliceJSON)
+	}))
+	defer s.Close()
+
+	c, err := NewForConfig(&rest.Config{Host: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := c.List("testns", meta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("Expected 1 slice, got %d", len(list.Items))
+	}
+	check(t, &list.Items[0])
+
+	w, err := c.Watch("testns", meta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	ev := <-w.ResultChan()
+	slice, ok := ev.Object.(*EndpointSlice)
+	if !ok {
+		t.Fatalf("Expected an *EndpointSlice, got %T", ev.Object)
+	}
+	check(t, slice)
+}
+
+func TestAllowed(t *testing.T) {
+	forbidden := false
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if forbidden {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","code":403}`)
+			return
+		}
+		fmt.Fprintf(w, `{"kind":"EndpointSliceList","apiVersion":"discovery.k8s.io/v1","metadata":{"resourceVersion":"1"},"items":[%s]}`, sliceJSON)
+	}))
+	defer s.Close()
+
+	c, err := NewForConfig(&rest.Config{Host: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Allowed(c) {
+		t.Error("Expected EndpointSlices to be allowed")
+	}
+	forbidden = true
+	if Allowed(c) {
+		t.Error("Expected EndpointSlices to be forbidden")
+	}
+}
+
+func check(t *testing.T, s *EndpointSlice) {
+	t.Helper()
+	if s.Name != "svc1-a" || s.Labels[LabelServiceName] != "svc1" {
+		t.Errorf("Unexpected metadata %v", s.ObjectMeta)
+	}
+	if len(s.Endpoints) != 1 || s.Endpoints[0].Addresses[0] != "10.0.0.1" || !*s.Endpoints[0].Conditions.Ready {
+		t.Errorf("Unexpected endpoints %v", s.Endpoints)
+	}
+	if *s.Endpoints[0].Zone != "z1" || s.Endpoints[0].Hints.ForZones[0].Name != "z1" {
+		t.Errorf("Unexpected topology %v", s.Endpoints[0])
+	}
+	if len(s.Ports) != 1 || *s.Ports[0].Port != 80 {
+		t.Errorf("Unexpected ports %v", s.Ports)
+	}
+}
@@ -0,0 +1,181 @@
+// Package discovery holds the discovery.k8s.io/v1 EndpointSlice API types and a client to list and watch them.
+//
+// The k8s.io/api and k8s.io/client-go versions we vendor predate EndpointSlices, these types are the subset
+// of the upstream ones that CoreDNS needs. They are wire compatible with the JSON encoding of the API server.
+package discovery
+
+import (
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// LabelServiceName is the label that holds the name of the service an EndpointSlice belongs to.
+const LabelServiceName = "kubernetes.io/service-name"
+
+// AddressType is the type of the addresses in an EndpointSlice.
+type AddressType string
+
+// The address types.
+const (
+	AddressTypeIPv4 = AddressType("IPv4")
+	AddressTypeIPv6 = AddressType("IPv6")
+	AddressTypeFQDN = AddressType("FQDN")
+)
+
+// EndpointSlice is a set of endpoints of a service.
+type EndpointSlice struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+
+	AddressType AddressType    `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+// Endpoint is a single endpoint in an EndpointSlice.
+type Endpoint struct {
+	Addresses  []string             `json:"addresses"`
+	Conditions EndpointConditions   `json:"conditions,omitempty"`
+	Hostname   *string              `json:"hostname,omitempty"`
+	TargetRef  *api.ObjectReference `json:"targetRef,omitempty"`
+	NodeName   *string              `json:"nodeName,omitempty"`
+	Zone       *string              `json:"zone,omitempty"`
+	Hints      *EndpointHints       `json:"hints,omitempty"`
+}
+
+// EndpointConditions are the conditions of an endpoint. A nil condition is unknown.
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+// EndpointHints are the topology hints of an endpoint.
+type EndpointHints struct {
+	ForZones []ForZone `json:"forZones,omitempty"`
+}
+
+// ForZone is a zone an endpoint should be used in.
+type ForZone struct {
+	Name string `json:"name"`
+}
+
+// EndpointPort is a port of an EndpointSlice. A nil Port means all ports.
+type EndpointPort struct {
+	Name     *string       `json:"name,omitempty"`
+	Protocol *api.Protocol `json:"protocol,omitempty"`
+	Port     *int32        `json:"port,omitempty"`
+}
+
+// EndpointSliceList is a list of EndpointSlices.
+type EndpointSliceList struct {
+	meta.TypeMeta `json:",inline"`
+	meta.ListMeta `json:"metadata,omitempty"`
+
+	Items []EndpointSlice `json:"items"`
+}
+
+var (
+	_ runtime.Object = &EndpointSlice{}
+	_ runtime.Object = &EndpointSliceList{}
+)
+
+// DeepCopyObject implements the runtime.Object interface.
+func (s *EndpointSlice) DeepCopyObject() runtime.Object { return s.DeepCopy() }
+
+// DeepCopy returns a deep copy of s.
+func (s *EndpointSlice) DeepCopy() *EndpointSlice {
+	if s == nil {
+		return nil
+	}
+	s1 := &EndpointSlice{TypeMeta: s.TypeMeta, AddressType: s.AddressType}
+	s.ObjectMeta.DeepCopyInto(&s1.ObjectMeta)
+
+	if s.Endpoints != nil {
+		s1.Endpoints = make([]Endpoint, len(s.Endpoints))
+		for i, e := range s.Endpoints {
+			s1.Endpoints[i] = e.deepCopy()
+		}
+	}
+	if s.Ports != nil {
+		s1.Ports = make([]EndpointPort, len(s.Ports))
+		for i, p := range s.Ports {
+			s1.Ports[i] = EndpointPort{Name: copyString(p.Name), Port: copyInt32(p.Port)}
+			if p.Protocol != nil {
+				proto := *p.Protocol
+				s1.Ports[i].Protocol = &proto
+			}
+		}
+	}
+	return s1
+}
+
+func (e Endpoint) deepCopy() Endpoint {
+	e1 := Endpoint{
+		Conditions: EndpointConditions{
+			Ready:       copyBool(e.Conditions.Ready),
+			Serving:     copyBool(e.Conditions.Serving),
+			Terminating: copyBool(e.Conditions.Terminating),
+		},
+		Hostname: copyString(e.Hostname),
+		NodeName: copyString(e.NodeName),
+		Zone:     copyString(e.Zone),
+	}
+	if e.Addresses != nil {
+		e1.Addresses = make([]string, len(e.Addresses))
+		copy(e1.Addresses, e.Addresses)
+	}
+	if e.TargetRef != nil {
+		ref := *e.TargetRef
+		e1.TargetRef = &ref
+	}
+	if e.Hints != nil {
+		e1.Hints = &EndpointHints{}
+		if e.Hints.ForZones != nil {
+			e1.Hints.ForZones = make([]ForZone, len(e.Hints.ForZones))
+			copy(e1.Hints.ForZones, e.Hints.ForZones)
+		}
+	}
+	return e1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *EndpointSliceList) DeepCopyObject() runtime.Object {
+	if l == nil {
+		return nil
+	}
+	l1 := &EndpointSliceList{TypeMeta: l.TypeMeta}
+	l.ListMeta.DeepCopyInto(&l1.ListMeta)
+	if l.Items != nil {
+		l1.Items = make([]EndpointSlice, len(l.Items))
+		for i := range l.Items {
+			l1.Items[i] = *l.Items[i].DeepCopy()
+		}
+	}
+	return l1
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	s1 := *s
+	return &s1
+}
+
+func copyInt32(i *int32) *int32 {
+	if i == nil {
+		return nil
+	}
+	i1 := *i
+	return &i1
+}
+
+func copyBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	b1 := *b
+	return &b1
+}
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// sliceMerger is the event handler of an EndpointSlice informer: it merges the EndpointSlices of each service
+// into one Endpoints in endpoints.
+type sliceMerger struct {
+	dns       *dnsControl
+	slices    cache.Indexer
+	endpoints cache.Indexer
+}
+
+// newEndpointsIndexer returns an indexer for merged Endpoints, with the indexes of the Endpoints informer.
+func newEndpointsIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc, epIPIndex: epIPIndexFunc})
+}
+
+// OnAdd implements the cache.ResourceEventHandler interface.
+func (m *sliceMerger) OnAdd(obj interface{}) { m.merge(obj) }
+
+// OnDelete implements the cache.ResourceEventHandler interface.
+func (m *sliceMerger) OnDelete(obj interface{}) { m.merge(obj) }
+
+// OnUpdate implements the cache.ResourceEventHandler interface.
+func (m *sliceMerger) OnUpdate(oldObj, newObj interface{}) {
+	o, ok := oldObj.(*object.EndpointSlice)
+	if n, ok1 := newObj.(*object.EndpointSlice); ok && ok1 && o.Index != n.Index {
+		// The slice moved to another service.
+		m.merge(oldObj)
+	}
+	m.merge(newObj)
+}
+
+// merge merges the EndpointSlices of the service obj belongs to, and stores the result in m.endpoints.
+func (m *sliceMerger) merge(obj interface{}) {
+	s, ok := obj.(*object.EndpointSlice)
+	if !ok || s.Index == "" {
+		return
+	}
+	os, err := m.slices.ByIndex(sliceServiceIndex, s.Index)
+	if err != nil {
+		return
+	}
+	var slices []*object.EndpointSlice
+	for _, o := range os {
+		if s, ok := o.(*object.EndpointSlice); ok {
+			slices = append(slices, s)
+		}
+	}
+
+	svc := strings.TrimSuffix(s.Index, "."+s.Namespace)
+	ep := &object.Endpoints{Name: svc, Namespace: s.Namespace}
+	old, exists, err := m.endpoints.Get(ep)
+	if err != nil {
+		return
+	}
+
+	if len(slices) == 0 {
+		if exists {
+			m.endpoints.Delete(old)
+			m.dns.updateModifed()
+		}
+		return
+	}
+
+	ep = object.MergeEndpointSlices(svc, s.Namespace, slices)
+	if !exists {
+		m.endpoints.Add(ep)
+		m.dns.updateModifed()
+		return
+	}
+	m.endpoints.Update(ep)
+	if !endpointsEquivalent(old.(*object.Endpoints), ep) {
+		m.dns.updateModifed()
+	}
+}
+
+func sliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	s, ok := obj.(*object.EndpointSlice)
+	if !ok {
+		return nil, errObj
+	}
+	return []string{s.Index}, nil
+}
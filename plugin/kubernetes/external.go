@@ -12,7 +12,8 @@ import (
 )
 
 // External implements the ExternalFunc call from the external plugin.
-// It returns any services matching in the services' ExternalIPs.
+// It returns any services matching in the services' ExternalIPs, or the hostname of their
+// load balancer when they have none.
 func (k *Kubernetes) External(state request.Request) ([]msg.Service, int) {
 	base, _ := dnsutil.TrimZone(state.Name(), state.Zone)
 
@@ -79,10 +80,28 @@ func (k *Kubernetes) External(state request.Request) ([]msg.Service, int) {
 				services = append(services, s)
 			}
 		}
+
+		// A name can only have one CNAME, and not if it has addresses.
+		if len(svc.ExternalIPs) > 0 || len(svc.ExternalHostnames) == 0 {
+			continue
+		}
+		for _, p := range svc.Ports {
+			if !(match(port, p.Name) && match(protocol, string(p.Protocol))) {
+				continue
+			}
+			rcode = dns.RcodeSuccess
+			s := msg.Service{Host: svc.ExternalHostnames[0], Port: int(p.Port), TTL: k.ttl}
+			s.Key = strings.Join([]string{zonePath, svc.Namespace, svc.Name}, "/")
+
+			services = append(services, s)
+		}
 	}
 	return services, rcode
 }
 
+// ExternalSerial returns the serial of the external zone, it changes when the services change.
+func (k *Kubernetes) ExternalSerial(string) uint32 { return uint32(k.APIConn.Modified()) }
+
 // ExternalAddress returns the external service address(es) for the CoreDNS service.
 func (k *Kubernetes) ExternalAddress(state request.Request) []dns.RR {
 	// This is probably wrong, because of all the fallback behavior of k.nsAddr, i.e. can get
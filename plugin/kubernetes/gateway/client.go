@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// SchemeGroupVersion is the group and version of the Gateway API.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1"}
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	scheme.AddKnownTypes(SchemeGroupVersion, &Gateway{}, &GatewayList{}, &HTTPRoute{}, &HTTPRouteList{})
+	meta.AddToGroupVersion(scheme, SchemeGroupVersion)
+}
+
+// Interface lists and watches Gateways and HTTPRoutes.
+type Interface interface {
+	ListGateways(namespace string, opts meta.ListOptions) (*GatewayList, error)
+	WatchGateways(namespace string, opts meta.ListOptions) (watch.Interface, error)
+	ListHTTPRoutes(namespace string, opts meta.ListOptions) (*HTTPRouteList, error)
+	WatchHTTPRoutes(namespace string, opts meta.ListOptions) (watch.Interface, error)
+}
+
+// Client is an Interface that talks to the API server.
+type Client struct {
+	rest rest.Interface
+}
+
+var _ Interface = &Client{}
+
+// NewForConfig returns a Client for the API server in c.
+func NewForConfig(c *rest.Config) (*Client, error) {
+	config := *c
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	// The Gateway API consists of custom resources, those are only served as JSON.
+	config.ContentType = runtime.ContentTypeJSON
+	config.AcceptContentTypes = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	r, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rest: r}, nil
+}
+
+// ListGateways lists the Gateways in namespace.
+func (c *Client) ListGateways(namespace string, opts meta.ListOptions) (*GatewayList, error) {
+	list := &GatewayList{}
+	err := c.list(namespace, "gateways", opts, list)
+	return list, err
+}
+
+// WatchGateways watches the Gateways in namespace.
+func (c *Client) WatchGateways(namespace string, opts meta.ListOptions) (watch.Interface, error) {
+	return c.watch(namespace, "gateways", opts)
+}
+
+// ListHTTPRoutes lists the HTTPRoutes in namespace.
+func (c *Client) ListHTTPRoutes(namespace string, opts meta.ListOptions) (*HTTPRouteList, error) {
+	list := &HTTPRouteList{}
+	err := c.list(namespace, "httproutes", opts, list)
+	return list, err
+}
+
+// WatchHTTPRoutes watches the HTTPRoutes in namespace.
+func (c *Client) WatchHTTPRoutes(namespace string, opts meta.ListOptions) (watch.Interface, error) {
+	return c.watch(namespace, "httproutes", opts)
+}
+
+func (c *Client) list(namespace, resource string, opts meta.ListOptions, into runtime.Object) error {
+	return c.rest.Get().
+		Namespace(namespace).
+		Resource(resource).
+		VersionedParams(&opts, parameterCodec).
+		Do().
+		Into(into)
+}
+
+func (c *Client) watch(namespace, resource string, opts meta.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.rest.Get().
+		Namespace(namespace).
+		Resource(resource).
+		VersionedParams(&opts, parameterCodec).
+		Watch()
+}
+
+// Supported returns true when the API server behind d serves Gateways and HTTPRoutes. The Gateway API
+// is not part of Kubernetes itself, its custom resources must be installed in the cluster.
+func Supported(d discovery.ServerResourcesInterface) bool {
+	resources, err := d.ServerResourcesForGroupVersion(SchemeGroupVersion.String())
+	if err != nil {
+		return false
+	}
+	gateways, routes := false, false
+	for _, r := range resources.APIResources {
+		switch r.Name {
+		case "gateways":
+			gateways = true
+		case "httproutes":
+			routes = true
+		}
+	}
+	return gateways && routes
+}
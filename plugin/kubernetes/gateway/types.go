@@ -0,0 +1,200 @@
+// Package gateway holds the gateway.networking.k8s.io/v1 Gateway and HTTPRoute API types, from the Gateway
+// API, and a client to list and watch them.
+//
+// These types are the subset of the upstream ones that CoreDNS needs. They are wire compatible with the JSON
+// encoding of the API server.
+package gateway
+
+import (
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AddressType is the type of an address of a Gateway.
+type AddressType string
+
+// The address types, a nil type is an IPAddress.
+const (
+	IPAddressType = AddressType("IPAddress")
+	HostnameType  = AddressType("Hostname")
+)
+
+// GroupName and KindGateway are the defaults of a ParentReference.
+const (
+	GroupName   = "gateway.networking.k8s.io"
+	KindGateway = "Gateway"
+)
+
+// Gateway is a load balancer that routes traffic into the cluster.
+type Gateway struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GatewaySpec   `json:"spec,omitempty"`
+	Status GatewayStatus `json:"status,omitempty"`
+}
+
+// GatewaySpec holds the listeners of a Gateway.
+type GatewaySpec struct {
+	Listeners []Listener `json:"listeners"`
+}
+
+// Listener is a listener of a Gateway, we only need its hostname. A nil hostname matches all hostnames.
+type Listener struct {
+	Name     string  `json:"name"`
+	Hostname *string `json:"hostname,omitempty"`
+}
+
+// GatewayStatus holds the addresses of a Gateway.
+type GatewayStatus struct {
+	Addresses []GatewayStatusAddress `json:"addresses,omitempty"`
+}
+
+// GatewayStatusAddress is an address of a Gateway.
+type GatewayStatusAddress struct {
+	Type  *AddressType `json:"type,omitempty"`
+	Value string       `json:"value"`
+}
+
+// GatewayList is a list of Gateways.
+type GatewayList struct {
+	meta.TypeMeta `json:",inline"`
+	meta.ListMeta `json:"metadata,omitempty"`
+
+	Items []Gateway `json:"items"`
+}
+
+// HTTPRoute routes HTTP requests for its hostnames, it is attached to the Gateways in its parent references.
+type HTTPRoute struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPRouteSpec `json:"spec,omitempty"`
+}
+
+// HTTPRouteSpec holds the parents and the hostnames of an HTTPRoute.
+type HTTPRouteSpec struct {
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+}
+
+// ParentReference refers to the parent of a route, normally a Gateway. A nil namespace is the namespace
+// of the route.
+type ParentReference struct {
+	Group     *string `json:"group,omitempty"`
+	Kind      *string `json:"kind,omitempty"`
+	Namespace *string `json:"namespace,omitempty"`
+	Name      string  `json:"name"`
+}
+
+// HTTPRouteList is a list of HTTPRoutes.
+type HTTPRouteList struct {
+	meta.TypeMeta `json:",inline"`
+	meta.ListMeta `json:"metadata,omitempty"`
+
+	Items []HTTPRoute `json:"items"`
+}
+
+var (
+	_ runtime.Object = &Gateway{}
+	_ runtime.Object = &GatewayList{}
+	_ runtime.Object = &HTTPRoute{}
+	_ runtime.Object = &HTTPRouteList{}
+)
+
+// DeepCopyObject implements the runtime.Object interface.
+func (g *Gateway) DeepCopyObject() runtime.Object { return g.DeepCopy() }
+
+// DeepCopy returns a deep copy of g.
+func (g *Gateway) DeepCopy() *Gateway {
+	if g == nil {
+		return nil
+	}
+	g1 := &Gateway{TypeMeta: g.TypeMeta}
+	g.ObjectMeta.DeepCopyInto(&g1.ObjectMeta)
+	if g.Spec.Listeners != nil {
+		g1.Spec.Listeners = make([]Listener, len(g.Spec.Listeners))
+		for i, l := range g.Spec.Listeners {
+			g1.Spec.Listeners[i] = Listener{Name: l.Name, Hostname: copyString(l.Hostname)}
+		}
+	}
+	if g.Status.Addresses != nil {
+		g1.Status.Addresses = make([]GatewayStatusAddress, len(g.Status.Addresses))
+		for i, a := range g.Status.Addresses {
+			g1.Status.Addresses[i] = GatewayStatusAddress{Value: a.Value}
+			if a.Type != nil {
+				t := *a.Type
+				g1.Status.Addresses[i].Type = &t
+			}
+		}
+	}
+	return g1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *GatewayList) DeepCopyObject() runtime.Object {
+	if l == nil {
+		return nil
+	}
+	l1 := &GatewayList{TypeMeta: l.TypeMeta}
+	l.ListMeta.DeepCopyInto(&l1.ListMeta)
+	if l.Items != nil {
+		l1.Items = make([]Gateway, len(l.Items))
+		for i := range l.Items {
+			l1.Items[i] = *l.Items[i].DeepCopy()
+		}
+	}
+	return l1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (r *HTTPRoute) DeepCopyObject() runtime.Object { return r.DeepCopy() }
+
+// DeepCopy returns a deep copy of r.
+func (r *HTTPRoute) DeepCopy() *HTTPRoute {
+	if r == nil {
+		return nil
+	}
+	r1 := &HTTPRoute{TypeMeta: r.TypeMeta}
+	r.ObjectMeta.DeepCopyInto(&r1.ObjectMeta)
+	if r.Spec.ParentRefs != nil {
+		r1.Spec.ParentRefs = make([]ParentReference, len(r.Spec.ParentRefs))
+		for i, p := range r.Spec.ParentRefs {
+			r1.Spec.ParentRefs[i] = ParentReference{
+				Group:     copyString(p.Group),
+				Kind:      copyString(p.Kind),
+				Namespace: copyString(p.Namespace),
+				Name:      p.Name,
+			}
+		}
+	}
+	if r.Spec.Hostnames != nil {
+		r1.Spec.Hostnames = make([]string, len(r.Spec.Hostnames))
+		copy(r1.Spec.Hostnames, r.Spec.Hostnames)
+	}
+	return r1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *HTTPRouteList) DeepCopyObject() runtime.Object {
+	if l == nil {
+		return nil
+	}
+	l1 := &HTTPRouteList{TypeMeta: l.TypeMeta}
+	l.ListMeta.DeepCopyInto(&l1.ListMeta)
+	if l.Items != nil {
+		l1.Items = make([]HTTPRoute, len(l.Items))
+		for i := range l.Items {
+			l1.Items[i] = *l.Items[i].DeepCopy()
+		}
+	}
+	return l1
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	s1 := *s
+	return &s1
+}
@@ -494,6 +494,11 @@ func (APIConnServeTest) Stop() error                               { return nil
 func (APIConnServeTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnServeTest) SvcIndexReverse(string) []*object.Service  { return nil }
 func (APIConnServeTest) Modified() int64                           { return time.Now().Unix() }
+func (APIConnServeTest) ServiceImportList() []*object.Service      { return nil }
+func (APIConnServeTest) SvcImportIndex(string) []*object.Service   { return nil }
+func (APIConnServeTest) McEpIndex(string) []*object.Endpoints      { return nil }
+func (APIConnServeTest) NodeIndex(string) []*object.Node           { return nil }
+func (APIConnServeTest) HostIndex(string) []*object.Ingress        { return nil }
 
 func (APIConnServeTest) PodIndex(string) []*object.Pod {
 	a := []*object.Pod{
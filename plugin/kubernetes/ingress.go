@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/request"
+)
+
+// isIngressZone returns true if zone is served from Ingresses and Gateways.
+func (k *Kubernetes) isIngressZone(zone string) bool {
+	for _, z := range k.ingressZones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// findIngresses returns the addresses of the load balancers of the Ingresses and Gateways that serve the
+// name in state. If none of those has an IP address, the name is a CNAME to the hostname of one of them.
+func (k *Kubernetes) findIngresses(state request.Request) (services []msg.Service, err error) {
+	host := strings.TrimSuffix(state.Name(), ".")
+	key := msg.Path(state.Name(), coredns)
+
+	hostname := ""
+	found := false
+	for _, ing := range k.APIConn.HostIndex(host) {
+		if !k.namespaceExposed(ing.Namespace) {
+			continue
+		}
+		found = true
+
+		for _, ip := range ing.IPs {
+			services = append(services, msg.Service{Key: key, Host: ip, TTL: k.ttl})
+		}
+		if hostname == "" && len(ing.Hostnames) > 0 {
+			hostname = ing.Hostnames[0]
+		}
+	}
+
+	if !found {
+		return nil, errNoItems
+	}
+	// A name can only have one CNAME, and not if it has addresses.
+	if len(services) == 0 && hostname != "" {
+		services = append(services, msg.Service{Key: key, Host: hostname, TTL: k.ttl})
+	}
+	return services, nil
+}
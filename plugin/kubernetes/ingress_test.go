@@ -0,0 +1,321 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/kubernetes/gateway"
+	"github.com/coredns/coredns/plugin/kubernetes/networking"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeIngresses is a networking.Interface that serves the Ingresses returned by ingresses.
+type fakeIngresses struct{}
+
+func (fakeIngresses) List(string, meta.ListOptions) (*networking.IngressList, error) {
+	return &networking.IngressList{Items: ingresses()}, nil
+}
+func (fakeIngresses) Watch(string, meta.ListOptions) (watch.Interface, error) {
+	return watch.NewFake(), nil
+}
+
+// fakeGateways is a gateway.Interface that serves the Gateways and HTTPRoutes returned by gateways and routes.
+type fakeGateways struct{}
+
+func (fakeGateways) ListGateways(string, meta.ListOptions) (*gateway.GatewayList, error) {
+	return &gateway.GatewayList{Items: gateways()}, nil
+}
+func (fakeGateways) WatchGateways(string, meta.ListOptions) (watch.Interface, error) {
+	return watch.NewFake(), nil
+}
+func (fakeGateways) ListHTTPRoutes(string, meta.ListOptions) (*gateway.HTTPRouteList, error) {
+	return &gateway.HTTPRouteList{Items: routes()}, nil
+}
+func (fakeGateways) WatchHTTPRoutes(string, meta.ListOptions) (watch.Interface, error) {
+	return watch.NewFake(), nil
+}
+
+func ingresses() []networking.Ingress {
+	return []networking.Ingress{
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "testns"},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{Host: "web.example.org"}, {Host: "*.Apps.example.org"}},
+			},
+			Status: networking.IngressStatus{LoadBalancer: networking.IngressLoadBalancerStatus{
+				Ingress: []networking.IngressLoadBalancerIngress{{IP: "192.0.2.1"}},
+			}},
+		},
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "elb", Namespace: "testns"},
+			Spec:       networking.IngressSpec{Rules: []networking.IngressRule{{Host: "elb.example.org"}}},
+			Status: networking.IngressStatus{LoadBalancer: networking.IngressLoadBalancerStatus{
+				Ingress: []networking.IngressLoadBalancerIngress{{Hostname: "lb.example.net"}},
+			}},
+		},
+	}
+}
+
+func gateways() []gateway.Gateway {
+	hostname, ipAddress := "gw.example.org", gateway.IPAddressType
+	return []gateway.Gateway{
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "gw", Namespace: "testns"},
+			Spec: gateway.GatewaySpec{
+				Listeners: []gateway.Listener{{Name: "http", Hostname: &hostname}, {Name: "any"}},
+			},
+			Status: gateway.GatewayStatus{
+				Addresses: []gateway.GatewayStatusAddress{{Value: "192.0.2.10"}, {Type: &ipAddress, Value: "2001:db8::10"}},
+			},
+		},
+	}
+}
+
+func routes() []gateway.HTTPRoute {
+	testns, service := "testns", "Service"
+	return []gateway.HTTPRoute{
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "shop", Namespace: "apps"},
+			Spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "gw", Namespace: &testns}},
+				Hostnames:  []string{"shop.example.org"},
+			},
+		},
+		// Only Gateways are parents we know.
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "mesh", Namespace: "testns"},
+			Spec: gateway.HTTPRouteSpec{
+				ParentRefs: []gateway.ParentReference{{Name: "svc1", Kind: &service}},
+				Hostnames:  []string{"mesh.example.org"},
+			},
+		},
+	}
+}
+
+var ingressCases = []test.Case{
+	// Nodes
+	{
+		Qname: "node1.node.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("node1.node.cluster.local.	5	IN	A	10.1.0.1"),
+			test.A("node1.node.cluster.local.	5	IN	A	203.0.113.1"),
+		},
+	},
+	{
+		Qname: "ip-10-1-0-2.ec2.internal.node.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("ip-10-1-0-2.ec2.internal.node.cluster.local.	5	IN	A	10.1.0.2"),
+		},
+	},
+	{
+		Qname: "node9.node.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// Ingresses
+	{
+		Qname: "web.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("web.example.org.	5	IN	A	192.0.2.1"),
+		},
+	},
+	{
+		Qname: "shop.apps.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("shop.apps.example.org.	5	IN	A	192.0.2.1"),
+		},
+	},
+	{
+		Qname: "a.shop.apps.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("example.org.	5	IN	SOA	ns.dns.example.org. hostmaster.example.org. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	{
+		Qname: "elb.example.org.", Qtype: dns.TypeCNAME,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("elb.example.org.	5	IN	CNAME	lb.example.net."),
+		},
+	},
+	// Gateways, through their listeners and their HTTPRoutes
+	{
+		Qname: "gw.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("gw.example.org.	5	IN	A	192.0.2.10"),
+		},
+	},
+	{
+		Qname: "gw.example.org.", Qtype: dns.TypeAAAA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.AAAA("gw.example.org.	5	IN	AAAA	2001:db8::10"),
+		},
+	},
+	{
+		Qname: "shop.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("shop.example.org.	5	IN	A	192.0.2.10"),
+		},
+	},
+	{
+		Qname: "mesh.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("example.org.	5	IN	SOA	ns.dns.example.org. hostmaster.example.org. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// Services are not in the ingress zone
+	{
+		Qname: "svc1.testns.svc.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("example.org.	5	IN	SOA	ns.dns.example.org. hostmaster.example.org. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestIngress(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, ns := range []string{"testns", "apps"} {
+		client.CoreV1().Namespaces().Create(&api.Namespace{ObjectMeta: meta.ObjectMeta{Name: ns}})
+	}
+	client.CoreV1().Nodes().Create(&api.Node{
+		ObjectMeta: meta.ObjectMeta{Name: "node1"},
+		Status: api.NodeStatus{Addresses: []api.NodeAddress{
+			{Type: api.NodeHostName, Address: "node1"},
+			{Type: api.NodeExternalIP, Address: "203.0.113.1"},
+			{Type: api.NodeInternalIP, Address: "10.1.0.1"},
+		}},
+	})
+	client.CoreV1().Nodes().Create(&api.Node{
+		ObjectMeta: meta.ObjectMeta{Name: "ip-10-1-0-2.ec2.internal"},
+		Status:     api.NodeStatus{Addresses: []api.NodeAddress{{Type: api.NodeInternalIP, Address: "10.1.0.2"}}},
+	})
+
+	controller := newdnsController(client, dnsControlOpts{
+		initNodeCache: true,
+		ingresses:     fakeIngresses{},
+		gateways:      fakeGateways{},
+	})
+	go controller.Run()
+	defer controller.Stop()
+	for i := 0; !controller.HasSynced(); i++ {
+		if i > 100 {
+			t.Fatal("Controller did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	k := New([]string{"cluster.local.", "example.org."})
+	k.nodeRecordZones = []string{"cluster.local."}
+	k.ingressZones = []string{"example.org."}
+	k.APIConn = controller
+	k.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range ingressCases {
+		r := tc.Msg()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := k.ServeDNS(ctx, w, r); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d, got nil message and no error for %q", i, r.Question[0].Name)
+		}
+		// The serial of the SOA is the time of the last change, which we don't control here.
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				soa.Serial = 1499347823
+			}
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestKubernetesParseIngress(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		nodes     []string
+		ingress   []string
+	}{
+		{`kubernetes cluster.local`, false, nil, nil},
+		{`kubernetes cluster.local 10.in-addr.arpa example.org {
+	nodes
+}`, false, []string{"cluster.local.", "example.org."}, nil},
+		{`kubernetes cluster.local example.org {
+	nodes cluster.local
+	ingress example.org
+}`, false, []string{"cluster.local."}, []string{"example.org."}},
+		{`kubernetes cluster.local {
+	nodes example.org
+}`, true, nil, nil},
+		{`kubernetes cluster.local example.org {
+	ingress
+}`, true, nil, nil},
+		{`kubernetes cluster.local example.org {
+	ingress cluster.local
+}`, true, nil, nil},
+		{`kubernetes cluster.local example.org {
+	ingress example.org
+	multicluster example.org
+}`, true, nil, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := kubernetesParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !equalZones(k.nodeRecordZones, tc.nodes) {
+			t.Errorf("Test %d: expected node zones %v, got %v", i, tc.nodes, k.nodeRecordZones)
+		}
+		if !equalZones(k.ingressZones, tc.ingress) {
+			t.Errorf("Test %d: expected ingress zones %v, got %v", i, tc.ingress, k.ingressZones)
+		}
+	}
+}
+
+func equalZones(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
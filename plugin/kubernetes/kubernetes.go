@@ -10,6 +10,10 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
+	"github.com/coredns/coredns/plugin/kubernetes/gateway"
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+	"github.com/coredns/coredns/plugin/kubernetes/networking"
 	"github.com/coredns/coredns/plugin/kubernetes/object"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/fall"
@@ -44,10 +48,16 @@ type Kubernetes struct {
 	ttl              uint32
 	opts             dnsControlOpts
 
+	multiclusterZones []string // zones served from ServiceImports
+	nodeRecordZones   []string // zones with node records
+	ingressZones      []string // zones served from Ingresses and Gateways
+
+	topologyMode string     // topologyPrefer or topologyOnly, empty when disabled
+	nodes        *nodeZones // zones of the nodes, for topology aware answers
+
 	primaryZoneIndex   int
 	interfaceAddrsFunc func() net.IP
 	autoPathSearch     []string // Local search path from /etc/resolv.conf. Needed for autopath.
-	TransferTo         []string
 }
 
 // New returns a initialized Kubernetes. It default interfaceAddrFunc to return 127.0.0.1. All other
@@ -59,6 +69,7 @@ func New(zones []string) *Kubernetes {
 	k.interfaceAddrsFunc = func() net.IP { return net.ParseIP("127.0.0.1") }
 	k.podMode = podModeDisabled
 	k.ttl = defaultTTL
+	k.nodes = &nodeZones{zones: make(map[string]nodeZoneEntry)}
 
 	return k
 }
@@ -235,7 +246,59 @@ func (k *Kubernetes) InitKubeCache() (err error) {
 		k.opts.namespaceSelector = selector
 	}
 
-	k.opts.initPodCache = k.podMode == podModeVerified
+	k.opts.initPodCache = k.podMode == podModeVerified || k.topologyMode != ""
+
+	if k.opts.initEndpointsCache && discovery.Supported(kubeClient.Discovery()) {
+		slices, err := discovery.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create EndpointSlice client: %q", err)
+		}
+		if discovery.Allowed(slices) {
+			k.opts.endpointSlices = slices
+			log.Info("Watching EndpointSlices instead of Endpoints")
+		} else {
+			log.Error("Not allowed to list EndpointSlices, watching Endpoints instead: add list and watch of " +
+				"endpointslices in the discovery.k8s.io API group to the ClusterRole of CoreDNS")
+		}
+	}
+
+	if len(k.multiclusterZones) > 0 {
+		imports, err := multicluster.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create ServiceImport client: %q", err)
+		}
+		k.opts.serviceImports = imports
+		if k.opts.initEndpointsCache {
+			slices, err := discovery.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to create EndpointSlice client: %q", err)
+			}
+			k.opts.importSlices = slices
+		}
+	}
+
+	k.opts.initNodeCache = len(k.nodeRecordZones) > 0
+
+	if len(k.ingressZones) > 0 {
+		if networking.Supported(kubeClient.Discovery()) {
+			ingresses, err := networking.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to create Ingress client: %q", err)
+			}
+			k.opts.ingresses = ingresses
+		} else {
+			log.Warning("The API server doesn't serve networking.k8s.io/v1 Ingresses")
+		}
+		if gateway.Supported(kubeClient.Discovery()) {
+			gateways, err := gateway.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to create Gateway client: %q", err)
+			}
+			k.opts.gateways = gateways
+		} else {
+			log.Info("The Gateway API is not installed, not watching Gateways and HTTPRoutes")
+		}
+	}
 
 	k.opts.zones = k.Zones
 	k.opts.endpointNameMode = k.endpointNameMode
@@ -246,7 +309,23 @@ func (k *Kubernetes) InitKubeCache() (err error) {
 
 // Records looks up services in kubernetes.
 func (k *Kubernetes) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
-	r, e := parseRequest(state)
+	if k.isIngressZone(state.Zone) {
+		if dnsutil.IsReverse(state.Name()) > 0 {
+			return nil, errNoItems
+		}
+		services, err := k.findIngresses(state)
+		return services, err
+	}
+
+	if k.isNodeZone(state.Zone) {
+		if name, ok := nodeName(state); ok {
+			nodes, err := k.findNodes(name, state.Zone)
+			return nodes, err
+		}
+	}
+
+	mcZone := k.isMultiClusterZone(state.Zone)
+	r, e := parseRequest(state, mcZone)
 	if e != nil {
 		return nil, e
 	}
@@ -262,12 +341,20 @@ func (k *Kubernetes) Records(ctx context.Context, state request.Request, exact b
 		return nil, errNsNotExposed
 	}
 
+	if mcZone {
+		if r.podOrSvc == Pod {
+			return nil, errNoItems
+		}
+		services, err := k.findMultiClusterServices(r, state.Zone)
+		return services, err
+	}
+
 	if r.podOrSvc == Pod {
 		pods, err := k.findPods(r, state.Zone)
 		return pods, err
 	}
 
-	services, err := k.findServices(r, state.Zone)
+	services, err := k.findServices(r, state.Zone, k.clientLocality(state))
 	return services, err
 }
 
@@ -388,8 +475,9 @@ func (k *Kubernetes) findPods(r recordRequest, zone string) (pods []msg.Service,
 	return pods, err
 }
 
-// findServices returns the services matching r from the cache.
-func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.Service, err error) {
+// findServices returns the services matching r from the cache. When client is not nil, the endpoints of
+// headless services are ordered by their distance to it.
+func (k *Kubernetes) findServices(r recordRequest, zone string, client *locality) (services []msg.Service, err error) {
 	if !wildcard(r.namespace) && !k.namespaceExposed(r.namespace) {
 		return nil, errNoItems
 	}
@@ -458,6 +546,12 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 			if endpointsList == nil {
 				endpointsList = endpointsListFunc()
 			}
+			// Only order the endpoints of a headless service, not the one(s) the client asks for by name.
+			topology := client != nil && r.endpoint == ""
+			var (
+				headless  []msg.Service
+				distances []int
+			)
 			for _, ep := range endpointsList {
 				if ep.Name != svc.Name || ep.Namespace != svc.Namespace {
 					continue
@@ -482,11 +576,19 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 
 							err = nil
 
-							services = append(services, s)
+							if !topology {
+								services = append(services, s)
+								continue
+							}
+							headless = append(headless, s)
+							distances = append(distances, k.distance(client, addr))
 						}
 					}
 				}
 			}
+			if topology {
+				services = append(services, k.nearest(headless, distances)...)
+			}
 			continue
 		}
 
@@ -67,6 +67,11 @@ func (APIConnServiceTest) PodIndex(string) []*object.Pod             { return ni
 func (APIConnServiceTest) SvcIndexReverse(string) []*object.Service  { return nil }
 func (APIConnServiceTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnServiceTest) Modified() int64                           { return 0 }
+func (APIConnServiceTest) ServiceImportList() []*object.Service      { return nil }
+func (APIConnServiceTest) SvcImportIndex(string) []*object.Service   { return nil }
+func (APIConnServiceTest) McEpIndex(string) []*object.Endpoints      { return nil }
+func (APIConnServiceTest) NodeIndex(string) []*object.Node           { return nil }
+func (APIConnServiceTest) HostIndex(string) []*object.Ingress        { return nil }
 
 func (APIConnServiceTest) SvcIndex(string) []*object.Service {
 	svcs := []*object.Service{
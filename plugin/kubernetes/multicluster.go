@@ -0,0 +1,115 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+
+	api "k8s.io/api/core/v1"
+)
+
+// isMultiClusterZone returns true if zone is served from ServiceImports.
+func (k *Kubernetes) isMultiClusterZone(zone string) bool {
+	for _, z := range k.multiclusterZones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// findMultiClusterServices returns the imported services matching r from the cache. A ClusterSetIP import
+// resolves to its ClusterSetIPs, a headless import to its endpoints in all clusters. Those are also reachable
+// by name: endpoint.cluster.service.namespace.svc.zone.
+func (k *Kubernetes) findMultiClusterServices(r recordRequest, zone string) (services []msg.Service, err error) {
+	if !wildcard(r.namespace) && !k.namespaceExposed(r.namespace) {
+		return nil, errNoItems
+	}
+
+	// handle empty service name
+	if r.service == "" {
+		if k.namespaceExposed(r.namespace) || wildcard(r.namespace) {
+			// NODATA
+			return nil, nil
+		}
+		// NXDOMAIN
+		return nil, errNoItems
+	}
+
+	err = errNoItems
+	if wildcard(r.service) && !wildcard(r.namespace) {
+		// If namespace exists, err should be nil, so that we return NODATA instead of NXDOMAIN
+		if k.namespaceExposed(r.namespace) {
+			err = nil
+		}
+	}
+
+	var serviceList []*object.Service
+	if wildcard(r.service) || wildcard(r.namespace) {
+		serviceList = k.APIConn.ServiceImportList()
+	} else {
+		serviceList = k.APIConn.SvcImportIndex(object.ServiceKey(r.service, r.namespace))
+	}
+
+	zonePath := msg.Path(zone, coredns)
+	for _, svc := range serviceList {
+		if !(match(r.namespace, svc.Namespace) && match(r.service, svc.Name)) {
+			continue
+		}
+
+		// If request namespace is a wildcard, filter results against Corefile namespace list.
+		if wildcard(r.namespace) && !k.namespaceExposed(svc.Namespace) {
+			continue
+		}
+
+		// Endpoint query or headless import
+		if svc.ClusterIP == api.ClusterIPNone || r.endpoint != "" {
+			for _, ep := range k.APIConn.McEpIndex(svc.Index) {
+				for _, eps := range ep.Subsets {
+					// An endpoint is only unique within its cluster.
+					if r.endpoint != "" && !match(r.cluster, eps.Cluster) {
+						continue
+					}
+
+					for _, addr := range eps.Addresses {
+						hostname := endpointHostname(addr, k.endpointNameMode)
+						if r.endpoint != "" && !match(r.endpoint, hostname) {
+							continue
+						}
+
+						for _, p := range eps.Ports {
+							if !(match(r.port, p.Name) && match(r.protocol, string(p.Protocol))) {
+								continue
+							}
+							s := msg.Service{Host: addr.IP, Port: int(p.Port), TTL: k.ttl}
+							s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name, eps.Cluster, hostname}, "/")
+
+							err = nil
+
+							services = append(services, s)
+						}
+					}
+				}
+			}
+			continue
+		}
+
+		// ClusterSetIP import
+		for _, ip := range svc.ClusterIPs {
+			for _, p := range svc.Ports {
+				if !(match(r.port, p.Name) && match(r.protocol, string(p.Protocol))) {
+					continue
+				}
+
+				err = nil
+
+				s := msg.Service{Host: ip, Port: int(p.Port), TTL: k.ttl}
+				s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
+
+				services = append(services, s)
+			}
+		}
+	}
+	return services, err
+}
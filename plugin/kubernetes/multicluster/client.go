@@ -0,0 +1,79 @@
+package multicluster
+
+import (
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+// SchemeGroupVersion is the group and version of the ServiceImport API.
+var SchemeGroupVersion = schema.GroupVersion{Group: "multicluster.x-k8s.io", Version: "v1alpha1"}
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	scheme.AddKnownTypes(SchemeGroupVersion, &ServiceImport{}, &ServiceImportList{})
+	meta.AddToGroupVersion(scheme, SchemeGroupVersion)
+}
+
+// Interface lists and watches ServiceImports.
+type Interface interface {
+	List(namespace string, opts meta.ListOptions) (*ServiceImportList, error)
+	Watch(namespace string, opts meta.ListOptions) (watch.Interface, error)
+}
+
+// Client is an Interface that talks to the API server.
+type Client struct {
+	rest rest.Interface
+}
+
+var _ Interface = &Client{}
+
+// NewForConfig returns a Client for the API server in c.
+func NewForConfig(c *rest.Config) (*Client, error) {
+	config := *c
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	// ServiceImport is a custom resource, those are only served as JSON.
+	config.ContentType = runtime.ContentTypeJSON
+	config.AcceptContentTypes = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	r, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rest: r}, nil
+}
+
+// List lists the ServiceImports in namespace.
+func (c *Client) List(namespace string, opts meta.ListOptions) (*ServiceImportList, error) {
+	list := &ServiceImportList{}
+	err := c.rest.Get().
+		Namespace(namespace).
+		Resource("serviceimports").
+		VersionedParams(&opts, parameterCodec).
+		Do().
+		Into(list)
+	return list, err
+}
+
+// Watch watches the ServiceImports in namespace.
+func (c *Client) Watch(namespace string, opts meta.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.rest.Get().
+		Namespace(namespace).
+		Resource("serviceimports").
+		VersionedParams(&opts, parameterCodec).
+		Watch()
+}
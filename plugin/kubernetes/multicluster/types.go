@@ -0,0 +1,100 @@
+// Package multicluster holds the multicluster.x-k8s.io/v1alpha1 ServiceImport API type, from the Multi-Cluster
+// Services API (KEP-1645), and a client to list and watch ServiceImports.
+//
+// These types are the subset of the upstream ones that CoreDNS needs. They are wire compatible with the JSON
+// encoding of the API server.
+package multicluster
+
+import (
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The labels of the EndpointSlices of a ServiceImport: the name of the imported service and the cluster
+// the endpoints are in.
+const (
+	LabelServiceName   = "multicluster.kubernetes.io/service-name"
+	LabelSourceCluster = "multicluster.kubernetes.io/source-cluster"
+)
+
+// ServiceImportType is the type of a ServiceImport.
+type ServiceImportType string
+
+// The ServiceImport types.
+const (
+	ClusterSetIP = ServiceImportType("ClusterSetIP")
+	Headless     = ServiceImportType("Headless")
+)
+
+// ServiceImport describes a service imported from the clusters in the ClusterSet.
+type ServiceImport struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceImportSpec `json:"spec,omitempty"`
+}
+
+// ServiceImportSpec holds the IPs, the ports and the type of a ServiceImport.
+type ServiceImportSpec struct {
+	Ports []ServicePort     `json:"ports"`
+	IPs   []string          `json:"ips,omitempty"`
+	Type  ServiceImportType `json:"type"`
+}
+
+// ServicePort is a port of a ServiceImport.
+type ServicePort struct {
+	Name     string       `json:"name,omitempty"`
+	Protocol api.Protocol `json:"protocol,omitempty"`
+	Port     int32        `json:"port"`
+}
+
+// ServiceImportList is a list of ServiceImports.
+type ServiceImportList struct {
+	meta.TypeMeta `json:",inline"`
+	meta.ListMeta `json:"metadata,omitempty"`
+
+	Items []ServiceImport `json:"items"`
+}
+
+var (
+	_ runtime.Object = &ServiceImport{}
+	_ runtime.Object = &ServiceImportList{}
+)
+
+// DeepCopyObject implements the runtime.Object interface.
+func (s *ServiceImport) DeepCopyObject() runtime.Object { return s.DeepCopy() }
+
+// DeepCopy returns a deep copy of s.
+func (s *ServiceImport) DeepCopy() *ServiceImport {
+	if s == nil {
+		return nil
+	}
+	s1 := &ServiceImport{TypeMeta: s.TypeMeta, Spec: ServiceImportSpec{Type: s.Spec.Type}}
+	s.ObjectMeta.DeepCopyInto(&s1.ObjectMeta)
+	if s.Spec.Ports != nil {
+		s1.Spec.Ports = make([]ServicePort, len(s.Spec.Ports))
+		copy(s1.Spec.Ports, s.Spec.Ports)
+	}
+	if s.Spec.IPs != nil {
+		s1.Spec.IPs = make([]string, len(s.Spec.IPs))
+		copy(s1.Spec.IPs, s.Spec.IPs)
+	}
+	return s1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *ServiceImportList) DeepCopyObject() runtime.Object {
+	if l == nil {
+		return nil
+	}
+	l1 := &ServiceImportList{TypeMeta: l.TypeMeta}
+	l.ListMeta.DeepCopyInto(&l1.ListMeta)
+	if l.Items != nil {
+		l1.Items = make([]ServiceImport, len(l.Items))
+		for i := range l.Items {
+			l1.Items[i] = *l.Items[i].DeepCopy()
+		}
+	}
+	return l1
+}
@@ -0,0 +1,238 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeImports is a multicluster.Interface that serves the ServiceImports returned by list.
+type fakeImports struct {
+	list func() []multicluster.ServiceImport
+}
+
+func (f fakeImports) List(string, meta.ListOptions) (*multicluster.ServiceImportList, error) {
+	return &multicluster.ServiceImportList{Items: f.list()}, nil
+}
+func (f fakeImports) Watch(string, meta.ListOptions) (watch.Interface, error) {
+	return watch.NewFake(), nil
+}
+
+func serviceImports() []multicluster.ServiceImport {
+	return []multicluster.ServiceImport{
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "svc1", Namespace: "testns"},
+			Spec: multicluster.ServiceImportSpec{
+				Type:  multicluster.ClusterSetIP,
+				IPs:   []string{"10.100.0.1", "fd00::1"},
+				Ports: []multicluster.ServicePort{{Name: "http", Protocol: api.ProtocolTCP, Port: 80}},
+			},
+		},
+		{
+			ObjectMeta: meta.ObjectMeta{Name: "hdls1", Namespace: "testns"},
+			Spec: multicluster.ServiceImportSpec{
+				Type:  multicluster.Headless,
+				Ports: []multicluster.ServicePort{{Name: "http", Protocol: api.ProtocolTCP, Port: 80}},
+			},
+		},
+	}
+}
+
+func importSlices() []discovery.EndpointSlice {
+	slice := func(cluster, ip string) discovery.EndpointSlice {
+		s := endpointSlice("hdls1-"+cluster, "", []int32{80}, endpoint(ip, true, true))
+		s.Labels = map[string]string{multicluster.LabelServiceName: "hdls1", multicluster.LabelSourceCluster: cluster}
+		hostname, port := "pod-0", "http"
+		s.Endpoints[0].Hostname = &hostname
+		s.Ports[0].Name = &port
+		return *s
+	}
+	return []discovery.EndpointSlice{slice("c1", "10.0.0.1"), slice("c2", "10.0.1.1")}
+}
+
+var multiClusterCases = []test.Case{
+	// ClusterSetIP import
+	{
+		Qname: "svc1.testns.svc.clusterset.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("svc1.testns.svc.clusterset.local.	5	IN	A	10.100.0.1"),
+		},
+	},
+	{
+		Qname: "svc1.testns.svc.clusterset.local.", Qtype: dns.TypeAAAA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.AAAA("svc1.testns.svc.clusterset.local.	5	IN	AAAA	fd00::1"),
+		},
+	},
+	// One SRV record for both ClusterSetIPs, its weight is shared with the duplicate that is dropped.
+	{
+		Qname: "_http._tcp.svc1.testns.svc.clusterset.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("_http._tcp.svc1.testns.svc.clusterset.local.	5	IN	SRV	0 50 80 svc1.testns.svc.clusterset.local."),
+		},
+		Extra: []dns.RR{
+			test.A("svc1.testns.svc.clusterset.local.	5	IN	A	10.100.0.1"),
+			test.AAAA("svc1.testns.svc.clusterset.local.	5	IN	AAAA	fd00::1"),
+		},
+	},
+	// Headless import, with the endpoints of all clusters
+	{
+		Qname: "hdls1.testns.svc.clusterset.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("hdls1.testns.svc.clusterset.local.	5	IN	A	10.0.0.1"),
+			test.A("hdls1.testns.svc.clusterset.local.	5	IN	A	10.0.1.1"),
+		},
+	},
+	{
+		Qname: "pod-0.c2.hdls1.testns.svc.clusterset.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("pod-0.c2.hdls1.testns.svc.clusterset.local.	5	IN	A	10.0.1.1"),
+		},
+	},
+	{
+		Qname: "_http._tcp.hdls1.testns.svc.clusterset.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("_http._tcp.hdls1.testns.svc.clusterset.local.	5	IN	SRV	0 50 80 pod-0.c1.hdls1.testns.svc.clusterset.local."),
+			test.SRV("_http._tcp.hdls1.testns.svc.clusterset.local.	5	IN	SRV	0 50 80 pod-0.c2.hdls1.testns.svc.clusterset.local."),
+		},
+		Extra: []dns.RR{
+			test.A("pod-0.c1.hdls1.testns.svc.clusterset.local.	5	IN	A	10.0.0.1"),
+			test.A("pod-0.c2.hdls1.testns.svc.clusterset.local.	5	IN	A	10.0.1.1"),
+		},
+	},
+	// An endpoint needs its cluster
+	{
+		Qname: "pod-0.hdls1.testns.svc.clusterset.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("clusterset.local.	5	IN	SOA	ns.dns.clusterset.local. hostmaster.clusterset.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	{
+		Qname: "pod-0.c3.hdls1.testns.svc.clusterset.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("clusterset.local.	5	IN	SOA	ns.dns.clusterset.local. hostmaster.clusterset.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// The imports are not in the cluster zone
+	{
+		Qname: "svc1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestMultiCluster(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.CoreV1().Namespaces().Create(&api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}})
+
+	controller := newdnsController(client, dnsControlOpts{
+		initEndpointsCache: true,
+		serviceImports:     fakeImports{list: serviceImports},
+		importSlices:       &fakeSlices{list: importSlices, w: watch.NewFake()},
+	})
+	go controller.Run()
+	defer controller.Stop()
+	for i := 0; !controller.HasSynced(); i++ {
+		if i > 100 {
+			t.Fatal("Controller did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	k := New([]string{"cluster.local.", "clusterset.local."})
+	k.multiclusterZones = []string{"clusterset.local."}
+	k.APIConn = controller
+	k.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range multiClusterCases {
+		r := tc.Msg()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := k.ServeDNS(ctx, w, r); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d, got nil message and no error for %q", i, r.Question[0].Name)
+		}
+		// The serial of the SOA is the time of the last change, which we don't control here.
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				soa.Serial = 1499347823
+			}
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestKubernetesParseMultiCluster(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []string
+	}{
+		{`kubernetes cluster.local clusterset.local {
+	multicluster clusterset.local
+}`, false, []string{"clusterset.local."}},
+		{`kubernetes cluster.local`, false, nil},
+		{`kubernetes cluster.local {
+	multicluster
+}`, true, nil},
+		{`kubernetes cluster.local {
+	multicluster clusterset.local
+}`, true, nil},
+		{`kubernetes cluster.local clusterset.local {
+	multicluster cluster.local
+}`, true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := kubernetesParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(k.multiclusterZones) != len(tc.expected) {
+			t.Errorf("Test %d: expected multicluster zones %v, got %v", i, tc.expected, k.multiclusterZones)
+			continue
+		}
+		for j := range tc.expected {
+			if k.multiclusterZones[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected multicluster zones %v, got %v", i, tc.expected, k.multiclusterZones)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package networking
+
+import (
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// SchemeGroupVersion is the group and version of the Ingress API.
+var SchemeGroupVersion = schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	scheme.AddKnownTypes(SchemeGroupVersion, &Ingress{}, &IngressList{})
+	meta.AddToGroupVersion(scheme, SchemeGroupVersion)
+}
+
+// Interface lists and watches Ingresses.
+type Interface interface {
+	List(namespace string, opts meta.ListOptions) (*IngressList, error)
+	Watch(namespace string, opts meta.ListOptions) (watch.Interface, error)
+}
+
+// Client is an Interface that talks to the API server.
+type Client struct {
+	rest rest.Interface
+}
+
+var _ Interface = &Client{}
+
+// NewForConfig returns a Client for the API server in c.
+func NewForConfig(c *rest.Config) (*Client, error) {
+	config := *c
+	config.GroupVersion = &SchemeGroupVersion
+	config.APIPath = "/apis"
+	// We only have JSON tags on our types, not protobuf ones.
+	config.ContentType = runtime.ContentTypeJSON
+	config.AcceptContentTypes = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	r, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rest: r}, nil
+}
+
+// List lists the Ingresses in namespace.
+func (c *Client) List(namespace string, opts meta.ListOptions) (*IngressList, error) {
+	list := &IngressList{}
+	err := c.rest.Get().
+		Namespace(namespace).
+		Resource("ingresses").
+		VersionedParams(&opts, parameterCodec).
+		Do().
+		Into(list)
+	return list, err
+}
+
+// Watch watches the Ingresses in namespace.
+func (c *Client) Watch(namespace string, opts meta.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.rest.Get().
+		Namespace(namespace).
+		Resource("ingresses").
+		VersionedParams(&opts, parameterCodec).
+		Watch()
+}
+
+// Supported returns true when the API server behind d serves Ingresses.
+func Supported(d discovery.ServerResourcesInterface) bool {
+	resources, err := d.ServerResourcesForGroupVersion(SchemeGroupVersion.String())
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "ingresses" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Package networking holds the networking.k8s.io/v1 Ingress API type and a client to list and watch Ingresses.
+//
+// The k8s.io/api and k8s.io/client-go versions we vendor predate this version of the Ingress API, these types
+// are the subset of the upstream ones that CoreDNS needs. They are wire compatible with the JSON encoding of
+// the API server.
+package networking
+
+import (
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Ingress exposes HTTP routes of services outside the cluster.
+type Ingress struct {
+	meta.TypeMeta   `json:",inline"`
+	meta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IngressSpec   `json:"spec,omitempty"`
+	Status IngressStatus `json:"status,omitempty"`
+}
+
+// IngressSpec holds the rules of an Ingress.
+type IngressSpec struct {
+	Rules []IngressRule `json:"rules,omitempty"`
+}
+
+// IngressRule is a rule of an Ingress, we only need its host.
+type IngressRule struct {
+	Host string `json:"host,omitempty"`
+}
+
+// IngressStatus holds the load balancer of an Ingress.
+type IngressStatus struct {
+	LoadBalancer IngressLoadBalancerStatus `json:"loadBalancer,omitempty"`
+}
+
+// IngressLoadBalancerStatus holds the addresses of the load balancer of an Ingress.
+type IngressLoadBalancerStatus struct {
+	Ingress []IngressLoadBalancerIngress `json:"ingress,omitempty"`
+}
+
+// IngressLoadBalancerIngress is an address of the load balancer of an Ingress, an IP or a hostname.
+type IngressLoadBalancerIngress struct {
+	IP       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// IngressList is a list of Ingresses.
+type IngressList struct {
+	meta.TypeMeta `json:",inline"`
+	meta.ListMeta `json:"metadata,omitempty"`
+
+	Items []Ingress `json:"items"`
+}
+
+var (
+	_ runtime.Object = &Ingress{}
+	_ runtime.Object = &IngressList{}
+)
+
+// DeepCopyObject implements the runtime.Object interface.
+func (i *Ingress) DeepCopyObject() runtime.Object { return i.DeepCopy() }
+
+// DeepCopy returns a deep copy of i.
+func (i *Ingress) DeepCopy() *Ingress {
+	if i == nil {
+		return nil
+	}
+	i1 := &Ingress{TypeMeta: i.TypeMeta}
+	i.ObjectMeta.DeepCopyInto(&i1.ObjectMeta)
+	if i.Spec.Rules != nil {
+		i1.Spec.Rules = make([]IngressRule, len(i.Spec.Rules))
+		copy(i1.Spec.Rules, i.Spec.Rules)
+	}
+	if i.Status.LoadBalancer.Ingress != nil {
+		i1.Status.LoadBalancer.Ingress = make([]IngressLoadBalancerIngress, len(i.Status.LoadBalancer.Ingress))
+		copy(i1.Status.LoadBalancer.Ingress, i.Status.LoadBalancer.Ingress)
+	}
+	return i1
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *IngressList) DeepCopyObject() runtime.Object {
+	if l == nil {
+		return nil
+	}
+	l1 := &IngressList{TypeMeta: l.TypeMeta}
+	l.ListMeta.DeepCopyInto(&l1.ListMeta)
+	if l.Items != nil {
+		l1.Items = make([]Ingress, len(l.Items))
+		for i := range l.Items {
+			l1.Items[i] = *l.Items[i].DeepCopy()
+		}
+	}
+	return l1
+}
@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+)
+
+// Node is the DNS schema for kubernetes nodes: <node>.node.<zone>.
+const Node = "node"
+
+// isNodeZone returns true if zone has node records.
+func (k *Kubernetes) isNodeZone(zone string) bool {
+	for _, z := range k.nodeRecordZones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeName returns the name of the node in the query in state, and false if it isn't a node query.
+// Node names may have dots: ip-10-0-0-1.ec2.internal.node.cluster.local.
+func nodeName(state request.Request) (string, bool) {
+	base, _ := dnsutil.TrimZone(state.Name(), state.Zone)
+	if base == Node {
+		return "", true
+	}
+	if !strings.HasSuffix(base, "."+Node) {
+		return "", false
+	}
+	return strings.TrimSuffix(base, "."+Node), true
+}
+
+// findNodes returns the addresses of the node name from the cache.
+func (k *Kubernetes) findNodes(name, zone string) (nodes []msg.Service, err error) {
+	// node.<zone> exists, but has no records.
+	if name == "" {
+		return nil, nil
+	}
+
+	zonePath := msg.Path(zone, coredns)
+	for _, n := range k.APIConn.NodeIndex(name) {
+		for _, ip := range n.IPs {
+			s := msg.Service{Key: strings.Join([]string{zonePath, Node, n.Name}, "/"), Host: ip, TTL: k.ttl}
+			nodes = append(nodes, s)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errNoItems
+	}
+	return nodes, nil
+}
@@ -19,6 +19,11 @@ func (APIConnTest) SvcIndexReverse(string) []*object.Service { return nil }
 func (APIConnTest) EpIndex(string) []*object.Endpoints       { return nil }
 func (APIConnTest) EndpointsList() []*object.Endpoints       { return nil }
 func (APIConnTest) Modified() int64                          { return 0 }
+func (APIConnTest) ServiceImportList() []*object.Service     { return nil }
+func (APIConnTest) SvcImportIndex(string) []*object.Service  { return nil }
+func (APIConnTest) McEpIndex(string) []*object.Endpoints     { return nil }
+func (APIConnTest) NodeIndex(string) []*object.Node          { return nil }
+func (APIConnTest) HostIndex(string) []*object.Ingress       { return nil }
 
 func (APIConnTest) ServiceList() []*object.Service {
 	svcs := []*object.Service{
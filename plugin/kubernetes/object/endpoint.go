@@ -22,6 +22,9 @@ type Endpoints struct {
 type EndpointSubset struct {
 	Addresses []EndpointAddress
 	Ports     []EndpointPort
+
+	// Cluster is the cluster the addresses are in, it is only set for the endpoints of a ServiceImport.
+	Cluster string
 }
 
 // EndpointAddress is a tuple that describes single IP address.
@@ -30,6 +33,10 @@ type EndpointAddress struct {
 	Hostname      string
 	NodeName      string
 	TargetRefName string
+
+	// Zone and ForZones, the zones the endpoint is hinted for, are only set for EndpointSlices.
+	Zone     string
+	ForZones []string
 }
 
 // EndpointPort is a tuple that describes a single port.
@@ -128,10 +135,10 @@ func (e *Endpoints) DeepCopyObject() runtime.Object {
 		sub := EndpointSubset{
 			Addresses: make([]EndpointAddress, len(eps.Addresses)),
 			Ports:     make([]EndpointPort, len(eps.Ports)),
+			Cluster:   eps.Cluster,
 		}
 		for j, a := range eps.Addresses {
-			ea := EndpointAddress{IP: a.IP, Hostname: a.Hostname, NodeName: a.NodeName, TargetRefName: a.TargetRefName}
-			sub.Addresses[j] = ea
+			sub.Addresses[j] = a.deepCopy()
 		}
 		for k, p := range eps.Ports {
 			ep := EndpointPort{Port: p.Port, Name: p.Name, Protocol: p.Protocol}
@@ -143,6 +150,15 @@ func (e *Endpoints) DeepCopyObject() runtime.Object {
 	return e1
 }
 
+func (a EndpointAddress) deepCopy() EndpointAddress {
+	a1 := EndpointAddress{IP: a.IP, Hostname: a.Hostname, NodeName: a.NodeName, TargetRefName: a.TargetRefName, Zone: a.Zone}
+	if a.ForZones != nil {
+		a1.ForZones = make([]string, len(a.ForZones))
+		copy(a1.ForZones, a.ForZones)
+	}
+	return a1
+}
+
 // GetNamespace implements the metav1.Object interface.
 func (e *Endpoints) GetNamespace() string { return e.Namespace }
 
@@ -0,0 +1,236 @@
+package object
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// EndpointSlice is a stripped down discovery.EndpointSlice with only the items we need for CoreDNS. The
+// slices of a service are merged into Endpoints with MergeEndpointSlices.
+type EndpointSlice struct {
+	Version   string
+	Name      string
+	Namespace string
+	Index     string // the key of the service the slice belongs to, empty when it has none
+	Cluster   string // the cluster of a ServiceImport's slice
+	Ports     []EndpointPort
+
+	// Ready holds the ready endpoints, Serving the endpoints that are terminating but still serving.
+	Ready   []EndpointAddress
+	Serving []EndpointAddress
+
+	*Empty
+}
+
+// ToEndpointSlice converts a discovery.EndpointSlice to a *EndpointSlice.
+func ToEndpointSlice(obj interface{}) interface{} {
+	slice, ok := obj.(*discovery.EndpointSlice)
+	if !ok {
+		return nil
+	}
+
+	s := &EndpointSlice{
+		Version:   slice.GetResourceVersion(),
+		Name:      slice.GetName(),
+		Namespace: slice.GetNamespace(),
+	}
+	if svc := slice.GetLabels()[discovery.LabelServiceName]; svc != "" {
+		s.Index = EndpointsKey(svc, slice.GetNamespace())
+	}
+
+	if len(slice.Ports) == 0 {
+		// Add sentinal if there are no ports.
+		s.Ports = []EndpointPort{{Port: -1}}
+	} else {
+		s.Ports = make([]EndpointPort, len(slice.Ports))
+	}
+	for i, p := range slice.Ports {
+		ep := EndpointPort{Port: -1, Protocol: "TCP"}
+		if p.Port != nil {
+			ep.Port = *p.Port
+		}
+		if p.Name != nil {
+			ep.Name = *p.Name
+		}
+		if p.Protocol != nil {
+			ep.Protocol = string(*p.Protocol)
+		}
+		s.Ports[i] = ep
+	}
+
+	// FQDN endpoints can't be served as address records.
+	if slice.AddressType == discovery.AddressTypeFQDN {
+		*slice = discovery.EndpointSlice{}
+		return s
+	}
+
+	for _, e := range slice.Endpoints {
+		ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+		serving := e.Conditions.Serving != nil && *e.Conditions.Serving
+		if !ready && !serving {
+			continue
+		}
+
+		for _, ip := range e.Addresses {
+			ea := EndpointAddress{IP: ip}
+			if e.Hostname != nil {
+				ea.Hostname = *e.Hostname
+			}
+			if e.NodeName != nil {
+				ea.NodeName = *e.NodeName
+			}
+			if e.TargetRef != nil {
+				ea.TargetRefName = e.TargetRef.Name
+			}
+			if e.Zone != nil {
+				ea.Zone = *e.Zone
+			}
+			if e.Hints != nil {
+				for _, z := range e.Hints.ForZones {
+					ea.ForZones = append(ea.ForZones, z.Name)
+				}
+			}
+
+			if ready {
+				s.Ready = append(s.Ready, ea)
+			} else {
+				s.Serving = append(s.Serving, ea)
+			}
+		}
+	}
+
+	*slice = discovery.EndpointSlice{}
+
+	return s
+}
+
+// ToMultiClusterEndpointSlice converts a discovery.EndpointSlice of a ServiceImport to a *EndpointSlice.
+func ToMultiClusterEndpointSlice(obj interface{}) interface{} {
+	slice, ok := obj.(*discovery.EndpointSlice)
+	if !ok {
+		return nil
+	}
+	labels := slice.GetLabels()
+
+	s := ToEndpointSlice(slice).(*EndpointSlice)
+	s.Index = ""
+	if svc := labels[multicluster.LabelServiceName]; svc != "" {
+		s.Index = EndpointsKey(svc, s.Namespace)
+	}
+	s.Cluster = labels[multicluster.LabelSourceCluster]
+	return s
+}
+
+// MergeEndpointSlices merges the slices of the service name in namespace into Endpoints. Slices with the same
+// cluster and ports become one subset and addresses that are in more than one slice are only used once. Only
+// the ready addresses are used, unless the service has none: then the ones that are terminating, but still
+// serving, are used so clients can reach the service while it is being replaced.
+func MergeEndpointSlices(name, namespace string, slices []*EndpointSlice) *Endpoints {
+	sort.Slice(slices, func(i, j int) bool { return slices[i].Name < slices[j].Name })
+
+	ready := false
+	for _, s := range slices {
+		if len(s.Ready) > 0 {
+			ready = true
+			break
+		}
+	}
+
+	e := &Endpoints{
+		Name:      name,
+		Namespace: namespace,
+		Index:     EndpointsKey(name, namespace),
+	}
+
+	versions := make([]string, len(slices))
+	subsets := make(map[string]int) // subset index by cluster and ports
+	seen := make(map[string]bool)   // addresses by cluster, ports and IP
+	for i, s := range slices {
+		versions[i] = s.Version
+
+		addrs := s.Ready
+		if !ready {
+			addrs = s.Serving
+		}
+		if len(addrs) == 0 {
+			continue
+		}
+
+		key := s.Cluster + "/" + portsKey(s.Ports)
+		j, ok := subsets[key]
+		if !ok {
+			j = len(e.Subsets)
+			subsets[key] = j
+			e.Subsets = append(e.Subsets, EndpointSubset{Ports: s.Ports, Cluster: s.Cluster})
+		}
+		for _, a := range addrs {
+			if seen[key+"/"+a.IP] {
+				continue
+			}
+			seen[key+"/"+a.IP] = true
+			e.Subsets[j].Addresses = append(e.Subsets[j].Addresses, a)
+			e.IndexIP = append(e.IndexIP, a.IP)
+		}
+	}
+	e.Version = strings.Join(versions, ",")
+
+	return e
+}
+
+// portsKey returns a string that is the same for equal sets of ports.
+func portsKey(ports []EndpointPort) string {
+	keys := make([]string, len(ports))
+	for i, p := range ports {
+		keys[i] = p.Name + "/" + p.Protocol + "/" + strconv.Itoa(int(p.Port))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+var _ runtime.Object = &EndpointSlice{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (s *EndpointSlice) DeepCopyObject() runtime.Object {
+	s1 := &EndpointSlice{
+		Version:   s.Version,
+		Name:      s.Name,
+		Namespace: s.Namespace,
+		Index:     s.Index,
+		Cluster:   s.Cluster,
+		Ports:     make([]EndpointPort, len(s.Ports)),
+		Ready:     make([]EndpointAddress, len(s.Ready)),
+		Serving:   make([]EndpointAddress, len(s.Serving)),
+	}
+	copy(s1.Ports, s.Ports)
+	for i, a := range s.Ready {
+		s1.Ready[i] = a.deepCopy()
+	}
+	for i, a := range s.Serving {
+		s1.Serving[i] = a.deepCopy()
+	}
+	return s1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (s *EndpointSlice) GetNamespace() string { return s.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (s *EndpointSlice) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (s *EndpointSlice) GetName() string { return s.Name }
+
+// SetName implements the metav1.Object interface.
+func (s *EndpointSlice) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (s *EndpointSlice) GetResourceVersion() string { return s.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (s *EndpointSlice) SetResourceVersion(version string) {}
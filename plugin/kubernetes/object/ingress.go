@@ -0,0 +1,205 @@
+package object
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/kubernetes/gateway"
+	"github.com/coredns/coredns/plugin/kubernetes/networking"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Ingress is a stripped down networking.Ingress, or gateway.Gateway, with only the items we need for CoreDNS:
+// the hostnames it serves and the addresses of its load balancer.
+type Ingress struct {
+	Version   string
+	Name      string
+	Namespace string
+	Hosts     []string // lower case and without a trailing dot, these may be wildcards: *.example.org
+
+	// IPs are the IP addresses of the load balancer, Hostnames its hostnames. Cloud load balancers may
+	// only have a hostname.
+	IPs       []string
+	Hostnames []string
+
+	*Empty
+}
+
+// ToIngress converts a networking.Ingress to a *Ingress.
+func ToIngress(obj interface{}) interface{} {
+	ing, ok := obj.(*networking.Ingress)
+	if !ok {
+		return nil
+	}
+
+	i := &Ingress{
+		Version:   ing.GetResourceVersion(),
+		Name:      ing.GetName(),
+		Namespace: ing.GetNamespace(),
+	}
+	for _, r := range ing.Spec.Rules {
+		if r.Host != "" {
+			i.Hosts = append(i.Hosts, normalizeHost(r.Host))
+		}
+	}
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			i.IPs = append(i.IPs, lb.IP)
+		} else if lb.Hostname != "" {
+			i.Hostnames = append(i.Hostnames, lb.Hostname)
+		}
+	}
+
+	*ing = networking.Ingress{}
+
+	return i
+}
+
+// ToGateway converts a gateway.Gateway to a *Ingress, the hosts are the hostnames of its listeners.
+func ToGateway(obj interface{}) interface{} {
+	gw, ok := obj.(*gateway.Gateway)
+	if !ok {
+		return nil
+	}
+
+	i := &Ingress{
+		Version:   gw.GetResourceVersion(),
+		Name:      gw.GetName(),
+		Namespace: gw.GetNamespace(),
+	}
+	for _, l := range gw.Spec.Listeners {
+		if l.Hostname != nil && *l.Hostname != "" {
+			i.Hosts = append(i.Hosts, normalizeHost(*l.Hostname))
+		}
+	}
+	for _, a := range gw.Status.Addresses {
+		switch {
+		case a.Type == nil || *a.Type == gateway.IPAddressType:
+			i.IPs = append(i.IPs, a.Value)
+		case *a.Type == gateway.HostnameType:
+			i.Hostnames = append(i.Hostnames, a.Value)
+		}
+	}
+
+	*gw = gateway.Gateway{}
+
+	return i
+}
+
+var _ runtime.Object = &Ingress{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (i *Ingress) DeepCopyObject() runtime.Object {
+	i1 := &Ingress{
+		Version:   i.Version,
+		Name:      i.Name,
+		Namespace: i.Namespace,
+		Hosts:     make([]string, len(i.Hosts)),
+		IPs:       make([]string, len(i.IPs)),
+		Hostnames: make([]string, len(i.Hostnames)),
+	}
+	copy(i1.Hosts, i.Hosts)
+	copy(i1.IPs, i.IPs)
+	copy(i1.Hostnames, i.Hostnames)
+	return i1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (i *Ingress) GetNamespace() string { return i.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (i *Ingress) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (i *Ingress) GetName() string { return i.Name }
+
+// SetName implements the metav1.Object interface.
+func (i *Ingress) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (i *Ingress) GetResourceVersion() string { return i.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (i *Ingress) SetResourceVersion(version string) {}
+
+// HTTPRoute is a stripped down gateway.HTTPRoute with only the items we need for CoreDNS.
+type HTTPRoute struct {
+	Version   string
+	Name      string
+	Namespace string
+	Hosts     []string // lower case and without a trailing dot, these may be wildcards: *.example.org
+	Gateways  []string // the keys, namespace/name, of the Gateways the route is attached to
+
+	*Empty
+}
+
+// ToHTTPRoute converts a gateway.HTTPRoute to a *HTTPRoute.
+func ToHTTPRoute(obj interface{}) interface{} {
+	route, ok := obj.(*gateway.HTTPRoute)
+	if !ok {
+		return nil
+	}
+
+	r := &HTTPRoute{
+		Version:   route.GetResourceVersion(),
+		Name:      route.GetName(),
+		Namespace: route.GetNamespace(),
+	}
+	for _, h := range route.Spec.Hostnames {
+		r.Hosts = append(r.Hosts, normalizeHost(h))
+	}
+	for _, p := range route.Spec.ParentRefs {
+		if p.Group != nil && *p.Group != gateway.GroupName {
+			continue
+		}
+		if p.Kind != nil && *p.Kind != gateway.KindGateway {
+			continue
+		}
+		ns := r.Namespace
+		if p.Namespace != nil {
+			ns = *p.Namespace
+		}
+		r.Gateways = append(r.Gateways, ns+"/"+p.Name)
+	}
+
+	*route = gateway.HTTPRoute{}
+
+	return r
+}
+
+var _ runtime.Object = &HTTPRoute{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (r *HTTPRoute) DeepCopyObject() runtime.Object {
+	r1 := &HTTPRoute{
+		Version:   r.Version,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Hosts:     make([]string, len(r.Hosts)),
+		Gateways:  make([]string, len(r.Gateways)),
+	}
+	copy(r1.Hosts, r.Hosts)
+	copy(r1.Gateways, r.Gateways)
+	return r1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (r *HTTPRoute) GetNamespace() string { return r.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (r *HTTPRoute) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (r *HTTPRoute) GetName() string { return r.Name }
+
+// SetName implements the metav1.Object interface.
+func (r *HTTPRoute) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (r *HTTPRoute) GetResourceVersion() string { return r.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (r *HTTPRoute) SetResourceVersion(version string) {}
+
+// normalizeHost returns host in lower case and without a trailing dot.
+func normalizeHost(host string) string { return strings.ToLower(strings.TrimSuffix(host, ".")) }
@@ -0,0 +1,70 @@
+package object
+
+import (
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Node is a stripped down api.Node with only the items we need for CoreDNS.
+type Node struct {
+	Version string
+	Name    string
+	IPs     []string // the InternalIPs, followed by the ExternalIPs
+
+	*Empty
+}
+
+// ToNode converts an api.Node to a *Node.
+func ToNode(obj interface{}) interface{} {
+	node, ok := obj.(*api.Node)
+	if !ok {
+		return nil
+	}
+
+	n := &Node{
+		Version: node.GetResourceVersion(),
+		Name:    node.GetName(),
+	}
+	for _, t := range []api.NodeAddressType{api.NodeInternalIP, api.NodeExternalIP} {
+		for _, a := range node.Status.Addresses {
+			if a.Type == t {
+				n.IPs = append(n.IPs, a.Address)
+			}
+		}
+	}
+
+	*node = api.Node{}
+
+	return n
+}
+
+var _ runtime.Object = &Node{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (n *Node) DeepCopyObject() runtime.Object {
+	n1 := &Node{
+		Version: n.Version,
+		Name:    n.Name,
+		IPs:     make([]string, len(n.IPs)),
+	}
+	copy(n1.IPs, n.IPs)
+	return n1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (n *Node) GetNamespace() string { return "" }
+
+// SetNamespace implements the metav1.Object interface.
+func (n *Node) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (n *Node) GetName() string { return n.Name }
+
+// SetName implements the metav1.Object interface.
+func (n *Node) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (n *Node) GetResourceVersion() string { return n.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (n *Node) SetResourceVersion(version string) {}
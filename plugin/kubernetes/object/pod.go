@@ -11,6 +11,7 @@ type Pod struct {
 	PodIP     string
 	Name      string
 	Namespace string
+	NodeName  string
 	Deleting  bool
 
 	*Empty
@@ -28,6 +29,7 @@ func ToPod(obj interface{}) interface{} {
 		PodIP:     pod.Status.PodIP,
 		Namespace: pod.GetNamespace(),
 		Name:      pod.GetName(),
+		NodeName:  pod.Spec.NodeName,
 	}
 	t := pod.ObjectMeta.DeletionTimestamp
 	if t != nil {
@@ -48,6 +50,7 @@ func (p *Pod) DeepCopyObject() runtime.Object {
 		PodIP:     p.PodIP,
 		Namespace: p.Namespace,
 		Name:      p.Name,
+		NodeName:  p.NodeName,
 		Deleting:  p.Deleting,
 	}
 	return p1
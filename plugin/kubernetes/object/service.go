@@ -18,6 +18,11 @@ type Service struct {
 
 	// ExternalIPs we may want to export.
 	ExternalIPs []string
+	// ExternalHostnames are the hostnames of the load balancer, for load balancers without an IP.
+	ExternalHostnames []string
+
+	// ClusterIPs holds the ClusterSetIPs of a ServiceImport, ClusterIP is the first of those.
+	ClusterIPs []string
 
 	*Empty
 }
@@ -41,7 +46,7 @@ func ToService(obj interface{}) interface{} {
 		Type:         svc.Spec.Type,
 		ExternalName: svc.Spec.ExternalName,
 
-		ExternalIPs: make([]string, len(svc.Status.LoadBalancer.Ingress)+len(svc.Spec.ExternalIPs)),
+		ExternalIPs: make([]string, len(svc.Spec.ExternalIPs), len(svc.Status.LoadBalancer.Ingress)+len(svc.Spec.ExternalIPs)),
 	}
 
 	if len(svc.Spec.Ports) == 0 {
@@ -52,9 +57,14 @@ func ToService(obj interface{}) interface{} {
 		copy(s.Ports, svc.Spec.Ports)
 	}
 
-	li := copy(s.ExternalIPs, svc.Spec.ExternalIPs)
-	for i, lb := range svc.Status.LoadBalancer.Ingress {
-		s.ExternalIPs[li+i] = lb.IP
+	copy(s.ExternalIPs, svc.Spec.ExternalIPs)
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		// Cloud load balancers may only have a hostname.
+		if lb.IP != "" {
+			s.ExternalIPs = append(s.ExternalIPs, lb.IP)
+		} else if lb.Hostname != "" {
+			s.ExternalHostnames = append(s.ExternalHostnames, lb.Hostname)
+		}
 	}
 
 	*svc = api.Service{}
@@ -79,6 +89,14 @@ func (s *Service) DeepCopyObject() runtime.Object {
 	}
 	copy(s1.Ports, s.Ports)
 	copy(s1.ExternalIPs, s.ExternalIPs)
+	if s.ExternalHostnames != nil {
+		s1.ExternalHostnames = make([]string, len(s.ExternalHostnames))
+		copy(s1.ExternalHostnames, s.ExternalHostnames)
+	}
+	if s.ClusterIPs != nil {
+		s1.ClusterIPs = make([]string, len(s.ClusterIPs))
+		copy(s1.ClusterIPs, s.ClusterIPs)
+	}
 	return s1
 }
 
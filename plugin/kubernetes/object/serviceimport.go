@@ -0,0 +1,44 @@
+package object
+
+import (
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+
+	api "k8s.io/api/core/v1"
+)
+
+// ToServiceImport converts a multicluster.ServiceImport to a *Service. A headless import gets the
+// api.ClusterIPNone ClusterIP, like a headless service.
+func ToServiceImport(obj interface{}) interface{} {
+	si, ok := obj.(*multicluster.ServiceImport)
+	if !ok {
+		return nil
+	}
+
+	s := &Service{
+		Version:   si.GetResourceVersion(),
+		Name:      si.GetName(),
+		Namespace: si.GetNamespace(),
+		Index:     ServiceKey(si.GetName(), si.GetNamespace()),
+		Type:      api.ServiceTypeClusterIP,
+		ClusterIP: api.ClusterIPNone,
+	}
+	if si.Spec.Type == multicluster.ClusterSetIP && len(si.Spec.IPs) > 0 {
+		s.ClusterIP = si.Spec.IPs[0]
+		s.ClusterIPs = make([]string, len(si.Spec.IPs))
+		copy(s.ClusterIPs, si.Spec.IPs)
+	}
+
+	if len(si.Spec.Ports) == 0 {
+		// Add sentinal if there are no ports.
+		s.Ports = []api.ServicePort{{Port: -1}}
+	} else {
+		s.Ports = make([]api.ServicePort, len(si.Spec.Ports))
+		for i, p := range si.Spec.Ports {
+			s.Ports[i] = api.ServicePort{Name: p.Name, Protocol: p.Protocol, Port: p.Port}
+		}
+	}
+
+	*si = multicluster.ServiceImport{}
+
+	return s
+}
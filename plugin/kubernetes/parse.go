@@ -15,6 +15,8 @@ type recordRequest struct {
 	// SRV record.
 	protocol string
 	endpoint string
+	// The cluster of the endpoint, only used in multicluster zones.
+	cluster string
 	// The servicename used in Kubernetes.
 	service string
 	// The namespace used in Kubernetes.
@@ -25,11 +27,13 @@ type recordRequest struct {
 
 // parseRequest parses the qname to find all the elements we need for querying k8s. Anything
 // that is not parsed will have the wildcard "*" value (except r.endpoint).
-// Potential underscores are stripped from _port and _protocol.
-func parseRequest(state request.Request) (r recordRequest, err error) {
+// Potential underscores are stripped from _port and _protocol. In a multicluster zone endpoints are qualified
+// with their cluster.
+func parseRequest(state request.Request, multicluster bool) (r recordRequest, err error) {
 	// 3 Possible cases:
 	// 1. _port._protocol.service.namespace.pod|svc.zone
 	// 2. (endpoint): endpoint.service.namespace.pod|svc.zone
+	//    or in a multicluster zone: endpoint.cluster.service.namespace.svc.zone
 	// 3. (service): service.namespace.pod|svc.zone
 	//
 	// Federations are handled in the federation plugin. And aren't parsed here.
@@ -84,7 +88,13 @@ func parseRequest(state request.Request) (r recordRequest, err error) {
 
 	case 0: // endpoint only
 		r.endpoint = segs[last]
-	case 1: // service and port
+	case 1:
+		if multicluster && segs[last][0] != '_' { // endpoint and cluster
+			r.cluster = segs[last]
+			r.endpoint = segs[last-1]
+			break
+		}
+		// service and port
 		r.protocol = stripUnderscore(segs[last])
 		r.port = stripUnderscore(segs[last-1])
 
@@ -109,6 +119,9 @@ func (r recordRequest) String() string {
 	s := r.port
 	s += "." + r.protocol
 	s += "." + r.endpoint
+	if r.cluster != "" {
+		s += "." + r.cluster
+	}
 	s += "." + r.service
 	s += "." + r.namespace
 	s += "." + r.podOrSvc
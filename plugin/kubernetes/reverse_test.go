@@ -15,14 +15,19 @@ import (
 
 type APIConnReverseTest struct{}
 
-func (APIConnReverseTest) HasSynced() bool                    { return true }
-func (APIConnReverseTest) Run()                               { return }
-func (APIConnReverseTest) Stop() error                        { return nil }
-func (APIConnReverseTest) PodIndex(string) []*object.Pod      { return nil }
-func (APIConnReverseTest) EpIndex(string) []*object.Endpoints { return nil }
-func (APIConnReverseTest) EndpointsList() []*object.Endpoints { return nil }
-func (APIConnReverseTest) ServiceList() []*object.Service     { return nil }
-func (APIConnReverseTest) Modified() int64                    { return 0 }
+func (APIConnReverseTest) HasSynced() bool                         { return true }
+func (APIConnReverseTest) Run()                                    { return }
+func (APIConnReverseTest) Stop() error                             { return nil }
+func (APIConnReverseTest) PodIndex(string) []*object.Pod           { return nil }
+func (APIConnReverseTest) EpIndex(string) []*object.Endpoints      { return nil }
+func (APIConnReverseTest) EndpointsList() []*object.Endpoints      { return nil }
+func (APIConnReverseTest) ServiceList() []*object.Service          { return nil }
+func (APIConnReverseTest) Modified() int64                         { return 0 }
+func (APIConnReverseTest) ServiceImportList() []*object.Service    { return nil }
+func (APIConnReverseTest) SvcImportIndex(string) []*object.Service { return nil }
+func (APIConnReverseTest) McEpIndex(string) []*object.Endpoints    { return nil }
+func (APIConnReverseTest) NodeIndex(string) []*object.Node         { return nil }
+func (APIConnReverseTest) HostIndex(string) []*object.Ingress      { return nil }
 
 func (APIConnReverseTest) SvcIndex(svc string) []*object.Service {
 	if svc != "svc1.testns" {
@@ -13,7 +13,6 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
-	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 
 	"github.com/mholt/caddy"
@@ -183,6 +182,18 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 				continue
 			}
 			return nil, c.ArgErr()
+		case "topology":
+			args := c.RemainingArgs()
+			if len(args) == 1 {
+				switch args[0] {
+				case topologyPrefer, topologyOnly:
+					k8s.topologyMode = args[0]
+				default:
+					return nil, fmt.Errorf("wrong value for topology: %s, must be one of: prefer, only", args[0])
+				}
+				continue
+			}
+			return nil, c.ArgErr()
 		case "namespaces":
 			args := c.RemainingArgs()
 			if len(args) > 0 {
@@ -264,15 +275,6 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 				return nil, c.Errf("ttl must be in range [0, 3600]: %d", t)
 			}
 			k8s.ttl = uint32(t)
-		case "transfer":
-			tos, froms, err := parse.Transfer(c, false)
-			if err != nil {
-				return nil, err
-			}
-			if len(froms) != 0 {
-				return nil, c.Errf("transfer from is not supported with this plugin")
-			}
-			k8s.TransferTo = tos
 		case "noendpoints":
 			if len(c.RemainingArgs()) != 0 {
 				return nil, c.ArgErr()
@@ -289,6 +291,51 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 					return nil, fmt.Errorf("unable to parse ignore value: '%v'", ignore)
 				}
 			}
+		case "multicluster":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			for _, z := range args {
+				z = plugin.Host(z).Normalize()
+				if plugin.Zones(k8s.Zones).Matches(z) != z {
+					return nil, c.Errf("multicluster zone %q is not a zone of the plugin", z)
+				}
+				if z == k8s.Zones[k8s.primaryZoneIndex] {
+					return nil, c.Errf("multicluster zone %q can't be the primary zone", z)
+				}
+				k8s.multiclusterZones = append(k8s.multiclusterZones, z)
+			}
+		case "nodes":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				args = k8s.Zones
+			}
+			for _, z := range args {
+				z = plugin.Host(z).Normalize()
+				if plugin.Zones(k8s.Zones).Matches(z) != z {
+					return nil, c.Errf("nodes zone %q is not a zone of the plugin", z)
+				}
+				if dnsutil.IsReverse(z) > 0 {
+					continue
+				}
+				k8s.nodeRecordZones = append(k8s.nodeRecordZones, z)
+			}
+		case "ingress":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			for _, z := range args {
+				z = plugin.Host(z).Normalize()
+				if plugin.Zones(k8s.Zones).Matches(z) != z {
+					return nil, c.Errf("ingress zone %q is not a zone of the plugin", z)
+				}
+				if z == k8s.Zones[k8s.primaryZoneIndex] {
+					return nil, c.Errf("ingress zone %q can't be the primary zone", z)
+				}
+				k8s.ingressZones = append(k8s.ingressZones, z)
+			}
 		case "kubeconfig":
 			args := c.RemainingArgs()
 			if len(args) == 2 {
@@ -305,6 +352,12 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 		}
 	}
 
+	for _, z := range k8s.ingressZones {
+		if k8s.isMultiClusterZone(z) {
+			return nil, c.Errf("zone %q can't be both a multicluster and an ingress zone", z)
+		}
+	}
+
 	if len(k8s.Namespaces) != 0 && k8s.opts.namespaceLabelSelector != nil {
 		return nil, c.Errf("namespaces and namespace_labels cannot both be set")
 	}
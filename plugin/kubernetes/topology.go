@@ -0,0 +1,190 @@
+package kubernetes
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/request"
+)
+
+const (
+	// topologyPrefer puts the endpoints close to the client first.
+	topologyPrefer = "prefer"
+	// topologyOnly only returns the endpoints close to the client, if it has any.
+	topologyOnly = "only"
+
+	// LabelTopologyZone is the node label with the zone of the node, LabelZone is its deprecated predecessor.
+	LabelTopologyZone = "topology.kubernetes.io/zone"
+)
+
+var (
+	errNodeNotFound = errors.New("node not found")
+	errNodeTimeout  = errors.New("timeout getting node")
+)
+
+// The distance of an endpoint to the client.
+const (
+	sameNode = iota
+	sameZone
+	remote
+)
+
+// locality is where a client runs.
+type locality struct {
+	node string
+	zone string
+}
+
+// nodeZones caches the zones of the nodes, so we only ask the API server once per node.
+type nodeZones struct {
+	sync.RWMutex
+	zones map[string]nodeZoneEntry
+}
+
+// nodeZoneEntry is the zone of a node. Failed lookups are cached until expire, so a broken API server
+// doesn't slow down every query; successful ones never expire.
+type nodeZoneEntry struct {
+	zone   string
+	expire time.Time
+}
+
+var (
+	// nodeZoneTimeout bounds the time a query waits for the API server to return a node.
+	nodeZoneTimeout = 2 * time.Second
+	// nodeZoneFailTTL is how long a failed node lookup is cached.
+	nodeZoneFailTTL = 30 * time.Second
+)
+
+// clientLocality returns the locality of the pod that sent the query in state. It returns nil if topology
+// aware answers are disabled or when the client isn't a pod we know.
+func (k *Kubernetes) clientLocality(state request.Request) *locality {
+	if k.topologyMode == "" {
+		return nil
+	}
+	pod := k.podWithIP(state.IP())
+	if pod == nil || pod.NodeName == "" {
+		return nil
+	}
+	return &locality{node: pod.NodeName, zone: k.nodeZone(pod.NodeName)}
+}
+
+// nodeZone returns the zone of the node name, or the empty string if it is unknown.
+func (k *Kubernetes) nodeZone(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	k.nodes.RLock()
+	e, ok := k.nodes.zones[name]
+	k.nodes.RUnlock()
+	if ok && (e.expire.IsZero() || time.Now().Before(e.expire)) {
+		return e.zone
+	}
+
+	zone, err := k.lookupNodeZone(name)
+	e = nodeZoneEntry{zone: zone}
+	if err != nil {
+		log.Warningf("Failed to get the zone of node %s: %s", name, err)
+		e.expire = time.Now().Add(nodeZoneFailTTL)
+	}
+
+	k.nodes.Lock()
+	k.nodes.zones[name] = e
+	k.nodes.Unlock()
+	return zone
+}
+
+// lookupNodeZone asks the API server for the zone of the node name, it gives up after nodeZoneTimeout.
+func (k *Kubernetes) lookupNodeZone(name string) (string, error) {
+	type result struct {
+		zone string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		node, err := k.APIConn.GetNodeByName(name)
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		if node == nil {
+			ch <- result{err: errNodeNotFound}
+			return
+		}
+		zone := node.Labels[LabelTopologyZone]
+		if zone == "" {
+			zone = node.Labels[LabelZone]
+		}
+		ch <- result{zone: zone}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.zone, r.err
+	case <-time.After(nodeZoneTimeout):
+		return "", errNodeTimeout
+	}
+}
+
+// distance returns how far addr is from the client in l. Topology hints take precedence over the zone
+// of the endpoint: an endpoint with hints is only in the zones it is hinted for.
+func (k *Kubernetes) distance(l *locality, addr object.EndpointAddress) int {
+	if addr.NodeName != "" && addr.NodeName == l.node {
+		return sameNode
+	}
+	if l.zone == "" {
+		return remote
+	}
+	if len(addr.ForZones) > 0 {
+		for _, z := range addr.ForZones {
+			if z == l.zone {
+				return sameZone
+			}
+		}
+		return remote
+	}
+
+	zone := addr.Zone
+	if zone == "" {
+		zone = k.nodeZone(addr.NodeName)
+	}
+	if zone == l.zone {
+		return sameZone
+	}
+	return remote
+}
+
+// nearest orders services by their distance to the client, the topologyOnly mode also drops the remote ones.
+// It falls back to returning all services when none are close to the client, so the name keeps resolving.
+func (k *Kubernetes) nearest(services []msg.Service, distances []int) []msg.Service {
+	sort.Stable(byDistance{services, distances})
+	if k.topologyMode != topologyOnly {
+		return services
+	}
+
+	for i, d := range distances {
+		if d == remote {
+			if i == 0 {
+				return services
+			}
+			return services[:i]
+		}
+	}
+	return services
+}
+
+type byDistance struct {
+	services  []msg.Service
+	distances []int
+}
+
+func (b byDistance) Len() int           { return len(b.services) }
+func (b byDistance) Less(i, j int) bool { return b.distances[i] < b.distances[j] }
+func (b byDistance) Swap(i, j int) {
+	b.services[i], b.services[j] = b.services[j], b.services[i]
+	b.distances[i], b.distances[j] = b.distances[j], b.distances[i]
+}
@@ -0,0 +1,201 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// topologyConn serves a headless service with endpoints spread over nodes and zones. The client,
+// 10.240.0.1, runs on clientNode.
+type topologyConn struct {
+	APIConnServeTest
+	clientNode string
+	lookups    map[string]int
+}
+
+func (c *topologyConn) PodIndex(ip string) []*object.Pod {
+	if ip != "10.240.0.1" {
+		return nil
+	}
+	return []*object.Pod{{Name: "client", Namespace: "testns", PodIP: ip, NodeName: c.clientNode}}
+}
+
+func (c *topologyConn) SvcIndex(string) []*object.Service {
+	return []*object.Service{{Name: "hdls", Namespace: "testns", ClusterIP: api.ClusterIPNone}}
+}
+
+func (c *topologyConn) EpIndex(string) []*object.Endpoints {
+	return []*object.Endpoints{{
+		Name:      "hdls",
+		Namespace: "testns",
+		Subsets: []object.EndpointSubset{{
+			Addresses: []object.EndpointAddress{
+				{IP: "10.0.0.1", Hostname: "ep1", NodeName: "node3"},
+				{IP: "10.0.0.2", Hostname: "ep2", NodeName: "node2"},
+				{IP: "10.0.0.3", Hostname: "ep3", NodeName: "node1"},
+				{IP: "10.0.0.4", Hostname: "ep4", NodeName: "node3", Zone: "z2", ForZones: []string{"z1"}},
+				{IP: "10.0.0.5", Hostname: "ep5", NodeName: "node2", Zone: "z1", ForZones: []string{"z2"}},
+			},
+			Ports: []object.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+		}},
+	}}
+}
+
+func (c *topologyConn) GetNodeByName(name string) (*api.Node, error) {
+	c.lookups[name]++
+	labels := map[string]map[string]string{
+		"node1": {LabelTopologyZone: "z1"},
+		"node2": {LabelZone: "z1"},
+		"node3": {LabelTopologyZone: "z2"},
+		"node4": {LabelTopologyZone: "z3"},
+	}[name]
+	if labels == nil {
+		return nil, errors.New("node not found")
+	}
+	return &api.Node{ObjectMeta: meta.ObjectMeta{Name: name, Labels: labels}}, nil
+}
+
+func TestTopology(t *testing.T) {
+	tests := []struct {
+		mode       string
+		clientNode string
+		expected   []string
+	}{
+		{"", "node1", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+		{topologyPrefer, "node1", []string{"10.0.0.3", "10.0.0.2", "10.0.0.4", "10.0.0.1", "10.0.0.5"}},
+		{topologyOnly, "node1", []string{"10.0.0.3", "10.0.0.2", "10.0.0.4"}},
+		// Nothing is close to node4, return everything.
+		{topologyOnly, "node4", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+		// The client's node is unknown, only endpoints on the same node are close.
+		{topologyOnly, "node5", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+		{topologyOnly, "node2", []string{"10.0.0.2", "10.0.0.5", "10.0.0.3", "10.0.0.4"}},
+	}
+
+	for i, tc := range tests {
+		k := New([]string{"cluster.local."})
+		k.Namespaces = map[string]struct{}{"testns": {}}
+		k.topologyMode = tc.mode
+		conn := &topologyConn{clientNode: tc.clientNode, lookups: make(map[string]int)}
+		k.APIConn = conn
+
+		m := new(dns.Msg)
+		m.SetQuestion("hdls.testns.svc.cluster.local.", dns.TypeA)
+		state := request.Request{Req: m, W: &test.ResponseWriter{}, Zone: "cluster.local."}
+
+		// Query twice, the second query must be answered from the node cache.
+		for j := 0; j < 2; j++ {
+			services, err := k.Records(context.TODO(), state, false)
+			if err != nil {
+				t.Fatalf("Test %d: expected no error, got %s", i, err)
+			}
+			if len(services) != len(tc.expected) {
+				t.Fatalf("Test %d: expected %d services, got %d", i, len(tc.expected), len(services))
+			}
+			for n, s := range services {
+				if s.Host != tc.expected[n] {
+					t.Errorf("Test %d: expected %s at %d, got %s", i, tc.expected[n], n, s.Host)
+				}
+			}
+		}
+		// Failed lookups, for node5, are cached too.
+		for node, n := range conn.lookups {
+			if n > 1 {
+				t.Errorf("Test %d: expected node %s to be looked up once, got %d", i, node, n)
+			}
+		}
+	}
+}
+
+// slowConn is an API server that takes forever to return a node.
+type slowConn struct {
+	APIConnServeTest
+	lookups int32
+}
+
+func (c *slowConn) GetNodeByName(name string) (*api.Node, error) {
+	atomic.AddInt32(&c.lookups, 1)
+	time.Sleep(time.Second)
+	return &api.Node{ObjectMeta: meta.ObjectMeta{Name: name, Labels: map[string]string{LabelTopologyZone: "z1"}}}, nil
+}
+
+func TestNodeZoneTimeout(t *testing.T) {
+	defer func(timeout, ttl time.Duration) { nodeZoneTimeout, nodeZoneFailTTL = timeout, ttl }(nodeZoneTimeout, nodeZoneFailTTL)
+	nodeZoneTimeout = 10 * time.Millisecond
+	nodeZoneFailTTL = 100 * time.Millisecond
+
+	k := New([]string{"cluster.local."})
+	conn := &slowConn{}
+	k.APIConn = conn
+
+	start := time.Now()
+	if zone := k.nodeZone("node1"); zone != "" {
+		t.Errorf("Expected no zone after a timeout, got %q", zone)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Expected the lookup to give up after %s, took %s", nodeZoneTimeout, d)
+	}
+	// The failure is cached.
+	k.nodeZone("node1")
+	if x := atomic.LoadInt32(&conn.lookups); x != 1 {
+		t.Errorf("Expected 1 lookup, got %d", x)
+	}
+
+	// Until it expires.
+	time.Sleep(nodeZoneFailTTL)
+	k.nodeZone("node1")
+	if x := atomic.LoadInt32(&conn.lookups); x != 2 {
+		t.Errorf("Expected 2 lookups after the failure expired, got %d", x)
+	}
+}
+
+func TestKubernetesParseTopology(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  string
+	}{
+		{`kubernetes cluster.local`, false, ""},
+		{`kubernetes cluster.local {
+	topology prefer
+}`, false, topologyPrefer},
+		{`kubernetes cluster.local {
+	topology only
+}`, false, topologyOnly},
+		{`kubernetes cluster.local {
+	topology
+}`, true, ""},
+		{`kubernetes cluster.local {
+	topology nearest
+}`, true, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := kubernetesParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if k.topologyMode != tc.expected {
+			t.Errorf("Test %d: expected topology %q, got %q", i, tc.expected, k.topologyMode)
+		}
+	}
+}
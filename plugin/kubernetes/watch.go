@@ -1,6 +1,11 @@
 package kubernetes
 
 import (
+	"github.com/coredns/coredns/plugin/kubernetes/discovery"
+	"github.com/coredns/coredns/plugin/kubernetes/gateway"
+	"github.com/coredns/coredns/plugin/kubernetes/multicluster"
+	"github.com/coredns/coredns/plugin/kubernetes/networking"
+
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/watch"
@@ -37,6 +42,51 @@ func endpointsWatchFunc(c kubernetes.Interface, ns string, s labels.Selector) fu
 	}
 }
 
+func endpointSliceWatchFunc(c discovery.Interface, ns string, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		if s != nil {
+			options.LabelSelector = s.String()
+		}
+		w, err := c.Watch(ns, options)
+		return w, err
+	}
+}
+
+func serviceImportWatchFunc(c multicluster.Interface, ns string) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		w, err := c.Watch(ns, options)
+		return w, err
+	}
+}
+
+func nodeWatchFunc(c kubernetes.Interface) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		w, err := c.CoreV1().Nodes().Watch(options)
+		return w, err
+	}
+}
+
+func ingressWatchFunc(c networking.Interface, ns string) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		w, err := c.Watch(ns, options)
+		return w, err
+	}
+}
+
+func gatewayWatchFunc(c gateway.Interface, ns string) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		w, err := c.WatchGateways(ns, options)
+		return w, err
+	}
+}
+
+func httpRouteWatchFunc(c gateway.Interface, ns string) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		w, err := c.WatchHTTPRoutes(ns, options)
+		return w, err
+	}
+}
+
 func namespaceWatchFunc(c kubernetes.Interface, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
 	return func(options meta.ListOptions) (watch.Interface, error) {
 		if s != nil {
@@ -14,85 +14,49 @@ import (
 	api "k8s.io/api/core/v1"
 )
 
-const transferLength = 2000
-
-// Serial implements the Transferer interface.
+// Serial implements the plugin.ServiceBackend interface.
 func (k *Kubernetes) Serial(state request.Request) uint32 { return uint32(k.APIConn.Modified()) }
 
-// MinTTL implements the Transferer interface.
+// MinTTL implements the plugin.ServiceBackend interface.
 func (k *Kubernetes) MinTTL(state request.Request) uint32 { return k.ttl }
 
-// Transfer implements the Transferer interface.
-func (k *Kubernetes) Transfer(ctx context.Context, state request.Request) (int, error) {
-
-	if !k.transferAllowed(state) {
-		return dns.RcodeRefused, nil
-	}
-
-	// Get all services.
-	rrs := make(chan dns.RR)
-	go k.transfer(rrs, state.Zone)
-
-	records := []dns.RR{}
-	for r := range rrs {
-		records = append(records, r)
-	}
-
-	if len(records) == 0 {
-		return dns.RcodeServerFailure, nil
+// Transfer implements the plugin.Transferer interface.
+func (k *Kubernetes) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
+	if plugin.Zones(k.Zones).Matches(zone) != zone || k.isMultiClusterZone(zone) || k.isIngressZone(zone) {
+		return nil, plugin.ErrNotAuthoritative
 	}
 
-	ch := make(chan *dns.Envelope)
-	tr := new(dns.Transfer)
-
-	soa, err := plugin.SOA(ctx, k, state.Zone, state, plugin.Options{})
+	// state is not used in these calls; the SOA only depends on the zone.
+	state := request.Request{}
+	soa, err := plugin.SOA(context.TODO(), k, zone, state, plugin.Options{})
 	if err != nil {
-		return dns.RcodeServerFailure, nil
+		return nil, err
 	}
 
-	records = append(soa, records...)
-	records = append(records, soa...)
-	go func(ch chan *dns.Envelope) {
-		j, l := 0, 0
-		log.Infof("Outgoing transfer of %d records of zone %s to %s started", len(records), state.Zone, state.IP())
-		for i, r := range records {
-			l += dns.Len(r)
-			if l > transferLength {
-				ch <- &dns.Envelope{RR: records[j:i]}
-				l = 0
-				j = i
-			}
-		}
-		if j < len(records) {
-			ch <- &dns.Envelope{RR: records[j:]}
+	ch := make(chan []dns.RR)
+	go func() {
+		defer close(ch)
+		ch <- soa
+		if serial != 0 && !serialLess(serial, soa[0].(*dns.SOA).Serial) {
+			// Client is up to date.
+			return
 		}
-		close(ch)
-	}(ch)
 
-	tr.Out(state.W, state.Req, ch)
-	// Defer closing to the client
-	state.W.Hijack()
-	return dns.RcodeSuccess, nil
+		rrs := make(chan dns.RR)
+		go k.transfer(rrs, zone)
+		for r := range rrs {
+			ch <- []dns.RR{r}
+		}
+	}()
+	return ch, nil
 }
 
-// transferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
-// Note: This is copied from zone.transferAllowed, but should eventually be factored into a common transfer pkg.
-func (k *Kubernetes) transferAllowed(state request.Request) bool {
-	for _, t := range k.TransferTo {
-		if t == "*" {
-			return true
-		}
-		// If remote IP matches we accept.
-		remote := state.IP()
-		to, _, err := net.SplitHostPort(t)
-		if err != nil {
-			continue
-		}
-		if to == remote {
-			return true
-		}
+// serialLess returns true if serial a is older than b, using serial number arithmetic (RFC 1982).
+func serialLess(a, b uint32) bool {
+	if a < b {
+		return (b - a) <= math.MaxInt32
 	}
-	return false
+	return (a - b) > math.MaxInt32
 }
 
 func (k *Kubernetes) transfer(c chan dns.RR, zone string) {
@@ -1,53 +1,41 @@
 package kubernetes
 
 import (
-	"context"
+	"math"
 	"strings"
 	"testing"
 
+	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/kubernetes/object"
-	"github.com/coredns/coredns/plugin/pkg/dnstest"
-	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
 )
 
-func TestKubernetesXFR(t *testing.T) {
+func TestKubernetesTransfer(t *testing.T) {
 	k := New([]string{"cluster.local."})
 	k.APIConn = &APIConnServeTest{}
-	k.TransferTo = []string{"10.240.0.1:53"}
 	k.Namespaces = map[string]struct{}{"testns": {}}
 
-	ctx := context.TODO()
-	w := dnstest.NewMultiRecorder(&test.ResponseWriter{})
-	dnsmsg := &dns.Msg{}
-	dnsmsg.SetAxfr(k.Zones[0])
-
-	_, err := k.ServeDNS(ctx, w, dnsmsg)
+	ch, err := k.Transfer(k.Zones[0], 0)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	if len(w.Msgs) == 0 {
-		t.Logf("%+v\n", w)
-		t.Fatal("Did not get back a zone response")
+	gotRRs := []dns.RR{}
+	for rrs := range ch {
+		gotRRs = append(gotRRs, rrs...)
 	}
 
-	if len(w.Msgs[0].Answer) == 0 {
-		t.Logf("%+v\n", w)
-		t.Fatal("Did not get back an answer")
+	if len(gotRRs) == 0 {
+		t.Fatal("Did not get back a zone response")
 	}
 
 	// Ensure xfr starts with SOA
-	if w.Msgs[0].Answer[0].Header().Rrtype != dns.TypeSOA {
+	if gotRRs[0].Header().Rrtype != dns.TypeSOA {
 		t.Error("Invalid XFR, does not start with SOA record")
 	}
-
-	// Ensure xfr starts with SOA
-	// Last message is empty, so we need to go back one further
-	if w.Msgs[len(w.Msgs)-2].Answer[len(w.Msgs[len(w.Msgs)-2].Answer)-1].Header().Rrtype != dns.TypeSOA {
-		t.Error("Invalid XFR, does not end with SOA record")
-	}
+	// Skip SOA records since these test cases do not exist
+	gotRRs = gotRRs[1:]
 
 	testRRs := []dns.RR{}
 	for _, tc := range dnsTestCases {
@@ -69,20 +57,6 @@ func TestKubernetesXFR(t *testing.T) {
 		}
 	}
 
-	gotRRs := []dns.RR{}
-	for _, resp := range w.Msgs {
-		for _, ans := range resp.Answer {
-			// Skip SOA records since these
-			// test cases do not exist
-			if ans.Header().Rrtype == dns.TypeSOA {
-				continue
-			}
-
-			gotRRs = append(gotRRs, ans)
-		}
-
-	}
-
 	diff := difference(testRRs, gotRRs)
 	if len(diff) != 0 {
 		t.Errorf("Got back %d records that do not exist in test cases, should be 0:", len(diff))
@@ -100,30 +74,56 @@ func TestKubernetesXFR(t *testing.T) {
 	}
 }
 
-func TestKubernetesXFRNotAllowed(t *testing.T) {
+func TestKubernetesTransferIXFR(t *testing.T) {
 	k := New([]string{"cluster.local."})
 	k.APIConn = &APIConnServeTest{}
-	k.TransferTo = []string{"1.2.3.4:53"}
 	k.Namespaces = map[string]struct{}{"testns": {}}
 
-	ctx := context.TODO()
-	w := dnstest.NewMultiRecorder(&test.ResponseWriter{})
-	dnsmsg := &dns.Msg{}
-	dnsmsg.SetAxfr(k.Zones[0])
+	serial := uint32(k.APIConn.Modified())
+	ch, err := k.Transfer(k.Zones[0], serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotRRs := []dns.RR{}
+	for rrs := range ch {
+		gotRRs = append(gotRRs, rrs...)
+	}
+	if len(gotRRs) != 1 {
+		t.Fatalf("Expected only the SOA record, got %d records", len(gotRRs))
+	}
+	if gotRRs[0].Header().Rrtype != dns.TypeSOA {
+		t.Errorf("Expected SOA record, got %s", gotRRs[0])
+	}
+}
 
-	_, err := k.ServeDNS(ctx, w, dnsmsg)
+func TestKubernetesTransferIXFRSerialWrap(t *testing.T) {
+	k := New([]string{"cluster.local."})
+	k.APIConn = &APIConnServeTest{}
+	k.Namespaces = map[string]struct{}{"testns": {}}
+
+	// Numerically higher, but in serial number arithmetic older than our serial.
+	serial := uint32(k.APIConn.Modified()) + math.MaxInt32 + 10
+	ch, err := k.Transfer(k.Zones[0], serial)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	if len(w.Msgs) == 0 {
-		t.Logf("%+v\n", w)
-		t.Fatal("Did not get back a zone response")
+	gotRRs := []dns.RR{}
+	for rrs := range ch {
+		gotRRs = append(gotRRs, rrs...)
+	}
+	if len(gotRRs) < 2 {
+		t.Fatalf("Expected the full zone, got %d records", len(gotRRs))
 	}
+}
+
+func TestKubernetesTransferNotAuthoritative(t *testing.T) {
+	k := New([]string{"cluster.local."})
+	k.APIConn = &APIConnServeTest{}
 
-	if len(w.Msgs[0].Answer) != 0 {
-		t.Logf("%+v\n", w)
-		t.Fatal("Got an answer, should not have")
+	if _, err := k.Transfer("example.org.", 0); err != plugin.ErrNotAuthoritative {
+		t.Errorf("Expected %q, got %v", plugin.ErrNotAuthoritative, err)
 	}
 }
 
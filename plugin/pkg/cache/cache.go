@@ -62,6 +62,26 @@ func (c *Cache) Remove(key uint64) {
 	c.shards[shard].Remove(key)
 }
 
+// Walk calls f for each element in the cache until f returns false. Each shard is read locked while it is
+// walked, so f must not modify the cache.
+func (c *Cache) Walk(f func(key uint64, el interface{}) bool) {
+	for _, s := range c.shards {
+		if !s.Walk(f) {
+			return
+		}
+	}
+}
+
+// RemoveFunc removes the elements for which f returns true, it returns the number of removed elements.
+// Each shard is write locked while f is called for its elements.
+func (c *Cache) RemoveFunc(f func(key uint64, el interface{}) bool) int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.RemoveFunc(f)
+	}
+	return n
+}
+
 // Len returns the number of elements in the cache.
 func (c *Cache) Len() int {
 	l := 0
@@ -123,6 +143,32 @@ func (s *shard) Get(key uint64) (interface{}, bool) {
 	return el, found
 }
 
+// Walk calls f for each element in the shard, it returns false as soon as f does.
+func (s *shard) Walk(f func(key uint64, el interface{}) bool) bool {
+	s.RLock()
+	defer s.RUnlock()
+	for k, el := range s.items {
+		if !f(k, el) {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveFunc removes the elements for which f returns true and returns how many were removed.
+func (s *shard) RemoveFunc(f func(key uint64, el interface{}) bool) int {
+	s.Lock()
+	defer s.Unlock()
+	n := 0
+	for k, el := range s.items {
+		if f(k, el) {
+			delete(s.items, k)
+			n++
+		}
+	}
+	return n
+}
+
 // Len returns the current length of the cache.
 func (s *shard) Len() int {
 	s.RLock()
@@ -30,6 +30,52 @@ func TestCacheLen(t *testing.T) {
 	}
 }
 
+func TestCacheWalk(t *testing.T) {
+	c := New(4)
+	for i := uint64(0); i < 10; i++ {
+		c.Add(i, i)
+	}
+
+	sum := uint64(0)
+	c.Walk(func(key uint64, el interface{}) bool {
+		sum += el.(uint64)
+		return true
+	})
+	if sum != 45 {
+		t.Fatalf("Sum of walked elements should be %d, got %d", 45, sum)
+	}
+
+	n := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("Walk should stop after %d elements, got %d", 3, n)
+	}
+}
+
+func TestCacheRemoveFunc(t *testing.T) {
+	c := New(4)
+	for i := uint64(0); i < 10; i++ {
+		c.Add(i, i)
+	}
+
+	n := c.RemoveFunc(func(key uint64, el interface{}) bool { return el.(uint64)%2 == 0 })
+	if n != 5 {
+		t.Fatalf("Should have removed %d elements, got %d", 5, n)
+	}
+	if l := c.Len(); l != 5 {
+		t.Fatalf("Cache size should %d, got %d", 5, l)
+	}
+	if _, found := c.Get(2); found {
+		t.Fatal("Found removed record")
+	}
+	if _, found := c.Get(3); !found {
+		t.Fatal("Failed to find record that should not be removed")
+	}
+}
+
 func BenchmarkCache(b *testing.B) {
 	b.ReportAllocs()
 
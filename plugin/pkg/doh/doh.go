@@ -38,7 +38,7 @@ func NewRequest(method, url string, m *dns.Msg) (*http.Request, error) {
 		return req, nil
 
 	case http.MethodPost:
-		req, err := http.NewRequest(http.MethodPost, "https://"+url+Path+"?bla=foo:443", bytes.NewReader(buf))
+		req, err := http.NewRequest(http.MethodPost, "https://"+url+Path, bytes.NewReader(buf))
 		if err != nil {
 			return req, err
 		}
@@ -0,0 +1,25 @@
+package edns
+
+import "github.com/miekg/dns"
+
+// OptionEDE is the code of the Extended DNS Error option, see RFC 8914.
+const OptionEDE = 15
+
+// Extended DNS Error info codes, see section 4 of RFC 8914.
+const (
+	EDEStaleAnswer          = 3
+	EDEBogus                = 6 // DNSSEC Bogus
+	EDESignatureExpired     = 7
+	EDESignatureNotYetValid = 8
+	EDEDNSKEYMissing        = 9
+	EDERRSIGsMissing        = 10
+	EDENSECMissing          = 12
+	EDEFiltered             = 17
+	EDENoReachableAuthority = 22
+	EDENetworkError         = 23
+)
+
+// NewEDE returns an Extended DNS Error option with info code and the optional extra text.
+func NewEDE(code uint16, text string) *dns.EDNS0_LOCAL {
+	return &dns.EDNS0_LOCAL{Code: OptionEDE, Data: append([]byte{byte(code >> 8), byte(code)}, text...)}
+}
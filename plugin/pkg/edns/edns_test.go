@@ -26,6 +26,16 @@ func TestVersionNoEdns(t *testing.T) {
 	}
 }
 
+func TestNewEDE(t *testing.T) {
+	e := NewEDE(EDEFiltered, "filtered")
+	if e.Code != OptionEDE {
+		t.Errorf("Expected option code %d, got %d", OptionEDE, e.Code)
+	}
+	if x := string(e.Data); x != "\x00\x11filtered" {
+		t.Errorf("Expected info code 17 and the extra text, got %q", x)
+	}
+}
+
 func ednsMsg() *dns.Msg {
 	m := new(dns.Msg)
 	m.SetQuestion("example.com.", dns.TypeA)
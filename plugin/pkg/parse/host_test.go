@@ -24,6 +24,16 @@ func TestHostPortOrFile(t *testing.T) {
 			"8.8.8.8:153",
 			false,
 		},
+		{
+			"https://8.8.8.8",
+			"https://8.8.8.8:443",
+			false,
+		},
+		{
+			"quic://8.8.8.8",
+			"quic://8.8.8.8:853",
+			false,
+		},
 		{
 			"/etc/resolv.conf:53",
 			"",
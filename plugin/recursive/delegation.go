@@ -0,0 +1,168 @@
+package recursive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// delegation is a zone cut: the name servers of zone and the addresses of those that came as glue.
+type delegation struct {
+	zone   string
+	ns     []string
+	glue   map[string][]string
+	expire time.Time
+}
+
+// addrEntry holds the addresses of a name server.
+type addrEntry struct {
+	addrs  []string
+	expire time.Time
+}
+
+// closest returns the delegation of the closest enclosing zone of name that is known.
+func (r *Recursive) closest(name string, now time.Time) *delegation {
+	for {
+		if name == "." {
+			return r.root
+		}
+		if d, ok := r.delegations.Get(cache.Hash([]byte(name))); ok && now.Before(d.(*delegation).expire) {
+			return d.(*delegation)
+		}
+		off, _ := dns.NextLabel(name, 0)
+		name = name[off:]
+		if name == "" {
+			name = "."
+		}
+	}
+}
+
+// referral returns the delegation from the referral in m, sent by a server of zone while resolving qname.
+// It returns nil when m is not a referral or when the referral isn't to a zone below zone and above or at
+// qname.
+func referral(m *dns.Msg, zone, qname string, now time.Time) *delegation {
+	if m.Rcode != dns.RcodeSuccess || m.Authoritative || len(m.Answer) > 0 {
+		return nil
+	}
+	d := &delegation{glue: map[string][]string{}}
+	ttl := uint32(maxTTL)
+	for _, rr := range m.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(ns.Hdr.Name)
+		if d.zone == "" {
+			d.zone = name
+		}
+		if name != d.zone {
+			continue
+		}
+		d.ns = append(d.ns, strings.ToLower(ns.Ns))
+		if ns.Hdr.Ttl < ttl {
+			ttl = ns.Hdr.Ttl
+		}
+	}
+	if len(d.ns) == 0 || d.zone == zone || !dns.IsSubDomain(zone, d.zone) || !dns.IsSubDomain(d.zone, qname) {
+		return nil
+	}
+
+	// Glue is only accepted from the servers of the zone it is in.
+	for _, rr := range m.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(zone, name) {
+			continue
+		}
+		switch x := rr.(type) {
+		case *dns.A:
+			d.glue[name] = append(d.glue[name], x.A.String())
+		case *dns.AAAA:
+			d.glue[name] = append(d.glue[name], x.AAAA.String())
+		}
+	}
+	d.expire = now.Add(time.Duration(ttl) * time.Second)
+	return d
+}
+
+// parseHints parses the root hints in r: NS records for the root zone and the addresses of those
+// name servers.
+func parseHints(r io.Reader, file string) (*delegation, error) {
+	d := &delegation{zone: ".", glue: map[string][]string{}}
+	zp := dns.NewZoneParser(r, ".", file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := strings.ToLower(rr.Header().Name)
+		switch x := rr.(type) {
+		case *dns.NS:
+			if name != "." {
+				return nil, fmt.Errorf("root hints must only have NS records for the root zone: %s", rr)
+			}
+			d.ns = append(d.ns, strings.ToLower(x.Ns))
+		case *dns.A:
+			d.glue[name] = append(d.glue[name], x.A.String())
+		case *dns.AAAA:
+			d.glue[name] = append(d.glue[name], x.AAAA.String())
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if len(d.ns) == 0 {
+		return nil, fmt.Errorf("no root servers in %s", file)
+	}
+	for _, ns := range d.ns {
+		if len(d.glue[ns]) == 0 {
+			return nil, fmt.Errorf("no address for root server %s in %s", ns, file)
+		}
+	}
+	return d, nil
+}
+
+// rootHints are the root servers, as published by IANA.
+const rootHints = `
+.                  518400  IN  NS    a.root-servers.net.
+.                  518400  IN  NS    b.root-servers.net.
+.                  518400  IN  NS    c.root-servers.net.
+.                  518400  IN  NS    d.root-servers.net.
+.                  518400  IN  NS    e.root-servers.net.
+.                  518400  IN  NS    f.root-servers.net.
+.                  518400  IN  NS    g.root-servers.net.
+.                  518400  IN  NS    h.root-servers.net.
+.                  518400  IN  NS    i.root-servers.net.
+.                  518400  IN  NS    j.root-servers.net.
+.                  518400  IN  NS    k.root-servers.net.
+.                  518400  IN  NS    l.root-servers.net.
+.                  518400  IN  NS    m.root-servers.net.
+a.root-servers.net. 518400 IN  A     198.41.0.4
+a.root-servers.net. 518400 IN  AAAA  2001:503:ba3e::2:30
+b.root-servers.net. 518400 IN  A     170.247.170.2
+b.root-servers.net. 518400 IN  AAAA  2801:1b8:10::b
+c.root-servers.net. 518400 IN  A     192.33.4.12
+c.root-servers.net. 518400 IN  AAAA  2001:500:2::c
+d.root-servers.net. 518400 IN  A     199.7.91.13
+d.root-servers.net. 518400 IN  AAAA  2001:500:2d::d
+e.root-servers.net. 518400 IN  A     192.203.230.10
+e.root-servers.net. 518400 IN  AAAA  2001:500:a8::e
+f.root-servers.net. 518400 IN  A     192.5.5.241
+f.root-servers.net. 518400 IN  AAAA  2001:500:2f::f
+g.root-servers.net. 518400 IN  A     192.112.36.4
+g.root-servers.net. 518400 IN  AAAA  2001:500:12::d0d
+h.root-servers.net. 518400 IN  A     198.97.190.53
+h.root-servers.net. 518400 IN  AAAA  2001:500:1::53
+i.root-servers.net. 518400 IN  A     192.36.148.17
+i.root-servers.net. 518400 IN  AAAA  2001:7fe::53
+j.root-servers.net. 518400 IN  A     192.58.128.30
+j.root-servers.net. 518400 IN  AAAA  2001:503:c27::2:30
+k.root-servers.net. 518400 IN  A     193.0.14.129
+k.root-servers.net. 518400 IN  AAAA  2001:7fd::1
+l.root-servers.net. 518400 IN  A     199.7.83.42
+l.root-servers.net. 518400 IN  AAAA  2001:500:9f::42
+m.root-servers.net. 518400 IN  A     202.12.27.33
+m.root-servers.net. 518400 IN  AAAA  2001:dc3::35
+`
+
+const maxTTL = 86400 // upper bound of the time delegations and addresses are cached
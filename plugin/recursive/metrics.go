@@ -0,0 +1,23 @@
+package recursive
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	UpstreamCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursive",
+		Name:      "upstream_request_count_total",
+		Help:      "Counter of queries sent to authoritative servers.",
+	})
+	UpstreamFailureCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursive",
+		Name:      "upstream_failure_count_total",
+		Help:      "Counter of queries to authoritative servers that failed.",
+	})
+)
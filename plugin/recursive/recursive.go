@@ -0,0 +1,85 @@
+// Package recursive implements an iterative resolver: it resolves names by following the referrals
+// from the root servers down to the servers that are authoritative for the name.
+package recursive
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("recursive")
+
+// Recursive is a plugin that resolves queries by iterating from the root hints.
+type Recursive struct {
+	Next  plugin.Handler
+	Zones []string
+
+	root     *delegation // the root servers, from the root hints
+	minimize bool        // QNAME minimisation, RFC 9156
+	maxDepth int         // how deep resolving name server names and CNAME targets may nest
+	timeout  time.Duration
+
+	delegations *cache.Cache // zone cuts, keyed by zone name
+	addresses   *cache.Cache // addresses of name servers, keyed by name
+
+	port string // port of the authoritative servers, only changed in tests
+}
+
+// New returns a new Recursive, it uses the built-in root hints.
+func New() *Recursive {
+	root, _ := parseHints(strings.NewReader(rootHints), "root hints")
+	return &Recursive{
+		root:        root,
+		minimize:    true,
+		maxDepth:    defaultMaxDepth,
+		timeout:     defaultTimeout,
+		delegations: cache.New(defaultCapacity),
+		addresses:   cache.New(defaultCapacity),
+		port:        "53",
+	}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (r *Recursive) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: req}
+	if plugin.Zones(r.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, req)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	res, err := r.resolve(ctx, state.Name(), state.QType(), 0)
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.RecursionAvailable = true
+	m.Rcode = res.Rcode
+	m.Answer = res.Answer
+	m.Ns = res.Ns
+	state.SizeAndDo(m)
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (r *Recursive) Name() string { return "recursive" }
+
+const (
+	defaultMaxDepth = 8
+	defaultTimeout  = 2 * time.Second // per query to an authoritative server
+	defaultCapacity = 10000
+	resolveTimeout  = 10 * time.Second
+)
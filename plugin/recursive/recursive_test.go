@@ -0,0 +1,253 @@
+package recursive
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const rootZone = `. 3600 IN SOA a.root.test. hostmaster.root.test. 1 7200 3600 1209600 3600
+. 3600 IN NS a.root.test.
+a.root.test. 3600 IN A 127.0.0.1
+org. 3600 IN NS ns.org.
+ns.org. 3600 IN A 127.0.0.2
+net. 3600 IN NS ns.org.
+`
+
+const orgZone = `org. 3600 IN SOA ns.org. hostmaster.org. 1 7200 3600 1209600 3600
+org. 3600 IN NS ns.org.
+ns.org. 3600 IN A 127.0.0.2
+example.org. 3600 IN NS ns1.example.net.
+`
+
+const netZone = `net. 3600 IN SOA ns.org. hostmaster.org. 1 7200 3600 1209600 3600
+net. 3600 IN NS ns.org.
+ns1.example.net. 3600 IN A 127.0.0.3
+www.example.net. 3600 IN A 127.0.0.11
+`
+
+const exampleZone = `example.org. 3600 IN SOA ns1.example.net. hostmaster.example.org. 1 7200 3600 1209600 3600
+example.org. 3600 IN NS ns1.example.net.
+www.example.org. 3600 IN A 127.0.0.10
+alias.example.org. 3600 IN CNAME www.example.org.
+ext.example.org. 3600 IN CNAME www.example.net.
+a.b.c.example.org. 3600 IN A 127.0.0.12
+sub.example.org. 3600 IN NS ns1.example.net.
+deleg.example.org. 3600 IN CNAME www.sub.example.org.
+`
+
+const subZone = `sub.example.org. 3600 IN SOA ns1.example.net. hostmaster.example.org. 1 7200 3600 1209600 3600
+sub.example.org. 3600 IN NS ns1.example.net.
+www.sub.example.org. 3600 IN A 127.0.0.13
+`
+
+// authServers runs authoritative servers for a small tree of zones on 127.0.0.1 (root), 127.0.0.2
+// (org. and net.) and 127.0.0.3 (example.org. and sub.example.org.), all on the same port. It returns the port and the
+// queries each server received.
+type authServers struct {
+	port    string
+	servers []*dns.Server
+
+	mu      sync.Mutex
+	queries map[string][]string // by server address
+}
+
+func newAuthServers(t *testing.T) *authServers {
+	a := &authServers{queries: map[string][]string{}}
+	zones := map[string]map[string]string{
+		"127.0.0.1": {".": rootZone},
+		"127.0.0.2": {"org.": orgZone, "net.": netZone},
+		"127.0.0.3": {"example.org.": exampleZone, "sub.example.org.": subZone},
+	}
+	for _, ip := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"} {
+		addr := ip + ":0"
+		if a.port != "" {
+			addr = net.JoinHostPort(ip, a.port)
+		}
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			a.close()
+			t.Skipf("Can't listen on %s: %s", addr, err)
+		}
+		if a.port == "" {
+			_, a.port, _ = net.SplitHostPort(pc.LocalAddr().String())
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(ip, a.port))
+		if err != nil {
+			pc.Close()
+			a.close()
+			t.Skipf("Can't listen on %s: %s", addr, err)
+		}
+
+		f := file.File{Zones: file.Zones{Z: map[string]*file.Zone{}}}
+		for origin, z := range zones[ip] {
+			zone, err := file.Parse(strings.NewReader(z), origin, "stdin", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Zones.Z[origin] = zone
+			f.Zones.Names = append(f.Zones.Names, origin)
+		}
+		f.Next = test.ErrorHandler()
+
+		ip := ip
+		h := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			a.mu.Lock()
+			a.queries[ip] = append(a.queries[ip], r.Question[0].Name+"/"+dns.TypeToString[r.Question[0].Qtype])
+			a.mu.Unlock()
+			if rcode, _ := f.ServeDNS(context.TODO(), w, r); !plugin.ClientWrite(rcode) {
+				m := new(dns.Msg)
+				m.SetRcode(r, rcode)
+				w.WriteMsg(m)
+			}
+		})
+		s1 := &dns.Server{PacketConn: pc, Handler: h}
+		s2 := &dns.Server{Listener: l, Handler: h}
+		go s1.ActivateAndServe()
+		go s2.ActivateAndServe()
+		a.servers = append(a.servers, s1, s2)
+	}
+	return a
+}
+
+// get returns a copy of the queries the server on ip received.
+func (a *authServers) get(ip string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.queries[ip]...)
+}
+
+func (a *authServers) close() {
+	for _, s := range a.servers {
+		s.Shutdown()
+	}
+}
+
+func newTestRecursive(a *authServers) *Recursive {
+	r := New()
+	r.root, _ = parseHints(strings.NewReader(". IN NS a.root.test.\na.root.test. IN A 127.0.0.1\n"), "test")
+	r.port = a.port
+	r.Zones = []string{"."}
+	return r
+}
+
+func TestRecursive(t *testing.T) {
+	a := newAuthServers(t)
+	defer a.close()
+	r := newTestRecursive(a)
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []dns.RR
+	}{
+		{"www.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("www.example.org. 3600 IN A 127.0.0.10")}},
+		{"alias.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{
+			test.CNAME("alias.example.org. 3600 IN CNAME www.example.org."),
+			test.A("www.example.org. 3600 IN A 127.0.0.10"),
+		}},
+		{"ext.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{
+			test.CNAME("ext.example.org. 3600 IN CNAME www.example.net."),
+			test.A("www.example.net. 3600 IN A 127.0.0.11"),
+		}},
+		{"a.b.c.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("a.b.c.example.org. 3600 IN A 127.0.0.12")}},
+		{"deleg.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{
+			test.CNAME("deleg.example.org. 3600 IN CNAME www.sub.example.org."),
+			test.A("www.sub.example.org. 3600 IN A 127.0.0.13"),
+		}},
+		{"www.example.org.", dns.TypeMX, dns.RcodeSuccess, nil},
+		{"nx.example.org.", dns.TypeA, dns.RcodeNameError, nil},
+		{"a.nx.example.org.", dns.TypeA, dns.RcodeNameError, nil},
+		{"example.com.", dns.TypeA, dns.RcodeNameError, nil},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if !rec.Msg.RecursionAvailable {
+			t.Errorf("Test %d: expected RA to be set", i)
+		}
+		if len(rec.Msg.Answer) != len(tc.answer) {
+			t.Errorf("Test %d: expected %d answers, got %d", i, len(tc.answer), len(rec.Msg.Answer))
+			continue
+		}
+		if err := test.Section(test.Case{Answer: tc.answer}, test.Answer, rec.Msg.Answer); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestRecursiveMinimization(t *testing.T) {
+	a := newAuthServers(t)
+	defer a.close()
+	r := newTestRecursive(a)
+
+	m := new(dns.Msg)
+	m.SetQuestion("a.b.c.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// The root and org. servers never see the full name, and the delegations are cached.
+	for _, q := range a.get("127.0.0.1") {
+		if q != "org./A" && q != "net./A" {
+			t.Errorf("Unexpected query to the root server: %s", q)
+		}
+	}
+	for _, q := range a.get("127.0.0.2") {
+		if strings.Contains(q, "a.b.c") {
+			t.Errorf("Unexpected query to the org. server: %s", q)
+		}
+	}
+	root := len(a.get("127.0.0.1"))
+
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatal(err)
+	}
+	if x := len(a.get("127.0.0.1")); x != root {
+		t.Errorf("Expected no new queries to the root server, got %d", x-root)
+	}
+}
+
+func TestRecursiveNoMinimization(t *testing.T) {
+	a := newAuthServers(t)
+	defer a.close()
+	r := newTestRecursive(a)
+	r.minimize = false
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Msg.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(rec.Msg.Answer))
+	}
+	queries := a.get("127.0.0.1")
+	if len(queries) == 0 {
+		t.Fatal("Expected queries to the root server, got none")
+	}
+	if q := queries[0]; q != "www.example.org./A" {
+		t.Errorf("Expected the full name to be sent to the root server, got %s", q)
+	}
+}
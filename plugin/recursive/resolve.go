@@ -0,0 +1,233 @@
+package recursive
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// resolve resolves qname and qtype by following the referrals from the closest known delegation.
+func (r *Recursive) resolve(ctx context.Context, qname string, qtype uint16, depth int) (*dns.Msg, error) {
+	if depth > r.maxDepth {
+		return nil, errMaxDepth
+	}
+	qname = strings.ToLower(qname)
+	start := qname
+	if qtype == dns.TypeDS && qname != "." {
+		// The DS records are in the parent zone.
+		off, _ := dns.NextLabel(qname, 0)
+		start = qname[off:]
+	}
+	d := r.closest(start, time.Now())
+
+	// With QNAME minimisation the servers of a zone are asked for one label more than the zone until
+	// that is qname, min is the name that was asked last.
+	min := d.zone
+	for i := 0; i < maxReferrals; i++ {
+		name, t := qname, qtype
+		if r.minimize {
+			if n := dns.CountLabel(min) + 1; n < dns.CountLabel(qname) {
+				name, t = suffix(qname, n), dns.TypeA
+			}
+		}
+
+		m, err := r.query(ctx, d, name, t, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		if next := referral(m, d.zone, qname, time.Now()); next != nil {
+			r.delegations.Add(cache.Hash([]byte(next.zone)), next)
+			d, min = next, next.zone
+			continue
+		}
+
+		if name != qname {
+			// No zone cut at name. If name doesn't exist, qname doesn't either; see RFC 8020.
+			if m.Rcode == dns.RcodeNameError {
+				return m, nil
+			}
+			min = name
+			continue
+		}
+
+		return r.answer(ctx, d, m, qname, qtype, depth)
+	}
+	return nil, errMaxReferrals
+}
+
+// answer returns the final response m for qname and qtype, received from a server of d. CNAMEs that
+// point outside of the zone of d, or to a name the server didn't answer for, are followed.
+func (r *Recursive) answer(ctx context.Context, d *delegation, m *dns.Msg, qname string, qtype uint16, depth int) (*dns.Msg, error) {
+	// Only records for names in the zone of the server are accepted.
+	answer := m.Answer[:0]
+	for _, rr := range m.Answer {
+		if dns.IsSubDomain(d.zone, rr.Header().Name) {
+			answer = append(answer, rr)
+		}
+	}
+	m.Answer = answer
+	if m.Rcode != dns.RcodeSuccess || qtype == dns.TypeCNAME {
+		return m, nil
+	}
+
+	target := qname
+	for i := 0; i < maxCNAME; i++ {
+		next := ""
+		for _, rr := range m.Answer {
+			h := rr.Header()
+			if !strings.EqualFold(h.Name, target) {
+				continue
+			}
+			if h.Rrtype == qtype || qtype == dns.TypeANY {
+				return m, nil
+			}
+			if c, ok := rr.(*dns.CNAME); ok {
+				next = strings.ToLower(c.Target)
+			}
+		}
+		if next == "" {
+			if target == qname {
+				return m, nil // no data
+			}
+			break // the server didn't answer for the target, e.g. because it's delegated
+		}
+		target = next
+		if !dns.IsSubDomain(d.zone, target) {
+			break
+		}
+	}
+
+	res, err := r.resolve(ctx, target, qtype, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	res.Answer = append(m.Answer, res.Answer...)
+	return res, nil
+}
+
+// query sends the query for name and qtype to the servers of d, until one of them responds.
+func (r *Recursive) query(ctx context.Context, d *delegation, name string, qtype uint16, depth int) (*dns.Msg, error) {
+	err := errNoServers
+	for _, ns := range shuffle(d.ns) {
+		for _, addr := range r.addrs(ctx, d, ns, depth) {
+			var m *dns.Msg
+			m, err = r.exchange(ctx, addr, name, qtype)
+			if err != nil {
+				continue
+			}
+			if m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError {
+				return m, nil
+			}
+			err = errors.New("lame server " + addr + " for " + d.zone + ": " + dns.RcodeToString[m.Rcode])
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// addrs returns the addresses of the name server ns of d: the glue, or the result of resolving ns.
+func (r *Recursive) addrs(ctx context.Context, d *delegation, ns string, depth int) []string {
+	if addrs := d.glue[ns]; len(addrs) > 0 {
+		return addrs
+	}
+	if dns.IsSubDomain(d.zone, ns) {
+		// Without glue this name can't be resolved, it needs the servers we're looking for.
+		return nil
+	}
+
+	key := cache.Hash([]byte(ns))
+	if e, ok := r.addresses.Get(key); ok && time.Now().Before(e.(*addrEntry).expire) {
+		return e.(*addrEntry).addrs
+	}
+
+	e := &addrEntry{expire: time.Now().Add(maxTTL * time.Second)}
+	for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		m, err := r.resolve(ctx, ns, t, depth+1)
+		if err != nil {
+			log.Debugf("Failed to resolve name server %s: %s", ns, err)
+			continue
+		}
+		for _, rr := range m.Answer {
+			switch x := rr.(type) {
+			case *dns.A:
+				e.addrs = append(e.addrs, x.A.String())
+			case *dns.AAAA:
+				e.addrs = append(e.addrs, x.AAAA.String())
+			default:
+				continue
+			}
+			if exp := time.Now().Add(time.Duration(rr.Header().Ttl) * time.Second); exp.Before(e.expire) {
+				e.expire = exp
+			}
+		}
+	}
+	if len(e.addrs) > 0 {
+		r.addresses.Add(key, e)
+	}
+	return e.addrs
+}
+
+// exchange sends the query for name and qtype to the authoritative server at addr. When the response
+// is truncated the query is retried over TCP.
+func (r *Recursive) exchange(ctx context.Context, addr, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	m.SetEdns0(ednsSize, false)
+
+	c := &dns.Client{Net: "udp", Timeout: r.timeout}
+	UpstreamCount.Add(1)
+	ret, _, err := c.ExchangeContext(ctx, m, net.JoinHostPort(addr, r.port))
+	if err == nil && ret.Truncated {
+		c.Net = "tcp"
+		UpstreamCount.Add(1)
+		ret, _, err = c.ExchangeContext(ctx, m, net.JoinHostPort(addr, r.port))
+	}
+	if err != nil {
+		UpstreamFailureCount.Add(1)
+		return nil, err
+	}
+	if len(ret.Question) != 1 || !strings.EqualFold(ret.Question[0].Name, name) || ret.Question[0].Qtype != qtype {
+		UpstreamFailureCount.Add(1)
+		return nil, errWrongQuestion
+	}
+	return ret, nil
+}
+
+// suffix returns the last n labels of name.
+func suffix(name string, n int) string {
+	idx := dns.Split(name)
+	return name[idx[len(idx)-n]:]
+}
+
+// shuffle returns the names in a random order, so the load is spread over the servers.
+func shuffle(names []string) []string {
+	s := make([]string, len(names))
+	for i, j := range rand.Perm(len(names)) {
+		s[i] = names[j]
+	}
+	return s
+}
+
+var (
+	errMaxDepth      = errors.New("maximum depth reached")
+	errMaxReferrals  = errors.New("maximum number of referrals reached")
+	errNoServers     = errors.New("no reachable name servers")
+	errWrongQuestion = errors.New("response for the wrong question")
+)
+
+const (
+	ednsSize     = 1232
+	maxReferrals = 30
+	maxCNAME     = 8
+)
@@ -0,0 +1,127 @@
+package recursive
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("recursive", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	r, err := parse(c)
+	if err != nil {
+		return plugin.Error("recursive", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, UpstreamCount, UpstreamFailureCount)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		r.Next = next
+		return r
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Recursive, error) {
+	r := New()
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		r.Zones = c.RemainingArgs()
+		if len(r.Zones) == 0 {
+			r.Zones = make([]string, len(c.ServerBlockKeys))
+			copy(r.Zones, c.ServerBlockKeys)
+		}
+		for i := range r.Zones {
+			r.Zones[i] = plugin.Host(r.Zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "hints":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				fname := c.Val()
+				if !filepath.IsAbs(fname) && config.Root != "" {
+					fname = filepath.Join(config.Root, fname)
+				}
+				f, err := os.Open(fname)
+				if err != nil {
+					return nil, err
+				}
+				root, err := parseHints(f, fname)
+				f.Close()
+				if err != nil {
+					return nil, c.Errf("failed to parse %s: %s", fname, err)
+				}
+				r.root = root
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "qname_minimization":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				switch c.Val() {
+				case "on":
+					r.minimize = true
+				case "off":
+					r.minimize = false
+				default:
+					return nil, c.Errf("qname_minimization must be on or off: %s", c.Val())
+				}
+			case "max_depth":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 {
+					return nil, c.Errf("max_depth must be positive: %d", n)
+				}
+				r.maxDepth = n
+			case "timeout":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, c.Errf("timeout must be positive: %s", d)
+				}
+				r.timeout = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return r, nil
+}
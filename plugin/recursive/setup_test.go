@@ -0,0 +1,101 @@
+package recursive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		expectedErr string
+	}{
+		{`recursive`, false, ""},
+		{`recursive example.org`, false, ""},
+		{"recursive {\nqname_minimization off\nmax_depth 4\ntimeout 1s\n}", false, ""},
+		{"recursive {\nqname_minimization maybe\n}", true, "on or off"},
+		{"recursive {\nmax_depth 0\n}", true, "positive"},
+		{"recursive {\ntimeout -1s\n}", true, "positive"},
+		{"recursive {\nhints\n}", true, "Wrong argument count"},
+		{"recursive {\nhints /does/not/exist\n}", true, "no such file"},
+		{"recursive {\nblaat\n}", true, "unknown property"},
+		{"recursive\nrecursive", true, "plugin"},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		_, err := parse(c)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+		}
+		if err != nil {
+			if !tc.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+			} else if !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("Test %d: expected error to contain: %v, found error: %v, input: %s", i, tc.expectedErr, err, tc.input)
+			}
+		}
+	}
+}
+
+func TestSetupOptions(t *testing.T) {
+	c := caddy.NewTestController("dns", "recursive example.org {\nqname_minimization off\nmax_depth 4\ntimeout 1s\n}")
+	r, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.minimize || r.maxDepth != 4 || r.timeout != time.Second {
+		t.Errorf("Expected the options to be set, got minimize %t, max_depth %d, timeout %s", r.minimize, r.maxDepth, r.timeout)
+	}
+	if len(r.Zones) != 1 || r.Zones[0] != "example.org." {
+		t.Errorf("Expected zone example.org., got %v", r.Zones)
+	}
+	if len(r.root.ns) != 13 {
+		t.Errorf("Expected 13 root servers, got %d", len(r.root.ns))
+	}
+}
+
+func TestSetupHints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-recursive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		hints     string
+		shouldErr bool
+	}{
+		{". IN NS a.root.test.\na.root.test. IN A 127.0.0.1\n", false},
+		{". IN NS a.root.test.\n", true},                                 // no address
+		{"org. IN NS a.root.test.\na.root.test. IN A 127.0.0.1\n", true}, // not for the root
+		{"a.root.test. IN A 127.0.0.1\n", true},                          // no root servers
+	}
+	for i, tc := range tests {
+		name := filepath.Join(dir, "hints")
+		if err := ioutil.WriteFile(name, []byte(tc.hints), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c := caddy.NewTestController("dns", "recursive {\nhints "+name+"\n}")
+		r, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		if len(r.root.ns) != 1 || r.root.glue["a.root.test."][0] != "127.0.0.1" {
+			t.Errorf("Test %d: expected the root server from the hints", i)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package secondary
 
 import (
+	"strings"
+	"time"
+
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/file"
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/coredns/coredns/plugin/tsig"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -28,13 +34,21 @@ func setup(c *caddy.Controller) error {
 		z := zones.Z[n]
 		if len(z.TransferFrom) > 0 {
 			c.OnStartup(func() error {
+				var err error
 				z.StartupOnce.Do(func() {
+					// Only the name of the key is known after parsing, get the key itself from the tsig plugin.
+					if z.TransferTsig != nil {
+						if z.TransferTsig, err = tsig.Lookup(c, z.TransferTsig.Name); err != nil {
+							return
+						}
+					}
+					z.Xfr, _ = dnsserver.GetConfig(c).Handler("transfer").(*transfer.Transfer)
 					z.TransferIn()
 					go func() {
 						z.Update()
 					}()
 				})
-				return nil
+				return err
 			})
 		}
 	}
@@ -68,15 +82,37 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 
 			for c.NextBlock() {
 
-				t, f := []string{}, []string{}
-				var e error
+				f := []string{}
+				timeout := time.Duration(0)
+				key := ""
 
 				switch c.Val() {
 				case "transfer":
-					t, f, e = parse.Transfer(c, true)
+					t, froms, e := parse.Transfer(c, true)
+					if e != nil {
+						return file.Zones{}, e
+					}
+					if len(t) > 0 {
+						return file.Zones{}, c.Errf("transfer to is handled by the transfer plugin")
+					}
+					f = froms
+				case "timeout":
+					if !c.NextArg() {
+						return file.Zones{}, c.ArgErr()
+					}
+					d, e := time.ParseDuration(c.Val())
 					if e != nil {
 						return file.Zones{}, e
 					}
+					if d <= 0 {
+						return file.Zones{}, c.Errf("timeout must be positive: %s", d)
+					}
+					timeout = d
+				case "tsig":
+					if !c.NextArg() {
+						return file.Zones{}, c.ArgErr()
+					}
+					key = c.Val()
 				case "upstream":
 					c.RemainingArgs() // eat args
 				default:
@@ -84,12 +120,15 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 				}
 
 				for _, origin := range origins {
-					if t != nil {
-						z[origin].TransferTo = append(z[origin].TransferTo, t...)
-					}
 					if f != nil {
 						z[origin].TransferFrom = append(z[origin].TransferFrom, f...)
 					}
+					if timeout > 0 {
+						z[origin].TransferTimeout = timeout
+					}
+					if key != "" {
+						z[origin].TransferTsig = &tsig.Key{Name: dns.Fqdn(strings.ToLower(key))}
+					}
 					z[origin].Upstream = upstr
 				}
 			}
@@ -2,6 +2,7 @@ package secondary
 
 import (
 	"testing"
+	"time"
 
 	"github.com/mholt/caddy"
 )
@@ -12,30 +13,73 @@ func TestSecondaryParse(t *testing.T) {
 		shouldErr      bool
 		transferFrom   string
 		zones          []string
+		timeout        time.Duration
 	}{
 		{
 			`secondary`,
 			false, // TODO(miek): should actually be true, because without transfer lines this does not make sense
 			"",
 			nil,
+			0,
 		},
 		{
 			`secondary {
 				transfer from 127.0.0.1
-				transfer to 127.0.0.1
 			}`,
 			false,
 			"127.0.0.1:53",
 			nil,
+			0,
 		},
 		{
 			`secondary example.org {
 				transfer from 127.0.0.1
-				transfer to 127.0.0.1
 			}`,
 			false,
 			"127.0.0.1:53",
 			[]string{"example.org."},
+			0,
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1
+				timeout 5s
+			}`,
+			false,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			5 * time.Second,
+		},
+		// errors
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1
+				transfer to 127.0.0.1
+			}`,
+			true,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			0,
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1
+				timeout 0s
+			}`,
+			true,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			0,
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1
+				timeout
+			}`,
+			true,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			0,
 		},
 	}
 
@@ -48,6 +92,9 @@ func TestSecondaryParse(t *testing.T) {
 		} else if err != nil && !test.shouldErr {
 			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
 		}
+		if test.shouldErr {
+			continue
+		}
 
 		for i, name := range test.zones {
 			if x := s.Names[i]; x != name {
@@ -60,6 +107,32 @@ func TestSecondaryParse(t *testing.T) {
 			if x := v.TransferFrom[0]; x != test.transferFrom {
 				t.Fatalf("Test %d transform from names don't match expected %q, but got %q", i, test.transferFrom, x)
 			}
+			if v.TransferTimeout != test.timeout {
+				t.Fatalf("Test %d expected timeout %s, but got %s", i, test.timeout, v.TransferTimeout)
+			}
 		}
 	}
 }
+
+func TestSecondaryParseTsig(t *testing.T) {
+	c := caddy.NewTestController("dns", `secondary example.org {
+		transfer from 127.0.0.1
+		tsig Transfer.Key
+	}`)
+	s, err := secondaryParse(c)
+	if err != nil {
+		t.Fatalf("Expected no errors, but got '%v'", err)
+	}
+	k := s.Z["example.org."].TransferTsig
+	if k == nil || k.Name != "transfer.key." {
+		t.Fatalf("Expected TSIG key name transfer.key., got %v", k)
+	}
+
+	c = caddy.NewTestController("dns", `secondary example.org {
+		transfer from 127.0.0.1
+		tsig
+	}`)
+	if _, err := secondaryParse(c); err == nil {
+		t.Fatalf("Expected error for tsig without a key name")
+	}
+}
@@ -0,0 +1,77 @@
+package transfer
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/plugin/tsig"
+
+	"github.com/miekg/dns"
+)
+
+// Notify will send notifies to all configured to hosts IP addresses. The string zone must be
+// lowercased.
+func (t *Transfer) Notify(zone string) error {
+	if t == nil { // t might be nil, mostly expected in tests, so intercept and to a noop in that case
+		return nil
+	}
+
+	x := longestMatch(t.xfrs, zone)
+	if x == nil {
+		// Zone is not transferred, so there is no one to notify.
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetNotify(zone)
+	c := new(dns.Client)
+	if x.key != nil {
+		c.TsigSecret = x.key.Secrets()
+	}
+
+	var err1 error
+	for _, to := range x.to {
+		if to == "*" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(to); err != nil {
+			continue // a network, not a host
+		}
+		if err := sendNotify(c, m, x.key, to); err != nil {
+			err1 = err
+		} else {
+			log.Infof("Sent notify for zone %q to %q", zone, to)
+		}
+	}
+	return err1
+}
+
+// sendNotify sends a notify to a remote server. It will try up to three times before giving up. If
+// key isn't nil, each attempt is signed with it.
+func sendNotify(c *dns.Client, m *dns.Msg, key *tsig.Key, s string) error {
+	var err error
+	var ret *dns.Msg
+
+	code := dns.RcodeServerFailure
+	for i := 0; i < 3; i++ {
+		mm := m
+		if key != nil {
+			// The TSIG record is removed from a message when it is written, so every attempt gets a freshly signed copy.
+			mm = m.Copy()
+			key.Sign(mm)
+		}
+		ret, _, err = c.Exchange(mm, s)
+		if err != nil {
+			continue
+		}
+		code = ret.Rcode
+		if code == dns.RcodeSuccess {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("notify for zone %q was not accepted by %q: %q", m.Question[0].Name, s, err)
+	}
+	return fmt.Errorf("notify for zone %q was not accepted by %q: rcode was %q", m.Question[0].Name, s, rcode.ToString(code))
+}
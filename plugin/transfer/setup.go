@@ -0,0 +1,117 @@
+package transfer
+
+import (
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	parsepkg "github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/tsig"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("transfer", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	t, err := parse(c)
+	if err != nil {
+		return plugin.Error("transfer", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		t.Next = next
+		return t
+	})
+
+	c.OnStartup(func() error {
+		// Find all plugins that implement Transferer and add them to Transferers.
+		plugins := dnsserver.GetConfig(c).Handlers()
+		for _, pl := range plugins {
+			tr, ok := pl.(plugin.Transferer)
+			if !ok {
+				continue
+			}
+			t.Transferers = append(t.Transferers, tr)
+		}
+		// Only the names of the keys are known after parsing, get the keys themselves from the tsig plugin.
+		for _, x := range t.xfrs {
+			if x.key == nil {
+				continue
+			}
+			k, err := tsig.Lookup(c, x.key.Name)
+			if err != nil {
+				return plugin.Error("transfer", err)
+			}
+			x.key = k
+		}
+		return nil
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Transfer, error) {
+	t := &Transfer{}
+	for c.Next() {
+		x := &xfr{}
+		zones := c.RemainingArgs()
+
+		if len(zones) != 0 {
+			x.Zones = zones
+			for i := 0; i < len(x.Zones); i++ {
+				x.Zones[i] = plugin.Host(x.Zones[i]).Normalize()
+			}
+		} else {
+			x.Zones = make([]string, len(c.ServerBlockKeys))
+			for i := 0; i < len(c.ServerBlockKeys); i++ {
+				x.Zones[i] = plugin.Host(c.ServerBlockKeys[i]).Normalize()
+			}
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "to":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, host := range args {
+					if host == "*" {
+						x.to = append(x.to, host)
+						continue
+					}
+					if _, _, err := net.ParseCIDR(host); err == nil {
+						x.to = append(x.to, host)
+						continue
+					}
+					normalized, err := parsepkg.HostPort(host, transport.Port)
+					if err != nil {
+						return nil, err
+					}
+					x.to = append(x.to, normalized)
+				}
+			case "tsig":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				x.key = &tsig.Key{Name: dns.Fqdn(strings.ToLower(c.Val()))}
+			default:
+				return nil, plugin.Error("transfer", c.Errf("unknown property '%s'", c.Val()))
+			}
+		}
+		if len(x.to) == 0 {
+			return nil, plugin.Error("transfer", c.Errf("'to' is required"))
+		}
+		t.xfrs = append(t.xfrs, x)
+	}
+	return t, nil
+}
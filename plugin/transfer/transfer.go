@@ -0,0 +1,214 @@
+// Package transfer implements a plugin that handles outgoing zone transfers (AXFR and IXFR) for
+// plugins that implement the plugin.Transferer interface.
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/tsig"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("transfer")
+
+// Transfer is a plugin that handles zone transfers.
+type Transfer struct {
+	Transferers []plugin.Transferer // List of plugins that implement Transferer
+	xfrs        []*xfr
+	Next        plugin.Handler
+}
+
+type xfr struct {
+	Zones []string
+	to    []string
+	key   *tsig.Key // key to sign notifies with, only the name is set until startup
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (t *Transfer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if state.QType() != dns.TypeAXFR && state.QType() != dns.TypeIXFR {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	x := longestMatch(t.xfrs, state.QName())
+	if x == nil {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	if !x.allowed(state) {
+		return dns.RcodeRefused, nil
+	}
+
+	if state.Proto() != "tcp" && state.QType() == dns.TypeAXFR {
+		return dns.RcodeRefused, nil
+	}
+
+	// A signed request must have passed verification in the server.
+	if r.IsTsig() != nil && w.TsigStatus() != nil {
+		log.Warningf("Refusing transfer of %s to %s: %s", state.QName(), state.IP(), w.TsigStatus())
+		// The server only writes a reply for the rcodes in plugin.ClientWrite, NOTAUTH isn't one of them.
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNotAuth)
+		w.WriteMsg(m)
+		return dns.RcodeNotAuth, nil
+	}
+
+	serial := uint32(0)
+	if state.QType() == dns.TypeIXFR {
+		// The IXFR request carries the client's serial in a SOA record in the authority section.
+		for _, rr := range r.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				serial = soa.Serial
+				break
+			}
+		}
+	}
+
+	// Get a receiving channel from the first Transferer plugin that returns one.
+	var rrs <-chan []dns.RR
+	for _, p := range t.Transferers {
+		var err error
+		rrs, err = p.Transfer(state.QName(), serial)
+		if err == plugin.ErrNotAuthoritative {
+			continue
+		}
+		if err != nil {
+			return dns.RcodeServerFailure, err
+		}
+		break
+	}
+
+	if rrs == nil {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	n, err := t.out(w, r, rrs)
+	if err != nil {
+		log.Errorf("Failed outgoing transfer of %s to %s: %s", state.QName(), state.IP(), err)
+		return dns.RcodeServerFailure, nil
+	}
+	log.Infof("Outgoing transfer of %d records of zone %s to %s done", n, state.QName(), state.IP())
+
+	w.Hijack()
+	// w.Close() // Client closes connection
+	return dns.RcodeSuccess, nil
+}
+
+// out writes the records read from rrs to w, chunked into messages of at most transferLength
+// bytes. The first record read must be a SOA record, it is repeated at the end to close the
+// transfer, unless it is the only record in which case the client is up to date. It returns the
+// number of records written.
+func (t *Transfer) out(w dns.ResponseWriter, r *dns.Msg, rrs <-chan []dns.RR) (int, error) {
+	var (
+		soa     *dns.SOA
+		pending []dns.RR
+		l, n    int
+		err     error
+	)
+	for records := range rrs {
+		if err != nil {
+			continue // drain the channel so the sending goroutine can exit
+		}
+		if soa == nil && len(records) > 0 {
+			s, ok := records[0].(*dns.SOA)
+			if !ok {
+				err = fmt.Errorf("first record of transfer is not a SOA but %s", dns.TypeToString[records[0].Header().Rrtype])
+				continue
+			}
+			soa = s
+		}
+		for _, rr := range records {
+			l += dns.Len(rr)
+			if l > transferLength && len(pending) > 0 {
+				if err = writeMsg(w, r, pending); err != nil {
+					break
+				}
+				n += len(pending)
+				pending, l = nil, dns.Len(rr)
+			}
+			pending = append(pending, rr)
+		}
+	}
+	if err != nil {
+		return n, err
+	}
+	if soa == nil {
+		return n, fmt.Errorf("no records to transfer")
+	}
+
+	if n > 0 || len(pending) > 1 {
+		pending = append(pending, soa) // add closing SOA to the end
+	}
+	if err := writeMsg(w, r, pending); err != nil {
+		return n, err
+	}
+	return n + len(pending), nil
+}
+
+// writeMsg writes a single transfer message holding rrs. If the request was signed, the reply is
+// signed as well.
+func writeMsg(w dns.ResponseWriter, r *dns.Msg, rrs []dns.RR) error {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.Answer = rrs
+	if t := r.IsTsig(); t != nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+		// Subsequent messages only need the timers, RFC 2845, Section 4.4.
+		defer w.TsigTimersOnly(true)
+	}
+	return w.WriteMsg(m)
+}
+
+// allowed checks if incoming request for transferring the zone is allowed according to the ACLs.
+func (x *xfr) allowed(state request.Request) bool {
+	remote := net.ParseIP(state.IP())
+	for _, to := range x.to {
+		if to == "*" {
+			return true
+		}
+		if strings.Contains(to, "/") {
+			_, n, err := net.ParseCIDR(to)
+			if err == nil && n.Contains(remote) {
+				return true
+			}
+			continue
+		}
+		// If remote IP matches we accept.
+		host, _, err := net.SplitHostPort(to)
+		if err != nil {
+			continue
+		}
+		if net.ParseIP(host).Equal(remote) {
+			return true
+		}
+	}
+	return false
+}
+
+// longestMatch returns the xfr with the longest zone that matches qname, or nil.
+func longestMatch(xfrs []*xfr, qname string) *xfr {
+	zone := ""
+	var x *xfr
+	for _, xfr := range xfrs {
+		if z := plugin.Zones(xfr.Zones).Matches(qname); len(z) > len(zone) {
+			zone = z
+			x = xfr
+		}
+	}
+	return x
+}
+
+// Name implements the Handler interface.
+func (t *Transfer) Name() string { return "transfer" }
+
+const transferLength = 1000 // Start a new envelop after message reaches this size in bytes. Intentionally small to test multi envelope parsing.
@@ -0,0 +1,226 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/plugin/tsig"
+
+	"github.com/miekg/dns"
+)
+
+// testTransferer is a Transferer for a single zone that holds an SOA and a number of A records.
+type testTransferer struct {
+	zone   string
+	serial uint32
+	n      int
+}
+
+func (t testTransferer) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
+	if zone != t.zone {
+		return nil, plugin.ErrNotAuthoritative
+	}
+	soa := test.SOA(fmt.Sprintf("%s 100 IN SOA ns.dns.%s hostmaster.%s %d 7200 1800 86400 100", zone, zone, zone, t.serial))
+	ch := make(chan []dns.RR)
+	go func() {
+		defer close(ch)
+		ch <- []dns.RR{soa}
+		if serial != 0 && serial >= t.serial {
+			return
+		}
+		for i := 0; i < t.n; i++ {
+			ch <- []dns.RR{test.A(fmt.Sprintf("a%d.%s 100 IN A 127.0.0.1", i, zone))}
+		}
+	}()
+	return ch, nil
+}
+
+func newTestTransfer(to ...string) *Transfer {
+	return &Transfer{
+		Transferers: []plugin.Transferer{
+			testTransferer{zone: "example.com.", serial: 10, n: 2},
+			testTransferer{zone: "example.org.", serial: 20, n: 100},
+		},
+		xfrs: []*xfr{{Zones: []string{"example.com.", "example.org."}, to: to}},
+		Next: test.NextHandler(dns.RcodeNameError, nil),
+	}
+}
+
+func answers(w *dnstest.MultiRecorder) []dns.RR {
+	rrs := []dns.RR{}
+	for _, m := range w.Msgs {
+		rrs = append(rrs, m.Answer...)
+	}
+	return rrs
+}
+
+func TestTransferAXFR(t *testing.T) {
+	tr := newTestTransfer("*")
+
+	for _, zone := range []string{"example.com.", "example.org."} {
+		m := new(dns.Msg)
+		m.SetAxfr(zone)
+		w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+
+		if _, err := tr.ServeDNS(context.TODO(), w, m); err != nil {
+			t.Fatalf("Zone %s: expected no error, got %s", zone, err)
+		}
+		rrs := answers(w)
+		if len(rrs) < 2 {
+			t.Fatalf("Zone %s: expected at least 2 records, got %d", zone, len(rrs))
+		}
+		if rrs[0].Header().Rrtype != dns.TypeSOA {
+			t.Errorf("Zone %s: expected transfer to start with a SOA, got %s", zone, rrs[0])
+		}
+		if rrs[len(rrs)-1].Header().Rrtype != dns.TypeSOA {
+			t.Errorf("Zone %s: expected transfer to end with a SOA, got %s", zone, rrs[len(rrs)-1])
+		}
+	}
+
+	// example.org has enough records to be split over multiple messages.
+	m := new(dns.Msg)
+	m.SetAxfr("example.org.")
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+	tr.ServeDNS(context.TODO(), w, m)
+	if len(w.Msgs) < 2 {
+		t.Errorf("Expected the transfer to be split over multiple messages, got %d", len(w.Msgs))
+	}
+	if x := len(answers(w)); x != 102 {
+		t.Errorf("Expected 102 records, got %d", x)
+	}
+}
+
+func TestTransferIXFR(t *testing.T) {
+	tr := newTestTransfer("*")
+
+	tests := []struct {
+		serial   uint32
+		expected int
+	}{
+		{serial: 10, expected: 1}, // up to date
+		{serial: 11, expected: 1}, // newer than ours
+		{serial: 9, expected: 4},  // full zone
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetIxfr("example.com.", tc.serial, "ns.dns.example.com.", "hostmaster.example.com.")
+		w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+
+		if _, err := tr.ServeDNS(context.TODO(), w, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if x := len(answers(w)); x != tc.expected {
+			t.Errorf("Test %d: expected %d records, got %d", i, tc.expected, x)
+		}
+	}
+}
+
+func TestTransferNotAllowed(t *testing.T) {
+	tr := newTestTransfer("10.240.0.2:53", "192.168.0.0/16")
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.com.")
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+
+	rcode, _ := tr.ServeDNS(context.TODO(), w, m)
+	if rcode != dns.RcodeRefused {
+		t.Errorf("Expected rcode %d, got %d", dns.RcodeRefused, rcode)
+	}
+	if len(w.Msgs) != 0 {
+		t.Errorf("Expected no messages, got %d", len(w.Msgs))
+	}
+}
+
+func TestTransferAllowedNetwork(t *testing.T) {
+	tr := newTestTransfer("10.240.0.0/24")
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.com.")
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+
+	tr.ServeDNS(context.TODO(), w, m)
+	if len(w.Msgs) == 0 {
+		t.Errorf("Expected a transfer, got nothing")
+	}
+}
+
+func TestTransferUDP(t *testing.T) {
+	tr := newTestTransfer("*")
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.com.")
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{})
+
+	rcode, _ := tr.ServeDNS(context.TODO(), w, m)
+	if rcode != dns.RcodeRefused {
+		t.Errorf("Expected rcode %d, got %d", dns.RcodeRefused, rcode)
+	}
+}
+
+func TestTransferNotAuthoritative(t *testing.T) {
+	tr := newTestTransfer("*")
+	tr.xfrs[0].Zones = append(tr.xfrs[0].Zones, "example.net.")
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.net.")
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{TCP: true})
+
+	rcode, _ := tr.ServeDNS(context.TODO(), w, m)
+	if rcode != dns.RcodeNameError {
+		t.Errorf("Expected the next plugin to be called, got rcode %d", rcode)
+	}
+}
+
+// badTsigWriter is a ResponseWriter for a request whose TSIG failed verification.
+type badTsigWriter struct{ *test.ResponseWriter }
+
+func (badTsigWriter) TsigStatus() error { return dns.ErrSig }
+
+func TestTransferBadTsig(t *testing.T) {
+	tr := newTestTransfer("*")
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.org.")
+	m.SetTsig("key.", dns.HmacSHA256, 300, 0)
+	w := dnstest.NewMultiRecorder(badTsigWriter{&test.ResponseWriter{TCP: true}})
+
+	rcode, _ := tr.ServeDNS(context.TODO(), w, m)
+	if rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected rcode %d, got %d", dns.RcodeNotAuth, rcode)
+	}
+	if len(w.Msgs) != 1 {
+		t.Fatalf("Expected 1 reply, got %d", len(w.Msgs))
+	}
+	if w.Msgs[0].Rcode != dns.RcodeNotAuth {
+		t.Errorf("Expected NOTAUTH reply, got %s", dns.RcodeToString[w.Msgs[0].Rcode])
+	}
+}
+
+func TestNotifySigned(t *testing.T) {
+	var signed int32
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.IsTsig() != nil {
+			atomic.AddInt32(&signed, 1)
+		}
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+	})
+	defer s.Close()
+
+	tr := newTestTransfer(s.Addr)
+	tr.xfrs[0].key = &tsig.Key{Name: "key.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"}
+
+	if err := tr.Notify("example.org."); err == nil {
+		t.Fatal("Expected an error for a refused notify")
+	}
+	// All three attempts must be signed.
+	if n := atomic.LoadInt32(&signed); n != 3 {
+		t.Errorf("Expected 3 signed notifies, got %d", n)
+	}
+}
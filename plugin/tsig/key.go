@@ -0,0 +1,166 @@
+package tsig
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+// Key is a TSIG key.
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    string // base64 encoded
+}
+
+// Sign adds a TSIG record for k to m. The message is signed when it is written to a dns.Conn (or
+// sent with dns.Client or dns.Transfer) that has the secrets from k.Secrets.
+func (k Key) Sign(m *dns.Msg) { m.SetTsig(k.Name, k.Algorithm, fudge, time.Now().Unix()) }
+
+// Secrets returns the secret of k in the form used by dns.Client, dns.Conn and dns.Transfer.
+func (k Key) Secrets() map[string]string { return map[string]string{k.Name: k.Secret} }
+
+// Lookup returns the key with name from the tsig plugin in the server block of c. It should be
+// called from a startup function, as the plugin is only available once all plugins are set up.
+func Lookup(c *caddy.Controller, name string) (*Key, error) {
+	t, ok := dnsserver.GetConfig(c).Handler("tsig").(*TSIGServer)
+	if !ok {
+		return nil, fmt.Errorf("TSIG key %q used, but the tsig plugin is not loaded", name)
+	}
+	k, ok := t.Key(name)
+	if !ok {
+		return nil, fmt.Errorf("TSIG key %q not found in the tsig plugin", name)
+	}
+	return &k, nil
+}
+
+// newKey returns a key, name is made fully qualified and the algorithm is checked.
+func newKey(name, algorithm, secret string) (Key, error) {
+	alg, ok := algorithms[strings.ToLower(strings.TrimSuffix(algorithm, "."))]
+	if !ok {
+		return Key{}, fmt.Errorf("unsupported TSIG algorithm: %s", algorithm)
+	}
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return Key{}, fmt.Errorf("invalid secret for TSIG key %s: %s", name, err)
+	}
+	return Key{Name: dns.Fqdn(strings.ToLower(name)), Algorithm: alg, Secret: secret}, nil
+}
+
+// parseKeyFile parses keys in the BIND format, i.e.:
+//
+//	key "name" {
+//		algorithm hmac-sha256;
+//		secret "base64==";
+//	};
+func parseKeyFile(f io.Reader) ([]Key, error) {
+	tokens, err := tokenize(f)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []Key{}
+	for i := 0; i < len(tokens); {
+		if tokens[i] != "key" || i+2 >= len(tokens) || tokens[i+2] != "{" {
+			return nil, fmt.Errorf("expected 'key NAME {', got %q", strings.Join(tokens[i:min(i+3, len(tokens))], " "))
+		}
+		name := tokens[i+1]
+		i += 3
+
+		algorithm, secret := "", ""
+		for i < len(tokens) && tokens[i] != "}" {
+			if i+2 >= len(tokens) || tokens[i+2] != ";" {
+				return nil, fmt.Errorf("expected 'PROPERTY VALUE;' in key %s", name)
+			}
+			switch tokens[i] {
+			case "algorithm":
+				algorithm = tokens[i+1]
+			case "secret":
+				secret = tokens[i+1]
+			default:
+				return nil, fmt.Errorf("unknown property %q in key %s", tokens[i], name)
+			}
+			i += 3
+		}
+		if i+1 >= len(tokens) || tokens[i+1] != ";" {
+			return nil, fmt.Errorf("expected '};' after key %s", name)
+		}
+		i += 2
+
+		if secret == "" {
+			return nil, fmt.Errorf("no secret in key %s", name)
+		}
+		if algorithm == "" {
+			algorithm = defaultAlgorithm
+		}
+		k, err := newKey(name, algorithm, secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// tokenize splits a key file in words, quoted strings and the characters '{', '}' and ';'.
+// Comments starting with '#' or '//' are skipped.
+func tokenize(f io.Reader) ([]string, error) {
+	tokens := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for len(line) > 0 {
+			switch c := line[0]; {
+			case c == '#' || strings.HasPrefix(line, "//"):
+				line = ""
+			case c == ' ' || c == '\t':
+				line = line[1:]
+			case c == '{' || c == '}' || c == ';':
+				tokens = append(tokens, string(c))
+				line = line[1:]
+			case c == '"':
+				end := strings.IndexByte(line[1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated string: %s", line)
+				}
+				tokens = append(tokens, line[1:end+1])
+				line = line[end+2:]
+			default:
+				end := strings.IndexAny(line, " \t{};\"#")
+				if end < 0 {
+					end = len(line)
+				}
+				tokens = append(tokens, line[:end])
+				line = line[end:]
+			}
+		}
+	}
+	return tokens, scanner.Err()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var algorithms = map[string]string{
+	"hmac-md5":                 dns.HmacMD5,
+	"hmac-md5.sig-alg.reg.int": dns.HmacMD5,
+	"hmac-sha1":                dns.HmacSHA1,
+	"hmac-sha256":              dns.HmacSHA256,
+	"hmac-sha512":              dns.HmacSHA512,
+}
+
+const (
+	defaultAlgorithm = "hmac-sha256"
+	fudge            = 300
+)
@@ -0,0 +1,62 @@
+package tsig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseKeyFile(t *testing.T) {
+	tests := []struct {
+		input     string
+		keys      []Key
+		shouldErr bool
+	}{
+		{
+			`key "example.org." {
+	algorithm hmac-sha256;
+	secret "c2VjcmV0";
+};`,
+			[]Key{{Name: "example.org.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"}}, false,
+		},
+		{
+			`# two keys on a line, and a comment
+key a { secret "c2VjcmV0"; }; key "B" { algorithm hmac-sha512; secret "c2VjcmV0"; }; // done`,
+			[]Key{
+				{Name: "a.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"},
+				{Name: "b.", Algorithm: dns.HmacSHA512, Secret: "c2VjcmV0"},
+			}, false,
+		},
+		// errors
+		{`key a { algorithm hmac-sha256; };`, nil, true},                  // no secret
+		{`key a { algorithm hmac-sha3; secret "c2VjcmV0"; };`, nil, true}, // unknown algorithm
+		{`key a { secret "not base64"; };`, nil, true},                    // bad secret
+		{`key a { secret "c2VjcmV0" };`, nil, true},                       // missing ;
+		{`key a { secret "c2VjcmV0"; }`, nil, true},                       // missing ; after }
+		{`key a { secret "c2VjcmV0; };`, nil, true},                       // unterminated string
+		{`server 10.0.0.1 { keys { a; }; };`, nil, true},                  // not a key
+		{`key a { secret "c2VjcmV0"; port 53; };`, nil, true},             // unknown property
+	}
+
+	for i, tc := range tests {
+		keys, err := parseKeyFile(strings.NewReader(tc.input))
+		if err == nil && tc.shouldErr {
+			t.Errorf("Test %d: expected error, got none", i)
+			continue
+		}
+		if err != nil && !tc.shouldErr {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(keys) != len(tc.keys) {
+			t.Errorf("Test %d: expected %d keys, got %d", i, len(tc.keys), len(keys))
+			continue
+		}
+		for j := range keys {
+			if keys[j] != tc.keys[j] {
+				t.Errorf("Test %d: expected key %v, got %v", i, tc.keys[j], keys[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tsig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("tsig", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	t, err := parse(c)
+	if err != nil {
+		return plugin.Error("tsig", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	if config.TsigSecret == nil {
+		config.TsigSecret = make(map[string]string)
+	}
+	for name, k := range t.keys {
+		config.TsigSecret[name] = k.Secret
+	}
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		t.Next = next
+		return t
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*TSIGServer, error) {
+	t := &TSIGServer{
+		keys:    make(map[string]Key),
+		qtypes:  make(map[uint16]bool),
+		opcodes: make(map[int]bool),
+	}
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		t.Zones = c.RemainingArgs()
+		if len(t.Zones) == 0 {
+			t.Zones = make([]string, len(c.ServerBlockKeys))
+			copy(t.Zones, c.ServerBlockKeys)
+		}
+		for i := range t.Zones {
+			t.Zones[i] = plugin.Host(t.Zones[i]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "secret":
+				args := c.RemainingArgs()
+				if len(args) != 2 && len(args) != 3 {
+					return nil, c.ArgErr()
+				}
+				algorithm := defaultAlgorithm
+				if len(args) == 3 {
+					algorithm = args[2]
+				}
+				k, err := newKey(args[0], algorithm, args[1])
+				if err != nil {
+					return nil, c.Err(err.Error())
+				}
+				t.keys[k.Name] = k
+			case "secrets":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				fname := c.Val()
+				if !filepath.IsAbs(fname) && config.Root != "" {
+					fname = filepath.Join(config.Root, fname)
+				}
+				f, err := os.Open(fname)
+				if err != nil {
+					return nil, err
+				}
+				keys, err := parseKeyFile(f)
+				f.Close()
+				if err != nil {
+					return nil, c.Errf("failed to parse %s: %s", fname, err)
+				}
+				for _, k := range keys {
+					t.keys[k.Name] = k
+				}
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "require":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					a = strings.ToUpper(a)
+					switch a {
+					case "ALL":
+						t.all = true
+						continue
+					case "NONE":
+						t.all = false
+						t.qtypes = make(map[uint16]bool)
+						t.opcodes = make(map[int]bool)
+						continue
+					}
+					if op, ok := dns.StringToOpcode[a]; ok && op != dns.OpcodeQuery {
+						t.opcodes[op] = true
+						continue
+					}
+					qt, ok := dns.StringToType[a]
+					if !ok {
+						return nil, c.Errf("unknown query type or opcode: %s", a)
+					}
+					t.qtypes[qt] = true
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(t.keys) == 0 {
+		return nil, c.Err("no TSIG keys configured")
+	}
+	return t, nil
+}
@@ -0,0 +1,95 @@
+package tsig
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestParse(t *testing.T) {
+	f, err := ioutil.TempFile("", "tsig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`key "file.key." { algorithm hmac-sha512; secret "c2VjcmV0"; };`)
+	f.Close()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		keys      []string
+		qtypes    []uint16
+		opcodes   []int
+		all       bool
+	}{
+		{"tsig {\n secret key. c2VjcmV0\n}", false, []string{"key."}, nil, nil, false},
+		{"tsig {\n secret key. c2VjcmV0 hmac-sha1\n secrets " + f.Name() + "\n}", false, []string{"key.", "file.key."}, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0\n require AXFR ixfr notify UPDATE\n}", false, []string{"key."},
+			[]uint16{dns.TypeAXFR, dns.TypeIXFR}, []int{dns.OpcodeNotify, dns.OpcodeUpdate}, false},
+		{"tsig {\n secret key c2VjcmV0\n require all\n}", false, []string{"key."}, nil, nil, true},
+		{"tsig {\n secret key c2VjcmV0\n require AXFR\n require none\n}", false, []string{"key."}, nil, nil, false},
+		// errors
+		{"tsig", true, nil, nil, nil, false},
+		{"tsig {\n secret key\n}", true, nil, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0 hmac-foo\n}", true, nil, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0\n require BLA\n}", true, nil, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0\n require\n}", true, nil, nil, nil, false},
+		{"tsig {\n secrets /does/not/exist\n}", true, nil, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0\n blah\n}", true, nil, nil, nil, false},
+		{"tsig {\n secret key c2VjcmV0\n}\ntsig {\n secret key c2VjcmV0\n}", true, nil, nil, nil, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		ts, err := parse(c)
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: expected error, got none", i)
+		}
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if tc.shouldErr {
+			continue
+		}
+		if len(ts.keys) != len(tc.keys) {
+			t.Errorf("Test %d: expected %d keys, got %d", i, len(tc.keys), len(ts.keys))
+		}
+		for _, k := range tc.keys {
+			if _, ok := ts.Key(k); !ok {
+				t.Errorf("Test %d: expected key %s", i, k)
+			}
+		}
+		if len(ts.qtypes) != len(tc.qtypes) || len(ts.opcodes) != len(tc.opcodes) {
+			t.Errorf("Test %d: expected %d qtypes and %d opcodes, got %d and %d", i, len(tc.qtypes), len(tc.opcodes), len(ts.qtypes), len(ts.opcodes))
+		}
+		for _, qt := range tc.qtypes {
+			if !ts.qtypes[qt] {
+				t.Errorf("Test %d: expected %s to be required", i, dns.TypeToString[qt])
+			}
+		}
+		for _, op := range tc.opcodes {
+			if !ts.opcodes[op] {
+				t.Errorf("Test %d: expected %s to be required", i, dns.OpcodeToString[op])
+			}
+		}
+		if ts.all != tc.all {
+			t.Errorf("Test %d: expected all to be %t", i, tc.all)
+		}
+	}
+}
+
+func TestSetupSecrets(t *testing.T) {
+	c := caddy.NewTestController("dns", "tsig {\n secret Key. c2VjcmV0\n}")
+	if err := setup(c); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if s := dnsserver.GetConfig(c).TsigSecret["key."]; s != "c2VjcmV0" {
+		t.Errorf("Expected secret for key. in server config, got %q", s)
+	}
+}
@@ -0,0 +1,105 @@
+// Package tsig implements a plugin that verifies TSIG signed requests, requires TSIG for certain
+// requests and signs the responses to signed requests.
+package tsig
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("tsig")
+
+// TSIGServer verifies TSIG signed requests for Zones and signs the responses to them.
+type TSIGServer struct {
+	Zones []string
+	Next  plugin.Handler
+
+	keys    map[string]Key
+	all     bool            // require TSIG for all requests
+	qtypes  map[uint16]bool // require TSIG for these query types
+	opcodes map[int]bool    // require TSIG for these opcodes
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (t *TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if z := plugin.Zones(t.Zones).Matches(state.Name()); z == "" {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	tsigRR := r.IsTsig()
+	if tsigRR == nil {
+		if !t.required(r) {
+			return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+		}
+		log.Debugf("Refusing unsigned %s request for %s from %s", requestType(r), state.Name(), state.IP())
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	// The server has verified the TSIG before the request got here, see core/dnsserver.
+	if err := w.TsigStatus(); err != nil {
+		log.Warningf("Refusing %s request for %s from %s, TSIG key %s: %s", requestType(r), state.Name(), state.IP(), tsigRR.Hdr.Name, err)
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNotAuth)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	return plugin.NextOrFailure(t.Name(), t.Next, ctx, &restoreTsigWriter{ResponseWriter: w, req: r}, r)
+}
+
+// required returns true if r must be signed.
+func (t *TSIGServer) required(r *dns.Msg) bool {
+	if t.all {
+		return true
+	}
+	if t.opcodes[r.Opcode] {
+		return true
+	}
+	if r.Opcode != dns.OpcodeQuery || len(r.Question) == 0 {
+		return false
+	}
+	return t.qtypes[r.Question[0].Qtype]
+}
+
+// Key returns the key with name. Other plugins use this to sign their outgoing messages.
+func (t *TSIGServer) Key(name string) (Key, bool) {
+	k, ok := t.keys[dns.Fqdn(strings.ToLower(name))]
+	return k, ok
+}
+
+// Name implements the plugin.Handler interface.
+func (t *TSIGServer) Name() string { return "tsig" }
+
+// restoreTsigWriter adds a TSIG record to the response, so the server signs it with the key used
+// in the request.
+type restoreTsigWriter struct {
+	dns.ResponseWriter
+	req *dns.Msg
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *restoreTsigWriter) WriteMsg(m *dns.Msg) error {
+	if m.IsTsig() == nil {
+		t := r.req.IsTsig()
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+	return r.ResponseWriter.WriteMsg(m)
+}
+
+func requestType(r *dns.Msg) string {
+	if r.Opcode != dns.OpcodeQuery || len(r.Question) == 0 {
+		return dns.OpcodeToString[r.Opcode]
+	}
+	return dns.TypeToString[r.Question[0].Qtype]
+}
@@ -0,0 +1,102 @@
+package tsig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// statusWriter is a test.ResponseWriter with a TSIG status.
+type statusWriter struct {
+	test.ResponseWriter
+	status error
+}
+
+func (s *statusWriter) TsigStatus() error { return s.status }
+
+// reply is a next handler that writes a reply.
+var reply = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+})
+
+func TestServeDNS(t *testing.T) {
+	ts := &TSIGServer{
+		Zones:   []string{"example.org."},
+		Next:    reply,
+		keys:    map[string]Key{"key.": {Name: "key.", Algorithm: dns.HmacSHA256, Secret: "c2VjcmV0"}},
+		qtypes:  map[uint16]bool{dns.TypeAXFR: true},
+		opcodes: map[int]bool{dns.OpcodeUpdate: true},
+	}
+
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		opcode   int
+		signed   bool
+		status   error
+		rcode    int
+		wantTsig bool
+	}{
+		{"example.org.", dns.TypeA, dns.OpcodeQuery, false, nil, dns.RcodeSuccess, false},
+		{"example.org.", dns.TypeAXFR, dns.OpcodeQuery, false, nil, dns.RcodeRefused, false},
+		{"example.org.", dns.TypeSOA, dns.OpcodeUpdate, false, nil, dns.RcodeRefused, false},
+		{"example.org.", dns.TypeAXFR, dns.OpcodeQuery, true, nil, dns.RcodeSuccess, true},
+		{"example.org.", dns.TypeA, dns.OpcodeQuery, true, nil, dns.RcodeSuccess, true},
+		{"example.org.", dns.TypeA, dns.OpcodeQuery, true, dns.ErrSig, dns.RcodeNotAuth, false},
+		{"example.org.", dns.TypeAXFR, dns.OpcodeQuery, true, dns.ErrSecret, dns.RcodeNotAuth, false},
+		// other zone, not our business
+		{"example.net.", dns.TypeAXFR, dns.OpcodeQuery, false, nil, dns.RcodeSuccess, false},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.Opcode = tc.opcode
+		if tc.signed {
+			m.SetTsig("key.", dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		w := &statusWriter{status: tc.status}
+		rec := dnstest.NewRecorder(w)
+
+		ts.ServeDNS(context.TODO(), rec, m)
+		if rec.Msg == nil {
+			t.Fatalf("Test %d: no response written", i)
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if (rec.Msg.IsTsig() != nil) != tc.wantTsig {
+			t.Errorf("Test %d: expected TSIG in response to be %t", i, tc.wantTsig)
+		}
+	}
+}
+
+func TestRequireAll(t *testing.T) {
+	ts := &TSIGServer{Zones: []string{"."}, Next: reply, all: true}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	ts.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED for unsigned request, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
+
+func TestKey(t *testing.T) {
+	ts := &TSIGServer{keys: map[string]Key{"key.example.org.": {Name: "key.example.org."}}}
+	for _, name := range []string{"key.example.org.", "key.example.org", "Key.Example.ORG."} {
+		if _, ok := ts.Key(name); !ok {
+			t.Errorf("Expected key for %s", name)
+		}
+	}
+}
@@ -119,7 +119,9 @@ func TestAutoAXFR(t *testing.T) {
 		auto {
 			directory ` + tmpdir + ` db\.(.*) {1}
 			reload 1s
-			transfer to *
+		}
+		transfer {
+			to *
 		}
 	}
 `
@@ -129,9 +131,9 @@ func TestAutoAXFR(t *testing.T) {
 		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
 	}
 
-	udp, _ := CoreDNSServerPorts(i, 0)
-	if udp == "" {
-		t.Fatal("Could not get UDP listening port")
+	_, tcp := CoreDNSServerPorts(i, 0)
+	if tcp == "" {
+		t.Fatal("Could not get TCP listening port")
 	}
 	defer i.Stop()
 
@@ -144,7 +146,9 @@ func TestAutoAXFR(t *testing.T) {
 
 	m := new(dns.Msg)
 	m.SetAxfr("example.org.")
-	resp, err := dns.Exchange(m, udp)
+	c := new(dns.Client)
+	c.Net = "tcp"
+	resp, _, err := c.Exchange(m, tcp)
 	if err != nil {
 		t.Fatal("Expected to receive reply, but didn't")
 	}
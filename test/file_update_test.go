@@ -0,0 +1,108 @@
+package test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestFileUpdate(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		tsig {
+			secret update.key. ` + tsigSecret + `
+			require UPDATE
+		}
+		file ` + name + ` {
+			update 127.0.0.1 ::1
+			update_key update.key.
+		}
+	}
+`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("host.example.org. 300 IN A 10.0.0.1")})
+
+	// Unsigned is refused.
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected REFUSED for unsigned update, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	c := &dns.Client{TsigSecret: map[string]string{"update.key.": tsigSecret}}
+	m.SetTsig("update.key.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, err = c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive signed reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR for signed update, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	m = new(dns.Msg)
+	m.SetQuestion("host.example.org.", dns.TypeA)
+	r, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(r.Answer) != 1 || r.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Fatalf("Expected the updated record, got %v", r.Answer)
+	}
+}
+
+func TestUpdateNotForwarded(t *testing.T) {
+	var updates int32
+	upstream := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Opcode == dns.OpcodeUpdate {
+			atomic.AddInt32(&updates, 1)
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	defer upstream.Close()
+
+	corefile := `example.org:0 {
+		forward . ` + upstream.Addr + `
+	}
+`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("host.example.org. 300 IN A 10.0.0.1")})
+
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeNotImplemented {
+		t.Errorf("Expected NOTIMP for update of a forwarded zone, got %s", dns.RcodeToString[r.Rcode])
+	}
+	if n := atomic.LoadInt32(&updates); n != 0 {
+		t.Errorf("Expected the update not to be forwarded, upstream got %d", n)
+	}
+}
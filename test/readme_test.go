@@ -20,9 +20,12 @@ var contents = map[string]string{
 	"Kexample.org.+013+45330.key":     examplePub,
 	"Kexample.org.+013+45330.private": examplePriv,
 	"example.org.signed":              exampleOrg, // not signed, but does not matter for this test.
+	"forward.routes":                  forwardRoutes,
 }
 
 const (
+	forwardRoutes = `corp intranet.example.com
+`
 	examplePub = `example.org. IN DNSKEY 256 3 13 eNMYFZYb6e0oJOV47IPo5f/UHy7wY9aBebotvcKakIYLyyGscBmXJQhbKLt/LhrMNDE2Q96hQnI5PdTBeOLzhQ==
 `
 	examplePriv = `Private-key-format: v1.3
@@ -42,8 +42,9 @@ func TestSecondaryZoneTransfer(t *testing.T) {
 	defer rm()
 
 	corefile := `example.org:0 {
-       file ` + name + ` {
-	       transfer to *
+       file ` + name + `
+       transfer {
+	       to *
        }
 }
 `
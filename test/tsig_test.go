@@ -0,0 +1,186 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const tsigSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+func TestTsigTransfer(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		file ` + name + `
+		tsig {
+			secret transfer.key. ` + tsigSecret + `
+			require AXFR IXFR
+		}
+		transfer {
+			to *
+		}
+	}
+`
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	// Unsigned transfer is refused.
+	m := new(dns.Msg)
+	m.SetAxfr("example.org.")
+	tr := new(dns.Transfer)
+	c, err := tr.In(m, tcp)
+	if err != nil {
+		t.Fatalf("Failed to set up AXFR: %s", err)
+	}
+	if env := <-c; env.Error == nil {
+		t.Fatalf("Expected unsigned AXFR to fail")
+	}
+
+	// Signed transfer works and the responses are signed.
+	m = new(dns.Msg)
+	m.SetAxfr("example.org.")
+	m.SetTsig("transfer.key.", dns.HmacSHA256, 300, time.Now().Unix())
+	tr = &dns.Transfer{TsigSecret: map[string]string{"transfer.key.": tsigSecret}}
+	c, err = tr.In(m, tcp)
+	if err != nil {
+		t.Fatalf("Failed to set up signed AXFR: %s", err)
+	}
+	n := 0
+	for env := range c {
+		if env.Error != nil {
+			t.Fatalf("Failed signed AXFR: %s", env.Error)
+		}
+		n += len(env.RR)
+	}
+	if n == 0 {
+		t.Fatalf("Expected records in signed AXFR")
+	}
+
+	// A secondary with the key can transfer the zone.
+	corefile = `example.org:0 {
+		tsig {
+			secret transfer.key. ` + tsigSecret + `
+		}
+		secondary {
+			transfer from ` + tcp + `
+			tsig transfer.key.
+		}
+	}
+`
+	i1, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i1.Stop()
+
+	m = new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeSOA)
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(r.Answer) == 0 {
+		t.Fatalf("Expected answer section")
+	}
+}
+
+func TestTsigQuery(t *testing.T) {
+	corefile := `example.org:0 {
+		tsig {
+			secret query.key. ` + tsigSecret + `
+			require all
+		}
+		whoami
+	}
+`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected REFUSED for unsigned query, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	c := &dns.Client{TsigSecret: map[string]string{"query.key.": tsigSecret}}
+	m.SetTsig("query.key.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, err = c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive signed reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess || r.IsTsig() == nil {
+		t.Fatalf("Expected signed NOERROR reply, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	// Wrong key.
+	c = &dns.Client{TsigSecret: map[string]string{"query.key.": "d3Jvbmc="}}
+	m = new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetTsig("query.key.", dns.HmacSHA256, 300, time.Now().Unix())
+	r, _, _ = c.Exchange(m, udp)
+	if r == nil || r.Rcode != dns.RcodeNotAuth {
+		t.Fatalf("Expected NOTAUTH for badly signed query, got %v", r)
+	}
+}
+
+func TestTsigForward(t *testing.T) {
+	corefile := `example.org:0 {
+		tsig {
+			secret forward.key. ` + tsigSecret + `
+			require all
+		}
+		whoami
+	}
+`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	corefile = `example.org:0 {
+		tsig {
+			secret forward.key. ` + tsigSecret + `
+		}
+		forward . ` + udp + ` {
+			tsig forward.key.
+		}
+	}
+`
+	i1, udp1, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i1.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	r, err := dns.Exchange(m, udp1)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR from signed forward, got %s", dns.RcodeToString[r.Rcode])
+	}
+	if r.IsTsig() != nil {
+		t.Fatalf("Expected no TSIG in reply to unsigned query")
+	}
+}